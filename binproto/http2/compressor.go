@@ -0,0 +1,144 @@
+package http2
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const Identity = "identity"
+
+type (
+	// Compressor compresses gRPC messages for the grpc-encoding of its Name.
+	Compressor interface {
+		Name() string
+		Compress(data []byte) ([]byte, error)
+		// Decompress returns a reader of the data decompressed from r, so that callers may bound what they read.
+		Decompress(r io.Reader) (io.Reader, error)
+	}
+
+	gzipCompressor struct {
+		writers sync.Pool
+	}
+)
+
+var (
+	compressorsMu sync.RWMutex
+	compressors   = map[string]Compressor{}
+)
+
+func init() {
+	RegisterCompressor(&gzipCompressor{})
+}
+
+// RegisterCompressor makes c available to encode and decode messages of the grpc-encoding c.Name(),
+// replacing a compressor of the same name. gzip is registered by default.
+func RegisterCompressor(c Compressor) {
+	compressorsMu.Lock()
+	defer compressorsMu.Unlock()
+	compressors[c.Name()] = c
+}
+
+// GetCompressor returns the compressor of the grpc-encoding name, or nil if none is registered.
+func GetCompressor(name string) Compressor {
+	compressorsMu.RLock()
+	defer compressorsMu.RUnlock()
+	return compressors[name]
+}
+
+// AcceptEncoding returns the grpc-accept-encoding header of the registered compressors.
+func AcceptEncoding() string {
+	compressorsMu.RLock()
+	names := make([]string, 0, len(compressors))
+	for name := range compressors {
+		names = append(names, name)
+	}
+	compressorsMu.RUnlock()
+	sort.Strings(names)
+	return strings.Join(names, ",")
+}
+
+// AcceptsEncoding reports whether the grpc-accept-encoding header acceptEncoding lists name.
+func AcceptsEncoding(acceptEncoding, name string) bool {
+	for _, v := range strings.Split(acceptEncoding, ",") {
+		if strings.TrimSpace(v) == name {
+			return true
+		}
+	}
+	return false
+}
+
+// EncodeGrpcPayloadWithCompressor frames payload as a gRPC message, compressed by c unless c is nil.
+func EncodeGrpcPayloadWithCompressor(payload []byte, c Compressor) ([]byte, error) {
+	if c == nil {
+		return EncodeGrpcPayload(payload), nil
+	}
+	compressed, err := c.Compress(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compress grpc message with %s: %w", c.Name(), err)
+	}
+	frame := EncodeGrpcPayload(compressed)
+	frame[0] = 1
+	return frame, nil
+}
+
+// Decompress decodes a message of the grpc-encoding of a response, whose compressed flag is set.
+func Decompress(data []byte, encoding string) ([]byte, error) {
+	r, err := DecompressReader(bytes.NewReader(data), encoding)
+	if err != nil {
+		return nil, err
+	}
+	decompressed, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress %s data: %w", encoding, err)
+	}
+	return decompressed, nil
+}
+
+// DecompressReader returns a reader of the message read from r decoded by the grpc-encoding of a response,
+// which the caller may read up to a size limit.
+func DecompressReader(r io.Reader, encoding string) (io.Reader, error) {
+	if encoding == "" || encoding == Identity {
+		return nil, fmt.Errorf("compressed grpc message without grpc-encoding")
+	}
+	c := GetCompressor(encoding)
+	if c == nil {
+		return nil, fmt.Errorf("unsupported grpc-encoding: %s", encoding)
+	}
+	return c.Decompress(r)
+}
+
+func (c *gzipCompressor) Name() string {
+	return "gzip"
+}
+
+func (c *gzipCompressor) Compress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w, ok := c.writers.Get().(*gzip.Writer)
+	if ok {
+		w.Reset(&buf)
+	} else {
+		w = gzip.NewWriter(&buf)
+	}
+	defer c.writers.Put(w)
+
+	if _, err := w.Write(data); err != nil {
+		return nil, fmt.Errorf("failed to write gzip data: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return nil, fmt.Errorf("failed to close gzip writer: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func (c *gzipCompressor) Decompress(r io.Reader) (io.Reader, error) {
+	reader, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create gzip reader: %w", err)
+	}
+	return reader, nil
+}
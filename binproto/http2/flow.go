@@ -0,0 +1,202 @@
+package http2
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+const (
+	// DefaultWindowSize is the initial flow-control window of connections and streams (RFC 7540 Section 6.9.2).
+	DefaultWindowSize = 65535
+	// DefaultMaxFrameSize is the initial value of SETTINGS_MAX_FRAME_SIZE.
+	DefaultMaxFrameSize = 16384
+
+	maxWindowSize = 1<<31 - 1
+)
+
+var (
+	ErrFlowClosed         = errors.New("flow control closed")
+	ErrFlowStreamNotFound = errors.New("flow control stream not found")
+)
+
+// OutFlow is the send window of a connection and its streams. Writers of DATA frames call Take before writing,
+// which blocks until the peer has granted window on both the connection and the stream.
+//
+// The zero value is not usable, use NewOutFlow instead.
+type OutFlow struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	conn    int64
+	initial int64
+	streams map[uint32]int64
+	closed  bool
+}
+
+// NewOutFlow creates an OutFlow with the default window sizes.
+func NewOutFlow() *OutFlow {
+	f := &OutFlow{
+		conn:    DefaultWindowSize,
+		initial: DefaultWindowSize,
+		streams: make(map[uint32]int64),
+	}
+	f.cond = sync.NewCond(&f.mu)
+	return f
+}
+
+// AddStream opens the send window of streamId with the peer's SETTINGS_INITIAL_WINDOW_SIZE.
+func (f *OutFlow) AddStream(streamId uint32) {
+	f.mu.Lock()
+	f.streams[streamId] = f.initial
+	f.mu.Unlock()
+}
+
+// RemoveStream drops the send window of streamId, writers waiting on it fail with ErrFlowStreamNotFound.
+func (f *OutFlow) RemoveStream(streamId uint32) {
+	f.mu.Lock()
+	delete(f.streams, streamId)
+	f.mu.Unlock()
+	f.cond.Broadcast()
+}
+
+// Update applies a WINDOW_UPDATE frame, streamId 0 means the connection.
+func (f *OutFlow) Update(streamId, increment uint32) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if streamId == 0 {
+		if f.conn+int64(increment) > maxWindowSize {
+			return fmt.Errorf("connection flow control window overflow")
+		}
+		f.conn += int64(increment)
+	} else {
+		window, ok := f.streams[streamId]
+		if !ok {
+			return nil // the stream is already closed
+		}
+		if window+int64(increment) > maxWindowSize {
+			return fmt.Errorf("stream %d flow control window overflow", streamId)
+		}
+		f.streams[streamId] = window + int64(increment)
+	}
+	f.cond.Broadcast()
+	return nil
+}
+
+// SetInitialWindowSize applies SETTINGS_INITIAL_WINDOW_SIZE of the peer, the windows of all open streams are
+// adjusted by the difference (RFC 7540 Section 6.9.2).
+func (f *OutFlow) SetInitialWindowSize(size uint32) error {
+	if size > maxWindowSize {
+		return fmt.Errorf("invalid initial window size: %d", size)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	delta := int64(size) - f.initial
+	f.initial = int64(size)
+	for streamId, window := range f.streams {
+		if window+delta > maxWindowSize {
+			return fmt.Errorf("stream %d flow control window overflow", streamId)
+		}
+		f.streams[streamId] = window + delta
+	}
+	f.cond.Broadcast()
+	return nil
+}
+
+// Take waits until both the connection and streamId have window available, and takes up to n bytes of it.
+// It returns 0 without waiting if n is 0.
+func (f *OutFlow) Take(ctx context.Context, streamId uint32, n int) (int, error) {
+	if n <= 0 {
+		return 0, nil
+	}
+
+	stop := context.AfterFunc(ctx, func() {
+		f.mu.Lock()
+		f.cond.Broadcast()
+		f.mu.Unlock()
+	})
+	defer stop()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for {
+		if f.closed {
+			return 0, ErrFlowClosed
+		}
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+		window, ok := f.streams[streamId]
+		if !ok {
+			return 0, ErrFlowStreamNotFound
+		}
+		if available := min(f.conn, window); available > 0 {
+			taken := int(min(available, int64(n)))
+			f.conn -= int64(taken)
+			f.streams[streamId] = window - int64(taken)
+			return taken, nil
+		}
+		f.cond.Wait()
+	}
+}
+
+// Close wakes up all waiting writers, which fail with ErrFlowClosed.
+func (f *OutFlow) Close() {
+	f.mu.Lock()
+	f.closed = true
+	f.mu.Unlock()
+	f.cond.Broadcast()
+}
+
+// InFlow is the receive window of a connection or a stream. It tells how many bytes to grant back to the peer
+// in WINDOW_UPDATE frames, once at least half of the window has been consumed.
+type InFlow struct {
+	mu      sync.Mutex
+	size    int32
+	avail   int32
+	unacked int32
+}
+
+// NewInFlow creates an InFlow with the given window size.
+func NewInFlow(size int32) *InFlow {
+	return &InFlow{
+		size:  size,
+		avail: size,
+	}
+}
+
+// Take accounts n bytes of received DATA, including padding. It fails if the peer has exceeded the window.
+func (f *InFlow) Take(n uint32) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if int64(n) > int64(f.avail) {
+		return fmt.Errorf("flow control window exceeded, received: %d, available: %d", n, f.avail)
+	}
+	f.avail -= int32(n)
+	return nil
+}
+
+// Add returns n consumed bytes to the window. It returns the increment to send in a WINDOW_UPDATE frame,
+// or 0 if there is no need to send one yet.
+func (f *InFlow) Add(n uint32) uint32 {
+	if n == 0 {
+		return 0
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.unacked += int32(n)
+	if f.unacked < f.size/2 {
+		return 0
+	}
+	increment := f.unacked
+	f.avail += increment
+	f.unacked = 0
+	return uint32(increment)
+}
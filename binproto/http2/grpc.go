@@ -2,10 +2,8 @@ package http2
 
 import (
 	"bytes"
-	"compress/gzip"
 	"encoding/binary"
 	"fmt"
-	"io"
 	"log"
 
 	"google.golang.org/protobuf/proto"
@@ -51,43 +49,33 @@ func EncodeGrpcFrame(message proto.Message) ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
+// DecodeGrpcFrame decodes the gRPC message in data into message. A compressed message is taken as gzip,
+// the only built-in grpc-encoding, use DecodeGrpcFrameWithDecompress with the grpc-encoding of the response
+// for others.
 func DecodeGrpcFrame(data []byte, message proto.Message) error {
-	if len(data) < 5 {
-		return fmt.Errorf("invalid grpc frame data")
-	}
-	compressedFlag := data[0]
-	messageLength := binary.BigEndian.Uint32(data[1:5])
-
-	messageData := data[5 : 5+messageLength]
-
-	if compressedFlag != 0 {
-		return fmt.Errorf("compressed responses are not supported in this client")
-	}
-
-	err := proto.Unmarshal(messageData, message)
-	if err != nil {
-		log.Printf("Failed to unmarshal response: %v", err)
-		return err
-	}
-	return nil
+	return DecodeGrpcFrameWithDecompress(data, "gzip", message)
 }
 
+// DecodeGrpcFrameWithDecompress decodes the gRPC message in data into message, decompressing it by
+// compressionAlgo, the grpc-encoding of the response, if its compressed flag is set.
 func DecodeGrpcFrameWithDecompress(data []byte, compressionAlgo string, message proto.Message) error {
 	if len(data) < 5 {
 		return fmt.Errorf("invalid grpc frame data")
 	}
 	compressedFlag := data[0]
 	messageLength := binary.BigEndian.Uint32(data[1:5])
+	if uint64(len(data)-5) < uint64(messageLength) {
+		return fmt.Errorf("truncated grpc frame data, expected %d bytes, got %d", messageLength, len(data)-5)
+	}
 
 	messageData := data[5 : 5+messageLength]
 
 	if compressedFlag != 0 {
-		decompressedData, err := decompress(messageData, compressionAlgo)
+		decompressedData, err := Decompress(messageData, compressionAlgo)
 		if err != nil {
 			return fmt.Errorf("failed to decompress grpc message: %w", err)
 		}
 		messageData = decompressedData
-		return fmt.Errorf("compressed responses are not supported in this client")
 	}
 
 	err := proto.Unmarshal(messageData, message)
@@ -97,40 +85,3 @@ func DecodeGrpcFrameWithDecompress(data []byte, compressionAlgo string, message
 	}
 	return nil
 }
-
-func compress(data []byte, algo string) ([]byte, error) {
-	switch algo {
-	case "gzip":
-		var buf bytes.Buffer
-		writer := gzip.NewWriter(&buf)
-		_, err := writer.Write(data)
-		if err != nil {
-			return nil, fmt.Errorf("failed to write gzip data: %w", err)
-		}
-		if err := writer.Close(); err != nil {
-			return nil, fmt.Errorf("failed to close gzip writer: %w", err)
-		}
-		return buf.Bytes(), nil
-	default:
-		return nil, fmt.Errorf("unsupported compression algorithm: %s", algo)
-	}
-}
-
-func decompress(data []byte, algo string) ([]byte, error) {
-	switch algo {
-	case "gzip":
-		reader, err := gzip.NewReader(bytes.NewReader(data))
-		if err != nil {
-			return nil, fmt.Errorf("failed to create gzip reader: %w", err)
-		}
-		defer reader.Close()
-
-		decompressedData, err := io.ReadAll(reader)
-		if err != nil {
-			return nil, fmt.Errorf("failed to read gzip data: %w", err)
-		}
-		return decompressedData, nil
-	default:
-		return nil, fmt.Errorf("unsupported compression algorithm: %s", algo)
-	}
-}
@@ -2,6 +2,7 @@ package distro
 
 import (
 	"fmt"
+	"github.com/pysugar/wheels/features/stats"
 	"github.com/pysugar/wheels/http/extensions"
 	"github.com/spf13/cobra"
 	"log"
@@ -15,10 +16,16 @@ var devtoolCmd = &cobra.Command{
 Start a DevTool for HTTP.
 
 Start a DevTool for HTTP: netool devtool --port=8080 --verbose
+Expose traffic counters for Prometheus at /metrics: netool devtool --port=8080 --metrics --metrics-reset
 `,
 	Run: func(cmd *cobra.Command, args []string) {
 		port, _ := cmd.Flags().GetInt("port")
 		verbose, _ := cmd.Flags().GetBool("verbose")
+		metrics, _ := cmd.Flags().GetBool("metrics")
+		metricsReset, _ := cmd.Flags().GetBool("metrics-reset")
+		if metrics {
+			registerMetricsHandler(metricsReset)
+		}
 		RunDevtoolHTTPServer(port, verbose)
 	},
 }
@@ -26,6 +33,17 @@ Start a DevTool for HTTP: netool devtool --port=8080 --verbose
 func init() {
 	devtoolCmd.Flags().IntP("port", "p", 8080, "http proxy	 port")
 	devtoolCmd.Flags().BoolP("verbose", "V", false, "Verbose mode")
+	devtoolCmd.Flags().Bool("metrics", false, "Expose traffic counters in Prometheus format at /metrics")
+	devtoolCmd.Flags().Bool("metrics-reset", false, "Reset counters after each scrape of /metrics")
+}
+
+var devtoolStats = stats.NewManager()
+
+func registerMetricsHandler(resetOnRead bool) {
+	http.Handle("/metrics", &stats.PrometheusHandler{
+		Manager:     devtoolStats,
+		ResetOnRead: resetOnRead,
+	})
 }
 
 func RunDevtoolHTTPServer(port int, verbose bool) {
@@ -35,6 +53,10 @@ func RunDevtoolHTTPServer(port int, verbose bool) {
 		debugHandler = extensions.LoggingMiddleware(debugHandler)
 		debugHandlerJSON = extensions.LoggingMiddleware(debugHandlerJSON)
 	}
+	uplink, _ := stats.GetOrRegisterCounter(devtoolStats, "inbound>>>devtool>>>traffic>>>uplink")
+	downlink, _ := stats.GetOrRegisterCounter(devtoolStats, "inbound>>>devtool>>>traffic>>>downlink")
+	debugHandler = extensions.StatsMiddleware(debugHandler, uplink, downlink)
+	debugHandlerJSON = extensions.StatsMiddleware(debugHandlerJSON, uplink, downlink)
 	http.Handle("/", extensions.CORSMiddleware(debugHandler))
 	http.Handle("/json", extensions.CORSMiddleware(debugHandlerJSON))
 
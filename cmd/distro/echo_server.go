@@ -2,12 +2,21 @@ package distro
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
 	"log"
+	"log/slog"
+	"net/http"
+	"os"
+	"syscall"
+	"time"
 
+	"github.com/pysugar/wheels/grpc/grpcweb"
+	"github.com/pysugar/wheels/grpc/interceptors"
 	pb "github.com/pysugar/wheels/grpc/proto"
 	"github.com/pysugar/wheels/grpc/server"
 	"github.com/spf13/cobra"
-	"google.golang.org/grpc"
 )
 
 type serverImp struct {
@@ -26,20 +35,101 @@ var echoServiceCmd = &cobra.Command{
 Start a gRPC echo service.
 
 Start a gRPC echo service: netool echoservice --port=8080
+Start on a unix socket:    netool echoservice --unix=/tmp/echo.sock
+Start with mutual TLS:     netool echoservice --cert=server.pem --key=server-key.pem --client-ca=ca.pem
+Serve gRPC-Web as well:    netool echoservice --port=8080 --web-port=8081 --web-origins=https://app.example
 `,
 	Run: func(cmd *cobra.Command, args []string) {
 		port, _ := cmd.Flags().GetInt("port")
-		//verbose, _ := cmd.Flags().GetBool("verbose")
-		err := server.StartGrpcServer(port, "echoservice", func(s *grpc.Server) {
-			pb.RegisterEchoServiceServer(s, &serverImp{})
-		})
+		unixSocket, _ := cmd.Flags().GetString("unix")
+		certFile, _ := cmd.Flags().GetString("cert")
+		keyFile, _ := cmd.Flags().GetString("key")
+		clientCAFile, _ := cmd.Flags().GetString("client-ca")
+		webPort, _ := cmd.Flags().GetInt("web-port")
+		webOrigins, _ := cmd.Flags().GetStringSlice("web-origins")
+		verbose, _ := cmd.Flags().GetBool("verbose")
+
+		opts := []server.Option{
+			server.WithAddress(fmt.Sprintf(":%d", port)),
+			server.WithHealthServices("echoservice"),
+			server.WithStopSignals(os.Interrupt, syscall.SIGTERM),
+		}
+		if unixSocket != "" {
+			opts = append(opts, server.WithUnixSocket(unixSocket))
+		}
+		// gRPC-Web is served with the same certificate and client authentication as native gRPC
+		var tlsConfig *tls.Config
+		if certFile != "" {
+			var err error
+			if tlsConfig, err = server.LoadTLSConfig(certFile, keyFile, clientCAFile); err != nil {
+				log.Fatal(err.Error())
+			}
+			opts = append(opts, server.WithTLSConfig(tlsConfig))
+		}
+		logLevel := slog.LevelInfo
+		if verbose {
+			logLevel = slog.LevelDebug
+		}
+		logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: logLevel}))
+		opts = append(opts, server.WithUnaryInterceptors(
+			interceptors.RequestIDUnaryServerInterceptor,
+			interceptors.SlogUnaryServerInterceptor(logger),
+			interceptors.RecoveryUnaryServerInterceptor,
+		), server.WithStreamInterceptors(
+			interceptors.RequestIDStreamServerInterceptor,
+			interceptors.SlogStreamServerInterceptor(logger),
+			interceptors.RecoveryStreamServerInterceptor,
+		))
+
+		s, err := server.New(opts...)
 		if err != nil {
 			log.Fatal(err.Error())
 		}
+		pb.RegisterEchoServiceServer(s, &serverImp{})
+		log.Printf("echo service is serving on %s", s.Addr())
+
+		var webServer *http.Server
+		if webPort > 0 {
+			webServer = &http.Server{
+				Addr:    fmt.Sprintf(":%d", webPort),
+				Handler: grpcweb.NewHandler(s.GRPCServer(), grpcweb.WithAllowedOrigins(webOrigins...)),
+			}
+			if tlsConfig != nil {
+				webServer.TLSConfig = tlsConfig.Clone()
+			}
+			go func() {
+				log.Printf("echo service is serving gRPC-Web on %s", webServer.Addr)
+				var err error
+				if webServer.TLSConfig != nil {
+					err = webServer.ListenAndServeTLS("", "")
+				} else {
+					err = webServer.ListenAndServe()
+				}
+				if err != nil && !errors.Is(err, http.ErrServerClosed) {
+					log.Fatal(err.Error())
+				}
+			}()
+		}
+
+		if err = s.Serve(); err != nil {
+			log.Fatal(err.Error())
+		}
+		if webServer != nil {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			_ = webServer.Shutdown(ctx)
+		}
+		log.Printf("echo service stopped")
 	},
 }
 
 func init() {
 	echoServiceCmd.Flags().IntP("port", "p", 8080, "http proxy	 port")
+	echoServiceCmd.Flags().String("unix", "", "Listen on a unix socket instead of the port")
+	echoServiceCmd.Flags().String("cert", "", "Server certificate (PEM) to serve TLS")
+	echoServiceCmd.Flags().String("key", "", "Private key (PEM) of the server certificate")
+	echoServiceCmd.Flags().String("client-ca", "", "CA certificates (PEM) to require client certificates with")
+	echoServiceCmd.Flags().Int("web-port", 0, "Serve gRPC-Web (application/grpc-web and grpc-web-text) on this port as well")
+	echoServiceCmd.Flags().StringSlice("web-origins", nil, "Origins whose pages may call gRPC-Web across origins, * for any without credentials")
 	echoServiceCmd.Flags().BoolP("verbose", "V", false, "Verbose mode")
 }
@@ -2,6 +2,8 @@ package distro
 
 import (
 	"bufio"
+	"crypto/subtle"
+	"encoding/base64"
 	"fmt"
 	"io"
 	"log"
@@ -20,19 +22,23 @@ var httpProxyCmd = &cobra.Command{
 Start a Transparent HTTP Proxy.
 
 Start a Transparent HTTP Proxy: netool httpproxy --port=8080
+Require Basic proxy authentication: netool httpproxy --port=8080 --auth=user:password
 `,
 	Run: func(cmd *cobra.Command, args []string) {
 		port, _ := cmd.Flags().GetInt("port")
+		auth, _ := cmd.Flags().GetString("auth")
 
-		RunHTTPProxy(port)
+		RunHTTPProxy(port, auth)
 	},
 }
 
 func init() {
 	httpProxyCmd.Flags().IntP("port", "p", 8080, "http proxy	 port")
+	httpProxyCmd.Flags().StringP("auth", "a", "", "require Proxy-Authorization with these user:password credentials")
 }
 
-func RunHTTPProxy(port int) {
+// RunHTTPProxy serves the proxy on port, requests must carry the Basic credentials auth unless it is empty.
+func RunHTTPProxy(port int, auth string) {
 	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
 	if err != nil {
 		log.Fatalf("Error starting listener: %v\n", err)
@@ -46,11 +52,11 @@ func RunHTTPProxy(port int) {
 			continue
 		}
 
-		go handleHTTPProxy(clientConn)
+		go handleHTTPProxy(clientConn, auth)
 	}
 }
 
-func handleHTTPProxy(clientConn net.Conn) {
+func handleHTTPProxy(clientConn net.Conn, auth string) {
 	defer clientConn.Close()
 
 	reader := bufio.NewReader(clientConn)
@@ -60,6 +66,14 @@ func handleHTTPProxy(clientConn net.Conn) {
 		return
 	}
 
+	if auth != "" && !proxyAuthorized(request, auth) {
+		log.Printf("Unauthorized proxy request from %s", clientConn.RemoteAddr())
+		fmt.Fprintf(clientConn, "HTTP/1.1 407 Proxy Authentication Required\r\n"+
+			"Proxy-Authenticate: Basic realm=\"netool\"\r\nContent-Length: 0\r\nConnection: close\r\n\r\n")
+		return
+	}
+	request.Header.Del("Proxy-Authorization")
+
 	if request.Method == http.MethodConnect {
 		handleConnectMethod(clientConn, request)
 	} else {
@@ -67,6 +81,15 @@ func handleHTTPProxy(clientConn net.Conn) {
 	}
 }
 
+func proxyAuthorized(request *http.Request, auth string) bool {
+	credentials, ok := strings.CutPrefix(request.Header.Get("Proxy-Authorization"), "Basic ")
+	if !ok {
+		return false
+	}
+	decoded, err := base64.StdEncoding.DecodeString(credentials)
+	return err == nil && subtle.ConstantTimeCompare(decoded, []byte(auth)) == 1
+}
+
 func handleConnectMethod(clientConn net.Conn, request *http.Request) {
 	targetHost := request.Host
 	if !strings.Contains(targetHost, ":") {
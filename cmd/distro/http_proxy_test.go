@@ -0,0 +1,79 @@
+package distro
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pysugar/wheels/http/client"
+)
+
+func startHTTPProxy(t *testing.T, auth string) *url.URL {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { lis.Close() })
+	go func() {
+		for {
+			conn, er := lis.Accept()
+			if er != nil {
+				return
+			}
+			go handleHTTPProxy(conn, auth)
+		}
+	}()
+	return &url.URL{Scheme: "http", Host: lis.Addr().String()}
+}
+
+func TestHTTPProxyConnect(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "hello "+r.Header.Get("Proxy-Authorization"))
+	}))
+	defer server.Close()
+
+	proxyURL := startHTTPProxy(t, "user:pass")
+	tests := []struct {
+		name    string
+		user    *url.Userinfo
+		want    string
+		wantErr string
+	}{
+		{name: "authorized", user: url.UserPassword("user", "pass"), want: "hello "},
+		{name: "wrong password", user: url.UserPassword("user", "wrong"), wantErr: "407"},
+		{name: "no credentials", wantErr: "407"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u := *proxyURL
+			u.User = tt.user
+			f := client.NewFetcher(client.WithDialOptions(client.WithProxy(&u)))
+			defer f.Close()
+
+			ctx, cancel := context.WithTimeout(client.WithProtocol(context.Background(), client.HTTP1), 5*time.Second)
+			defer cancel()
+			req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+			res, err := f.Do(ctx, req)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error %s, but actually %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+			if body, _ := io.ReadAll(res.Body); string(body) != tt.want {
+				t.Errorf("expected %q, but actually %q", tt.want, body)
+			}
+		})
+	}
+}
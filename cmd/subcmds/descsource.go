@@ -0,0 +1,213 @@
+package subcmds
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/desc/protoparse"
+	"github.com/jhump/protoreflect/desc/protoprint"
+	"github.com/jhump/protoreflect/grpcreflect"
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+type (
+	// descriptorSource resolves the services of a server and the messages they use, either from the server by
+	// reflection or from proto and protoset files.
+	descriptorSource interface {
+		ListServices() ([]string, error)
+		FindSymbol(fullName string) (desc.Descriptor, error)
+	}
+
+	// reflectionSource asks the server through grpc.reflection.v1, or v1alpha if the server lacks v1.
+	reflectionSource struct {
+		client *grpcreflect.Client
+	}
+
+	// fileSource holds the files parsed from proto sources or loaded from protosets, with their dependencies.
+	fileSource struct {
+		files map[string]*desc.FileDescriptor
+	}
+)
+
+// addDescriptorFlags adds the flags of the descriptor files, without which the server is asked by reflection.
+func addDescriptorFlags(cmd *cobra.Command) {
+	cmd.Flags().StringSlice("protoset", nil, "Compiled FileDescriptorSet files, as protoc --descriptor_set_out writes them")
+	cmd.Flags().StringSlice("import-path", nil, "Import paths of the proto files, the current directory by default")
+	cmd.Flags().StringSlice("proto", nil, "Proto files, relative to the import paths")
+}
+
+// newDescriptorSource reads the files of the descriptor flags of cmd, and resorts to reflection through conn
+// without them. extraProtos are proto file paths resolved from their own directory.
+func newDescriptorSource(ctx context.Context, cmd *cobra.Command, conn grpc.ClientConnInterface, extraProtos ...string) (descriptorSource, error) {
+	protosets, _ := cmd.Flags().GetStringSlice("protoset")
+	importPaths, _ := cmd.Flags().GetStringSlice("import-path")
+	protos, _ := cmd.Flags().GetStringSlice("proto")
+	for _, p := range extraProtos {
+		if p != "" {
+			importPaths = append(importPaths, filepath.Dir(p))
+			protos = append(protos, filepath.Base(p))
+		}
+	}
+
+	if len(protosets) == 0 && len(protos) == 0 {
+		if conn == nil {
+			return nil, fmt.Errorf("no descriptor source, neither proto files nor server reflection")
+		}
+		return &reflectionSource{client: grpcreflect.NewClientAuto(ctx, conn)}, nil
+	}
+
+	src := &fileSource{files: make(map[string]*desc.FileDescriptor)}
+	for _, name := range protosets {
+		if err := src.loadProtoset(name); err != nil {
+			return nil, err
+		}
+	}
+	if len(protos) > 0 {
+		parser := protoparse.Parser{ImportPaths: importPaths, IncludeSourceCodeInfo: true}
+		fds, err := parser.ParseFiles(protos...)
+		if err != nil {
+			return nil, fmt.Errorf("resolve proto files failure: %v", err)
+		}
+		for _, fd := range fds {
+			src.add(fd)
+		}
+	}
+	return src, nil
+}
+
+func (s *reflectionSource) ListServices() ([]string, error) {
+	services, err := s.client.ListServices()
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(services)
+	return services, nil
+}
+
+func (s *reflectionSource) FindSymbol(fullName string) (desc.Descriptor, error) {
+	fd, err := s.client.FileContainingSymbol(fullName)
+	if err != nil {
+		return nil, err
+	}
+	if d := fd.FindSymbol(fullName); d != nil {
+		return d, nil
+	}
+	return nil, fmt.Errorf("symbol not found: %s", fullName)
+}
+
+func (s *fileSource) loadProtoset(name string) error {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return fmt.Errorf("read protoset %s failure: %v", name, err)
+	}
+	fdSet := new(descriptorpb.FileDescriptorSet)
+	if err = proto.Unmarshal(data, fdSet); err != nil {
+		return fmt.Errorf("invalid protoset %s, err: %v", name, err)
+	}
+	fds, err := desc.CreateFileDescriptorsFromSet(fdSet)
+	if err != nil {
+		return fmt.Errorf("invalid protoset %s, err: %v", name, err)
+	}
+	for _, fd := range fds {
+		s.add(fd)
+	}
+	return nil
+}
+
+func (s *fileSource) add(fd *desc.FileDescriptor) {
+	if _, ok := s.files[fd.GetName()]; ok {
+		return
+	}
+	s.files[fd.GetName()] = fd
+	for _, dep := range fd.GetDependencies() {
+		s.add(dep)
+	}
+}
+
+func (s *fileSource) ListServices() ([]string, error) {
+	var services []string
+	for _, fd := range s.files {
+		for _, sd := range fd.GetServices() {
+			services = append(services, sd.GetFullyQualifiedName())
+		}
+	}
+	sort.Strings(services)
+	return services, nil
+}
+
+func (s *fileSource) FindSymbol(fullName string) (desc.Descriptor, error) {
+	for _, fd := range s.files {
+		if d := fd.FindSymbol(fullName); d != nil {
+			return d, nil
+		}
+	}
+	return nil, fmt.Errorf("symbol not found: %s", fullName)
+}
+
+// findMethod resolves serviceName/methodName, the method name is case-insensitive.
+func findMethod(src descriptorSource, serviceName, methodName string) (protoreflect.MethodDescriptor, error) {
+	d, err := src.FindSymbol(serviceName)
+	if err != nil {
+		return nil, err
+	}
+	sd, ok := d.(*desc.ServiceDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a service", serviceName)
+	}
+	for _, md := range sd.GetMethods() {
+		if strings.EqualFold(md.GetName(), methodName) {
+			return md.UnwrapMethod(), nil
+		}
+	}
+	return nil, fmt.Errorf("method not found: %s in service: %s\n%s", methodName, serviceName, serviceInfo(sd.UnwrapService()))
+}
+
+// describeSymbol prints the proto source of a service, a method along with its request and response, a message
+// or an enum.
+func describeSymbol(src descriptorSource, fullName string) (string, error) {
+	d, err := src.FindSymbol(fullName)
+	if err != nil {
+		return "", err
+	}
+	printer := &protoprint.Printer{Compact: true, ForceFullyQualifiedNames: true}
+	descs := []desc.Descriptor{d}
+	if md, ok := d.(*desc.MethodDescriptor); ok {
+		descs = append(descs, md.GetInputType(), md.GetOutputType())
+	}
+
+	var out strings.Builder
+	for _, d = range descs {
+		source, er := printer.PrintProtoToString(d)
+		if er != nil {
+			return "", fmt.Errorf("print %s failure: %v", d.GetFullyQualifiedName(), er)
+		}
+		fmt.Fprintf(&out, "%s is a %s:\n%s\n", d.GetFullyQualifiedName(), descriptorKind(d), source)
+	}
+	return out.String(), nil
+}
+
+func descriptorKind(d desc.Descriptor) string {
+	switch d.(type) {
+	case *desc.ServiceDescriptor:
+		return "service"
+	case *desc.MethodDescriptor:
+		return "method"
+	case *desc.MessageDescriptor:
+		return "message"
+	case *desc.EnumDescriptor:
+		return "enum"
+	case *desc.FieldDescriptor:
+		return "field"
+	default:
+		return "descriptor"
+	}
+}
@@ -0,0 +1,170 @@
+package subcmds
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	testpb "google.golang.org/grpc/interop/grpc_testing"
+	"google.golang.org/grpc/reflection"
+	reflectionv1alphapb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+const echoProto = `syntax = "proto3";
+
+package echo;
+
+import "google/protobuf/timestamp.proto";
+
+message EchoRequest {
+  string message = 1;
+  google.protobuf.Timestamp sent_at = 2;
+}
+
+service Echo {
+  rpc Echo(EchoRequest) returns (EchoRequest);
+}
+`
+
+// newV1AlphaServer serves the test service with the v1alpha reflection only.
+func newV1AlphaServer(t *testing.T) *grpc.ClientConn {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer()
+	testpb.RegisterTestServiceServer(server, &streamingService{})
+	reflectionv1alphapb.RegisterServerReflectionServer(server, reflection.NewServer(reflection.ServerOptions{Services: server}))
+	go server.Serve(lis)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// writeProtoset writes fd and its dependencies into a FileDescriptorSet file.
+func writeProtoset(t *testing.T, fd protoreflect.FileDescriptor) string {
+	t.Helper()
+	fdSet := new(descriptorpb.FileDescriptorSet)
+	seen := make(map[string]bool)
+	var add func(protoreflect.FileDescriptor)
+	add = func(fd protoreflect.FileDescriptor) {
+		if seen[fd.Path()] {
+			return
+		}
+		seen[fd.Path()] = true
+		for i := 0; i < fd.Imports().Len(); i++ {
+			add(fd.Imports().Get(i).FileDescriptor)
+		}
+		fdSet.File = append(fdSet.File, protodesc.ToFileDescriptorProto(fd))
+	}
+	add(fd)
+
+	data, err := proto.Marshal(fdSet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	name := filepath.Join(t.TempDir(), "test.protoset")
+	if err = os.WriteFile(name, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return name
+}
+
+func TestDescriptorSources(t *testing.T) {
+	protoDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(protoDir, "echo.proto"), []byte(echoProto), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	protoset := writeProtoset(t, testpb.File_grpc_testing_test_proto)
+	conn := newV1AlphaServer(t)
+
+	tests := []struct {
+		name     string
+		args     []string
+		service  string
+		method   string
+		input    protoreflect.FullName
+		describe []string
+	}{
+		{
+			name:     "reflection v1alpha",
+			service:  "grpc.testing.TestService",
+			method:   "unaryCall",
+			input:    "grpc.testing.SimpleRequest",
+			describe: []string{"rpc UnaryCall ( .grpc.testing.SimpleRequest ) returns ( .grpc.testing.SimpleResponse );", "message SimpleRequest {"},
+		},
+		{
+			name:     "protoset",
+			args:     []string{"--protoset", protoset},
+			service:  "grpc.testing.TestService",
+			method:   "StreamingOutputCall",
+			input:    "grpc.testing.StreamingOutputCallRequest",
+			describe: []string{"rpc StreamingOutputCall ( .grpc.testing.StreamingOutputCallRequest ) returns ( stream .grpc.testing.StreamingOutputCallResponse );"},
+		},
+		{
+			name:     "proto files",
+			args:     []string{"--import-path", protoDir, "--proto", "echo.proto"},
+			service:  "echo.Echo",
+			method:   "Echo",
+			input:    "echo.EchoRequest",
+			describe: []string{"message EchoRequest {", ".google.protobuf.Timestamp sent_at = 2;"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := &cobra.Command{}
+			addDescriptorFlags(cmd)
+			if err := cmd.ParseFlags(tt.args); err != nil {
+				t.Fatal(err)
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			src, err := newDescriptorSource(ctx, cmd, conn)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			services, err := src.ListServices()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(strings.Join(services, ","), tt.service) {
+				t.Errorf("expected %s in the services, but actually %v", tt.service, services)
+			}
+			methodDesc, err := findMethod(src, tt.service, tt.method)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if methodDesc.Input().FullName() != tt.input {
+				t.Errorf("expected input %s, but actually %s", tt.input, methodDesc.Input().FullName())
+			}
+
+			source, err := describeSymbol(src, string(methodDesc.FullName()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range tt.describe {
+				if !strings.Contains(source, want) {
+					t.Errorf("expected %q in the description, but actually\n%s", want, source)
+				}
+			}
+		})
+	}
+}
@@ -1,25 +1,31 @@
 package subcmds
 
 import (
+	"bufio"
 	"context"
+	"crypto/tls"
+	"encoding/json"
 	"fmt"
 	"io"
 	"log"
+	"net"
 	"net/http"
 	"net/http/httptrace"
 	"net/url"
-	"path/filepath"
+	"os"
 	"strings"
 	"sync/atomic"
 	"time"
 
-	"github.com/jhump/protoreflect/desc/protoparse"
 	"github.com/pysugar/wheels/cmd/base"
 	"github.com/pysugar/wheels/http/client"
 	"github.com/pysugar/wheels/http/extensions"
+	"github.com/pysugar/wheels/http/har"
 	"github.com/spf13/cobra"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
 	"google.golang.org/protobuf/encoding/protojson"
-	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/reflect/protoreflect"
 	"google.golang.org/protobuf/types/dynamicpb"
 )
@@ -37,6 +43,13 @@ fetch http2 response from url: netool fetch https://www.google.com
 call grpc service: netool fetch --grpc https://localhost:8443/grpc.health.v1.Health/Check
 call grpc via context path: netool fetch --grpc http://localhost:8080/grpc/grpc.health.v1.Health/Check
 call grpc service: netool fetch --grpc https://localhost:8443/grpc.health.v1.Health/Check --proto-path=health.proto -d'{"service": ""}'
+call grpc with proto files: netool fetch --grpc --import-path ./protos --proto health.proto https://localhost:8443/grpc.health.v1.Health/Check
+call grpc with a protoset: netool fetch --grpc --protoset health.protoset https://localhost:8443/grpc.health.v1.Health/Check
+mutual tls with a private ca: netool fetch --cacert ca.pem --cert client.pem --key client-key.pem https://localhost:8443
+pin the server public key: netool fetch --pin sha256//BASE64== https://www.google.com
+record a har: netool fetch --har-out fetch.har https://www.google.com
+replay a har: netool fetch --har-replay fetch.har https://www.google.com
+time the request phases: netool fetch --timing https://www.google.com, or --timing=json
 `,
 		Run: func(cmd *cobra.Command, args []string) {
 			if len(args) < 1 {
@@ -51,6 +64,11 @@ call grpc service: netool fetch --grpc https://localhost:8443/grpc.health.v1.Hea
 			}
 
 			isGRPC, _ := cmd.Flags().GetBool("grpc")
+			isWS, _ := cmd.Flags().GetBool("websocket")
+			isGorilla, _ := cmd.Flags().GetBool("ws")
+			if harReplay, _ := cmd.Flags().GetString("har-replay"); harReplay != "" && (isGRPC || isWS || isGorilla) {
+				log.Fatal("--har-replay replays HTTP requests only, not gRPC calls or WebSocket connections")
+			}
 			if isGRPC {
 				err := gRPCCall(cmd, targetURL)
 				if err != nil {
@@ -59,10 +77,11 @@ call grpc service: netool fetch --grpc https://localhost:8443/grpc.health.v1.Hea
 				return
 			}
 
-			isWS, _ := cmd.Flags().GetBool("websocket")
-			isGorilla, _ := cmd.Flags().GetBool("ws")
 			if isWS || isGorilla {
-				err := wsCall(cmd, targetURL, isGorilla)
+				if harOut, _ := cmd.Flags().GetString("har-out"); harOut != "" {
+					log.Fatal("--har-out can not record WebSocket connections")
+				}
+				err := wsCall(cmd, targetURL)
 				if err != nil {
 					log.Fatal(err)
 				}
@@ -73,6 +92,7 @@ call grpc service: netool fetch --grpc https://localhost:8443/grpc.health.v1.Hea
 			isUpgrade, _ := cmd.Flags().GetBool("upgrade")
 			ctx, cancel := newContext(isVerbose, isUpgrade)
 			defer cancel()
+			ctx, timing := withTiming(ctx, cmd)
 
 			isHTTP1, _ := cmd.Flags().GetBool("http1")
 			isHTTP2, _ := cmd.Flags().GetBool("http2")
@@ -114,7 +134,33 @@ call grpc service: netool fetch --grpc https://localhost:8443/grpc.health.v1.Hea
 			}
 			req.ContentLength = contentLength
 
-			res, er := client.NewFetcher().Do(ctx, req)
+			pool := client.NewPool()
+			defer pool.Close()
+			fetcher, er := newFetcher(cmd, client.WithPool(pool))
+			if er != nil {
+				log.Fatal(er)
+			}
+			defer fetcher.Close()
+
+			var res *http.Response
+			if harReplay, _ := cmd.Flags().GetString("har-replay"); harReplay != "" {
+				h, err := har.Load(harReplay)
+				if err != nil {
+					log.Fatal(err)
+				}
+				res, er = har.NewReplayer(h).RoundTrip(req)
+			} else if harOut, _ := cmd.Flags().GetString("har-out"); harOut != "" {
+				recorder := har.NewRecorder(fetcher)
+				// deferred before the response body is closed, so that it is written after
+				defer func() {
+					if err := recorder.WriteFile(harOut); err != nil {
+						log.Printf("failed to write har %s, err: %v", harOut, err)
+					}
+				}()
+				res, er = recorder.Do(ctx, req)
+			} else {
+				res, er = fetcher.Do(ctx, req)
+			}
 			if er != nil {
 				fmt.Printf("Call %v %s error: %v\n", client.ProtocolFromContext(ctx), targetURL, er)
 				return
@@ -128,8 +174,14 @@ call grpc service: netool fetch --grpc https://localhost:8443/grpc.health.v1.Hea
 				fmt.Printf("%s: %s\r\n", k, strings.Join(v, ","))
 			}
 			fmt.Printf("\r\n")
-			resBody, _ := io.ReadAll(res.Body)
-			fmt.Printf("%s", resBody)
+			defer res.Body.Close()
+			io.Copy(os.Stdout, res.Body)
+			printTiming(cmd, timing)
+			if isVerbose {
+				stats := pool.Stats()
+				fmt.Printf("\n[pool] conns: %d, idle: %d, draining: %d, active streams: %d, pings in flight: %d\n",
+					stats.Conns, stats.IdleConns, stats.DrainingConns, stats.ActiveStreams, stats.PingsInFlight)
+			}
 		},
 	}
 )
@@ -142,38 +194,80 @@ func init() {
 	fetchCmd.Flags().BoolP("http1", "", false, "Is HTTP1 Request Or Not")
 	fetchCmd.Flags().BoolP("http2", "", false, "Is HTTP2 Request Or Not")
 	fetchCmd.Flags().BoolP("websocket", "W", false, "Is WebSocket Request Or Not")
-	fetchCmd.Flags().BoolP("ws", "", false, "Use gorilla client for websocket")
+	fetchCmd.Flags().BoolP("ws", "", false, "Same as --websocket")
+	fetchCmd.Flags().StringSlice("subprotocol", nil, "WebSocket subprotocols in the order of preference")
+	fetchCmd.Flags().Bool("compress", false, "Negotiate WebSocket permessage-deflate")
+	fetchCmd.Flags().Duration("ping-interval", 0, "Interval of WebSocket keepalive pings, 0 disables keepalive")
 	fetchCmd.Flags().BoolP("grpc", "G", false, "Is GRPC Request Or Not")
+	fetchCmd.Flags().String("grpc-compression", "", "Compress GRPC requests with the grpc-encoding, e.g. gzip")
 	fetchCmd.Flags().BoolP("verbose", "V", false, "Verbose mode")
 	fetchCmd.Flags().BoolP("upgrade", "U", false, "try http upgrade")
 	fetchCmd.Flags().StringP("proto-path", "P", "", "Proto Path")
+	addDescriptorFlags(fetchCmd)
 	fetchCmd.Flags().BoolP("insecure", "i", false, "Skip server certificate and domain verification (skip TLS)")
+	fetchCmd.Flags().String("cacert", "", "CA certificates (PEM) to verify the server with")
+	fetchCmd.Flags().String("cert", "", "Client certificate (PEM) for mutual TLS")
+	fetchCmd.Flags().String("key", "", "Private key (PEM) of the client certificate")
+	fetchCmd.Flags().StringP("proxy", "x", "", "Proxy URL, http://, https://, socks5:// or socks5h:// with optional user:password@, "+
+		"defaults to HTTPS_PROXY, HTTP_PROXY, ALL_PROXY and NO_PROXY")
+	fetchCmd.Flags().String("timing", "", "Print the timing of the request phases, as text like curl -w or as json")
+	fetchCmd.Flags().Lookup("timing").NoOptDefVal = "text"
+	fetchCmd.Flags().String("har-out", "", "Record the exchanges of an HTTP request or a gRPC call into a HAR file")
+	fetchCmd.Flags().String("har-replay", "", "Serve the response recorded in a HAR file instead of sending the HTTP request")
+	fetchCmd.Flags().StringSlice("pin", nil, "Pinned server public key, base64 SHA-256 of the SPKI, optionally prefixed by sha256//")
 	base.AddSubCommands(fetchCmd)
 }
 
-func wsCall(cmd *cobra.Command, targetURL *url.URL, isGorilla bool) error {
+func wsCall(cmd *cobra.Command, targetURL *url.URL) error {
 	isVerbose, _ := cmd.Flags().GetBool("verbose")
-	isInsecure, _ := cmd.Flags().GetBool("insecure")
 	ctx, cancel := newContext(isVerbose, true)
 	defer cancel()
 	ctx = client.WithProtocol(ctx, client.WebSocket)
-	if isGorilla {
-		ctx = client.WithGorilla(ctx)
-	}
-	if isInsecure {
-		ctx = client.WithInsecure(ctx)
-	}
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, targetURL.String(), nil)
 	if err != nil {
 		fmt.Printf("failed to create request: %v\n", err)
 		return err
 	}
-	err = client.NewFetcher().WS(ctx, req)
+
+	fetcher, err := newFetcher(cmd)
+	if err != nil {
+		return err
+	}
+	defer fetcher.Close()
+	conn, err := fetcher.DialWebSocket(ctx, req)
 	if err != nil {
 		fmt.Printf("failed to fetch websocket: %v\n", err)
 		return err
 	}
-	return nil
+	if subprotocol := conn.Subprotocol(); subprotocol != "" {
+		fmt.Printf("subprotocol: %s\n", subprotocol)
+	}
+
+	// the dial timeout of ctx does not apply to the interactive session
+	go func() {
+		for {
+			mt, msg, er := conn.ReadMessage(context.Background())
+			if er != nil {
+				fmt.Printf("\nconnection closed: %v\n", er)
+				os.Exit(0)
+			}
+			fmt.Printf("receive %s message: %s\nPlease input message: ", mt, msg)
+		}
+	}()
+
+	reader := bufio.NewReader(os.Stdin)
+	fmt.Print("Please input message: ")
+	for {
+		msg, er := reader.ReadString('\n')
+		msg = strings.TrimSpace(msg)
+		if er != nil || msg == ":quit" || msg == ":exit" {
+			return conn.Close(client.CloseNormalClosure, msg)
+		}
+		if er := conn.WriteMessage(context.Background(), client.TextMessage, []byte(msg)); er != nil {
+			log.Printf("send message failure: %v", er)
+			return er
+		}
+	}
 }
 
 func gRPCCall(cmd *cobra.Command, targetURL *url.URL) error {
@@ -186,11 +280,27 @@ func gRPCCall(cmd *cobra.Command, targetURL *url.URL) error {
 	requestJson, _ := cmd.Flags().GetString("data")
 	protoPath, _ := cmd.Flags().GetString("proto-path")
 	isVerbose, _ := cmd.Flags().GetBool("verbose")
-	reqMessage, resMessage, err := getReqResMessages(protoPath, service, method, isVerbose)
+	isUpgrade, _ := cmd.Flags().GetBool("upgrade")
+	ctx, cancel := newContext(isVerbose, isUpgrade)
+	defer cancel()
+
+	conn, err := dialReflection(cmd, targetURL)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	src, err := newDescriptorSource(ctx, cmd, conn, protoPath)
+	if err != nil {
+		log.Printf("invalid descriptor source, err: %v\n", err)
+		return err
+	}
+	methodDesc, err := findMethod(src, service, method)
 	if err != nil {
-		log.Printf("invalid proto file: %s, err: %v\n", protoPath, err)
+		log.Printf("resolve method %s/%s failure: %v\n", service, method, err)
 		return err
 	}
+	reqMessage := dynamicpb.NewMessage(methodDesc.Input())
+	resMessage := dynamicpb.NewMessage(methodDesc.Output())
 
 	err = protojson.Unmarshal([]byte(requestJson), reqMessage)
 	if err != nil {
@@ -198,15 +308,26 @@ func gRPCCall(cmd *cobra.Command, targetURL *url.URL) error {
 		return err
 	}
 
-	isUpgrade, _ := cmd.Flags().GetBool("upgrade")
-	ctx, cancel := newContext(isVerbose, isUpgrade)
-	defer cancel()
-
 	ctx = client.WithProtocol(ctx, client.HTTP2)
-	if er := client.NewFetcher().CallGRPC(ctx, targetURL, reqMessage, resMessage); er != nil {
-		log.Printf("Call grpc %s error: %v\n", targetURL, er)
+	ctx, timing := withTiming(ctx, cmd)
+	fetcher, err := newFetcher(cmd)
+	if err != nil {
 		return err
 	}
+	defer fetcher.Close()
+	if harOut, _ := cmd.Flags().GetString("har-out"); harOut != "" {
+		recorder := har.NewRecorder(fetcher)
+		defer func() {
+			if er := recorder.WriteFile(harOut); er != nil {
+				log.Printf("failed to write har %s, err: %v", harOut, er)
+			}
+		}()
+		fetcher = recorder
+	}
+	if er := fetcher.CallGRPC(ctx, targetURL, reqMessage, resMessage); er != nil {
+		log.Printf("Call grpc %s error: %v\n", targetURL, er)
+		return er
+	}
 
 	responseJson, err := protojson.Marshal(resMessage)
 	if err != nil {
@@ -214,9 +335,108 @@ func gRPCCall(cmd *cobra.Command, targetURL *url.URL) error {
 		return err
 	}
 	fmt.Printf("%s\n", responseJson)
+	printTiming(cmd, timing)
 	return nil
 }
 
+// newFetcher creates a fetcher with the TLS settings of the command flags.
+func newFetcher(cmd *cobra.Command, opts ...client.FetcherOption) (client.Fetcher, error) {
+	if isInsecure, _ := cmd.Flags().GetBool("insecure"); isInsecure {
+		opts = append(opts, client.WithInsecureSkipVerify())
+	}
+	if caCert, _ := cmd.Flags().GetString("cacert"); caCert != "" {
+		pool, err := client.LoadCertPool(caCert)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load ca certificates, err: %v", err)
+		}
+		opts = append(opts, client.WithRootCAs(pool))
+	}
+	certFile, _ := cmd.Flags().GetString("cert")
+	keyFile, _ := cmd.Flags().GetString("key")
+	if certFile != "" || keyFile != "" {
+		if keyFile == "" {
+			keyFile = certFile // both in one PEM file
+		}
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate, err: %v", err)
+		}
+		opts = append(opts, client.WithClientCertificate(cert))
+	}
+	pins, _ := cmd.Flags().GetStringSlice("pin")
+	for _, s := range pins {
+		pin, err := client.ParsePin(s)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, client.WithPinnedKeys(pin))
+	}
+	if subprotocols, _ := cmd.Flags().GetStringSlice("subprotocol"); len(subprotocols) > 0 {
+		opts = append(opts, client.WithWebSocketSubprotocols(subprotocols...))
+	}
+	if compress, _ := cmd.Flags().GetBool("compress"); compress {
+		opts = append(opts, client.WithWebSocketCompression())
+	}
+	if interval, _ := cmd.Flags().GetDuration("ping-interval"); interval > 0 {
+		opts = append(opts, client.WithWebSocketKeepalive(interval, interval))
+	}
+	if compression, _ := cmd.Flags().GetString("grpc-compression"); compression != "" {
+		opts = append(opts, client.WithGRPCCompression(compression))
+	}
+	if proxy, _ := cmd.Flags().GetString("proxy"); proxy != "" {
+		proxyURL, err := url.Parse(proxy)
+		if err != nil || proxyURL.Host == "" {
+			return nil, fmt.Errorf("invalid proxy %q, expected scheme://[user:password@]host:port", proxy)
+		}
+		opts = append(opts, client.WithDialOptions(client.WithProxy(proxyURL)))
+	}
+	return client.NewFetcher(opts...), nil
+}
+
+// withTiming records the request phases in the timing it returns if the --timing flag is set, nil otherwise.
+func withTiming(ctx context.Context, cmd *cobra.Command) (context.Context, *client.Timing) {
+	if format, _ := cmd.Flags().GetString("timing"); format == "" {
+		return ctx, nil
+	}
+	timing := new(client.Timing)
+	return client.WithTiming(ctx, timing), timing
+}
+
+// printTiming prints the times since the start of the request at which its phases ended, as curl -w does.
+func printTiming(cmd *cobra.Command, timing *client.Timing) {
+	if timing == nil {
+		return
+	}
+	r := timing.Report()
+	phases := []struct {
+		name string
+		d    time.Duration
+	}{
+		{"time_namelookup", r.NameLookup},
+		{"time_connect", r.Connect},
+		{"time_appconnect", r.AppConnect},
+		{"time_settings", r.Settings},
+		{"time_pretransfer", r.PreTransfer},
+		{"time_wrote_request", r.WroteRequest},
+		{"time_starttransfer", r.StartTransfer},
+		{"time_total", r.Total},
+	}
+
+	if format, _ := cmd.Flags().GetString("timing"); format == "json" {
+		report := map[string]any{"http_version": r.Proto, "reused": r.Reused}
+		for _, p := range phases {
+			report[p.name] = p.d.Seconds()
+		}
+		data, _ := json.MarshalIndent(report, "", "  ")
+		fmt.Printf("\n%s\n", data)
+		return
+	}
+	fmt.Printf("\n%20s: %s\n%20s: %v\n", "http_version", r.Proto, "reused", r.Reused)
+	for _, p := range phases {
+		fmt.Printf("%20s: %.6fs\n", p.name, p.d.Seconds())
+	}
+}
+
 func newContext(isVerbose, isUpgrade bool) (context.Context, context.CancelFunc) {
 	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
 	if isVerbose {
@@ -245,58 +465,56 @@ func extractServiceAndMethod(parsedURL *url.URL) (string, string, error) {
 	return service, method, nil
 }
 
-func getReqResMessages(protoPath, serviceName, methodName string, verbose bool) (proto.Message, proto.Message, error) {
-	parser := protoparse.Parser{
-		ImportPaths: []string{filepath.Dir(protoPath)},
-	}
-
-	fileDescriptors, err := parser.ParseFiles(filepath.Base(protoPath))
-	if err != nil {
-		return nil, nil, fmt.Errorf("resolve proto file failure: %v", err)
-	}
-
-	if verbose {
-		fmt.Printf("dir: %+v\n", filepath.Dir(protoPath))
-		fmt.Printf("base: %+v\n", filepath.Base(protoPath))
-		for _, fd := range fileDescriptors {
-			fmt.Printf("fd: %v\n", fd)
+// dialReflection prepares a connection to the server of targetURL for server reflection, which resolves the
+// method unless descriptor files are given. It connects on first use only.
+func dialReflection(cmd *cobra.Command, targetURL *url.URL) (*grpc.ClientConn, error) {
+	cred := insecure.NewCredentials()
+	port := "80"
+	if targetURL.Scheme == "https" {
+		tlsConfig, err := newTLSConfig(cmd)
+		if err != nil {
+			return nil, err
 		}
+		cred = credentials.NewTLS(tlsConfig)
+		port = "443"
 	}
-
-	if len(fileDescriptors) == 0 {
-		return nil, nil, fmt.Errorf("file descriptor not found")
-	}
-
-	descriptor := fileDescriptors[0]
-	srvDesc := descriptor.FindService(serviceName)
-	if srvDesc == nil {
-		showServices(descriptor.UnwrapFile())
-		return nil, nil, fmt.Errorf("service not found: %s", serviceName)
-	}
-
-	method := srvDesc.FindMethodByName(methodName)
-	if method == nil {
-		fmt.Println(serviceInfo(srvDesc.UnwrapService()))
-		return nil, nil, fmt.Errorf("method not found: %s in service: %s", methodName, serviceName)
+	opts := []grpc.DialOption{grpc.WithTransportCredentials(cred)}
+	segments := strings.Split(strings.Trim(targetURL.Path, "/"), "/")
+	if len(segments) > 2 {
+		contextPath := strings.Join(segments[:len(segments)-2], "/")
+		opts = append(opts, grpc.WithStreamInterceptor(contextPathStreamInterceptor(contextPath)))
 	}
-
-	inputDesc := method.GetInputType()
-	outputDesc := method.GetOutputType()
-
-	if inputDesc == nil || outputDesc == nil {
-		return nil, nil, fmt.Errorf("input or output is empty")
+	target := targetURL.Host
+	if targetURL.Port() == "" {
+		target = net.JoinHostPort(targetURL.Hostname(), port)
 	}
-
-	reqMessage := dynamicpb.NewMessage(inputDesc.UnwrapMessage())
-	resMessage := dynamicpb.NewMessage(outputDesc.UnwrapMessage())
-
-	return reqMessage, resMessage, nil
+	return grpc.NewClient(target, opts...)
 }
 
-func showServices(fd protoreflect.FileDescriptor) {
-	for i := 0; i < fd.Services().Len(); i++ {
-		fmt.Println(serviceInfo(fd.Services().Get(i)))
+// newTLSConfig creates the TLS config of the command flags, as newFetcher applies them.
+func newTLSConfig(cmd *cobra.Command) (*tls.Config, error) {
+	tlsConfig := &tls.Config{}
+	tlsConfig.InsecureSkipVerify, _ = cmd.Flags().GetBool("insecure")
+	if caCert, _ := cmd.Flags().GetString("cacert"); caCert != "" {
+		pool, err := client.LoadCertPool(caCert)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load ca certificates, err: %v", err)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	certFile, _ := cmd.Flags().GetString("cert")
+	keyFile, _ := cmd.Flags().GetString("key")
+	if certFile != "" || keyFile != "" {
+		if keyFile == "" {
+			keyFile = certFile // both in one PEM file
+		}
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate, err: %v", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
 	}
+	return tlsConfig, nil
 }
 
 func serviceInfo(srvDesc protoreflect.ServiceDescriptor) string {
@@ -1,13 +1,20 @@
 package subcmds
 
 import (
+	"bytes"
 	"context"
 	"crypto/tls"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"os"
+	"sort"
 	"strings"
 	"time"
 
+	"github.com/jhump/protoreflect/desc"
 	"github.com/pysugar/wheels/binproto/grpc/codec"
 	"github.com/pysugar/wheels/cmd/base"
 	"github.com/spf13/cobra"
@@ -16,14 +23,9 @@ import (
 	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
 	"google.golang.org/grpc/metadata"
-	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1"
 	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/encoding/protojson"
-	"google.golang.org/protobuf/proto"
-	"google.golang.org/protobuf/reflect/protodesc"
 	"google.golang.org/protobuf/reflect/protoreflect"
-	"google.golang.org/protobuf/reflect/protoregistry"
-	"google.golang.org/protobuf/types/descriptorpb"
 	"google.golang.org/protobuf/types/dynamicpb"
 
 	_ "google.golang.org/protobuf/types/known/anypb"
@@ -32,8 +34,6 @@ import (
 	_ "google.golang.org/protobuf/types/known/wrapperspb"
 )
 
-const contextPathKey = "contextPath"
-
 var (
 	grpcCmd = &cobra.Command{
 		Use:   `grpc -d '{}' 127.0.0.1:50051 grpc.health.v1.Health/Check`,
@@ -45,6 +45,11 @@ Send an empty request:                     netool grpc grpc.server.com:443 my.cu
 Send a request with a header and a body:   netool grpc -H "Authorization: Bearer $token" -d '{"foo": "bar"}' grpc.server.com:443 my.custom.server.Service/Method
 List all services exposed by a server:     netool grpc grpc.server.com:443 list
 List all methods in a particular service:  netool grpc grpc.server.com:443 list my.custom.server.Service
+Print a service or message as proto:       netool grpc grpc.server.com:443 describe my.custom.server.Service my.custom.server.Request
+Use proto files instead of reflection:     netool grpc --import-path ./protos --proto service.proto grpc.server.com:443 list
+Use compiled protosets:                    netool grpc --protoset service.protoset grpc.server.com:443 my.custom.server.Service/Method
+Stream requests of a file to a method:     netool grpc -d @requests.json grpc.server.com:443 my.custom.server.Service/StreamingMethod
+Stream requests typed on stdin:            netool grpc -d @- --max-time 0 grpc.server.com:443 my.custom.server.Service/StreamingMethod
 `,
 		Run: func(cmd *cobra.Command, args []string) {
 			if len(args) < 2 {
@@ -62,12 +67,13 @@ List all methods in a particular service:  netool grpc grpc.server.com:443 list
 
 			target := args[0]
 			op := args[1]
-			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			maxTime, _ := cmd.Flags().GetDuration("max-time")
+			ctx, cancel := context.WithCancel(context.Background())
+			if maxTime > 0 {
+				ctx, cancel = context.WithTimeout(ctx, maxTime)
+			}
 			defer cancel()
 			contextPath, _ := cmd.Flags().GetString("context-path")
-			if contextPath != "" {
-				ctx = context.WithValue(ctx, contextPathKey, contextPath)
-			}
 			headers, _ := cmd.Flags().GetStringArray("header")
 
 			md := make(map[string]string)
@@ -88,20 +94,48 @@ List all methods in a particular service:  netool grpc grpc.server.com:443 list
 			mdContext := metadata.Pairs(mdPairs...)
 			ctx = metadata.NewOutgoingContext(ctx, mdContext)
 
-			if strings.EqualFold(op, "list") {
+			opts := []grpc.DialOption{grpc.WithTransportCredentials(cred)}
+			if contextPath != "" {
+				opts = append(
+					opts,
+					grpc.WithUnaryInterceptor(contextPathUnaryInterceptor(contextPath)),
+					grpc.WithStreamInterceptor(contextPathStreamInterceptor(contextPath)),
+				)
+			}
+			conn, err := grpc.NewClient(target, opts...)
+			if err != nil {
+				log.Printf("connect to %s error: %v\n", target, err)
+				return
+			}
+			defer conn.Close()
+
+			src, err := newDescriptorSource(ctx, cmd, conn)
+			if err != nil {
+				log.Printf("descriptor source error: %v\n", err)
+				return
+			}
+
+			switch {
+			case strings.EqualFold(op, "list"):
 				if len(args) > 2 {
-					serviceName := args[2]
-					if err := listDescriptors(ctx, target, serviceName, grpc.WithTransportCredentials(cred)); err != nil {
-						log.Printf("List descriptors error: %v\n", err)
-					}
-				} else {
-					if err := listServices(ctx, target, grpc.WithTransportCredentials(cred)); err != nil {
-						log.Printf("List services error: %v\n", err)
+					if err = listMethods(src, args[2]); err != nil {
+						log.Printf("List methods error: %v\n", err)
 					}
+				} else if err = listServices(src); err != nil {
+					log.Printf("List services error: %v\n", err)
 				}
-			} else {
-				data, _ := cmd.Flags().GetString("data")
-				if err := makeGenericGrpcCall(ctx, target, op, []byte(data), grpc.WithTransportCredentials(cred)); err != nil {
+			case strings.EqualFold(op, "describe"):
+				if err = describeSymbols(src, args[2:]); err != nil {
+					log.Printf("Describe error: %v\n", err)
+				}
+			default:
+				data, er := requestData(cmd)
+				if er != nil {
+					log.Printf("read request data error: %v\n", er)
+					return
+				}
+				defer data.Close()
+				if err = makeGenericGrpcCall(ctx, conn, src, op, data); err != nil {
 					log.Printf("make generic grpc call error: %v\n", err)
 				}
 			}
@@ -112,257 +146,97 @@ List all methods in a particular service:  netool grpc grpc.server.com:443 list
 func init() {
 	grpcCmd.Flags().BoolP("plaintext", "p", false, "Use plain-text HTTP/2 when connecting to server (no TLS)")
 	grpcCmd.Flags().BoolP("insecure", "i", false, "Skip server certificate and domain verification (skip TLS)")
-	grpcCmd.Flags().StringP("data", "d", "{}", "request data")
+	grpcCmd.Flags().StringP("data", "d", "{}", "request data, @file or @- to read it from a file or stdin, "+
+		"streaming methods take one JSON message per line")
+	grpcCmd.Flags().Duration("max-time", 10*time.Second, "Maximum time of the call, 0 means no limit")
 	grpcCmd.Flags().StringP("context-path", "c", "", "context path")
 	grpcCmd.Flags().StringArrayP("header", "H", []string{}, "Extra header to include in information sent")
+	addDescriptorFlags(grpcCmd)
 	base.AddSubCommands(grpcCmd)
 }
 
-func listDescriptors(ctx context.Context, target, serviceName string, opts ...grpc.DialOption) error {
-	conn, err := grpc.NewClient(target, opts...)
+func listServices(src descriptorSource) error {
+	services, err := src.ListServices()
 	if err != nil {
 		return err
 	}
-	defer conn.Close()
-
-	clientStream, err := newReflectionClient(ctx, conn, opts...)
-	if err != nil {
-		return err
+	for _, srv := range services {
+		log.Printf("Discovered service: %v\n", srv)
 	}
-
-	doneCh := make(chan struct{})
-	go func() {
-		defer close(doneCh)
-		for {
-			resp, er := clientStream.Recv()
-			if er != nil {
-				log.Printf("Failed to receive service reflection response: %v", er)
-				return
-			}
-
-			if errResp := resp.GetErrorResponse(); errResp != nil {
-				log.Printf("Failed to receive service reflection response: %v", errResp)
-				continue
-			}
-
-			fileDescriptorResp := resp.GetFileDescriptorResponse()
-			if fileDescriptorResp == nil {
-				continue
-			}
-
-			for i, fdBytes := range fileDescriptorResp.FileDescriptorProto {
-				fdProto := &descriptorpb.FileDescriptorProto{}
-				if e := proto.Unmarshal(fdBytes, fdProto); e != nil {
-					log.Printf("[%d] failed to unmarshal FileDescriptorProto: %v\n", i, e)
-					continue
-				}
-
-				protoregistry.GlobalTypes.RangeMessages(func(mt protoreflect.MessageType) bool {
-					log.Printf("GlobalType: %v\n", mt.Descriptor().FullName())
-					return true
-				})
-
-				for _, dep := range fdProto.GetDependency() {
-					log.Printf("Dependency: %s\n", dep)
-				}
-
-				fileDesc, e := protodesc.NewFile(fdProto, protoregistry.GlobalFiles)
-				if e != nil {
-					log.Printf("[%d] failed to create FileDescriptor from %v: %v\n", i, fdProto, e)
-					return
-				}
-
-				for j := 0; j < fileDesc.Services().Len(); j++ {
-					srv := fileDesc.Services().Get(j)
-					log.Printf("[%d] %s\n", i, srv.FullName())
-					for k := 0; k < srv.Methods().Len(); k++ {
-						mth := srv.Methods().Get(k)
-						log.Printf("[%d]\t %s\n", i, mth.FullName())
-						log.Printf("[%d]\t\t %v\n", i, mth.Input().FullName())
-						log.Printf("[%d]\t\t %v\n", i, mth.Output().FullName())
-						log.Printf("[%d]\t\t stream client: %v\n", i, mth.IsStreamingClient())
-						log.Printf("[%d]\t\t stream server: %v\n", i, mth.IsStreamingServer())
-					}
-				}
-			}
-			return
-		}
-	}()
-
-	if er := clientStream.Send(&reflectionpb.ServerReflectionRequest{
-		MessageRequest: &reflectionpb.ServerReflectionRequest_FileContainingSymbol{
-			FileContainingSymbol: serviceName,
-		},
-	}); er != nil {
-		return fmt.Errorf("failed to send reflection request for service: %v", er)
-	}
-
-	<-doneCh
 	return nil
 }
 
-func listServices(ctx context.Context, target string, opts ...grpc.DialOption) error {
-	conn, err := grpc.NewClient(target, opts...)
+func listMethods(src descriptorSource, serviceName string) error {
+	d, err := src.FindSymbol(serviceName)
 	if err != nil {
 		return err
 	}
-	defer conn.Close()
-
-	clientStream, err := newReflectionClient(ctx, conn, opts...)
-	if err != nil {
-		return err
+	srv, ok := d.(*desc.ServiceDescriptor)
+	if !ok {
+		return fmt.Errorf("%s is not a service", serviceName)
 	}
-
-	doneCh := make(chan struct{})
-	go func() {
-		defer close(doneCh)
-		for {
-			resp, er := clientStream.Recv()
-			if er != nil {
-				log.Printf("Failed to receive service reflection response: %v", er)
-				return
-			}
-
-			if errResp := resp.GetErrorResponse(); errResp != nil {
-				log.Printf("Failed to receive service reflection response: %v", errResp)
-				continue
-			}
-
-			listServicesResp := resp.GetListServicesResponse()
-			if listServicesResp == nil {
-				continue
-			}
-
-			for _, srv := range listServicesResp.GetService() {
-				log.Printf("Discovered service: %v\n", srv.GetName())
-			}
-			return
-		}
-	}()
-
-	if er := clientStream.Send(&reflectionpb.ServerReflectionRequest{
-		MessageRequest: &reflectionpb.ServerReflectionRequest_ListServices{
-			ListServices: "*",
-		},
-	}); er != nil {
-		return fmt.Errorf("failed to send reflection request for service: %v", err)
+	log.Printf("%s\n", srv.GetFullyQualifiedName())
+	for _, mth := range srv.GetMethods() {
+		log.Printf("\t %s\n", mth.GetFullyQualifiedName())
+		log.Printf("\t\t %v\n", mth.GetInputType().GetFullyQualifiedName())
+		log.Printf("\t\t %v\n", mth.GetOutputType().GetFullyQualifiedName())
+		log.Printf("\t\t stream client: %v\n", mth.IsClientStreaming())
+		log.Printf("\t\t stream server: %v\n", mth.IsServerStreaming())
 	}
-
-	<-doneCh
 	return nil
 }
 
-func findMethodDescriptor(ctx context.Context, conn *grpc.ClientConn, serviceName, methodName string) (protoreflect.MethodDescriptor, error) {
-	reflectionClient := reflectionpb.NewServerReflectionClient(conn)
-	clientStream, err := reflectionClient.ServerReflectionInfo(ctx)
-	if err != nil {
-		return nil, err
-	}
-
-	doneCh := make(chan any)
-	go func() {
-		defer close(doneCh)
-		for {
-			resp, er := clientStream.Recv()
-			if er != nil {
-				log.Printf("Failed to receive service reflection response: %v", er)
-				doneCh <- er
-				return
-			}
-
-			if errResp := resp.GetErrorResponse(); errResp != nil {
-				log.Printf("Failed to receive service reflection response: %v", errResp)
-				doneCh <- fmt.Errorf("code: %d, message: %s", errResp.ErrorCode, errResp.ErrorMessage)
-				return
-			}
-
-			descResp := resp.GetFileDescriptorResponse()
-			descResp.GetFileDescriptorProto()
-
-			for _, fdBytes := range descResp.FileDescriptorProto {
-				fdProto := &descriptorpb.FileDescriptorProto{}
-				if e := proto.Unmarshal(fdBytes, fdProto); e != nil {
-					log.Printf("failed to unmarshal FileDescriptorProto: %v", er)
-					continue
-				}
-
-				fileDesc, e := protodesc.NewFile(fdProto, protoregistry.GlobalFiles)
-				if e != nil {
-					log.Printf("failed to create FileDescriptor: %v", e)
-					return
-				}
-
-				for j := 0; j < fileDesc.Services().Len(); j++ {
-					srv := fileDesc.Services().Get(j)
-					if strings.EqualFold(string(srv.FullName()), serviceName) {
-						for k := 0; k < srv.Methods().Len(); k++ {
-							mth := srv.Methods().Get(k)
-							if strings.EqualFold(string(mth.Name()), methodName) {
-								doneCh <- mth
-							}
-						}
-						doneCh <- fmt.Errorf("method %s not found in service %s", methodName, serviceName)
-					}
-				}
-			}
+// describeSymbols prints the proto source of symbols, of all services without symbols.
+func describeSymbols(src descriptorSource, symbols []string) error {
+	if len(symbols) == 0 {
+		services, err := src.ListServices()
+		if err != nil {
+			return err
 		}
-	}()
-
-	if er := clientStream.Send(&reflectionpb.ServerReflectionRequest{
-		MessageRequest: &reflectionpb.ServerReflectionRequest_FileContainingSymbol{
-			FileContainingSymbol: serviceName,
-		},
-	}); er != nil {
-		return nil, fmt.Errorf("failed to send reflection request for service: %v", er)
+		symbols = services
 	}
-
-	if v, ok := <-doneCh; ok {
-		if er, has := v.(error); has {
-			return nil, er
-		} else if md, suc := v.(protoreflect.MethodDescriptor); suc {
-			return md, nil
+	for _, symbol := range symbols {
+		source, err := describeSymbol(src, symbol)
+		if err != nil {
+			return err
 		}
-		return nil, fmt.Errorf("unexpected error: %v", v)
+		fmt.Print(source)
 	}
-	return nil, fmt.Errorf("unexpected error")
+	return nil
 }
 
-func makeGenericGrpcCall(ctx context.Context, target, fullMethod string, jsonData []byte, opts ...grpc.DialOption) error {
-	if contextPath, ok := ctx.Value(contextPathKey).(string); ok {
-		opts = append(
-			opts,
-			grpc.WithUnaryInterceptor(contextPathUnaryInterceptor(contextPath)),
-			grpc.WithStreamInterceptor(contextPathStreamInterceptor(contextPath)),
-		)
-	}
-
-	conn, err := grpc.NewClient(target, opts...)
-	if err != nil {
-		return err
-	}
-	defer conn.Close()
-
+func makeGenericGrpcCall(ctx context.Context, conn *grpc.ClientConn, src descriptorSource, fullMethod string, data io.Reader) error {
 	serviceName, methodName, err := parseMethod(fullMethod)
 	if err != nil {
 		return err
 	}
 
-	methodDesc, err := findMethodDescriptor(ctx, conn, serviceName, methodName)
+	methodDesc, err := findMethod(src, serviceName, methodName)
 	if err != nil {
-		st, ok := status.FromError(err)
-		if !ok {
+		// without reflection, the server is sent the request as json
+		if status.Code(err) != codes.Unimplemented {
 			return err
 		}
+	}
 
-		if st.Code() != codes.Unimplemented {
-			return err
-		}
+	rpcMethod := fmt.Sprintf("/%s/%s", serviceName, methodName)
+	if methodDesc != nil {
+		rpcMethod = fmt.Sprintf("/%s/%s", methodDesc.Parent().FullName(), methodDesc.Name())
+	}
+	if methodDesc != nil && (methodDesc.IsStreamingClient() || methodDesc.IsStreamingServer()) {
+		return makeStreamingGrpcCall(ctx, conn, rpcMethod, methodDesc, data)
 	}
 
-	if len(jsonData) == 0 {
+	jsonData, err := io.ReadAll(data)
+	if err != nil {
+		return fmt.Errorf("failed to read request data: %v", err)
+	}
+	if len(bytes.TrimSpace(jsonData)) == 0 {
 		jsonData = []byte("{}")
 	}
 
+	var header, trailer metadata.MD
+	callOpts := []grpc.CallOption{grpc.Header(&header), grpc.Trailer(&trailer)}
 	if methodDesc != nil {
 		inputDesc := methodDesc.Input()
 		reqMessage := dynamicpb.NewMessage(inputDesc)
@@ -374,9 +248,10 @@ func makeGenericGrpcCall(ctx context.Context, target, fullMethod string, jsonDat
 		outputDesc := methodDesc.Output()
 		resMessage := dynamicpb.NewMessage(outputDesc)
 
-		rpcMethod := fmt.Sprintf("/%s/%s", serviceName, methodName)
-		err = conn.Invoke(ctx, rpcMethod, reqMessage, resMessage)
+		err = conn.Invoke(ctx, rpcMethod, reqMessage, resMessage, callOpts...)
+		printMetadata("Response headers", header)
 		if err != nil {
+			printMetadata("Response trailers", trailer)
 			return fmt.Errorf("gRPC call failed: %v", err)
 		}
 
@@ -388,20 +263,140 @@ func makeGenericGrpcCall(ctx context.Context, target, fullMethod string, jsonDat
 	} else {
 		request := &codec.JsonFrame{RawData: jsonData}
 		response := &codec.JsonFrame{}
-		jsonOpts := []grpc.CallOption{
+		jsonOpts := append(callOpts,
 			grpc.ForceCodec(&codec.JsonFrame{}),
 			grpc.CallContentSubtype("json"),
-		}
-		rpcMethod := fmt.Sprintf("/%s/%s", serviceName, methodName)
+		)
 		err = conn.Invoke(ctx, rpcMethod, request, response, jsonOpts...)
+		printMetadata("Response headers", header)
 		if err != nil {
+			printMetadata("Response trailers", trailer)
 			return fmt.Errorf("gRPC call failed: %v", err)
 		}
 		log.Printf("Response: %s", response.RawData)
 	}
+	printMetadata("Response trailers", trailer)
+	log.Printf("Status: %s", codes.OK)
 	return nil
 }
 
+// makeStreamingGrpcCall sends the JSON messages of data one after another, a single one unless the client
+// streams, and prints each response as it arrives, the headers first and the trailers and status last.
+func makeStreamingGrpcCall(ctx context.Context, conn *grpc.ClientConn, rpcMethod string, methodDesc protoreflect.MethodDescriptor, data io.Reader) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	streamDesc := &grpc.StreamDesc{
+		StreamName:    string(methodDesc.Name()),
+		ClientStreams: methodDesc.IsStreamingClient(),
+		ServerStreams: methodDesc.IsStreamingServer(),
+	}
+	stream, err := conn.NewStream(ctx, streamDesc, rpcMethod)
+	if err != nil {
+		return fmt.Errorf("gRPC call failed: %v", err)
+	}
+
+	sendErr := make(chan error, 1)
+	go func() {
+		sendErr <- sendRequests(stream, methodDesc.Input(), data, streamDesc.ClientStreams)
+	}()
+
+	if header, er := stream.Header(); er == nil {
+		printMetadata("Response headers", header)
+	}
+	for i := 1; ; i++ {
+		resMessage := dynamicpb.NewMessage(methodDesc.Output())
+		if err = stream.RecvMsg(resMessage); err != nil {
+			break
+		}
+		responseJson, er := protojson.Marshal(resMessage)
+		if er != nil {
+			return fmt.Errorf("failed to serialize response to JSON: %v", er)
+		}
+		log.Printf("Response[%d]: %s", i, responseJson)
+	}
+	printMetadata("Response trailers", stream.Trailer())
+
+	if errors.Is(err, io.EOF) {
+		err = nil
+	}
+	st := status.Convert(err)
+	log.Printf("Status: %s %s", st.Code(), st.Message())
+	if err != nil {
+		return fmt.Errorf("gRPC call failed: %v", err)
+	}
+	select {
+	case er := <-sendErr:
+		return er
+	default:
+		// the server ended the call before the client has sent all requests
+		return nil
+	}
+}
+
+// sendRequests sends the JSON messages of data, the first one only unless the client streams, then half-closes
+// the stream. A message may span several lines, an empty data sends an empty message.
+func sendRequests(stream grpc.ClientStream, inputDesc protoreflect.MessageDescriptor, data io.Reader, clientStreams bool) error {
+	decoder := json.NewDecoder(data)
+	for sent := 0; ; sent++ {
+		var raw json.RawMessage
+		if err := decoder.Decode(&raw); errors.Is(err, io.EOF) {
+			if sent == 0 {
+				raw = json.RawMessage("{}")
+			} else {
+				break
+			}
+		} else if err != nil {
+			stream.CloseSend()
+			return fmt.Errorf("failed to read request %d: %v", sent+1, err)
+		}
+
+		reqMessage := dynamicpb.NewMessage(inputDesc)
+		if err := protojson.Unmarshal(raw, reqMessage); err != nil {
+			stream.CloseSend()
+			return fmt.Errorf("failed to parse JSON to Protobuf: %v", err)
+		}
+		if err := stream.SendMsg(reqMessage); err != nil {
+			// the error of the call is returned by RecvMsg
+			return nil
+		}
+		if !clientStreams {
+			break
+		}
+	}
+	return stream.CloseSend()
+}
+
+// requestData opens the data flag, a literal request or @file, @- for stdin.
+func requestData(cmd *cobra.Command) (io.ReadCloser, error) {
+	data, _ := cmd.Flags().GetString("data")
+	switch {
+	case data == "@-":
+		return io.NopCloser(cmd.InOrStdin()), nil
+	case strings.HasPrefix(data, "@"):
+		return os.Open(data[1:])
+	default:
+		return io.NopCloser(strings.NewReader(data)), nil
+	}
+}
+
+func printMetadata(title string, md metadata.MD) {
+	if len(md) == 0 {
+		return
+	}
+	keys := make([]string, 0, len(md))
+	for key := range md {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	log.Printf("%s:", title)
+	for _, key := range keys {
+		for _, value := range md[key] {
+			log.Printf("\t%s: %s", key, value)
+		}
+	}
+}
+
 func parseMethod(fullMethodName string) (string, string, error) {
 	parts := strings.Split(fullMethodName, "/")
 	if len(parts) != 2 {
@@ -412,15 +407,6 @@ func parseMethod(fullMethodName string) (string, string, error) {
 	return serviceName, methodName, nil
 }
 
-func newReflectionClient(ctx context.Context, conn *grpc.ClientConn, opts ...grpc.DialOption) (grpc.BidiStreamingClient[reflectionpb.ServerReflectionRequest, reflectionpb.ServerReflectionResponse], error) {
-	reflectionClient := reflectionpb.NewServerReflectionClient(conn)
-	clientStream, err := reflectionClient.ServerReflectionInfo(ctx)
-	if err != nil {
-		return nil, err
-	}
-	return clientStream, nil
-}
-
 func contextPathStreamInterceptor(contextPath string) grpc.StreamClientInterceptor {
 	return func(
 		ctx context.Context,
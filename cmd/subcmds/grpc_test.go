@@ -0,0 +1,139 @@
+package subcmds
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jhump/protoreflect/grpcreflect"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	testpb "google.golang.org/grpc/interop/grpc_testing"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/reflection"
+)
+
+type streamingService struct {
+	testpb.UnimplementedTestServiceServer
+}
+
+func (s *streamingService) StreamingOutputCall(req *testpb.StreamingOutputCallRequest, stream testpb.TestService_StreamingOutputCallServer) error {
+	stream.SetHeader(metadata.Pairs("x-header", "h1"))
+	for _, p := range req.GetResponseParameters() {
+		body := bytes.Repeat([]byte{'x'}, int(p.GetSize()))
+		if err := stream.Send(&testpb.StreamingOutputCallResponse{Payload: &testpb.Payload{Body: body}}); err != nil {
+			return err
+		}
+	}
+	stream.SetTrailer(metadata.Pairs("x-trailer", "t1"))
+	return nil
+}
+
+func (s *streamingService) StreamingInputCall(stream testpb.TestService_StreamingInputCallServer) error {
+	var size int32
+	for {
+		req, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return stream.SendAndClose(&testpb.StreamingInputCallResponse{AggregatedPayloadSize: size})
+		}
+		if err != nil {
+			return err
+		}
+		size += int32(len(req.GetPayload().GetBody()))
+	}
+}
+
+func (s *streamingService) FullDuplexCall(stream testpb.TestService_FullDuplexCallServer) error {
+	for {
+		req, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err = stream.Send(&testpb.StreamingOutputCallResponse{Payload: req.GetPayload()}); err != nil {
+			return err
+		}
+	}
+}
+
+func newStreamingServer(t *testing.T) string {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer()
+	testpb.RegisterTestServiceServer(server, &streamingService{})
+	reflection.Register(server)
+	go server.Serve(lis)
+	t.Cleanup(server.Stop)
+	return lis.Addr().String()
+}
+
+func TestMakeStreamingGrpcCall(t *testing.T) {
+	target := newStreamingServer(t)
+
+	tests := []struct {
+		name   string
+		method string
+		data   string
+		want   []string
+	}{
+		{
+			name:   "server streaming",
+			method: "grpc.testing.TestService/StreamingOutputCall",
+			data:   `{"responseParameters": [{"size": 1}, {"size": 2}]}`,
+			want: []string{"x-header: h1", `Response[1]: {"payload":{"body":"eA=="}}`,
+				`Response[2]: {"payload":{"body":"eHg="}}`, "x-trailer: t1", "Status: OK"},
+		},
+		{
+			name:   "client streaming",
+			method: "grpc.testing.TestService/StreamingInputCall",
+			data:   "{\"payload\": {\"body\": \"eA==\"}}\n{\"payload\": {\"body\": \"eHg=\"}}\n",
+			want:   []string{`Response[1]: {"aggregatedPayloadSize":3}`, "Status: OK"},
+		},
+		{
+			name:   "bidi streaming",
+			method: "grpc.testing.TestService/FullDuplexCall",
+			data:   "{\"payload\": {\"body\": \"eA==\"}}\n{\"payload\": {\"body\": \"eHg=\"}}",
+			want:   []string{`Response[1]: {"payload":{"body":"eA=="}}`, `Response[2]: {"payload":{"body":"eHg="}}`, "Status: OK"},
+		},
+	}
+
+	conn, err := grpc.NewClient(target, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	src := &reflectionSource{client: grpcreflect.NewClientAuto(ctx, conn)}
+
+	var out bytes.Buffer
+	log.SetOutput(&out)
+	defer log.SetOutput(io.Discard)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out.Reset()
+			if err := makeGenericGrpcCall(ctx, conn, src, tt.method, strings.NewReader(tt.data)); err != nil {
+				t.Fatal(err)
+			}
+			last := -1
+			for _, w := range tt.want {
+				i := strings.Index(out.String(), w)
+				if i <= last {
+					t.Fatalf("expected %q after the output before, but actually %s", w, out.String())
+				}
+				last = i
+			}
+		})
+	}
+}
@@ -0,0 +1,165 @@
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Duration converts Second to time.Duration.
+func (s *Second) Duration() time.Duration {
+	if s == nil {
+		return 0
+	}
+	return time.Second * time.Duration(s.Value)
+}
+
+// ToSession converts the Policy into a Session. Unset fields are taken from SessionDefault.
+func (p *Policy) ToSession() Session {
+	session := SessionDefault()
+	if p == nil {
+		return session
+	}
+
+	if t := p.Timeout; t != nil {
+		if t.Handshake != nil {
+			session.Timeouts.Handshake = t.Handshake.Duration()
+		}
+		if t.ConnectionIdle != nil {
+			session.Timeouts.ConnectionIdle = t.ConnectionIdle.Duration()
+		}
+		if t.UplinkOnly != nil {
+			session.Timeouts.UplinkOnly = t.UplinkOnly.Duration()
+		}
+		if t.DownlinkOnly != nil {
+			session.Timeouts.DownlinkOnly = t.DownlinkOnly.Duration()
+		}
+	}
+	if s := p.Stats; s != nil {
+		session.Stats.UserUplink = s.UserUplink
+		session.Stats.UserDownlink = s.UserDownlink
+	}
+	if b := p.Buffer; b != nil {
+		session.Buffer.PerConnection = b.Connection
+	}
+	return session
+}
+
+// ToSystem converts the SystemPolicy into a System.
+func (p *SystemPolicy) ToSystem() System {
+	system := System{
+		Buffer: defaultBufferPolicy(),
+	}
+	if p == nil || p.Stats == nil {
+		return system
+	}
+
+	system.Stats = SystemStats{
+		InboundUplink:    p.Stats.InboundUplink,
+		InboundDownlink:  p.Stats.InboundDownlink,
+		OutboundUplink:   p.Stats.OutboundUplink,
+		OutboundDownlink: p.Stats.OutboundDownlink,
+	}
+	return system
+}
+
+// LevelPolicyJSON is the JSON form of Policy, with timeouts in seconds and buffer size in kilobytes.
+type LevelPolicyJSON struct {
+	Handshake         *uint32 `json:"handshake"`
+	ConnectionIdle    *uint32 `json:"connIdle"`
+	UplinkOnly        *uint32 `json:"uplinkOnly"`
+	DownlinkOnly      *uint32 `json:"downlinkOnly"`
+	StatsUserUplink   bool    `json:"statsUserUplink"`
+	StatsUserDownlink bool    `json:"statsUserDownlink"`
+	BufferSize        *int32  `json:"bufferSize"`
+}
+
+// Build converts the JSON form into a Policy.
+func (c *LevelPolicyJSON) Build() *Policy {
+	p := &Policy{
+		Timeout: &Policy_Timeout{},
+		Stats: &Policy_Stats{
+			UserUplink:   c.StatsUserUplink,
+			UserDownlink: c.StatsUserDownlink,
+		},
+	}
+	if c.Handshake != nil {
+		p.Timeout.Handshake = &Second{Value: *c.Handshake}
+	}
+	if c.ConnectionIdle != nil {
+		p.Timeout.ConnectionIdle = &Second{Value: *c.ConnectionIdle}
+	}
+	if c.UplinkOnly != nil {
+		p.Timeout.UplinkOnly = &Second{Value: *c.UplinkOnly}
+	}
+	if c.DownlinkOnly != nil {
+		p.Timeout.DownlinkOnly = &Second{Value: *c.DownlinkOnly}
+	}
+	if c.BufferSize != nil {
+		size := *c.BufferSize
+		if size >= 0 {
+			size *= 1024
+		} else {
+			size = -1
+		}
+		p.Buffer = &Policy_Buffer{Connection: size}
+	}
+	return p
+}
+
+// SystemPolicyJSON is the JSON form of SystemPolicy.
+type SystemPolicyJSON struct {
+	StatsInboundUplink    bool `json:"statsInboundUplink"`
+	StatsInboundDownlink  bool `json:"statsInboundDownlink"`
+	StatsOutboundUplink   bool `json:"statsOutboundUplink"`
+	StatsOutboundDownlink bool `json:"statsOutboundDownlink"`
+}
+
+// Build converts the JSON form into a SystemPolicy.
+func (c *SystemPolicyJSON) Build() *SystemPolicy {
+	return &SystemPolicy{
+		Stats: &SystemPolicy_Stats{
+			InboundUplink:    c.StatsInboundUplink,
+			InboundDownlink:  c.StatsInboundDownlink,
+			OutboundUplink:   c.StatsOutboundUplink,
+			OutboundDownlink: c.StatsOutboundDownlink,
+		},
+	}
+}
+
+// ConfigJSON is the JSON form of Config, keyed by user level.
+type ConfigJSON struct {
+	Levels map[string]*LevelPolicyJSON `json:"levels"`
+	System *SystemPolicyJSON           `json:"system"`
+}
+
+// Build converts the JSON form into a Config.
+func (c *ConfigJSON) Build() (*Config, error) {
+	config := &Config{
+		Level: make(map[uint32]*Policy),
+	}
+	for key, value := range c.Levels {
+		level, err := strconv.ParseUint(key, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid policy level: %s, err: %v", key, err)
+		}
+		if value == nil {
+			continue
+		}
+		config.Level[uint32(level)] = value.Build()
+	}
+	if c.System != nil {
+		config.System = c.System.Build()
+	}
+	return config, nil
+}
+
+// ParseJSONConfig parses a Config from its JSON form.
+func ParseJSONConfig(data []byte) (*Config, error) {
+	var c ConfigJSON
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("failed to parse policy config, err: %v", err)
+	}
+	return c.Build()
+}
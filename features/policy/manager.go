@@ -0,0 +1,46 @@
+package policy
+
+// Instance is an implementation of Manager, whose Session policies are loaded from a Config.
+type Instance struct {
+	levels map[uint32]*Policy
+	system *SystemPolicy
+}
+
+// New creates a new Instance with the given config. A nil config makes every level use SessionDefault.
+func New(config *Config) *Instance {
+	m := &Instance{
+		levels: make(map[uint32]*Policy),
+	}
+	if config != nil {
+		for level, p := range config.Level {
+			m.levels[level] = p
+		}
+		m.system = config.System
+	}
+	return m
+}
+
+// Type implements lang.HasType.
+func (*Instance) Type() interface{} {
+	return ManagerType()
+}
+
+// ForLevel implements Manager.
+func (m *Instance) ForLevel(level uint32) Session {
+	return m.levels[level].ToSession()
+}
+
+// ForSystem implements Manager.
+func (m *Instance) ForSystem() System {
+	return m.system.ToSystem()
+}
+
+// Start implements lang.Runnable.
+func (*Instance) Start() error {
+	return nil
+}
+
+// Close implements lang.Closable.
+func (*Instance) Close() error {
+	return nil
+}
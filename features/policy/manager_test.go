@@ -0,0 +1,108 @@
+package policy_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	. "github.com/pysugar/wheels/features/policy"
+)
+
+func TestInstanceForLevel(t *testing.T) {
+	m := New(&Config{
+		Level: map[uint32]*Policy{
+			0: {
+				Timeout: &Policy_Timeout{
+					Handshake:    &Second{Value: 2},
+					DownlinkOnly: &Second{Value: 0},
+				},
+			},
+			1: {
+				Stats:  &Policy_Stats{UserUplink: true},
+				Buffer: &Policy_Buffer{Connection: 4096},
+			},
+		},
+	})
+
+	expected := SessionDefault()
+	expected.Timeouts.Handshake = time.Second * 2
+	expected.Timeouts.DownlinkOnly = 0
+	if r := cmp.Diff(m.ForLevel(0), expected); r != "" {
+		t.Error(r)
+	}
+
+	expected = SessionDefault()
+	expected.Stats.UserUplink = true
+	expected.Buffer.PerConnection = 4096
+	if r := cmp.Diff(m.ForLevel(1), expected); r != "" {
+		t.Error(r)
+	}
+
+	if r := cmp.Diff(m.ForLevel(2), SessionDefault()); r != "" {
+		t.Error(r)
+	}
+	if r := cmp.Diff(m.ForSystem().Stats, SystemStats{}); r != "" {
+		t.Error(r)
+	}
+}
+
+func TestParseJSONConfig(t *testing.T) {
+	config, err := ParseJSONConfig([]byte(`{
+		"levels": {
+			"0": {"handshake": 4, "connIdle": 300, "uplinkOnly": 2, "downlinkOnly": 5, "statsUserUplink": true, "bufferSize": 10},
+			"1": {"bufferSize": -5}
+		},
+		"system": {"statsInboundUplink": true, "statsOutboundDownlink": true}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := New(config)
+
+	if r := cmp.Diff(m.ForLevel(0), Session{
+		Timeouts: Timeout{
+			Handshake:      time.Second * 4,
+			ConnectionIdle: time.Second * 300,
+			UplinkOnly:     time.Second * 2,
+			DownlinkOnly:   time.Second * 5,
+		},
+		Stats:  Stats{UserUplink: true},
+		Buffer: Buffer{PerConnection: 10 * 1024},
+	}); r != "" {
+		t.Error(r)
+	}
+	if r := cmp.Diff(m.ForLevel(1).Buffer, Buffer{PerConnection: -1}); r != "" {
+		t.Error(r)
+	}
+	if r := cmp.Diff(m.ForSystem().Stats, SystemStats{InboundUplink: true, OutboundDownlink: true}); r != "" {
+		t.Error(r)
+	}
+
+	if _, err := ParseJSONConfig([]byte(`{"levels": {"admin": {}}}`)); err == nil {
+		t.Error("expected error for invalid level, but actually nil")
+	}
+}
+
+func TestSessionPolicyContext(t *testing.T) {
+	ctx := context.Background()
+	if r := cmp.Diff(SessionPolicyFromContext(ctx), SessionDefault()); r != "" {
+		t.Error(r)
+	}
+
+	session := SessionDefault()
+	session.Timeouts.ConnectionIdle = time.Second
+	session.Buffer.PerConnection = 1024
+	ctx = ContextWithSessionPolicy(ctx, session)
+	if r := cmp.Diff(SessionPolicyFromContext(ctx), session); r != "" {
+		t.Error(r)
+	}
+	if r := cmp.Diff(BufferPolicyFromContext(ctx), Buffer{PerConnection: 1024}); r != "" {
+		t.Error(r)
+	}
+
+	ctx = ContextWithBufferPolicy(ctx, Buffer{PerConnection: -1})
+	if r := cmp.Diff(BufferPolicyFromContext(ctx), Buffer{PerConnection: -1}); r != "" {
+		t.Error(r)
+	}
+}
@@ -130,16 +130,35 @@ func SessionDefault() Session {
 type policyKey int32
 
 const (
-	bufferPolicyKey policyKey = 0
+	bufferPolicyKey  policyKey = 0
+	sessionPolicyKey policyKey = 1
 )
 
+// ContextWithSessionPolicy returns a context carrying the Session policy p.
+func ContextWithSessionPolicy(ctx context.Context, p Session) context.Context {
+	return context.WithValue(ctx, sessionPolicyKey, p)
+}
+
+// SessionPolicyFromContext returns the Session policy in ctx, or SessionDefault if there is none.
+func SessionPolicyFromContext(ctx context.Context) Session {
+	pPolicy := ctx.Value(sessionPolicyKey)
+	if pPolicy == nil {
+		return SessionDefault()
+	}
+	return pPolicy.(Session)
+}
+
 func ContextWithBufferPolicy(ctx context.Context, p Buffer) context.Context {
 	return context.WithValue(ctx, bufferPolicyKey, p)
 }
 
+// BufferPolicyFromContext returns the Buffer policy in ctx. It falls back to the Buffer of the Session policy in ctx, then to the default one.
 func BufferPolicyFromContext(ctx context.Context) Buffer {
 	pPolicy := ctx.Value(bufferPolicyKey)
 	if pPolicy == nil {
+		if session := ctx.Value(sessionPolicyKey); session != nil {
+			return session.(Session).Buffer
+		}
 		return defaultBufferPolicy()
 	}
 	return pPolicy.(Buffer)
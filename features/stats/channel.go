@@ -0,0 +1,185 @@
+package stats
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ChannelConfig contains settings for a StatChannel.
+type ChannelConfig struct {
+	// Whether Publish blocks until every subscriber has received the message, or drops it for slow subscribers.
+	Blocking bool
+	// Maximum number of subscribers. 0 for unlimited.
+	SubscriberLimit int
+	// Size of the buffer of published messages, and of each subscriber channel.
+	BufferSize int
+}
+
+// DefaultChannelConfig returns the ChannelConfig used by Manager.RegisterChannel.
+func DefaultChannelConfig() *ChannelConfig {
+	return &ChannelConfig{
+		Blocking:        false,
+		SubscriberLimit: 16,
+		BufferSize:      64,
+	}
+}
+
+// StatChannel is an implementation of Channel which broadcasts each published message to all subscribers.
+type StatChannel struct {
+	access  sync.RWMutex
+	closed  chan struct{}
+	channel chan channelMessage
+
+	blocking        bool
+	subscriberLimit int
+	bufferSize      int
+	subscribers     []chan interface{}
+}
+
+type channelMessage struct {
+	context context.Context
+	message interface{}
+}
+
+// NewStatChannel creates a StatChannel. A nil config falls back to DefaultChannelConfig.
+func NewStatChannel(config *ChannelConfig) *StatChannel {
+	if config == nil {
+		config = DefaultChannelConfig()
+	}
+	return &StatChannel{
+		blocking:        config.Blocking,
+		subscriberLimit: config.SubscriberLimit,
+		bufferSize:      config.BufferSize,
+	}
+}
+
+// Subscribers implements Channel.
+func (c *StatChannel) Subscribers() []chan interface{} {
+	c.access.RLock()
+	defer c.access.RUnlock()
+
+	subscribers := make([]chan interface{}, len(c.subscribers))
+	copy(subscribers, c.subscribers)
+	return subscribers
+}
+
+// Subscribe implements Channel.
+func (c *StatChannel) Subscribe() (chan interface{}, error) {
+	c.access.Lock()
+	defer c.access.Unlock()
+
+	if c.subscriberLimit > 0 && len(c.subscribers) >= c.subscriberLimit {
+		return nil, errors.New("number of subscribers has reached limit")
+	}
+	subscriber := make(chan interface{}, c.bufferSize)
+	c.subscribers = append(c.subscribers, subscriber)
+	return subscriber, nil
+}
+
+// Unsubscribe implements Channel.
+func (c *StatChannel) Unsubscribe(subscriber chan interface{}) error {
+	c.access.Lock()
+	defer c.access.Unlock()
+
+	for i, s := range c.subscribers {
+		if s == subscriber {
+			// Copy to new memory block to prevent modifying original data
+			subscribers := make([]chan interface{}, len(c.subscribers)-1)
+			copy(subscribers[:i], c.subscribers[:i])
+			copy(subscribers[i:], c.subscribers[i+1:])
+			c.subscribers = subscribers
+		}
+	}
+	return nil
+}
+
+// Publish implements Channel. Messages are dropped while the channel is not running.
+func (c *StatChannel) Publish(ctx context.Context, msg interface{}) {
+	c.access.RLock()
+	closed, channel := c.closed, c.channel
+	c.access.RUnlock()
+	if channel == nil {
+		return
+	}
+
+	select {
+	case channel <- channelMessage{ctx, msg}:
+	case <-closed:
+	case <-ctx.Done():
+	default:
+		if !c.blocking {
+			return
+		}
+		select {
+		case channel <- channelMessage{ctx, msg}:
+		case <-closed:
+		case <-ctx.Done():
+		}
+	}
+}
+
+// publish delivers msg to every subscriber, waiting up to 100ms for a full subscriber in blocking mode.
+func (c *StatChannel) publish(msg channelMessage) {
+	for _, subscriber := range c.Subscribers() {
+		if !c.blocking {
+			select {
+			case subscriber <- msg.message:
+			default: // Drop the message for slow subscribers
+			}
+			continue
+		}
+
+		timer := time.NewTimer(100 * time.Millisecond)
+		select {
+		case subscriber <- msg.message:
+		case <-msg.context.Done():
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+}
+
+// Running returns whether the channel is running.
+func (c *StatChannel) Running() bool {
+	c.access.RLock()
+	defer c.access.RUnlock()
+
+	return c.channel != nil
+}
+
+// Start implements lang.Runnable.
+func (c *StatChannel) Start() error {
+	c.access.Lock()
+	defer c.access.Unlock()
+
+	if c.channel != nil {
+		return nil
+	}
+	c.closed = make(chan struct{})
+	c.channel = make(chan channelMessage, c.bufferSize)
+	go func(closed chan struct{}, channel chan channelMessage) {
+		for {
+			select {
+			case msg := <-channel:
+				c.publish(msg)
+			case <-closed:
+				return
+			}
+		}
+	}(c.closed, c.channel)
+	return nil
+}
+
+// Close implements lang.Closable.
+func (c *StatChannel) Close() error {
+	c.access.Lock()
+	defer c.access.Unlock()
+
+	if c.channel != nil {
+		close(c.closed)
+		c.channel = nil
+	}
+	return nil
+}
@@ -0,0 +1,23 @@
+package stats
+
+import "sync/atomic"
+
+// StatCounter is an implementation of Counter backed by an atomic int64.
+type StatCounter struct {
+	value int64
+}
+
+// Value implements Counter.
+func (c *StatCounter) Value() int64 {
+	return atomic.LoadInt64(&c.value)
+}
+
+// Set implements Counter.
+func (c *StatCounter) Set(newValue int64) int64 {
+	return atomic.SwapInt64(&c.value, newValue)
+}
+
+// Add implements Counter.
+func (c *StatCounter) Add(delta int64) int64 {
+	return atomic.AddInt64(&c.value, delta) - delta
+}
@@ -0,0 +1,204 @@
+package stats
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"sync"
+)
+
+// CounterSnapshot is the value of a counter at the time it is taken.
+type CounterSnapshot struct {
+	Name  string
+	Value int64
+}
+
+// StatManager is an in-process implementation of Manager.
+type StatManager struct {
+	access   sync.RWMutex
+	counters map[string]*StatCounter
+	channels map[string]*StatChannel
+	running  bool
+}
+
+// NewManager creates a StatManager.
+func NewManager() *StatManager {
+	return &StatManager{
+		counters: make(map[string]*StatCounter),
+		channels: make(map[string]*StatChannel),
+	}
+}
+
+// Type implements lang.HasType.
+func (*StatManager) Type() interface{} {
+	return ManagerType()
+}
+
+// RegisterCounter implements Manager.
+func (m *StatManager) RegisterCounter(name string) (Counter, error) {
+	if name == "" {
+		return nil, errors.New("empty counter name")
+	}
+
+	m.access.Lock()
+	defer m.access.Unlock()
+
+	if _, found := m.counters[name]; found {
+		return nil, fmt.Errorf("counter %s already registered", name)
+	}
+	log.Printf("create new counter %s", name)
+	c := new(StatCounter)
+	m.counters[name] = c
+	return c, nil
+}
+
+// UnregisterCounter implements Manager.
+func (m *StatManager) UnregisterCounter(name string) error {
+	m.access.Lock()
+	defer m.access.Unlock()
+
+	if _, found := m.counters[name]; found {
+		log.Printf("remove counter %s", name)
+		delete(m.counters, name)
+	}
+	return nil
+}
+
+// GetCounter implements Manager.
+func (m *StatManager) GetCounter(name string) Counter {
+	m.access.RLock()
+	defer m.access.RUnlock()
+
+	if c, found := m.counters[name]; found {
+		return c
+	}
+	return nil
+}
+
+// VisitCounters calls visitor function on all managed counters, until it returns false.
+func (m *StatManager) VisitCounters(visitor func(string, Counter) bool) {
+	m.access.RLock()
+	defer m.access.RUnlock()
+
+	for name, c := range m.counters {
+		if !visitor(name, c) {
+			break
+		}
+	}
+}
+
+// QueryCounters returns the counters whose name matches pattern, sorted by name. An empty pattern matches all counters.
+// If reset is true, each matched counter is reset to 0 and the snapshot holds its value right before the reset.
+func (m *StatManager) QueryCounters(pattern string, reset bool) ([]CounterSnapshot, error) {
+	matcher, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid counter pattern %s, err: %v", pattern, err)
+	}
+
+	var snapshots []CounterSnapshot
+	m.VisitCounters(func(name string, c Counter) bool {
+		if !matcher.MatchString(name) {
+			return true
+		}
+		var value int64
+		if reset {
+			value = c.Set(0)
+		} else {
+			value = c.Value()
+		}
+		snapshots = append(snapshots, CounterSnapshot{Name: name, Value: value})
+		return true
+	})
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].Name < snapshots[j].Name
+	})
+	return snapshots, nil
+}
+
+// Snapshot returns the values of all counters, sorted by name.
+func (m *StatManager) Snapshot() []CounterSnapshot {
+	snapshots, _ := m.QueryCounters("", false)
+	return snapshots
+}
+
+// RegisterChannel implements Manager. The channel is created with DefaultChannelConfig.
+func (m *StatManager) RegisterChannel(name string) (Channel, error) {
+	return m.RegisterChannelWithConfig(name, DefaultChannelConfig())
+}
+
+// RegisterChannelWithConfig registers a new channel created with config.
+func (m *StatManager) RegisterChannelWithConfig(name string, config *ChannelConfig) (Channel, error) {
+	if name == "" {
+		return nil, errors.New("empty channel name")
+	}
+
+	m.access.Lock()
+	defer m.access.Unlock()
+
+	if _, found := m.channels[name]; found {
+		return nil, fmt.Errorf("channel %s already registered", name)
+	}
+	log.Printf("create new channel %s", name)
+	c := NewStatChannel(config)
+	m.channels[name] = c
+	if m.running {
+		return c, c.Start()
+	}
+	return c, nil
+}
+
+// UnregisterChannel implements Manager.
+func (m *StatManager) UnregisterChannel(name string) error {
+	m.access.Lock()
+	defer m.access.Unlock()
+
+	if c, found := m.channels[name]; found {
+		log.Printf("remove channel %s", name)
+		delete(m.channels, name)
+		return c.Close()
+	}
+	return nil
+}
+
+// GetChannel implements Manager.
+func (m *StatManager) GetChannel(name string) Channel {
+	m.access.RLock()
+	defer m.access.RUnlock()
+
+	if c, found := m.channels[name]; found {
+		return c
+	}
+	return nil
+}
+
+// Start implements lang.Runnable. All registered channels are started.
+func (m *StatManager) Start() error {
+	m.access.Lock()
+	defer m.access.Unlock()
+
+	m.running = true
+	var errs []error
+	for _, c := range m.channels {
+		if err := c.Start(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Close implements lang.Closable. All registered channels are closed.
+func (m *StatManager) Close() error {
+	m.access.Lock()
+	defer m.access.Unlock()
+
+	m.running = false
+	var errs []error
+	for name, c := range m.channels {
+		if err := c.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("failed to close channel %s, err: %v", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,184 @@
+package stats_test
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pysugar/wheels/buf"
+	. "github.com/pysugar/wheels/features/stats"
+)
+
+func TestStatCounter(t *testing.T) {
+	c := new(StatCounter)
+	if v := c.Add(10); v != 0 {
+		t.Error("expected previous value 0, but actually ", v)
+	}
+	if v := c.Set(3); v != 10 {
+		t.Error("expected previous value 10, but actually ", v)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.Add(1)
+		}()
+	}
+	wg.Wait()
+	if v := c.Value(); v != 103 {
+		t.Error("expected 103, but actually ", v)
+	}
+}
+
+func TestManagerCounters(t *testing.T) {
+	m := NewManager()
+	if _, err := m.RegisterCounter(""); err == nil {
+		t.Error("expected error for empty name, but actually nil")
+	}
+	up, err := m.RegisterCounter("user>>>a>>>traffic>>>uplink")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.RegisterCounter("user>>>a>>>traffic>>>uplink"); err == nil {
+		t.Error("expected error for duplicated name, but actually nil")
+	}
+	down, err := GetOrRegisterCounter(m, "user>>>a>>>traffic>>>downlink")
+	if err != nil {
+		t.Fatal(err)
+	}
+	inbound, err := GetOrRegisterCounter(m, "inbound>>>api>>>traffic>>>uplink")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := buf.Copy(buf.NewReader(strings.NewReader("hello")), buf.Discard, buf.AddToStatCounter(up)); err != nil {
+		t.Fatal(err)
+	}
+	down.Add(7)
+	inbound.Add(1)
+
+	snapshots, err := m.QueryCounters("^user>>>a>>>", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := cmp.Diff(snapshots, []CounterSnapshot{
+		{Name: "user>>>a>>>traffic>>>downlink", Value: 7},
+		{Name: "user>>>a>>>traffic>>>uplink", Value: 5},
+	}); r != "" {
+		t.Error(r)
+	}
+	if r := cmp.Diff(m.Snapshot(), []CounterSnapshot{
+		{Name: "inbound>>>api>>>traffic>>>uplink", Value: 1},
+		{Name: "user>>>a>>>traffic>>>downlink", Value: 0},
+		{Name: "user>>>a>>>traffic>>>uplink", Value: 0},
+	}); r != "" {
+		t.Error(r)
+	}
+
+	if _, err := m.QueryCounters("(", false); err == nil {
+		t.Error("expected error for invalid pattern, but actually nil")
+	}
+
+	if err := m.UnregisterCounter("inbound>>>api>>>traffic>>>uplink"); err != nil {
+		t.Fatal(err)
+	}
+	if c := m.GetCounter("inbound>>>api>>>traffic>>>uplink"); c != nil {
+		t.Error("expected nil counter, but actually ", c)
+	}
+}
+
+func receive(t *testing.T, sub chan interface{}) interface{} {
+	t.Helper()
+	select {
+	case msg := <-sub:
+		return msg
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for message")
+		return nil
+	}
+}
+
+func TestManagerChannel(t *testing.T) {
+	m := NewManager()
+	if err := m.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	c, err := GetOrRegisterChannel(m, "blocking")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub1, err := SubscribeRunnableChannel(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub2, err := c.Subscribe()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		c.Publish(context.Background(), i)
+	}
+	for i := 0; i < 3; i++ {
+		if msg := receive(t, sub1); msg != i {
+			t.Error("sub1 expected ", i, " but actually ", msg)
+		}
+		if msg := receive(t, sub2); msg != i {
+			t.Error("sub2 expected ", i, " but actually ", msg)
+		}
+	}
+
+	if err := UnsubscribeClosableChannel(c, sub1); err != nil {
+		t.Fatal(err)
+	}
+	if err := UnsubscribeClosableChannel(c, sub2); err != nil {
+		t.Fatal(err)
+	}
+	if c.(*StatChannel).Running() {
+		t.Error("expected channel closed after the last subscriber leaves")
+	}
+}
+
+func TestChannelPolicy(t *testing.T) {
+	for name, blocking := range map[string]bool{"blocking": true, "nonblocking": false} {
+		t.Run(name, func(t *testing.T) {
+			c := NewStatChannel(&ChannelConfig{Blocking: blocking, SubscriberLimit: 1, BufferSize: 1})
+			sub, err := SubscribeRunnableChannel(c)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer c.Close()
+			if _, err := c.Subscribe(); err == nil {
+				t.Error("expected error for subscriber limit, but actually nil")
+			}
+
+			// publish faster than the subscriber consumes, only blocking publish keeps every message
+			go func() {
+				for i := 0; i < 8; i++ {
+					c.Publish(context.Background(), i)
+				}
+			}()
+			received := 0
+		loop:
+			for {
+				select {
+				case <-sub:
+					received++
+					time.Sleep(20 * time.Millisecond)
+				case <-time.After(300 * time.Millisecond):
+					break loop
+				}
+			}
+			if blocking && received != 8 || !blocking && received == 8 {
+				t.Error("unexpected number of received messages: ", received)
+			}
+		})
+	}
+}
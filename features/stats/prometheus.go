@@ -0,0 +1,135 @@
+package stats
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+const (
+	prometheusContentType  = "text/plain; version=0.0.4; charset=utf-8"
+	openMetricsContentType = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+	defaultNamespace       = "wheels"
+)
+
+// PrometheusHandler is an http.Handler which renders all counters of a StatManager in Prometheus text exposition format,
+// or in OpenMetrics format when the scraper accepts application/openmetrics-text.
+//
+// Counter names in the form of "<dimension>>>><target>>>><kind>>>><direction>", e.g. "inbound>>>api>>>traffic>>>uplink",
+// become the metric "<namespace>_<kind>_<direction>_total" with the labels dimension and target. Traffic counters get
+// the "_bytes" unit. Any other name is used as the metric name with unsupported characters replaced by '_'.
+type PrometheusHandler struct {
+	Manager *StatManager
+	// Prefix of metric names. "wheels" if empty.
+	Namespace string
+	// Whether counters are reset to 0 after each scrape.
+	ResetOnRead bool
+}
+
+// ServeHTTP implements http.Handler.
+func (h *PrometheusHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	snapshots, err := h.Manager.QueryCounters("", h.ResetOnRead)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	openMetrics := strings.Contains(r.Header.Get("Accept"), "application/openmetrics-text")
+	if openMetrics {
+		w.Header().Set("Content-Type", openMetricsContentType)
+	} else {
+		w.Header().Set("Content-Type", prometheusContentType)
+	}
+	if err := WritePrometheus(w, snapshots, h.Namespace, openMetrics); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+type metricLabel struct {
+	name  string
+	value string
+}
+
+type metricSample struct {
+	family string
+	labels []metricLabel
+	value  int64
+}
+
+// WritePrometheus writes snapshots to w in Prometheus text exposition format, or in OpenMetrics format if openMetrics is true.
+func WritePrometheus(w io.Writer, snapshots []CounterSnapshot, namespace string, openMetrics bool) error {
+	if namespace == "" {
+		namespace = defaultNamespace
+	}
+
+	samples := make([]metricSample, 0, len(snapshots))
+	for _, s := range snapshots {
+		family, labels := metricName(namespace, s.Name)
+		samples = append(samples, metricSample{family: family, labels: labels, value: s.Value})
+	}
+	sort.SliceStable(samples, func(i, j int) bool {
+		return samples[i].family < samples[j].family
+	})
+
+	bw := bufio.NewWriter(w)
+	for i, s := range samples {
+		if i == 0 || samples[i-1].family != s.family {
+			typeName := s.family + "_total"
+			if openMetrics {
+				typeName = s.family
+			}
+			fmt.Fprintf(bw, "# TYPE %s counter\n", typeName)
+		}
+		bw.WriteString(s.family)
+		bw.WriteString("_total")
+		if len(s.labels) > 0 {
+			bw.WriteByte('{')
+			for j, l := range s.labels {
+				if j > 0 {
+					bw.WriteByte(',')
+				}
+				fmt.Fprintf(bw, "%s=\"%s\"", l.name, escapeLabelValue(l.value))
+			}
+			bw.WriteByte('}')
+		}
+		fmt.Fprintf(bw, " %d\n", s.value)
+	}
+	if openMetrics {
+		bw.WriteString("# EOF\n")
+	}
+	return bw.Flush()
+}
+
+func metricName(namespace, name string) (string, []metricLabel) {
+	parts := strings.Split(name, ">>>")
+	if len(parts) != 4 {
+		return sanitizeMetricName(namespace + "_" + strings.Join(parts, "_")), nil
+	}
+
+	family := namespace + "_" + parts[2] + "_" + parts[3]
+	if parts[2] == "traffic" {
+		family += "_bytes"
+	}
+	return sanitizeMetricName(family), []metricLabel{
+		{name: "dimension", value: parts[0]},
+		{name: "target", value: parts[1]},
+	}
+}
+
+func sanitizeMetricName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == ':' {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+var labelValueReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabelValue(value string) string {
+	return labelValueReplacer.Replace(value)
+}
@@ -0,0 +1,101 @@
+package stats_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	. "github.com/pysugar/wheels/features/stats"
+)
+
+func newTestManager(t *testing.T) *StatManager {
+	t.Helper()
+	m := NewManager()
+	for name, value := range map[string]int64{
+		"inbound>>>api>>>traffic>>>uplink":     10,
+		"inbound>>>api>>>traffic>>>downlink":   20,
+		"user>>>a@b.com>>>traffic>>>uplink":    30,
+		"outbound>>>direct>>>traffic>>>uplink": 40,
+		"dns-queries":                          5,
+	} {
+		c, err := m.RegisterCounter(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.Set(value)
+	}
+	return m
+}
+
+func scrape(t *testing.T, handler http.Handler, accept string) (string, string) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	body, _ := io.ReadAll(rec.Body)
+	return rec.Header().Get("Content-Type"), string(body)
+}
+
+func TestPrometheusHandler(t *testing.T) {
+	handler := &PrometheusHandler{Manager: newTestManager(t)}
+
+	contentType, body := scrape(t, handler, "")
+	if r := cmp.Diff(contentType, "text/plain; version=0.0.4; charset=utf-8"); r != "" {
+		t.Error(r)
+	}
+	if r := cmp.Diff(body, `# TYPE wheels_dns_queries_total counter
+wheels_dns_queries_total 5
+# TYPE wheels_traffic_downlink_bytes_total counter
+wheels_traffic_downlink_bytes_total{dimension="inbound",target="api"} 20
+# TYPE wheels_traffic_uplink_bytes_total counter
+wheels_traffic_uplink_bytes_total{dimension="inbound",target="api"} 10
+wheels_traffic_uplink_bytes_total{dimension="outbound",target="direct"} 40
+wheels_traffic_uplink_bytes_total{dimension="user",target="a@b.com"} 30
+`); r != "" {
+		t.Error(r)
+	}
+
+	// counters are kept without ResetOnRead
+	if _, again := scrape(t, handler, ""); again != body {
+		t.Error("unexpected body of second scrape: ", again)
+	}
+}
+
+func TestPrometheusHandlerOpenMetrics(t *testing.T) {
+	handler := &PrometheusHandler{Manager: newTestManager(t), Namespace: "xray", ResetOnRead: true}
+
+	contentType, body := scrape(t, handler, "application/openmetrics-text;version=1.0.0,text/plain;q=0.5")
+	if r := cmp.Diff(contentType, "application/openmetrics-text; version=1.0.0; charset=utf-8"); r != "" {
+		t.Error(r)
+	}
+	if r := cmp.Diff(body, `# TYPE xray_dns_queries counter
+xray_dns_queries_total 5
+# TYPE xray_traffic_downlink_bytes counter
+xray_traffic_downlink_bytes_total{dimension="inbound",target="api"} 20
+# TYPE xray_traffic_uplink_bytes counter
+xray_traffic_uplink_bytes_total{dimension="inbound",target="api"} 10
+xray_traffic_uplink_bytes_total{dimension="outbound",target="direct"} 40
+xray_traffic_uplink_bytes_total{dimension="user",target="a@b.com"} 30
+# EOF
+`); r != "" {
+		t.Error(r)
+	}
+
+	_, body = scrape(t, handler, "")
+	if r := cmp.Diff(body, `# TYPE xray_dns_queries_total counter
+xray_dns_queries_total 0
+# TYPE xray_traffic_downlink_bytes_total counter
+xray_traffic_downlink_bytes_total{dimension="inbound",target="api"} 0
+# TYPE xray_traffic_uplink_bytes_total counter
+xray_traffic_uplink_bytes_total{dimension="inbound",target="api"} 0
+xray_traffic_uplink_bytes_total{dimension="outbound",target="direct"} 0
+xray_traffic_uplink_bytes_total{dimension="user",target="a@b.com"} 0
+`); r != "" {
+		t.Error(r)
+	}
+}
@@ -3,6 +3,7 @@ module github.com/pysugar/wheels
 go 1.21
 
 require (
+	github.com/andybalholm/brotli v1.1.1
 	github.com/golang/mock v1.6.0
 	github.com/google/go-cmp v0.6.0
 	github.com/gorilla/websocket v1.5.3
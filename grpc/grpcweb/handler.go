@@ -0,0 +1,170 @@
+package grpcweb
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"google.golang.org/grpc"
+)
+
+// hopHeaders are the connection specific headers of HTTP/1.1, which an HTTP/2 request must not carry.
+var hopHeaders = []string{
+	"Connection",
+	"Keep-Alive",
+	"Proxy-Connection",
+	"Transfer-Encoding",
+	"Upgrade",
+	"Te",
+	"Content-Length",
+}
+
+// Handler bridges gRPC-Web calls to a gRPC server. It accepts the content types
+//
+//	application/grpc-web, application/grpc-web+proto       length-prefixed messages
+//	application/grpc-web-text, application/grpc-web-text+proto   the same in base64
+//
+// over HTTP/1.1 or HTTP/2, and answers in kind, with the trailers encoded in the body as the last frame.
+// Native gRPC calls, application/grpc over HTTP/2, are passed to the server as they are.
+type Handler struct {
+	backend http.Handler
+	hopts   *handlerOptions
+}
+
+// NewHandler returns a Handler calling server in process, through its ServeHTTP.
+func NewHandler(server *grpc.Server, opts ...Option) *Handler {
+	return newHandler(server, opts)
+}
+
+// NewTargetHandler returns a Handler calling the gRPC server at target, host:port, over h2c, or over TLS
+// with WithTargetTLS.
+func NewTargetHandler(target string, opts ...Option) (*Handler, error) {
+	h := newHandler(nil, opts)
+	backend, err := newTargetBackend(target, h.hopts.tlsConfig)
+	if err != nil {
+		return nil, err
+	}
+	h.backend = backend
+	return h, nil
+}
+
+func newHandler(backend http.Handler, opts []Option) *Handler {
+	hopts := defaultHandlerOptions()
+	for _, o := range opts {
+		o(hopts)
+	}
+	return &Handler{backend: backend, hopts: hopts}
+}
+
+// IsGrpcWebRequest reports whether r is a gRPC-Web call, to route it to a Handler.
+func IsGrpcWebRequest(r *http.Request) bool {
+	_, _, _, ok := parseContentType(r.Header.Get("Content-Type"))
+	return ok
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+		h.preflight(w, r)
+		return
+	}
+
+	contentType := r.Header.Get("Content-Type")
+	webContentType, grpcContentType, text, ok := parseContentType(contentType)
+	if !ok {
+		if r.ProtoMajor == 2 && strings.HasPrefix(contentType, "application/grpc") {
+			h.backend.ServeHTTP(w, r)
+			return
+		}
+		http.Error(w, fmt.Sprintf("unsupported content-type %q", contentType), http.StatusUnsupportedMediaType)
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, fmt.Sprintf("invalid gRPC-Web request method %q", r.Method), http.StatusMethodNotAllowed)
+		return
+	}
+	if !h.allowOrigin(w.Header(), r) {
+		http.Error(w, fmt.Sprintf("origin %q is not allowed", r.Header.Get("Origin")), http.StatusForbidden)
+		return
+	}
+	// the server may answer while the client is still sending, as a stream of HTTP/1.1 would
+	_ = http.NewResponseController(w).EnableFullDuplex()
+
+	req := r.Clone(r.Context())
+	req.ProtoMajor, req.ProtoMinor, req.Proto = 2, 0, "HTTP/2.0"
+	for _, k := range hopHeaders {
+		req.Header.Del(k)
+	}
+	req.Header.Set("Content-Type", grpcContentType)
+	req.Header.Set("Te", "trailers")
+	req.ContentLength = -1
+	if text {
+		req.Body = &textBody{r: bufio.NewReader(r.Body), body: r.Body}
+	}
+
+	ww := newResponseWriter(w, webContentType, text)
+	h.backend.ServeHTTP(ww, req)
+	ww.finish()
+}
+
+// preflight answers the CORS preflight request of a browser, which asks whether the call may be made.
+func (h *Handler) preflight(w http.ResponseWriter, r *http.Request) {
+	header := w.Header()
+	if !h.allowOrigin(header, r) {
+		http.Error(w, fmt.Sprintf("origin %q is not allowed", r.Header.Get("Origin")), http.StatusForbidden)
+		return
+	}
+	header.Set("Access-Control-Allow-Methods", "POST, OPTIONS")
+	if requested := r.Header.Get("Access-Control-Request-Headers"); requested != "" {
+		header.Set("Access-Control-Allow-Headers", requested)
+	}
+	header.Set("Access-Control-Max-Age", strconv.Itoa(int(h.hopts.maxAge.Seconds())))
+	header.Add("Vary", "Access-Control-Request-Headers")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// allowOrigin reports whether the browser may make the call r, and sets the CORS headers of the response
+// into header if it is made across origins. Origins listed by WithAllowedOrigins may call with credentials,
+// any other origin only without them if "*" is listed.
+func (h *Handler) allowOrigin(header http.Header, r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	header.Add("Vary", "Origin")
+	if origin == "" || sameOrigin(origin, r.Host) {
+		return true
+	}
+	switch {
+	case h.hopts.allowedOrigins[origin]:
+		header.Set("Access-Control-Allow-Origin", origin)
+		header.Set("Access-Control-Allow-Credentials", "true")
+	case h.hopts.allowedOrigins["*"]:
+		header.Set("Access-Control-Allow-Origin", "*")
+	default:
+		return false
+	}
+	return true
+}
+
+// sameOrigin reports whether origin, scheme://host[:port], is the host the request was sent to.
+func sameOrigin(origin, host string) bool {
+	u, err := url.Parse(origin)
+	return err == nil && u.Host != "" && strings.EqualFold(u.Host, host)
+}
+
+// parseContentType splits a gRPC-Web content type, application/grpc-web[-text][+codec], into itself without
+// parameters, the gRPC content type of the server, application/grpc[+codec], and whether the body is base64.
+func parseContentType(contentType string) (webContentType, grpcContentType string, text, ok bool) {
+	webContentType, _, _ = strings.Cut(contentType, ";")
+	webContentType = strings.ToLower(strings.TrimSpace(webContentType))
+	rest, found := strings.CutPrefix(webContentType, "application/grpc-web")
+	if !found {
+		return "", "", false, false
+	}
+	rest, text = strings.CutPrefix(rest, "-text")
+	if rest != "" && rest[0] != '+' {
+		return "", "", false, false
+	}
+	return webContentType, "application/grpc" + rest, text, true
+}
@@ -0,0 +1,326 @@
+package grpcweb
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"strings"
+	"testing"
+
+	"github.com/pysugar/wheels/binproto/http2"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	testpb "google.golang.org/grpc/interop/grpc_testing"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+type testService struct {
+	testpb.UnimplementedTestServiceServer
+}
+
+func (s *testService) UnaryCall(ctx context.Context, req *testpb.SimpleRequest) (*testpb.SimpleResponse, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	_ = grpc.SetHeader(ctx, metadata.Pairs("x-header", strings.Join(md.Get("x-token"), ",")))
+	_ = grpc.SetTrailer(ctx, metadata.Pairs("x-trailer", "t1"))
+	if string(req.GetPayload().GetBody()) == "fail" {
+		return nil, status.Error(codes.InvalidArgument, "bad payload: fail")
+	}
+	return &testpb.SimpleResponse{Payload: req.GetPayload()}, nil
+}
+
+func (s *testService) StreamingOutputCall(req *testpb.StreamingOutputCallRequest, stream testpb.TestService_StreamingOutputCallServer) error {
+	for _, p := range req.GetResponseParameters() {
+		body := bytes.Repeat([]byte{'x'}, int(p.GetSize()))
+		if err := stream.Send(&testpb.StreamingOutputCallResponse{Payload: &testpb.Payload{Body: body}}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type webResponse struct {
+	*http.Response
+	messages [][]byte
+	trailers http.Header
+}
+
+// call makes a gRPC-Web call of method with req, and splits the body of the response into its messages
+// and trailers.
+func call(t *testing.T, url, method, contentType string, req proto.Message, header http.Header) *webResponse {
+	t.Helper()
+	body, err := http2.EncodeGrpcFrame(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := strings.HasPrefix(contentType, "application/grpc-web-text")
+	if text {
+		body = []byte(base64.StdEncoding.EncodeToString(body))
+	}
+	httpReq, err := http.NewRequest(http.MethodPost, url+method, bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, vv := range header {
+		httpReq.Header[k] = vv
+	}
+	httpReq.Header.Set("Content-Type", contentType)
+	httpReq.Header.Set("X-Grpc-Web", "1")
+
+	resp, err := http.DefaultClient.Do(httpReq)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var r io.Reader = resp.Body
+	if text {
+		r = &textBody{r: bufio.NewReader(resp.Body), body: resp.Body}
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wr := &webResponse{Response: resp}
+	if _, _, _, ok := parseContentType(resp.Header.Get("Content-Type")); !ok {
+		return wr
+	}
+	for len(data) >= 5 {
+		flag, length := data[0], binary.BigEndian.Uint32(data[1:5])
+		if uint32(len(data)-5) < length {
+			t.Fatalf("truncated frame of %d bytes, got %d", length, len(data)-5)
+		}
+		payload := data[5 : 5+length]
+		data = data[5+length:]
+		if flag&trailerFlag == 0 {
+			wr.messages = append(wr.messages, payload)
+			continue
+		}
+		tp := textproto.NewReader(bufio.NewReader(io.MultiReader(bytes.NewReader(payload), strings.NewReader("\r\n"))))
+		trailers, err := tp.ReadMIMEHeader()
+		if err != nil {
+			t.Fatal(err)
+		}
+		wr.trailers = http.Header(trailers)
+	}
+	if len(data) != 0 {
+		t.Fatalf("unexpected %d bytes after the frames", len(data))
+	}
+	return wr
+}
+
+func TestHandler(t *testing.T) {
+	server := grpc.NewServer()
+	testpb.RegisterTestServiceServer(server, &testService{})
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go server.Serve(lis)
+	defer server.Stop()
+
+	targetHandler, err := NewTargetHandler(lis.Addr().String(), WithAllowedOrigins("https://allowed.example"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	handlers := map[string]http.Handler{
+		"server": NewHandler(server, WithAllowedOrigins("https://allowed.example")),
+		"target": targetHandler,
+	}
+	for name, handler := range handlers {
+		t.Run(name, func(t *testing.T) {
+			hs := httptest.NewServer(handler)
+			defer hs.Close()
+			testHandler(t, hs.URL)
+		})
+	}
+}
+
+func testHandler(t *testing.T, url string) {
+	const unary, streaming = "/grpc.testing.TestService/UnaryCall", "/grpc.testing.TestService/StreamingOutputCall"
+	origin := http.Header{"Origin": {"https://allowed.example"}, "X-Token": {"abc"}}
+
+	for _, contentType := range []string{"application/grpc-web", "application/grpc-web+proto", "application/grpc-web-text"} {
+		resp := call(t, url, unary, contentType, &testpb.SimpleRequest{Payload: &testpb.Payload{Body: []byte("hello")}}, origin)
+		if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != contentType {
+			t.Fatalf("%s: expected 200 of %s, but actually %d of %s", contentType, contentType, resp.StatusCode, resp.Header.Get("Content-Type"))
+		}
+		if len(resp.messages) != 1 {
+			t.Fatalf("%s: expected 1 message, but actually %d", contentType, len(resp.messages))
+		}
+		var reply testpb.SimpleResponse
+		if err := proto.Unmarshal(resp.messages[0], &reply); err != nil || string(reply.GetPayload().GetBody()) != "hello" {
+			t.Errorf("%s: expected hello, but actually %v, err: %v", contentType, &reply, err)
+		}
+		if resp.Header.Get("X-Header") != "abc" {
+			t.Errorf("%s: expected the metadata echoed in the header, but actually %v", contentType, resp.Header)
+		}
+		if resp.trailers.Get("grpc-status") != "0" || resp.trailers.Get("x-trailer") != "t1" {
+			t.Errorf("%s: expected the status and trailer in the body, but actually %v", contentType, resp.trailers)
+		}
+		if resp.Header.Get("Access-Control-Allow-Origin") != "https://allowed.example" ||
+			!strings.Contains(resp.Header.Get("Access-Control-Expose-Headers"), "x-header") {
+			t.Errorf("%s: expected the CORS headers, but actually %v", contentType, resp.Header)
+		}
+	}
+
+	resp := call(t, url, unary, "application/grpc-web", &testpb.SimpleRequest{Payload: &testpb.Payload{Body: []byte("fail")}}, nil)
+	if len(resp.messages) != 0 || resp.trailers.Get("grpc-status") != "3" || resp.trailers.Get("grpc-message") != "bad payload: fail" {
+		t.Errorf("expected InvalidArgument, but actually %v", resp.trailers)
+	}
+
+	resp = call(t, url, streaming, "application/grpc-web-text", &testpb.StreamingOutputCallRequest{
+		ResponseParameters: []*testpb.ResponseParameters{{Size: 1}, {Size: 2}, {Size: 3}},
+	}, nil)
+	if len(resp.messages) != 3 || resp.trailers.Get("grpc-status") != "0" {
+		t.Errorf("expected 3 messages and OK, but actually %d and %v", len(resp.messages), resp.trailers)
+	}
+	for i, m := range resp.messages {
+		var reply testpb.StreamingOutputCallResponse
+		if err := proto.Unmarshal(m, &reply); err != nil || len(reply.GetPayload().GetBody()) != i+1 {
+			t.Errorf("expected message %d of %d bytes, but actually %v, err: %v", i, i+1, &reply, err)
+		}
+	}
+
+	resp = call(t, url, "/grpc.testing.TestService/Missing", "application/grpc-web", &testpb.Empty{}, nil)
+	if status := resp.trailers.Get("grpc-status") + resp.Header.Get("Grpc-Status"); status != "12" {
+		t.Errorf("expected Unimplemented, but actually %v %v", resp.Header, resp.trailers)
+	}
+
+	resp = call(t, url, unary, "application/grpc-web", &testpb.SimpleRequest{},
+		http.Header{"Origin": {"https://denied.example"}})
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("expected 403 for the denied origin, but actually %d", resp.StatusCode)
+	}
+
+	resp = call(t, url, unary, "application/json", &testpb.SimpleRequest{}, nil)
+	if resp.StatusCode != http.StatusUnsupportedMediaType {
+		t.Errorf("expected 415, but actually %d", resp.StatusCode)
+	}
+
+	preflight, err := http.NewRequest(http.MethodOptions, url+unary, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	preflight.Header.Set("Origin", "https://allowed.example")
+	preflight.Header.Set("Access-Control-Request-Method", "POST")
+	preflight.Header.Set("Access-Control-Request-Headers", "content-type,x-grpc-web,x-token")
+	preflightResp, err := http.DefaultClient.Do(preflight)
+	if err != nil {
+		t.Fatal(err)
+	}
+	preflightResp.Body.Close()
+	if preflightResp.StatusCode != http.StatusNoContent ||
+		preflightResp.Header.Get("Access-Control-Allow-Origin") != "https://allowed.example" ||
+		preflightResp.Header.Get("Access-Control-Allow-Headers") != "content-type,x-grpc-web,x-token" ||
+		preflightResp.Header.Get("Access-Control-Max-Age") != "600" {
+		t.Errorf("expected the preflight allowed, but actually %d %v", preflightResp.StatusCode, preflightResp.Header)
+	}
+}
+
+func TestCORS(t *testing.T) {
+	const unary = "/grpc.testing.TestService/UnaryCall"
+	server := grpc.NewServer()
+	testpb.RegisterTestServiceServer(server, &testService{})
+
+	tests := []struct {
+		name            string
+		opts            []Option
+		origin          string
+		sameOrigin      bool
+		wantStatus      int
+		wantAllowOrigin string
+		wantCredentials bool
+	}{
+		{name: "default same origin", sameOrigin: true, wantStatus: http.StatusOK},
+		{name: "default cross origin", origin: "https://other.example", wantStatus: http.StatusForbidden},
+		{name: "any origin", opts: []Option{WithAllowedOrigins("*")}, origin: "https://other.example",
+			wantStatus: http.StatusOK, wantAllowOrigin: "*"},
+		{name: "listed origin", opts: []Option{WithAllowedOrigins("*", "https://app.example")}, origin: "https://app.example",
+			wantStatus: http.StatusOK, wantAllowOrigin: "https://app.example", wantCredentials: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hs := httptest.NewServer(NewHandler(server, tt.opts...))
+			defer hs.Close()
+			origin := tt.origin
+			if tt.sameOrigin {
+				origin = hs.URL
+			}
+
+			preflight, err := http.NewRequest(http.MethodOptions, hs.URL+unary, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			preflight.Header.Set("Origin", origin)
+			preflight.Header.Set("Access-Control-Request-Method", "POST")
+			preflightResp, err := http.DefaultClient.Do(preflight)
+			if err != nil {
+				t.Fatal(err)
+			}
+			preflightResp.Body.Close()
+			resp := call(t, hs.URL, unary, "application/grpc-web", &testpb.SimpleRequest{}, http.Header{"Origin": {origin}})
+
+			for _, r := range []*http.Response{preflightResp, resp.Response} {
+				wantStatus := tt.wantStatus
+				if r == preflightResp && wantStatus == http.StatusOK {
+					wantStatus = http.StatusNoContent
+				}
+				if r.StatusCode != wantStatus {
+					t.Errorf("%s: expected %d, but actually %d", r.Request.Method, wantStatus, r.StatusCode)
+				}
+				if got := r.Header.Get("Access-Control-Allow-Origin"); got != tt.wantAllowOrigin {
+					t.Errorf("%s: expected Access-Control-Allow-Origin %q, but actually %q", r.Request.Method, tt.wantAllowOrigin, got)
+				}
+				if got := r.Header.Get("Access-Control-Allow-Credentials") == "true"; got != tt.wantCredentials {
+					t.Errorf("%s: expected credentials %v, but actually %v", r.Request.Method, tt.wantCredentials, got)
+				}
+			}
+		})
+	}
+}
+
+func TestNewTargetHandler(t *testing.T) {
+	if _, err := NewTargetHandler("localhost"); err == nil {
+		t.Error("expected an error for a target without port")
+	}
+	h, err := NewTargetHandler("127.0.0.1:1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hs := httptest.NewServer(h)
+	defer hs.Close()
+	resp := call(t, hs.URL, "/grpc.testing.TestService/UnaryCall", "application/grpc-web", &testpb.SimpleRequest{}, nil)
+	if resp.Header.Get("Grpc-Status") != "14" {
+		t.Errorf("expected Unavailable as trailers only, but actually %v", resp.Header)
+	}
+}
+
+func TestParseContentType(t *testing.T) {
+	tests := map[string]struct {
+		grpcContentType string
+		text, ok        bool
+	}{
+		"application/grpc-web":                       {"application/grpc", false, true},
+		"application/grpc-web+proto":                 {"application/grpc+proto", false, true},
+		"application/grpc-web-text":                  {"application/grpc", true, true},
+		"Application/gRPC-Web-Text+proto; charset=x": {"application/grpc+proto", true, true},
+		"application/grpc":                           {"", false, false},
+		"application/grpc-webx":                      {"", false, false},
+	}
+	for contentType, want := range tests {
+		_, grpcContentType, text, ok := parseContentType(contentType)
+		if grpcContentType != want.grpcContentType || text != want.text || ok != want.ok {
+			t.Errorf("%s: expected %s %v %v, but actually %s %v %v", contentType, want.grpcContentType, want.text, want.ok,
+				grpcContentType, text, ok)
+		}
+	}
+}
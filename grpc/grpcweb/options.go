@@ -0,0 +1,49 @@
+package grpcweb
+
+import (
+	"crypto/tls"
+	"time"
+)
+
+type (
+	// Option configures a Handler.
+	Option func(*handlerOptions)
+
+	handlerOptions struct {
+		allowedOrigins map[string]bool // none by default, same origin calls only
+		maxAge         time.Duration
+		tlsConfig      *tls.Config
+	}
+)
+
+func defaultHandlerOptions() *handlerOptions {
+	return &handlerOptions{
+		maxAge: 10 * time.Minute,
+	}
+}
+
+// WithAllowedOrigins allows the pages of origins, e.g. https://example.com, to call across origins with
+// credentials, cookies included. "*" allows any other origin to call without credentials. By default only
+// pages of the same origin may call.
+func WithAllowedOrigins(origins ...string) Option {
+	return func(o *handlerOptions) {
+		o.allowedOrigins = make(map[string]bool, len(origins))
+		for _, origin := range origins {
+			o.allowedOrigins[origin] = true
+		}
+	}
+}
+
+// WithCORSMaxAge sets how long browsers may cache the answer to a preflight request, 10 minutes by default.
+func WithCORSMaxAge(maxAge time.Duration) Option {
+	return func(o *handlerOptions) {
+		o.maxAge = maxAge
+	}
+}
+
+// WithTargetTLS calls the remote target of NewTargetHandler over TLS with config, instead of h2c.
+func WithTargetTLS(config *tls.Config) Option {
+	return func(o *handlerOptions) {
+		o.tlsConfig = config
+	}
+}
@@ -0,0 +1,116 @@
+package grpcweb
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/http2"
+	"google.golang.org/grpc/codes"
+)
+
+// targetBackend serves gRPC requests by calling a remote gRPC server over HTTP/2.
+type targetBackend struct {
+	scheme    string
+	authority string
+	transport *http2.Transport
+}
+
+func newTargetBackend(target string, tlsConfig *tls.Config) (*targetBackend, error) {
+	if _, _, err := net.SplitHostPort(target); err != nil {
+		return nil, fmt.Errorf("invalid target %s, err: %v", target, err)
+	}
+	b := &targetBackend{authority: target}
+	if tlsConfig != nil {
+		b.scheme = "https"
+		b.transport = &http2.Transport{TLSClientConfig: tlsConfig}
+	} else {
+		b.scheme = "http"
+		b.transport = &http2.Transport{
+			AllowHTTP: true,
+			DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, network, addr)
+			},
+		}
+	}
+	return b, nil
+}
+
+func (b *targetBackend) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	out := r.Clone(r.Context())
+	out.URL.Scheme, out.URL.Host = b.scheme, b.authority
+	out.Host = b.authority
+	out.RequestURI = ""
+
+	resp, err := b.transport.RoundTrip(out)
+	if err != nil {
+		// trailers only
+		for k, vv := range statusHeader(codes.Unavailable, fmt.Sprintf("failed to call %s, err: %v", b.authority, err)) {
+			w.Header()[k] = vv
+		}
+		w.Header().Set("Content-Type", "application/grpc")
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	defer resp.Body.Close()
+
+	for k, vv := range resp.Header {
+		w.Header()[k] = vv
+	}
+	w.WriteHeader(resp.StatusCode)
+
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := resp.Body.Read(buf)
+		if n > 0 {
+			if _, werr := w.Write(buf[:n]); werr != nil {
+				return
+			}
+			if f, ok := w.(http.Flusher); ok {
+				f.Flush()
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if resp.Trailer.Get("Grpc-Status") == "" {
+				for k, vv := range statusHeader(codes.Unavailable, fmt.Sprintf("failed to read from %s, err: %v", b.authority, err)) {
+					w.Header()[http.TrailerPrefix+k] = vv
+				}
+			}
+			return
+		}
+	}
+	for k, vv := range resp.Trailer {
+		w.Header()[http.TrailerPrefix+k] = vv
+	}
+}
+
+// statusHeader returns the grpc-status and grpc-message of a call failing with code and msg.
+func statusHeader(code codes.Code, msg string) http.Header {
+	return http.Header{
+		"Grpc-Status":  {strconv.Itoa(int(code))},
+		"Grpc-Message": {encodeGrpcMessage(msg)},
+	}
+}
+
+// encodeGrpcMessage percent-encodes msg for grpc-message, as the gRPC protocol has it.
+func encodeGrpcMessage(msg string) string {
+	var sb strings.Builder
+	for i := 0; i < len(msg); i++ {
+		c := msg[i]
+		if c >= ' ' && c <= '~' && c != '%' {
+			sb.WriteByte(c)
+		} else {
+			fmt.Fprintf(&sb, "%%%02X", c)
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,208 @@
+package grpcweb
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/pysugar/wheels/binproto/http2"
+)
+
+// trailerFlag marks the frame carrying the trailers, after the messages in the body of a gRPC-Web response.
+const trailerFlag = 0x80
+
+type (
+	// responseWriter turns the gRPC response written by the server into a gRPC-Web response. The server
+	// declares its trailers in the Trailer header, or sets them with http.TrailerPrefix, as net/http has it,
+	// which are encoded in the body once the server returns.
+	responseWriter struct {
+		w           http.ResponseWriter
+		header      http.Header
+		contentType string
+		text        bool
+		encoder     io.WriteCloser // of the base64 chunk being written, for text
+		wroteHeader bool
+		passthrough bool // an HTTP error of the server, not a gRPC response
+	}
+
+	// textBody decodes the body of a grpc-web-text request, which a client may send as several base64
+	// chunks, each with its padding.
+	textBody struct {
+		r       *bufio.Reader
+		body    io.Closer
+		quantum [4]byte
+		decoded [3]byte
+		pending []byte
+	}
+)
+
+func newResponseWriter(w http.ResponseWriter, contentType string, text bool) *responseWriter {
+	return &responseWriter{
+		w:           w,
+		header:      make(http.Header),
+		contentType: contentType,
+		text:        text,
+	}
+}
+
+func (rw *responseWriter) Header() http.Header {
+	return rw.header
+}
+
+func (rw *responseWriter) WriteHeader(code int) {
+	if rw.wroteHeader {
+		return
+	}
+	rw.wroteHeader = true
+
+	declared := rw.declaredTrailers()
+	out := rw.w.Header()
+	var exposed []string
+	for k, vv := range rw.header {
+		if len(vv) == 0 || k == "Trailer" || k == "Content-Length" || declared[k] ||
+			strings.HasPrefix(k, http.TrailerPrefix) {
+			continue
+		}
+		out[k] = vv
+		exposed = append(exposed, strings.ToLower(k))
+	}
+	if code == http.StatusOK && strings.HasPrefix(out.Get("Content-Type"), "application/grpc") {
+		out.Set("Content-Type", rw.contentType)
+	} else {
+		rw.passthrough = true
+	}
+	if out.Get("Access-Control-Allow-Origin") != "" {
+		sort.Strings(exposed)
+		out.Set("Access-Control-Expose-Headers", strings.Join(append(exposed, "grpc-status", "grpc-message"), ", "))
+	}
+	rw.w.WriteHeader(code)
+}
+
+func (rw *responseWriter) Write(b []byte) (int, error) {
+	if !rw.wroteHeader {
+		rw.WriteHeader(http.StatusOK)
+	}
+	if rw.passthrough || !rw.text {
+		return rw.w.Write(b)
+	}
+	if rw.encoder == nil {
+		rw.encoder = base64.NewEncoder(base64.StdEncoding, rw.w)
+	}
+	return rw.encoder.Write(b)
+}
+
+// Flush sends what is written so far, ending the base64 chunk being written for text.
+func (rw *responseWriter) Flush() {
+	if !rw.wroteHeader {
+		rw.WriteHeader(http.StatusOK)
+	}
+	if rw.encoder != nil {
+		_ = rw.encoder.Close()
+		rw.encoder = nil
+	}
+	if f, ok := rw.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap returns the ResponseWriter of the client, for http.ResponseController.
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.w
+}
+
+// finish writes the trailers of the server as the last frame of the body, once the server returns.
+func (rw *responseWriter) finish() {
+	if !rw.wroteHeader {
+		rw.WriteHeader(http.StatusOK)
+	}
+	if rw.passthrough {
+		return
+	}
+
+	trailers := make(http.Header)
+	for k := range rw.declaredTrailers() {
+		if vv := rw.header[k]; len(vv) > 0 {
+			trailers[k] = vv
+		}
+	}
+	for k, vv := range rw.header {
+		if name, ok := strings.CutPrefix(k, http.TrailerPrefix); ok && len(vv) > 0 {
+			trailers[http.CanonicalHeaderKey(name)] = vv
+		}
+	}
+	if len(trailers) > 0 {
+		_, _ = rw.Write(encodeTrailers(trailers))
+	}
+	rw.Flush()
+}
+
+func (rw *responseWriter) declaredTrailers() map[string]bool {
+	declared := make(map[string]bool)
+	for _, v := range rw.header.Values("Trailer") {
+		for _, k := range strings.Split(v, ",") {
+			if k = strings.TrimSpace(k); k != "" {
+				declared[http.CanonicalHeaderKey(k)] = true
+			}
+		}
+	}
+	return declared
+}
+
+// encodeTrailers frames trailers as gRPC-Web has it, an HTTP/1.1 header block with lower case names.
+func encodeTrailers(trailers http.Header) []byte {
+	keys := make([]string, 0, len(trailers))
+	for k := range trailers {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var buf bytes.Buffer
+	for _, k := range keys {
+		for _, v := range trailers[k] {
+			buf.WriteString(strings.ToLower(k))
+			buf.WriteString(": ")
+			buf.WriteString(v)
+			buf.WriteString("\r\n")
+		}
+	}
+	frame := http2.EncodeGrpcPayload(buf.Bytes())
+	frame[0] = trailerFlag
+	return frame
+}
+
+func (t *textBody) Read(p []byte) (int, error) {
+	for len(t.pending) == 0 {
+		n := 0
+		for n < len(t.quantum) {
+			c, err := t.r.ReadByte()
+			if err != nil {
+				if err == io.EOF && n > 0 {
+					return 0, io.ErrUnexpectedEOF
+				}
+				return 0, err
+			}
+			if c == '\r' || c == '\n' {
+				continue
+			}
+			t.quantum[n] = c
+			n++
+		}
+		m, err := base64.StdEncoding.Decode(t.decoded[:], t.quantum[:])
+		if err != nil {
+			return 0, fmt.Errorf("invalid grpc-web-text body, err: %v", err)
+		}
+		t.pending = t.decoded[:m]
+	}
+	n := copy(p, t.pending)
+	t.pending = t.pending[n:]
+	return n, nil
+}
+
+func (t *textBody) Close() error {
+	return t.body.Close()
+}
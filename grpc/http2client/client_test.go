@@ -0,0 +1,22 @@
+package http2client_test
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/pysugar/wheels/grpc/http2client"
+	"github.com/pysugar/wheels/internal/clienttest"
+)
+
+func TestGRPCClient(t *testing.T) {
+	clienttest.RunGRPCClientTests(t, func(serverURL, proxyURL *url.URL, compression string) (clienttest.GRPCClient, error) {
+		var opts []http2client.DialOption
+		if proxyURL != nil {
+			opts = append(opts, http2client.WithProxy(proxyURL))
+		}
+		if compression != "" {
+			opts = append(opts, http2client.WithCompression(compression))
+		}
+		return http2client.NewGRPCClient(serverURL, opts...)
+	})
+}
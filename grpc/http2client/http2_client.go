@@ -16,6 +16,7 @@ import (
 	"time"
 
 	http2tool "github.com/pysugar/wheels/binproto/http2"
+	"github.com/pysugar/wheels/http/proxy"
 	"golang.org/x/net/http2"
 	"golang.org/x/net/http2/hpack"
 	"google.golang.org/protobuf/proto"
@@ -23,6 +24,12 @@ import (
 
 const (
 	ClientPreface = "PRI * HTTP/2.0\r\n\r\nSM\r\n\r\n"
+
+	// receive windows advertised to the server, replenished on receipt since responses are buffered whole
+	connWindowSize   = 1 << 24
+	streamWindowSize = 1 << 20
+
+	grpcStatusUnimplemented = 12
 )
 
 type (
@@ -34,38 +41,83 @@ type (
 		grpcMessage     string
 		compressionAlgo string
 		payload         []byte
+		inflow          *http2tool.InFlow
 		mu              sync.Mutex
 	}
 
 	grpcClient struct {
-		serverURL     *url.URL
-		conn          net.Conn
-		framer        *http2.Framer
-		clientStreams sync.Map // streamID -> activeStream
-		streamIdGen   uint32
-		ctx           context.Context
-		cancel        context.CancelFunc
-		encoder       *hpack.Encoder
-		decoder       *hpack.Decoder
-		encoderBuf    bytes.Buffer // encoder buffer
-		encodeMu      sync.Mutex   // protect encoder and encoderBuf
-		decodeMu      sync.Mutex
-		writeMu       sync.Mutex
-		settingsAcked chan struct{}
+		serverURL      *url.URL
+		conn           net.Conn
+		framer         *http2.Framer
+		clientStreams  sync.Map // streamID -> activeStream
+		streamIdGen    uint32
+		ctx            context.Context
+		cancel         context.CancelFunc
+		encoder        *hpack.Encoder
+		decoder        *hpack.Decoder
+		encoderBuf     bytes.Buffer // encoder buffer
+		encodeMu       sync.Mutex   // protect encoder and encoderBuf
+		decodeMu       sync.Mutex
+		writeMu        sync.Mutex
+		settingsAcked  chan struct{}
+		compressor     http2tool.Compressor
+		acceptEncoding atomic.Value // grpc-accept-encoding of the server
+		outflow        *http2tool.OutFlow
+		inflow         *http2tool.InFlow
+		maxFrameSize   uint32
 	}
 
 	GRPCClient interface {
 		Call(ctx context.Context, serviceMethod string, req, res proto.Message) error
 		Close()
 	}
+
+	// DialOption configures how NewGRPCClient connects to the server.
+	DialOption func(*dialOptions)
+
+	dialOptions struct {
+		proxy       proxy.Func
+		compression string
+	}
 )
 
 var (
 	clientPreface = []byte(ClientPreface)
 )
 
-func NewGRPCClient(serverURL *url.URL) (GRPCClient, error) {
-	conn, err := dialConn(serverURL)
+// WithProxy connects through proxyURL, an HTTP CONNECT or SOCKS5 proxy, instead of the proxies set by
+// HTTPS_PROXY, HTTP_PROXY, ALL_PROXY and NO_PROXY. A nil proxyURL connects directly.
+func WithProxy(proxyURL *url.URL) DialOption {
+	return func(o *dialOptions) {
+		o.proxy = proxy.Fixed(proxyURL)
+	}
+}
+
+// WithCompression compresses requests with the compressor name of binproto/http2.RegisterCompressor,
+// gzip is built in. Once the server lists its grpc-accept-encoding without name, typically rejecting a call
+// with Unimplemented, requests are sent uncompressed and the rejected call is retried.
+func WithCompression(name string) DialOption {
+	return func(o *dialOptions) {
+		o.compression = name
+	}
+}
+
+func NewGRPCClient(serverURL *url.URL, opts ...DialOption) (GRPCClient, error) {
+	dopts := &dialOptions{}
+	for _, o := range opts {
+		o(dopts)
+	}
+	if dopts.proxy == nil {
+		dopts.proxy = proxy.FromEnvironment()
+	}
+	var compressor http2tool.Compressor
+	if dopts.compression != "" && dopts.compression != http2tool.Identity {
+		if compressor = http2tool.GetCompressor(dopts.compression); compressor == nil {
+			return nil, fmt.Errorf("no compressor registered for %q", dopts.compression)
+		}
+	}
+
+	conn, err := dialConn(serverURL, dopts)
 	if err != nil {
 		return nil, err
 	}
@@ -76,11 +128,15 @@ func NewGRPCClient(serverURL *url.URL) (GRPCClient, error) {
 	settingsAcked := make(chan struct{})
 	client := &grpcClient{
 		serverURL:     serverURL,
+		compressor:    compressor,
 		framer:        framer,
 		conn:          conn,
 		ctx:           ctx,
 		cancel:        cancel,
 		settingsAcked: settingsAcked,
+		outflow:       http2tool.NewOutFlow(),
+		inflow:        http2tool.NewInFlow(connWindowSize),
+		maxFrameSize:  http2tool.DefaultMaxFrameSize,
 	}
 	client.encoder = hpack.NewEncoder(&client.encoderBuf)
 	client.decoder = hpack.NewDecoder(4096, func(f hpack.HeaderField) {
@@ -90,11 +146,19 @@ func NewGRPCClient(serverURL *url.URL) (GRPCClient, error) {
 	if er := framer.WriteSettings(http2.Setting{
 		ID:  http2.SettingMaxConcurrentStreams,
 		Val: 1024,
+	}, http2.Setting{
+		ID:  http2.SettingInitialWindowSize,
+		Val: streamWindowSize,
 	}); er != nil {
 		client.Close()
 		log.Printf("write settings failed: %v", er)
 		return nil, er
 	}
+	if er := framer.WriteWindowUpdate(0, connWindowSize-http2tool.DefaultWindowSize); er != nil {
+		client.Close()
+		log.Printf("write window update failed: %v", er)
+		return nil, er
+	}
 	log.Printf("Client Write Settings")
 	go client.readLoop(ctx)
 
@@ -110,6 +174,7 @@ func NewGRPCClient(serverURL *url.URL) (GRPCClient, error) {
 
 func (c *grpcClient) Close() {
 	c.cancel()
+	c.outflow.Close()
 	if c.conn != nil {
 		c.conn.Close()
 	}
@@ -120,10 +185,31 @@ func (c *grpcClient) Call(ctx context.Context, serviceMethod string, req, res pr
 	if err != nil {
 		return fmt.Errorf("failed to marshal request: %w", err)
 	}
-	headers := grpcHeaders(c.serverURL, serviceMethod)
+	compressor := c.requestCompressor()
+	grpcStatus, err := c.call(ctx, serviceMethod, compressor, reqBytes, res)
+	// the server lists the encodings it accepts when rejecting ours
+	if compressor != nil && grpcStatus == grpcStatusUnimplemented && c.requestCompressor() == nil {
+		log.Printf("%s rejected by the server, retry uncompressed", compressor.Name())
+		_, err = c.call(ctx, serviceMethod, nil, reqBytes, res)
+	}
+	return err
+}
+
+// call sends a request compressed by compressor, and returns the grpc-status of the response if any, or -1.
+func (c *grpcClient) call(ctx context.Context, serviceMethod string, compressor http2tool.Compressor, reqBytes []byte, res proto.Message) (int, error) {
+	encoding := http2tool.Identity
+	if compressor != nil {
+		encoding = compressor.Name()
+	}
+	reqBody, err := http2tool.EncodeGrpcPayloadWithCompressor(reqBytes, compressor)
+	if err != nil {
+		return -1, err
+	}
+	headers := grpcHeaders(c.serverURL, serviceMethod, encoding)
 
 	c.writeMu.Lock()
 	streamId := atomic.AddUint32(&c.streamIdGen, 2) - 1
+	c.outflow.AddStream(streamId)
 	// log.Printf("Generated stream ID: %d\n", streamId)
 	if er := c.framer.WriteHeaders(http2.HeadersFrameParam{
 		StreamID:      streamId,
@@ -131,7 +217,7 @@ func (c *grpcClient) Call(ctx context.Context, serviceMethod string, req, res pr
 		EndHeaders:    true,
 	}); er != nil {
 		c.writeMu.Unlock()
-		return er
+		return -1, er
 	}
 	c.writeMu.Unlock()
 
@@ -140,35 +226,76 @@ func (c *grpcClient) Call(ctx context.Context, serviceMethod string, req, res pr
 		activeAt:   time.Now(),
 		doneCh:     make(chan struct{}),
 		grpcStatus: -1,
+		inflow:     http2tool.NewInFlow(streamWindowSize),
 	}
 	c.clientStreams.Store(streamId, cs)
 	defer func() {
 		c.clientStreams.Delete(streamId)
+		c.outflow.RemoveStream(streamId)
 	}()
 
-	c.writeMu.Lock()
-	if er := c.framer.WriteData(streamId, true, http2tool.EncodeGrpcPayload(reqBytes)); er != nil {
-		c.writeMu.Unlock()
-		return er
+	if er := c.writeData(ctx, streamId, reqBody); er != nil {
+		return -1, er
 	}
-	c.writeMu.Unlock()
 
 	select {
 	case <-cs.doneCh:
 		if cs.grpcStatus != 0 {
-			return fmt.Errorf("[%d] grpc error: %s", cs.grpcStatus, cs.grpcMessage)
+			return cs.grpcStatus, fmt.Errorf("[%d] grpc error: %s", cs.grpcStatus, cs.grpcMessage)
 		}
 		if er := http2tool.DecodeGrpcFrameWithDecompress(cs.payload, cs.compressionAlgo, res); er != nil {
-			return fmt.Errorf("failed to decode response: %w", er)
+			return cs.grpcStatus, fmt.Errorf("failed to decode response: %w", er)
 		}
-		return nil
+		return cs.grpcStatus, nil
 	case <-ctx.Done():
-		return ctx.Err()
+		return -1, ctx.Err()
 	case <-c.ctx.Done():
-		return c.ctx.Err()
+		return -1, c.ctx.Err()
+	}
+}
+
+// requestCompressor returns the compressor of requests, nil unless set by WithCompression or if the server
+// does not accept it.
+func (c *grpcClient) requestCompressor() http2tool.Compressor {
+	if c.compressor == nil {
+		return nil
+	}
+	if v, ok := c.acceptEncoding.Load().(string); ok && !http2tool.AcceptsEncoding(v, c.compressor.Name()) {
+		return nil
+	}
+	return c.compressor
+}
+
+// writeData writes payload in DATA frames no larger than the send window and SETTINGS_MAX_FRAME_SIZE allow,
+// the last one carries END_STREAM.
+func (c *grpcClient) writeData(ctx context.Context, streamId uint32, payload []byte) error {
+	for {
+		size := min(len(payload), int(atomic.LoadUint32(&c.maxFrameSize)))
+		n, err := c.outflow.Take(ctx, streamId, size)
+		if err != nil {
+			return err
+		}
+		chunk := payload[:n]
+		payload = payload[n:]
+
+		c.writeMu.Lock()
+		err = c.framer.WriteData(streamId, len(payload) == 0, chunk)
+		c.writeMu.Unlock()
+		if err != nil || len(payload) == 0 {
+			return err
+		}
 	}
 }
 
+func (c *grpcClient) writeWindowUpdate(streamId, increment uint32) error {
+	if increment == 0 {
+		return nil
+	}
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	return c.framer.WriteWindowUpdate(streamId, increment)
+}
+
 func (c *grpcClient) readLoop(ctx context.Context) {
 	for {
 		select {
@@ -219,6 +346,7 @@ func (c *grpcClient) readFrame(ctx context.Context) error {
 	case *http2.GoAwayFrame: // 7
 		return c.processGoAwayFrame(f)
 	case *http2.WindowUpdateFrame: //8
+		return c.processWindowUpdateFrame(f)
 	case *http2.ContinuationFrame: //9
 	default:
 		log.Printf("Received unknown frame: %T", f)
@@ -227,8 +355,24 @@ func (c *grpcClient) readFrame(ctx context.Context) error {
 }
 
 func (c *grpcClient) processDataFrame(f *http2.DataFrame) error {
+	if err := c.inflow.Take(f.Length); err != nil {
+		return err
+	}
+	if err := c.writeWindowUpdate(0, c.inflow.Add(f.Length)); err != nil {
+		return err
+	}
+
 	if v, loaded := c.clientStreams.Load(f.StreamID); loaded {
 		if cs, ok := v.(*clientStream); ok {
+			if err := cs.inflow.Take(f.Length); err != nil {
+				return err
+			}
+			if !f.StreamEnded() {
+				if err := c.writeWindowUpdate(f.StreamID, cs.inflow.Add(f.Length)); err != nil {
+					return err
+				}
+			}
+
 			cs.mu.Lock()
 			cs.payload = append(cs.payload, f.Data()...)
 			cs.mu.Unlock()
@@ -265,6 +409,8 @@ func (c *grpcClient) processHeaderFrame(f *http2.HeadersFrame) error {
 					cs.grpcMessage = hf.Value
 				} else if hf.Name == "grpc-encoding" {
 					cs.compressionAlgo = hf.Value
+				} else if hf.Name == "grpc-accept-encoding" {
+					c.acceptEncoding.Store(hf.Value)
 				}
 			}
 			if f.StreamEnded() {
@@ -288,11 +434,23 @@ func (c *grpcClient) processRSTStreamFrame(f *http2.RSTStreamFrame) error {
 	return nil
 }
 
+func (c *grpcClient) processWindowUpdateFrame(f *http2.WindowUpdateFrame) error {
+	return c.outflow.Update(f.StreamID, f.Increment)
+}
+
 func (c *grpcClient) processSettingsFrame(f *http2.SettingsFrame) error {
 	log.Printf("Server Settings [%d]: ", f.NumSettings())
 	for i := 0; i < f.NumSettings(); i++ {
 		settings := f.Setting(i)
 		log.Printf("\t%+v: %d\n", settings.ID, settings.Val)
+		switch settings.ID {
+		case http2.SettingInitialWindowSize:
+			if err := c.outflow.SetInitialWindowSize(settings.Val); err != nil {
+				return err
+			}
+		case http2.SettingMaxFrameSize:
+			atomic.StoreUint32(&c.maxFrameSize, settings.Val)
+		}
 	}
 
 	if f.IsAck() {
@@ -332,7 +490,7 @@ func (c *grpcClient) processGoAwayFrame(f *http2.GoAwayFrame) error {
 	return nil //io.EOF
 }
 
-func grpcHeaders(serverURL *url.URL, fullMethod string) []hpack.HeaderField {
+func grpcHeaders(serverURL *url.URL, fullMethod, encoding string) []hpack.HeaderField {
 	if !strings.HasPrefix(fullMethod, "/") {
 		fullMethod = "/" + fullMethod
 	}
@@ -343,8 +501,8 @@ func grpcHeaders(serverURL *url.URL, fullMethod string) []hpack.HeaderField {
 		{Name: ":path", Value: fullMethod},
 		{Name: "content-type", Value: "application/grpc"},
 		{Name: "te", Value: "trailers"},
-		{Name: "grpc-encoding", Value: "identity"},
-		{Name: "grpc-accept-encoding", Value: "identity"},
+		{Name: "grpc-encoding", Value: encoding},
+		{Name: "grpc-accept-encoding", Value: http2tool.AcceptEncoding()},
 	}
 }
 
@@ -373,24 +531,26 @@ func (c *grpcClient) encodeHpackHeaders(headers []hpack.HeaderField) []byte {
 	//return c.encoderBuf.Bytes()[before:after]
 }
 
-func dialConn(serverURL *url.URL) (conn net.Conn, err error) {
+func dialConn(serverURL *url.URL, dopts *dialOptions) (conn net.Conn, err error) {
 	addr := getHostAddress(serverURL)
+	conn, err = proxy.Dial(context.Background(), dopts.proxy, serverURL.Scheme, addr)
+	if err != nil {
+		log.Printf("dial conn err: %v\n", err)
+		return
+	}
 	if serverURL.Scheme == "https" {
 		tlsConfig := &tls.Config{
+			ServerName:         serverURL.Hostname(),
 			InsecureSkipVerify: true, // NOTE: For testing only. Do not use in production.
 			NextProtos:         []string{"h2"},
 		}
-		conn, err = tls.Dial("tcp", addr, tlsConfig)
-		if err != nil {
-			log.Printf("dial conn err: %v\n", err)
-			return
-		}
-	} else {
-		conn, err = net.Dial("tcp", addr)
-		if err != nil {
+		tlsConn := tls.Client(conn, tlsConfig)
+		if err = tlsConn.Handshake(); err != nil {
 			log.Printf("dial conn err: %v\n", err)
+			conn.Close()
 			return
 		}
+		conn = tlsConn
 	}
 
 	log.Printf("[%T] Send HTTP/2 Client Preface: %s\n", conn, clientPreface)
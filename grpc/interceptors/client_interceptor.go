@@ -8,6 +8,9 @@ import (
 	"google.golang.org/grpc/metadata"
 )
 
+// LoggingUnaryClientInterceptor logs the metadata, request and response of calls as they are.
+//
+// Deprecated: use SlogUnaryClientInterceptor, which redacts credentials.
 func LoggingUnaryClientInterceptor(
 	ctx context.Context,
 	method string,
@@ -0,0 +1,83 @@
+package interceptors
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+// MethodTimeouts maps methods to the longest time their calls may take. Keys are full methods,
+// "/package.Service/Method", whole services, "/package.Service/*", or "*" for any other method.
+type MethodTimeouts map[string]time.Duration
+
+// timeout returns the timeout of method, the most specific key wins.
+func (t MethodTimeouts) timeout(method string) (time.Duration, bool) {
+	if d, ok := t[method]; ok {
+		return d, true
+	}
+	if i := strings.LastIndex(method, "/"); i > 0 {
+		if d, ok := t[method[:i+1]+"*"]; ok {
+			return d, true
+		}
+	}
+	d, ok := t["*"]
+	return d, ok
+}
+
+// withTimeout bounds ctx by the timeout of method, unless its deadline is sooner already.
+func (t MethodTimeouts) withTimeout(ctx context.Context, method string) (context.Context, context.CancelFunc) {
+	d, ok := t.timeout(method)
+	if !ok || d <= 0 {
+		return ctx, func() {}
+	}
+	if deadline, has := ctx.Deadline(); has && time.Until(deadline) <= d {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, d)
+}
+
+// DeadlineUnaryServerInterceptor bounds the handlers of the methods of timeouts, whatever deadline the client
+// asked for if it is later.
+func DeadlineUnaryServerInterceptor(timeouts MethodTimeouts) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, cancel := timeouts.withTimeout(ctx, info.FullMethod)
+		defer cancel()
+		return handler(ctx, req)
+	}
+}
+
+// DeadlineStreamServerInterceptor is DeadlineUnaryServerInterceptor for streaming calls.
+func DeadlineStreamServerInterceptor(timeouts MethodTimeouts) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, cancel := timeouts.withTimeout(ss.Context(), info.FullMethod)
+		defer cancel()
+		return handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// DeadlineUnaryClientInterceptor sets the deadline of calls to the methods of timeouts, unless the context of
+// the call has a sooner one. The server is told the deadline through grpc-timeout.
+func DeadlineUnaryClientInterceptor(timeouts MethodTimeouts) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		ctx, cancel := timeouts.withTimeout(ctx, method)
+		defer cancel()
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+// DeadlineStreamClientInterceptor is DeadlineUnaryClientInterceptor for streaming calls, the deadline covers
+// the whole stream.
+func DeadlineStreamClientInterceptor(timeouts MethodTimeouts) grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		ctx, cancel := timeouts.withTimeout(ctx, method)
+		opts = append(opts, grpc.OnFinish(func(error) { cancel() }))
+		cs, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+		return cs, nil
+	}
+}
@@ -0,0 +1,238 @@
+package interceptors
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pysugar/wheels/features/stats"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	testpb "google.golang.org/grpc/interop/grpc_testing"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+type testService struct {
+	testpb.UnimplementedTestServiceServer
+	requestIDs chan string
+}
+
+func (s *testService) UnaryCall(ctx context.Context, req *testpb.SimpleRequest) (*testpb.SimpleResponse, error) {
+	s.requestIDs <- RequestIDFromContext(ctx)
+	switch string(req.GetPayload().GetBody()) {
+	case "panic":
+		panic("boom")
+	case "block":
+		<-ctx.Done()
+		return nil, status.FromContextError(ctx.Err()).Err()
+	}
+	return &testpb.SimpleResponse{Payload: req.GetPayload()}, nil
+}
+
+func (s *testService) StreamingOutputCall(req *testpb.StreamingOutputCallRequest, stream testpb.TestService_StreamingOutputCallServer) error {
+	s.requestIDs <- RequestIDFromContext(stream.Context())
+	for _, p := range req.GetResponseParameters() {
+		body := bytes.Repeat([]byte{'x'}, int(p.GetSize()))
+		if err := stream.Send(&testpb.StreamingOutputCallResponse{Payload: &testpb.Payload{Body: body}}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func newTestServer(t *testing.T, m stats.Manager, logger *slog.Logger) (*testService, *grpc.ClientConn) {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	timeouts := MethodTimeouts{"/grpc.testing.TestService/*": 200 * time.Millisecond}
+	redacted := WithRedactedMetadata("x-secret")
+	server := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(
+			RequestIDUnaryServerInterceptor,
+			StatsUnaryServerInterceptor(m),
+			SlogUnaryServerInterceptor(logger, redacted),
+			DeadlineUnaryServerInterceptor(timeouts),
+			RecoveryUnaryServerInterceptor,
+		),
+		grpc.ChainStreamInterceptor(
+			RequestIDStreamServerInterceptor,
+			StatsStreamServerInterceptor(m),
+			SlogStreamServerInterceptor(logger, redacted),
+			DeadlineStreamServerInterceptor(timeouts),
+			RecoveryStreamServerInterceptor,
+		),
+	)
+	service := &testService{requestIDs: make(chan string, 16)}
+	testpb.RegisterTestServiceServer(server, service)
+	go server.Serve(lis)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient(lis.Addr().String(),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithChainUnaryInterceptor(RequestIDUnaryClientInterceptor, StatsUnaryClientInterceptor(m), SlogUnaryClientInterceptor(logger, redacted)),
+		grpc.WithChainStreamInterceptor(RequestIDStreamClientInterceptor, StatsStreamClientInterceptor(m), SlogStreamClientInterceptor(logger, redacted)),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return service, conn
+}
+
+func TestServerInterceptors(t *testing.T) {
+	m := stats.NewManager()
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	service, conn := newTestServer(t, m, logger)
+	client := testpb.NewTestServiceClient(conn)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer s3cr3t", "x-secret", "hidden", "x-tenant", "t1")
+
+	tests := []struct {
+		name string
+		body string
+		code codes.Code
+	}{
+		{name: "ok", body: "hello", code: codes.OK},
+		{name: "panic", body: "panic", code: codes.Internal},
+		{name: "deadline", body: "block", code: codes.DeadlineExceeded},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var header metadata.MD
+			_, err := client.UnaryCall(WithRequestID(ctx, "req-"+tt.name),
+				&testpb.SimpleRequest{Payload: &testpb.Payload{Body: []byte(tt.body)}}, grpc.Header(&header))
+			if status.Code(err) != tt.code {
+				t.Fatalf("expected %v, but actually %v", tt.code, err)
+			}
+			if id := <-service.requestIDs; id != "req-"+tt.name {
+				t.Errorf("expected request id req-%s in the handler, but actually %q", tt.name, id)
+			}
+			if ids := header.Get(RequestIDKey); len(ids) != 1 || ids[0] != "req-"+tt.name {
+				t.Errorf("expected request id req-%s in the header, but actually %v", tt.name, ids)
+			}
+			if tt.code == codes.Internal && strings.Contains(status.Convert(err).Message(), "boom") {
+				t.Errorf("expected the panic hidden from the client, but actually %v", err)
+			}
+		})
+	}
+
+	stream, err := client.StreamingOutputCall(ctx, &testpb.StreamingOutputCallRequest{
+		ResponseParameters: []*testpb.ResponseParameters{{Size: 1}, {Size: 2}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for received := 0; ; received++ {
+		if _, err = stream.Recv(); errors.Is(err, io.EOF) {
+			if received != 2 {
+				t.Errorf("expected 2 responses, but actually %d", received)
+			}
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if id := <-service.requestIDs; len(id) != 36 {
+		t.Errorf("expected a generated request id, but actually %q", id)
+	}
+
+	wantCounters := map[string]int64{
+		"grpc_server>>>/grpc.testing.TestService/UnaryCall>>>calls>>>ok":                 1,
+		"grpc_server>>>/grpc.testing.TestService/UnaryCall>>>calls>>>internal":           1,
+		"grpc_server>>>/grpc.testing.TestService/UnaryCall>>>calls>>>deadlineexceeded":   1,
+		"grpc_server>>>/grpc.testing.TestService/StreamingOutputCall>>>calls>>>ok":       1,
+		"grpc_client>>>/grpc.testing.TestService/UnaryCall>>>calls>>>ok":                 1,
+		"grpc_client>>>/grpc.testing.TestService/UnaryCall>>>calls>>>internal":           1,
+		"grpc_client>>>/grpc.testing.TestService/StreamingOutputCall>>>calls>>>ok":       1,
+		"grpc_client>>>/grpc.testing.TestService/UnaryCall>>>calls>>>deadlineexceeded":   1,
+		"grpc_server>>>/grpc.testing.TestService/StreamingOutputCall>>>calls>>>internal": 0,
+	}
+	for name, want := range wantCounters {
+		c := m.GetCounter(name)
+		if want == 0 && c == nil {
+			continue
+		}
+		if c == nil || c.Value() != want {
+			t.Errorf("expected %s to be %d, but actually %v", name, want, c)
+		}
+	}
+	if c := m.GetCounter("grpc_server>>>/grpc.testing.TestService/UnaryCall>>>latency>>>us"); c == nil || c.Value() < 200000 {
+		t.Errorf("expected the latency to include the deadline of 200ms, but actually %v", c)
+	}
+
+	out := logs.String()
+	for _, secret := range []string{"s3cr3t", "hidden"} {
+		if strings.Contains(out, secret) {
+			t.Errorf("expected %q redacted, but actually logged", secret)
+		}
+	}
+	for _, want := range []string{`"x-tenant":"t1"`, `"authorization":"[REDACTED]"`, `"grpc.code":"Internal"`,
+		`"level":"ERROR"`, `"grpc.request_id":"req-ok"`, `"grpc.sent":2`, `"msg":"grpc stream finished"`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %s in the logs, but actually\n%s", want, out)
+		}
+	}
+}
+
+func TestRedactMessage(t *testing.T) {
+	r := newRedactor()
+	r.addFields("ssn")
+	msg, err := structpb.NewStruct(map[string]any{
+		"user":     "alice",
+		"password": "p",
+		"profile":  map[string]any{"SSN": "123", "accessToken": "t"},
+		"keys":     []any{map[string]any{"api_key": "k", "name": "n"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := r.message(msg)
+	for _, secret := range []string{`"p"`, "123", `"t"`, `"k"`} {
+		if strings.Contains(out, secret) {
+			t.Errorf("expected %s redacted, but actually %s", secret, out)
+		}
+	}
+	if !strings.Contains(out, `"user":"alice"`) || !strings.Contains(out, `"name":"n"`) {
+		t.Errorf("expected the other fields kept, but actually %s", out)
+	}
+}
+
+func TestMethodTimeouts(t *testing.T) {
+	timeouts := MethodTimeouts{
+		"/pkg.Svc/Slow": time.Minute,
+		"/pkg.Svc/*":    time.Second,
+		"*":             time.Hour,
+	}
+	tests := map[string]time.Duration{
+		"/pkg.Svc/Slow":  time.Minute,
+		"/pkg.Svc/Fast":  time.Second,
+		"/other.Svc/Any": time.Hour,
+	}
+	for method, want := range tests {
+		if d, ok := timeouts.timeout(method); !ok || d != want {
+			t.Errorf("%s: expected %v, but actually %v", method, want, d)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	bounded, cancelBounded := timeouts.withTimeout(ctx, "/pkg.Svc/Fast")
+	defer cancelBounded()
+	if bounded != ctx {
+		t.Error("expected the sooner deadline of the context kept")
+	}
+}
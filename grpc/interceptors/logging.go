@@ -0,0 +1,226 @@
+package interceptors
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+type (
+	// LoggingOption configures the slog interceptors.
+	LoggingOption func(*loggingOptions)
+
+	loggingOptions struct {
+		redactor *redactor
+		payloads bool
+	}
+)
+
+// WithRedactedMetadata masks the values of metadata keys besides authorization, proxy-authorization, cookie,
+// set-cookie and x-api-key.
+func WithRedactedMetadata(keys ...string) LoggingOption {
+	return func(o *loggingOptions) {
+		o.redactor.addMetadataKeys(keys...)
+	}
+}
+
+// WithRedactedFields masks message fields of names besides password, secret, token and the like, at any depth.
+// Names match proto and json field names alike, case-insensitively.
+func WithRedactedFields(names ...string) LoggingOption {
+	return func(o *loggingOptions) {
+		o.redactor.addFields(names...)
+	}
+}
+
+// WithoutPayloads leaves the messages out of the logs, which record them at debug level by default.
+func WithoutPayloads() LoggingOption {
+	return func(o *loggingOptions) {
+		o.payloads = false
+	}
+}
+
+func newLoggingOptions(opts []LoggingOption) *loggingOptions {
+	lopts := &loggingOptions{redactor: newRedactor(), payloads: true}
+	for _, o := range opts {
+		o(lopts)
+	}
+	return lopts
+}
+
+// SlogUnaryServerInterceptor logs each call once it ends, with its method, peer, request id, redacted
+// metadata, status code and duration. The level follows the code: info for OK and client errors, warn for
+// failures such as deadlines, error for server faults. Messages are logged at debug level, redacted.
+// A nil logger logs to slog.Default().
+func SlogUnaryServerInterceptor(logger *slog.Logger, opts ...LoggingOption) grpc.UnaryServerInterceptor {
+	logger, lopts := defaultLogger(logger), newLoggingOptions(opts)
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		attrs := lopts.serverAttrs(ctx, info.FullMethod)
+		lopts.logPayload(ctx, logger, attrs, "grpc request", "grpc.request", req)
+		resp, err := handler(ctx, req)
+		if err == nil {
+			lopts.logPayload(ctx, logger, attrs, "grpc response", "grpc.response", resp)
+		}
+		logFinish(ctx, logger, attrs, "grpc call finished", err, time.Since(start))
+		return resp, err
+	}
+}
+
+// SlogStreamServerInterceptor is SlogUnaryServerInterceptor for streaming calls, which also logs the count of
+// messages sent and received.
+func SlogStreamServerInterceptor(logger *slog.Logger, opts ...LoggingOption) grpc.StreamServerInterceptor {
+	logger, lopts := defaultLogger(logger), newLoggingOptions(opts)
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		ctx := ss.Context()
+		attrs := lopts.serverAttrs(ctx, info.FullMethod)
+		var sent, received atomic.Int64
+		err := handler(srv, &serverStream{
+			ServerStream: ss,
+			onSend: func(m any, err error) {
+				if err == nil {
+					sent.Add(1)
+					lopts.logPayload(ctx, logger, attrs, "grpc stream send", "grpc.response", m)
+				}
+			},
+			onRecv: func(m any, err error) {
+				if err == nil {
+					received.Add(1)
+					lopts.logPayload(ctx, logger, attrs, "grpc stream recv", "grpc.request", m)
+				}
+			},
+		})
+		attrs = append(attrs, slog.Int64("grpc.sent", sent.Load()), slog.Int64("grpc.received", received.Load()))
+		logFinish(ctx, logger, attrs, "grpc stream finished", err, time.Since(start))
+		return err
+	}
+}
+
+// SlogUnaryClientInterceptor logs each call once it ends, as SlogUnaryServerInterceptor does on servers.
+func SlogUnaryClientInterceptor(logger *slog.Logger, opts ...LoggingOption) grpc.UnaryClientInterceptor {
+	logger, lopts := defaultLogger(logger), newLoggingOptions(opts)
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		start := time.Now()
+		attrs := lopts.clientAttrs(ctx, method, cc)
+		lopts.logPayload(ctx, logger, attrs, "grpc request", "grpc.request", req)
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		if err == nil {
+			lopts.logPayload(ctx, logger, attrs, "grpc response", "grpc.response", reply)
+		}
+		logFinish(ctx, logger, attrs, "grpc call finished", err, time.Since(start))
+		return err
+	}
+}
+
+// SlogStreamClientInterceptor logs each stream once it ends, as SlogStreamServerInterceptor does on servers.
+func SlogStreamClientInterceptor(logger *slog.Logger, opts ...LoggingOption) grpc.StreamClientInterceptor {
+	logger, lopts := defaultLogger(logger), newLoggingOptions(opts)
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		start := time.Now()
+		attrs := lopts.clientAttrs(ctx, method, cc)
+		var sent, received atomic.Int64
+		finish := finishOnce(func(err error) {
+			attrs := append(attrs[:len(attrs):len(attrs)], slog.Int64("grpc.sent", sent.Load()), slog.Int64("grpc.received", received.Load()))
+			logFinish(ctx, logger, attrs, "grpc stream finished", err, time.Since(start))
+		})
+		opts = append(opts, grpc.OnFinish(finish))
+		cs, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			finish(err)
+			return nil, err
+		}
+		return &clientStream{
+			ClientStream: cs,
+			onSend: func(m any, err error) {
+				if err == nil {
+					sent.Add(1)
+					lopts.logPayload(ctx, logger, attrs, "grpc stream send", "grpc.request", m)
+				}
+			},
+			onRecv: func(m any, err error) {
+				if err == nil {
+					received.Add(1)
+					lopts.logPayload(ctx, logger, attrs, "grpc stream recv", "grpc.response", m)
+				}
+			},
+		}, nil
+	}
+}
+
+func defaultLogger(logger *slog.Logger) *slog.Logger {
+	if logger == nil {
+		return slog.Default()
+	}
+	return logger
+}
+
+func (o *loggingOptions) serverAttrs(ctx context.Context, method string) []slog.Attr {
+	attrs := []slog.Attr{slog.String("grpc.side", "server"), slog.String("grpc.method", method)}
+	if p, ok := peer.FromContext(ctx); ok {
+		attrs = append(attrs, slog.String("grpc.peer", p.Addr.String()))
+	}
+	if id := RequestIDFromContext(ctx); id != "" {
+		attrs = append(attrs, slog.String("grpc.request_id", id))
+	}
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		attrs = append(attrs, o.redactor.metadata("grpc.metadata", md))
+	}
+	return attrs
+}
+
+func (o *loggingOptions) clientAttrs(ctx context.Context, method string, cc *grpc.ClientConn) []slog.Attr {
+	attrs := []slog.Attr{slog.String("grpc.side", "client"), slog.String("grpc.method", method),
+		slog.String("grpc.target", cc.Target())}
+	md, _ := metadata.FromOutgoingContext(ctx)
+	if ids := md.Get(RequestIDKey); len(ids) > 0 {
+		attrs = append(attrs, slog.String("grpc.request_id", ids[0]))
+	} else if id := RequestIDFromContext(ctx); id != "" {
+		attrs = append(attrs, slog.String("grpc.request_id", id))
+	}
+	if len(md) > 0 {
+		attrs = append(attrs, o.redactor.metadata("grpc.metadata", md))
+	}
+	return attrs
+}
+
+func (o *loggingOptions) logPayload(ctx context.Context, logger *slog.Logger, attrs []slog.Attr, msg, key string, m any) {
+	if !o.payloads || !logger.Enabled(ctx, slog.LevelDebug) {
+		return
+	}
+	// attrs are shared by the concurrent sends and receives of a stream, appending must copy them
+	logger.LogAttrs(ctx, slog.LevelDebug, msg, append(attrs[:len(attrs):len(attrs)], slog.String(key, o.redactor.message(m)))...)
+}
+
+func logFinish(ctx context.Context, logger *slog.Logger, attrs []slog.Attr, msg string, err error, elapsed time.Duration) {
+	if errors.Is(err, io.EOF) {
+		err = nil
+	}
+	code := status.Code(err)
+	attrs = append(attrs[:len(attrs):len(attrs)], slog.String("grpc.code", code.String()), slog.Duration("grpc.duration", elapsed))
+	if err != nil {
+		attrs = append(attrs, slog.String("error", status.Convert(err).Message()))
+	}
+	logger.LogAttrs(ctx, codeLevel(code), msg, attrs...)
+}
+
+// codeLevel maps the status code of a call to the level it is logged at.
+func codeLevel(code codes.Code) slog.Level {
+	switch code {
+	case codes.OK, codes.Canceled, codes.InvalidArgument, codes.NotFound, codes.AlreadyExists, codes.Unauthenticated:
+		return slog.LevelInfo
+	case codes.DeadlineExceeded, codes.PermissionDenied, codes.ResourceExhausted, codes.FailedPrecondition,
+		codes.Aborted, codes.OutOfRange:
+		return slog.LevelWarn
+	default:
+		return slog.LevelError
+	}
+}
@@ -0,0 +1,49 @@
+package interceptors
+
+import (
+	"context"
+	"log/slog"
+	"runtime/debug"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// RecoveryUnaryServerInterceptor turns a panic of the handler into an Internal error, and logs the panic with
+// its stack to slog.Default(). The client is not told the panic value.
+func RecoveryUnaryServerInterceptor(
+	ctx context.Context,
+	req interface{},
+	info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (resp interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = recovered(ctx, info.FullMethod, r)
+		}
+	}()
+	return handler(ctx, req)
+}
+
+// RecoveryStreamServerInterceptor is RecoveryUnaryServerInterceptor for streaming calls.
+func RecoveryStreamServerInterceptor(
+	srv interface{},
+	ss grpc.ServerStream,
+	info *grpc.StreamServerInfo,
+	handler grpc.StreamHandler,
+) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = recovered(ss.Context(), info.FullMethod, r)
+		}
+	}()
+	return handler(srv, ss)
+}
+
+func recovered(ctx context.Context, method string, r any) error {
+	slog.ErrorContext(ctx, "grpc handler panic", slog.String("grpc.method", method),
+		slog.String("grpc.request_id", RequestIDFromContext(ctx)), slog.Any("panic", r),
+		slog.String("stack", string(debug.Stack())))
+	return status.Error(codes.Internal, "internal error")
+}
@@ -0,0 +1,116 @@
+package interceptors
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+const redacted = "[REDACTED]"
+
+var (
+	defaultRedactedMetadata = []string{"authorization", "proxy-authorization", "cookie", "set-cookie", "x-api-key"}
+	defaultRedactedFields   = []string{"password", "passwd", "secret", "token", "access_token", "refresh_token",
+		"api_key", "private_key", "credentials"}
+)
+
+// redactor masks the values of sensitive metadata keys and message fields before they are logged.
+type redactor struct {
+	metadataKeys map[string]bool // lower case
+	fields       map[string]bool // lower case without '_', to match proto and json names alike
+}
+
+func newRedactor() *redactor {
+	r := &redactor{
+		metadataKeys: make(map[string]bool),
+		fields:       make(map[string]bool),
+	}
+	r.addMetadataKeys(defaultRedactedMetadata...)
+	r.addFields(defaultRedactedFields...)
+	return r
+}
+
+func (r *redactor) addMetadataKeys(keys ...string) {
+	for _, key := range keys {
+		r.metadataKeys[strings.ToLower(key)] = true
+	}
+}
+
+func (r *redactor) addFields(names ...string) {
+	for _, name := range names {
+		r.fields[normalizeFieldName(name)] = true
+	}
+}
+
+func normalizeFieldName(name string) string {
+	return strings.ToLower(strings.ReplaceAll(name, "_", ""))
+}
+
+// metadata returns md as a group of attributes sorted by key, binary values are reduced to their length.
+func (r *redactor) metadata(name string, md metadata.MD) slog.Attr {
+	keys := make([]string, 0, len(md))
+	for key := range md {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	attrs := make([]any, 0, len(keys))
+	for _, key := range keys {
+		values := md[key]
+		switch {
+		case r.metadataKeys[key]:
+			values = []string{redacted}
+		case strings.HasSuffix(key, "-bin"):
+			values = []string{fmt.Sprintf("%d bytes", len(strings.Join(values, "")))}
+		}
+		attrs = append(attrs, slog.String(key, strings.Join(values, ", ")))
+	}
+	return slog.Group(name, attrs...)
+}
+
+// message returns the json of a proto message with the redacted fields masked at any depth, or the type of
+// other messages.
+func (r *redactor) message(m any) string {
+	pm, ok := m.(proto.Message)
+	if !ok {
+		return fmt.Sprintf("%T", m)
+	}
+	data, err := protojson.Marshal(pm)
+	if err != nil {
+		return fmt.Sprintf("%T: %v", m, err)
+	}
+	if len(r.fields) == 0 {
+		return string(data)
+	}
+
+	var v any
+	if err = json.Unmarshal(data, &v); err != nil {
+		return fmt.Sprintf("%T: %v", m, err)
+	}
+	data, _ = json.Marshal(r.redactValue(v))
+	return string(data)
+}
+
+func (r *redactor) redactValue(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for key, value := range v {
+			if r.fields[normalizeFieldName(key)] {
+				v[key] = redacted
+			} else {
+				v[key] = r.redactValue(value)
+			}
+		}
+	case []any:
+		for i, value := range v {
+			v[i] = r.redactValue(value)
+		}
+	}
+	return v
+}
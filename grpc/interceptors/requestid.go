@@ -0,0 +1,105 @@
+package interceptors
+
+import (
+	"context"
+
+	"github.com/pysugar/wheels/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// RequestIDKey is the metadata key carrying the request id.
+const RequestIDKey = "x-request-id"
+
+type requestIDCtxKey struct{}
+
+// WithRequestID returns a context whose calls send id as request id.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDCtxKey{}, id)
+}
+
+// RequestIDFromContext returns the request id of a call, as set by WithRequestID or by the request id server
+// interceptors, or received in the incoming metadata.
+func RequestIDFromContext(ctx context.Context) string {
+	if id, ok := ctx.Value(requestIDCtxKey{}).(string); ok {
+		return id
+	}
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if ids := md.Get(RequestIDKey); len(ids) > 0 {
+			return ids[0]
+		}
+	}
+	return ""
+}
+
+// RequestIDUnaryServerInterceptor takes the request id of the incoming metadata, or generates one, stores it
+// in the context of the handler and sends it back in the response header.
+func RequestIDUnaryServerInterceptor(
+	ctx context.Context,
+	req interface{},
+	info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (interface{}, error) {
+	ctx = serverRequestID(ctx)
+	return handler(ctx, req)
+}
+
+// RequestIDStreamServerInterceptor is RequestIDUnaryServerInterceptor for streaming calls.
+func RequestIDStreamServerInterceptor(
+	srv interface{},
+	ss grpc.ServerStream,
+	info *grpc.StreamServerInfo,
+	handler grpc.StreamHandler,
+) error {
+	ctx := serverRequestID(ss.Context())
+	return handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+}
+
+// RequestIDUnaryClientInterceptor sends the request id of the context in the outgoing metadata, a new one if
+// the context has none. Servers calling other servers with the context of their handler propagate the id.
+func RequestIDUnaryClientInterceptor(
+	ctx context.Context,
+	method string,
+	req interface{},
+	reply interface{},
+	cc *grpc.ClientConn,
+	invoker grpc.UnaryInvoker,
+	opts ...grpc.CallOption,
+) error {
+	return invoker(clientRequestID(ctx), method, req, reply, cc, opts...)
+}
+
+// RequestIDStreamClientInterceptor is RequestIDUnaryClientInterceptor for streaming calls.
+func RequestIDStreamClientInterceptor(
+	ctx context.Context,
+	desc *grpc.StreamDesc,
+	cc *grpc.ClientConn,
+	method string,
+	streamer grpc.Streamer,
+	opts ...grpc.CallOption,
+) (grpc.ClientStream, error) {
+	return streamer(clientRequestID(ctx), desc, cc, method, opts...)
+}
+
+func serverRequestID(ctx context.Context) context.Context {
+	id := RequestIDFromContext(ctx)
+	if id == "" {
+		u := uuid.New()
+		id = u.String()
+	}
+	grpc.SetHeader(ctx, metadata.Pairs(RequestIDKey, id))
+	return WithRequestID(ctx, id)
+}
+
+func clientRequestID(ctx context.Context) context.Context {
+	if md, ok := metadata.FromOutgoingContext(ctx); ok && len(md.Get(RequestIDKey)) > 0 {
+		return ctx
+	}
+	id := RequestIDFromContext(ctx)
+	if id == "" {
+		u := uuid.New()
+		id = u.String()
+		ctx = WithRequestID(ctx, id)
+	}
+	return metadata.AppendToOutgoingContext(ctx, RequestIDKey, id)
+}
@@ -8,6 +8,9 @@ import (
 	"google.golang.org/grpc/metadata"
 )
 
+// LoggingUnaryServerInterceptor logs the metadata, request and response of calls as they are.
+//
+// Deprecated: use SlogUnaryServerInterceptor, which redacts credentials.
 func LoggingUnaryServerInterceptor(
 	ctx context.Context,
 	req interface{},
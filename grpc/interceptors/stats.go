@@ -0,0 +1,102 @@
+package interceptors
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pysugar/wheels/features/stats"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// callStats counts the calls of each method into a stats.Manager, with the counters
+//
+//	<dimension>>>><method>>>>calls>>><code>   calls ended with the status code, in lower case
+//	<dimension>>>><method>>>>latency>>>us     total microseconds of the calls
+//
+// where dimension is grpc_server or grpc_client, which the PrometheusHandler of stats exposes as
+// wheels_calls_<code>_total and wheels_latency_us_total labeled by dimension and method.
+type callStats struct {
+	m         stats.Manager
+	dimension string
+	counters  sync.Map // name -> stats.Counter
+}
+
+func newCallStats(m stats.Manager, dimension string) *callStats {
+	return &callStats{m: m, dimension: dimension}
+}
+
+func (s *callStats) record(method string, err error, elapsed time.Duration) {
+	code := strings.ToLower(status.Code(err).String())
+	s.add(s.dimension+">>>"+method+">>>calls>>>"+code, 1)
+	s.add(s.dimension+">>>"+method+">>>latency>>>us", elapsed.Microseconds())
+}
+
+func (s *callStats) add(name string, delta int64) {
+	if c, ok := s.counters.Load(name); ok {
+		c.(stats.Counter).Add(delta)
+		return
+	}
+	c, err := stats.GetOrRegisterCounter(s.m, name)
+	if err != nil {
+		// registered concurrently, or the manager has no counters
+		if c = s.m.GetCounter(name); c == nil {
+			return
+		}
+	}
+	actual, _ := s.counters.LoadOrStore(name, c)
+	actual.(stats.Counter).Add(delta)
+}
+
+// StatsUnaryServerInterceptor counts the calls of each method by status code, along with their latency, into m.
+func StatsUnaryServerInterceptor(m stats.Manager) grpc.UnaryServerInterceptor {
+	s := newCallStats(m, "grpc_server")
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		s.record(info.FullMethod, err, time.Since(start))
+		return resp, err
+	}
+}
+
+// StatsStreamServerInterceptor is StatsUnaryServerInterceptor for streaming calls.
+func StatsStreamServerInterceptor(m stats.Manager) grpc.StreamServerInterceptor {
+	s := newCallStats(m, "grpc_server")
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		err := handler(srv, ss)
+		s.record(info.FullMethod, err, time.Since(start))
+		return err
+	}
+}
+
+// StatsUnaryClientInterceptor counts the calls of each method by status code, along with their latency, into m.
+func StatsUnaryClientInterceptor(m stats.Manager) grpc.UnaryClientInterceptor {
+	s := newCallStats(m, "grpc_client")
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		start := time.Now()
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		s.record(method, err, time.Since(start))
+		return err
+	}
+}
+
+// StatsStreamClientInterceptor is StatsUnaryClientInterceptor for streaming calls, which end when the stream does.
+func StatsStreamClientInterceptor(m stats.Manager) grpc.StreamClientInterceptor {
+	s := newCallStats(m, "grpc_client")
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		start := time.Now()
+		finish := finishOnce(func(err error) {
+			s.record(method, err, time.Since(start))
+		})
+		opts = append(opts, grpc.OnFinish(finish))
+		cs, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			finish(err)
+			return nil, err
+		}
+		return cs, nil
+	}
+}
@@ -0,0 +1,74 @@
+package interceptors
+
+import (
+	"context"
+	"sync"
+
+	"google.golang.org/grpc"
+)
+
+type (
+	// serverStream overrides the context of a server stream, and observes its messages if the hooks are set.
+	serverStream struct {
+		grpc.ServerStream
+		ctx    context.Context
+		onSend func(m any, err error)
+		onRecv func(m any, err error)
+	}
+
+	// clientStream observes the messages of a client stream.
+	clientStream struct {
+		grpc.ClientStream
+		onSend func(m any, err error)
+		onRecv func(m any, err error)
+	}
+)
+
+func (s *serverStream) Context() context.Context {
+	if s.ctx != nil {
+		return s.ctx
+	}
+	return s.ServerStream.Context()
+}
+
+func (s *serverStream) SendMsg(m any) error {
+	err := s.ServerStream.SendMsg(m)
+	if s.onSend != nil {
+		s.onSend(m, err)
+	}
+	return err
+}
+
+func (s *serverStream) RecvMsg(m any) error {
+	err := s.ServerStream.RecvMsg(m)
+	if s.onRecv != nil {
+		s.onRecv(m, err)
+	}
+	return err
+}
+
+func (s *clientStream) SendMsg(m any) error {
+	err := s.ClientStream.SendMsg(m)
+	if s.onSend != nil {
+		s.onSend(m, err)
+	}
+	return err
+}
+
+func (s *clientStream) RecvMsg(m any) error {
+	err := s.ClientStream.RecvMsg(m)
+	if s.onRecv != nil {
+		s.onRecv(m, err)
+	}
+	return err
+}
+
+// finishOnce calls finish once, on grpc.OnFinish or on the failure to create the stream, which may both happen.
+func finishOnce(finish func(err error)) func(err error) {
+	var once sync.Once
+	return func(err error) {
+		once.Do(func() {
+			finish(err)
+		})
+	}
+}
@@ -0,0 +1,189 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"os"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/keepalive"
+)
+
+const (
+	defaultAddress      = ":50051"
+	defaultDrainTimeout = 30 * time.Second
+)
+
+type (
+	// Option configures the Server of New.
+	Option func(*serverOptions)
+
+	serverOptions struct {
+		network            string
+		address            string
+		listener           net.Listener
+		tlsConfig          *tls.Config
+		tlsErr             error
+		keepaliveParams    keepalive.ServerParameters
+		unaryInterceptors  []grpc.UnaryServerInterceptor
+		streamInterceptors []grpc.StreamServerInterceptor
+		maxRecvMsgSize     int
+		maxSendMsgSize     int
+		healthServices     []string
+		stopSignals        []os.Signal
+		drainDelay         time.Duration
+		drainTimeout       time.Duration
+		serverOptions      []grpc.ServerOption
+	}
+)
+
+func defaultServerOptions() *serverOptions {
+	return &serverOptions{
+		network: "tcp",
+		address: defaultAddress,
+		keepaliveParams: keepalive.ServerParameters{
+			MaxConnectionIdle:     5 * time.Minute,
+			MaxConnectionAge:      2 * time.Hour,
+			MaxConnectionAgeGrace: 5 * time.Minute,
+			Time:                  1 * time.Hour,
+			Timeout:               20 * time.Second,
+		},
+		drainTimeout: defaultDrainTimeout,
+	}
+}
+
+// WithAddress listens on the TCP address, host:port, :50051 by default.
+func WithAddress(address string) Option {
+	return func(o *serverOptions) {
+		o.network, o.address = "tcp", address
+	}
+}
+
+// WithUnixSocket listens on the unix socket at path, a stale socket file left there is removed first. New fails
+// if another kind of file is at path.
+func WithUnixSocket(path string) Option {
+	return func(o *serverOptions) {
+		o.network, o.address = "unix", path
+	}
+}
+
+// WithListener serves on lis instead of listening on an address.
+func WithListener(lis net.Listener) Option {
+	return func(o *serverOptions) {
+		o.listener = lis
+	}
+}
+
+// WithTLSConfig serves TLS with config, which requires client certificates for mutual TLS.
+func WithTLSConfig(config *tls.Config) Option {
+	return func(o *serverOptions) {
+		o.tlsConfig = config
+	}
+}
+
+// WithTLS serves TLS with the certificate and key of PEM files. With clientCAFile, clients must present a
+// certificate signed by one of its CAs, as in mutual TLS.
+func WithTLS(certFile, keyFile, clientCAFile string) Option {
+	return func(o *serverOptions) {
+		o.tlsConfig, o.tlsErr = LoadTLSConfig(certFile, keyFile, clientCAFile)
+	}
+}
+
+// LoadTLSConfig builds the server TLS configuration of WithTLS, e.g. to serve another listener of the same
+// service with the same client authentication.
+func LoadTLSConfig(certFile, keyFile, clientCAFile string) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load server certificate, err: %v", err)
+	}
+	config := &tls.Config{Certificates: []tls.Certificate{cert}}
+	if clientCAFile == "" {
+		return config, nil
+	}
+	pem, err := os.ReadFile(clientCAFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read client ca certificates, err: %v", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificate found in %s", clientCAFile)
+	}
+	config.ClientCAs = pool
+	config.ClientAuth = tls.RequireAndVerifyClientCert
+	return config, nil
+}
+
+// WithKeepaliveParams replaces the default keepalive parameters, 5 minutes of max idle and 2 hours of max age.
+func WithKeepaliveParams(params keepalive.ServerParameters) Option {
+	return func(o *serverOptions) {
+		o.keepaliveParams = params
+	}
+}
+
+// WithUnaryInterceptors chains interceptors of unary calls, the first one is the outermost.
+func WithUnaryInterceptors(interceptors ...grpc.UnaryServerInterceptor) Option {
+	return func(o *serverOptions) {
+		o.unaryInterceptors = append(o.unaryInterceptors, interceptors...)
+	}
+}
+
+// WithStreamInterceptors chains interceptors of streaming calls, the first one is the outermost.
+func WithStreamInterceptors(interceptors ...grpc.StreamServerInterceptor) Option {
+	return func(o *serverOptions) {
+		o.streamInterceptors = append(o.streamInterceptors, interceptors...)
+	}
+}
+
+// WithMaxRecvMsgSize limits the size of received messages, 4MB by default.
+func WithMaxRecvMsgSize(n int) Option {
+	return func(o *serverOptions) {
+		o.maxRecvMsgSize = n
+	}
+}
+
+// WithMaxSendMsgSize limits the size of sent messages, unlimited by default.
+func WithMaxSendMsgSize(n int) Option {
+	return func(o *serverOptions) {
+		o.maxSendMsgSize = n
+	}
+}
+
+// WithHealthServices reports names SERVING in the health service besides the registered services.
+func WithHealthServices(names ...string) Option {
+	return func(o *serverOptions) {
+		o.healthServices = append(o.healthServices, names...)
+	}
+}
+
+// WithStopSignals makes Serve stop gracefully on any of sigs, within the drain timeout.
+func WithStopSignals(sigs ...os.Signal) Option {
+	return func(o *serverOptions) {
+		o.stopSignals = sigs
+	}
+}
+
+// WithDrainDelay keeps serving for d after the health status flips to NOT_SERVING on GracefulStop, so that
+// load balancers checking the health stop sending calls before connections are closed.
+func WithDrainDelay(d time.Duration) Option {
+	return func(o *serverOptions) {
+		o.drainDelay = d
+	}
+}
+
+// WithDrainTimeout bounds the graceful stop on a stop signal, 30 seconds by default, after which the
+// remaining calls are canceled.
+func WithDrainTimeout(d time.Duration) Option {
+	return func(o *serverOptions) {
+		o.drainTimeout = d
+	}
+}
+
+// WithServerOptions passes opts to grpc.NewServer, after the options of the Server.
+func WithServerOptions(opts ...grpc.ServerOption) Option {
+	return func(o *serverOptions) {
+		o.serverOptions = append(o.serverOptions, opts...)
+	}
+}
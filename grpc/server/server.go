@@ -0,0 +1,199 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	ossignal "os/signal"
+	"sync"
+	"time"
+
+	"github.com/pysugar/wheels/signal/done"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/channelz/service"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+)
+
+// Server is a gRPC server with the health, reflection and channelz services. Services are registered on it
+// before Serve, e.g. pb.RegisterEchoServiceServer(s, impl).
+type Server struct {
+	sopts  *serverOptions
+	server *grpc.Server
+	health *health.Server
+	lis    net.Listener
+
+	stopOnce sync.Once
+	stopErr  error
+	stopped  *done.Instance
+}
+
+// New listens on the address of opts, :50051 by default, and creates a Server which serves on it once
+// Serve is called.
+func New(opts ...Option) (*Server, error) {
+	sopts := defaultServerOptions()
+	for _, o := range opts {
+		o(sopts)
+	}
+	if sopts.tlsErr != nil {
+		return nil, sopts.tlsErr
+	}
+
+	lis := sopts.listener
+	if lis == nil {
+		if sopts.network == "unix" {
+			if err := removeStaleSocket(sopts.address); err != nil {
+				return nil, err
+			}
+		}
+		var err error
+		if lis, err = net.Listen(sopts.network, sopts.address); err != nil {
+			return nil, fmt.Errorf("failed to listen on %s %s, err: %v", sopts.network, sopts.address, err)
+		}
+	}
+
+	serverOpts := []grpc.ServerOption{
+		grpc.KeepaliveParams(sopts.keepaliveParams),
+		grpc.ChainUnaryInterceptor(sopts.unaryInterceptors...),
+		grpc.ChainStreamInterceptor(sopts.streamInterceptors...),
+	}
+	if sopts.tlsConfig != nil {
+		serverOpts = append(serverOpts, grpc.Creds(credentials.NewTLS(sopts.tlsConfig)))
+	}
+	if sopts.maxRecvMsgSize > 0 {
+		serverOpts = append(serverOpts, grpc.MaxRecvMsgSize(sopts.maxRecvMsgSize))
+	}
+	if sopts.maxSendMsgSize > 0 {
+		serverOpts = append(serverOpts, grpc.MaxSendMsgSize(sopts.maxSendMsgSize))
+	}
+	serverOpts = append(serverOpts, sopts.serverOptions...)
+
+	s := &Server{
+		sopts:   sopts,
+		server:  grpc.NewServer(serverOpts...),
+		health:  health.NewServer(),
+		lis:     lis,
+		stopped: done.New(),
+	}
+	grpc_health_v1.RegisterHealthServer(s.server, s.health)
+	reflection.Register(s.server)
+	service.RegisterChannelzServiceToServer(s.server)
+	return s, nil
+}
+
+// removeStaleSocket removes the socket file left at path by a previous server, any other file is kept.
+func removeStaleSocket(path string) error {
+	fi, err := os.Lstat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to stat socket %s, err: %v", path, err)
+	}
+	if fi.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("failed to listen on unix socket %s, which is not a socket file", path)
+	}
+	if err = os.Remove(path); err != nil {
+		return fmt.Errorf("failed to remove stale socket %s, err: %v", path, err)
+	}
+	return nil
+}
+
+// RegisterService registers a service implementation, as grpc.ServiceRegistrar.
+func (s *Server) RegisterService(desc *grpc.ServiceDesc, impl any) {
+	s.server.RegisterService(desc, impl)
+}
+
+// GRPCServer returns the underlying grpc.Server, e.g. to serve gRPC-Web through grpcweb.NewHandler.
+func (s *Server) GRPCServer() *grpc.Server {
+	return s.server
+}
+
+// Addr returns the address the server listens on.
+func (s *Server) Addr() net.Addr {
+	return s.lis.Addr()
+}
+
+// Health returns the health service, which reports the registered services SERVING once Serve is called.
+func (s *Server) Health() *health.Server {
+	return s.health
+}
+
+// Serve serves until the server stops, and returns once a graceful stop completes.
+func (s *Server) Serve() error {
+	for name := range s.server.GetServiceInfo() {
+		s.health.SetServingStatus(name, grpc_health_v1.HealthCheckResponse_SERVING)
+	}
+	for _, name := range s.sopts.healthServices {
+		s.health.SetServingStatus(name, grpc_health_v1.HealthCheckResponse_SERVING)
+	}
+
+	if len(s.sopts.stopSignals) > 0 {
+		sigCh := make(chan os.Signal, 1)
+		ossignal.Notify(sigCh, s.sopts.stopSignals...)
+		go func() {
+			defer ossignal.Stop(sigCh)
+			select {
+			case <-sigCh:
+				ctx, cancel := context.WithTimeout(context.Background(), s.sopts.drainTimeout)
+				defer cancel()
+				s.GracefulStop(ctx)
+			case <-s.stopped.Wait():
+			}
+		}()
+	}
+
+	if err := s.server.Serve(s.lis); err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+		return err
+	}
+	<-s.stopped.Wait()
+	return s.stopErr
+}
+
+// GracefulStop reports the services NOT_SERVING, keeps serving for the drain delay, then stops accepting
+// connections and calls and waits for the pending calls to end. Once ctx is done, the pending calls are
+// canceled and ctx.Err() is returned.
+func (s *Server) GracefulStop(ctx context.Context) error {
+	s.stopOnce.Do(func() {
+		defer s.stopped.Close()
+		s.health.Shutdown()
+
+		if s.sopts.drainDelay > 0 {
+			timer := time.NewTimer(s.sopts.drainDelay)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+			}
+		}
+
+		stoppedCh := make(chan struct{})
+		go func() {
+			defer close(stoppedCh)
+			s.server.GracefulStop()
+		}()
+		select {
+		case <-stoppedCh:
+		case <-ctx.Done():
+			s.server.Stop()
+			<-stoppedCh
+			s.stopErr = ctx.Err()
+		}
+	})
+	<-s.stopped.Wait()
+	return s.stopErr
+}
+
+// Stop closes the listener and all connections at once, the pending calls are canceled.
+func (s *Server) Stop() {
+	s.stopOnce.Do(func() {
+		defer s.stopped.Close()
+		s.health.Shutdown()
+		s.server.Stop()
+	})
+	<-s.stopped.Wait()
+}
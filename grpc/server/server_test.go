@@ -0,0 +1,257 @@
+package server
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	pb "github.com/pysugar/wheels/grpc/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+type echoServer struct {
+	pb.UnimplementedEchoServiceServer
+	block chan struct{}
+}
+
+func (s *echoServer) Echo(ctx context.Context, req *pb.EchoRequest) (*pb.EchoResponse, error) {
+	if req.Message == "block" {
+		close(s.block)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	return &pb.EchoResponse{Message: req.Message}, nil
+}
+
+func startServer(t *testing.T, opts ...Option) (*Server, chan error) {
+	t.Helper()
+	s, err := New(opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pb.RegisterEchoServiceServer(s, &echoServer{block: make(chan struct{})})
+	served := make(chan error, 1)
+	go func() {
+		served <- s.Serve()
+	}()
+	t.Cleanup(s.Stop)
+	return s, served
+}
+
+func dial(t *testing.T, target string, cred credentials.TransportCredentials) *grpc.ClientConn {
+	t.Helper()
+	conn, err := grpc.NewClient(target, grpc.WithTransportCredentials(cred))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestServerGracefulStop(t *testing.T) {
+	var unaryCalls int32
+	s, served := startServer(t,
+		WithAddress("127.0.0.1:0"),
+		WithHealthServices("echoservice"),
+		WithDrainDelay(500*time.Millisecond),
+		WithUnaryInterceptors(func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+			atomic.AddInt32(&unaryCalls, 1)
+			return handler(ctx, req)
+		}),
+	)
+	conn := dial(t, s.Addr().String(), insecure.NewCredentials())
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	healthClient := grpc_health_v1.NewHealthClient(conn)
+	for _, name := range []string{"", "proto.EchoService", "echoservice"} {
+		res, err := healthClient.Check(ctx, &grpc_health_v1.HealthCheckRequest{Service: name})
+		if err != nil || res.Status != grpc_health_v1.HealthCheckResponse_SERVING {
+			t.Fatalf("expected %q SERVING, but actually %v, err: %v", name, res, err)
+		}
+	}
+	if res, err := pb.NewEchoServiceClient(conn).Echo(ctx, &pb.EchoRequest{Message: "hello"}); err != nil || res.Message != "hello" {
+		t.Fatalf("expected echo hello, but actually %v, err: %v", res, err)
+	}
+
+	stopped := make(chan error, 1)
+	go func() {
+		stopped <- s.GracefulStop(ctx)
+	}()
+	// calls are still served during the drain delay, reporting NOT_SERVING
+	for {
+		res, err := healthClient.Check(ctx, &grpc_health_v1.HealthCheckRequest{Service: "proto.EchoService"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Status == grpc_health_v1.HealthCheckResponse_NOT_SERVING {
+			break
+		}
+	}
+	if err := <-stopped; err != nil {
+		t.Fatal(err)
+	}
+	if err := <-served; err != nil {
+		t.Fatal(err)
+	}
+	if n := atomic.LoadInt32(&unaryCalls); n < 5 {
+		t.Errorf("expected the interceptor on every unary call, but actually %d calls", n)
+	}
+}
+
+func TestServerGracefulStopTimeout(t *testing.T) {
+	s, err := New(WithAddress("127.0.0.1:0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	impl := &echoServer{block: make(chan struct{})}
+	pb.RegisterEchoServiceServer(s, impl)
+	go s.Serve()
+	conn := dial(t, s.Addr().String(), insecure.NewCredentials())
+
+	callErr := make(chan error, 1)
+	go func() {
+		_, er := pb.NewEchoServiceClient(conn).Echo(context.Background(), &pb.EchoRequest{Message: "block"})
+		callErr <- er
+	}()
+	<-impl.block
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err = s.GracefulStop(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected deadline exceeded, but actually %v", err)
+	}
+	if err = <-callErr; status.Code(err) != codes.Unavailable && status.Code(err) != codes.Canceled {
+		t.Errorf("expected the pending call to end, but actually %v", err)
+	}
+}
+
+func TestServerUnixSocketMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	serverCert := writeCertificate(t, dir, "server", x509.ExtKeyUsageServerAuth)
+	clientCert := writeCertificate(t, dir, "client", x509.ExtKeyUsageClientAuth)
+	socket := filepath.Join(dir, "grpc.sock")
+	stale, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false) // leaves a stale socket file
+	stale.Close()
+
+	s, _ := startServer(t,
+		WithUnixSocket(socket),
+		WithTLS(filepath.Join(dir, "server.pem"), filepath.Join(dir, "server-key.pem"), filepath.Join(dir, "client.pem")),
+		WithMaxRecvMsgSize(1024),
+	)
+	if s.Addr().Network() != "unix" {
+		t.Fatalf("expected a unix socket, but actually %s", s.Addr().Network())
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(serverCert.Leaf)
+	tests := []struct {
+		name    string
+		certs   []tls.Certificate
+		message string
+		code    codes.Code
+	}{
+		{name: "with client certificate", certs: []tls.Certificate{clientCert}, message: "hello", code: codes.OK},
+		{name: "without client certificate", message: "hello", code: codes.Unavailable},
+		{name: "message too large", certs: []tls.Certificate{clientCert}, message: strings.Repeat("x", 2048), code: codes.ResourceExhausted},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cred := credentials.NewTLS(&tls.Config{RootCAs: roots, ServerName: "localhost", Certificates: tt.certs})
+			conn := dial(t, "unix://"+socket, cred)
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			_, err := pb.NewEchoServiceClient(conn).Echo(ctx, &pb.EchoRequest{Message: tt.message})
+			if status.Code(err) != tt.code {
+				t.Errorf("expected %v, but actually %v", tt.code, err)
+			}
+		})
+	}
+}
+
+func TestServerUnixSocketKeepsOtherFiles(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "grpc.conf")
+	if err := os.WriteFile(file, []byte("keep"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(dir, "grpc.sock")
+	if err := os.Symlink(file, link); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range []string{file, link} {
+		if _, err := New(WithUnixSocket(path)); err == nil {
+			t.Errorf("expected an error for %s, which is not a socket", path)
+		}
+		if _, err := os.Lstat(path); err != nil {
+			t.Errorf("expected %s kept, but actually %v", path, err)
+		}
+	}
+}
+
+// writeCertificate writes a self-signed certificate for localhost into dir/name.pem and dir/name-key.pem.
+func writeCertificate(t *testing.T, dir, name string, usage x509.ExtKeyUsage) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: "wheels " + name},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		IsCA:         true,
+
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+	if err = os.WriteFile(filepath.Join(dir, name+".pem"), certPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(filepath.Join(dir, name+"-key.pem"), keyPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"crypto/tls"
+	"encoding/binary"
 	"fmt"
 	"log"
 	"net"
@@ -14,12 +15,23 @@ import (
 	"time"
 
 	http2tool "github.com/pysugar/wheels/binproto/http2"
+	"github.com/pysugar/wheels/http/proxy"
 	"golang.org/x/net/http2/hpack"
 	"google.golang.org/protobuf/proto"
 )
 
 const (
 	ClientPreface = "PRI * HTTP/2.0\r\n\r\nSM\r\n\r\n"
+
+	// Settings Parameters
+	settingInitialWindowSize uint16 = 0x4
+	settingMaxFrameSize      uint16 = 0x5
+
+	// receive windows advertised to the server, replenished on receipt since responses are buffered whole
+	connWindowSize   = 1 << 24
+	streamWindowSize = 1 << 20
+
+	grpcStatusUnimplemented = 12
 )
 
 type (
@@ -31,38 +43,83 @@ type (
 		grpcMessage     string
 		compressionAlgo string
 		payload         []byte
+		inflow          *http2tool.InFlow
 		mu              sync.Mutex
 	}
 
 	grpcClient struct {
-		serverURL     *url.URL
-		conn          net.Conn
-		streamIdGen   uint32
-		clientStreams sync.Map // streamID -> clientStream
-		frameHandlers map[uint8]frameHandler
-		ctx           context.Context
-		cancel        context.CancelFunc
-		encoder       *hpack.Encoder
-		decoder       *hpack.Decoder
-		encoderBuf    bytes.Buffer // encoder buffer
-		encodeMu      sync.Mutex   // protect encoder and encoderBuf
-		decodeMu      sync.Mutex
-		writeMu       sync.Mutex
-		settingsAcked chan struct{}
+		serverURL      *url.URL
+		conn           net.Conn
+		streamIdGen    uint32
+		clientStreams  sync.Map // streamID -> clientStream
+		frameHandlers  map[uint8]frameHandler
+		ctx            context.Context
+		cancel         context.CancelFunc
+		encoder        *hpack.Encoder
+		decoder        *hpack.Decoder
+		encoderBuf     bytes.Buffer // encoder buffer
+		encodeMu       sync.Mutex   // protect encoder and encoderBuf
+		decodeMu       sync.Mutex
+		writeMu        sync.Mutex
+		settingsAcked  chan struct{}
+		compressor     http2tool.Compressor
+		acceptEncoding atomic.Value // grpc-accept-encoding of the server
+		outflow        *http2tool.OutFlow
+		inflow         *http2tool.InFlow
+		maxFrameSize   uint32
 	}
 
 	GRPCClient interface {
 		Call(ctx context.Context, serviceMethod string, req, res proto.Message) error
 		Close()
 	}
+
+	// DialOption configures how NewGRPCClient connects to the server.
+	DialOption func(*dialOptions)
+
+	dialOptions struct {
+		proxy       proxy.Func
+		compression string
+	}
 )
 
 var (
 	clientPreface = []byte(ClientPreface)
 )
 
-func NewGRPCClient(serverURL *url.URL) (GRPCClient, error) {
-	conn, err := dialConn(serverURL)
+// WithProxy connects through proxyURL, an HTTP CONNECT or SOCKS5 proxy, instead of the proxies set by
+// HTTPS_PROXY, HTTP_PROXY, ALL_PROXY and NO_PROXY. A nil proxyURL connects directly.
+func WithProxy(proxyURL *url.URL) DialOption {
+	return func(o *dialOptions) {
+		o.proxy = proxy.Fixed(proxyURL)
+	}
+}
+
+// WithCompression compresses requests with the compressor name of binproto/http2.RegisterCompressor,
+// gzip is built in. Once the server lists its grpc-accept-encoding without name, typically rejecting a call
+// with Unimplemented, requests are sent uncompressed and the rejected call is retried.
+func WithCompression(name string) DialOption {
+	return func(o *dialOptions) {
+		o.compression = name
+	}
+}
+
+func NewGRPCClient(serverURL *url.URL, opts ...DialOption) (GRPCClient, error) {
+	dopts := &dialOptions{}
+	for _, o := range opts {
+		o(dopts)
+	}
+	if dopts.proxy == nil {
+		dopts.proxy = proxy.FromEnvironment()
+	}
+	var compressor http2tool.Compressor
+	if dopts.compression != "" && dopts.compression != http2tool.Identity {
+		if compressor = http2tool.GetCompressor(dopts.compression); compressor == nil {
+			return nil, fmt.Errorf("no compressor registered for %q", dopts.compression)
+		}
+	}
+
+	conn, err := dialConn(serverURL, dopts)
 	if err != nil {
 		return nil, err
 	}
@@ -72,10 +129,14 @@ func NewGRPCClient(serverURL *url.URL) (GRPCClient, error) {
 	settingsAcked := make(chan struct{})
 	client := &grpcClient{
 		serverURL:     serverURL,
+		compressor:    compressor,
 		ctx:           ctx,
 		conn:          conn,
 		cancel:        cancel,
 		settingsAcked: settingsAcked,
+		outflow:       http2tool.NewOutFlow(),
+		inflow:        http2tool.NewInFlow(connWindowSize),
+		maxFrameSize:  http2tool.DefaultMaxFrameSize,
 	}
 
 	client.encoder = hpack.NewEncoder(&client.encoderBuf)
@@ -103,13 +164,23 @@ func NewGRPCClient(serverURL *url.URL) (GRPCClient, error) {
 		4: client.handleSettingsFrame,
 		6: client.handlePingFrame,
 		7: client.handleGoAwayFrame,
+		8: client.handleWindowUpdateFrame,
 	}
 
-	if er := WriteSettingsFrame(conn, 0, nil); er != nil {
+	// Setting: Identifier (2 bytes), Value (4 bytes)
+	settings := make([]byte, 6)
+	binary.BigEndian.PutUint16(settings[:2], settingInitialWindowSize)
+	binary.BigEndian.PutUint32(settings[2:], streamWindowSize)
+	if er := WriteSettingsFrame(conn, 0, settings); er != nil {
 		client.Close()
 		log.Printf("write settings failed: %v", er)
 		return nil, er
 	}
+	if er := WriteWindowUpdateFrame(conn, 0, connWindowSize-http2tool.DefaultWindowSize); er != nil {
+		client.Close()
+		log.Printf("write window update failed: %v", er)
+		return nil, er
+	}
 	log.Printf("Client Settings Write")
 	go client.readLoop(ctx)
 
@@ -124,6 +195,7 @@ func NewGRPCClient(serverURL *url.URL) (GRPCClient, error) {
 
 func (c *grpcClient) Close() {
 	c.cancel()
+	c.outflow.Close()
 	if c.conn != nil {
 		c.conn.Close()
 	}
@@ -134,14 +206,35 @@ func (c *grpcClient) Call(ctx context.Context, serviceMethod string, req, res pr
 	if err != nil {
 		return fmt.Errorf("failed to marshal request: %w", err)
 	}
-	headers := grpcHeaders(c.serverURL, serviceMethod)
+	compressor := c.requestCompressor()
+	grpcStatus, err := c.call(ctx, serviceMethod, compressor, reqBytes, res)
+	// the server lists the encodings it accepts when rejecting ours
+	if compressor != nil && grpcStatus == grpcStatusUnimplemented && c.requestCompressor() == nil {
+		log.Printf("%s rejected by the server, retry uncompressed", compressor.Name())
+		_, err = c.call(ctx, serviceMethod, nil, reqBytes, res)
+	}
+	return err
+}
+
+// call sends a request compressed by compressor, and returns the grpc-status of the response if any, or -1.
+func (c *grpcClient) call(ctx context.Context, serviceMethod string, compressor http2tool.Compressor, reqBytes []byte, res proto.Message) (int, error) {
+	encoding := http2tool.Identity
+	if compressor != nil {
+		encoding = compressor.Name()
+	}
+	reqBody, err := http2tool.EncodeGrpcPayloadWithCompressor(reqBytes, compressor)
+	if err != nil {
+		return -1, err
+	}
+	headers := grpcHeaders(c.serverURL, serviceMethod, encoding)
 
 	c.writeMu.Lock()
 	streamId := atomic.AddUint32(&c.streamIdGen, 2) - 1
+	c.outflow.AddStream(streamId)
 	// log.Printf("Generated stream ID: %d\n", streamId)
 	if er := c.writeHeadersFrame(streamId, headers); er != nil {
 		c.writeMu.Unlock()
-		return er
+		return -1, er
 	}
 	c.writeMu.Unlock()
 
@@ -150,36 +243,47 @@ func (c *grpcClient) Call(ctx context.Context, serviceMethod string, req, res pr
 		activeAt:   time.Now(),
 		doneCh:     make(chan struct{}),
 		grpcStatus: -1,
+		inflow:     http2tool.NewInFlow(streamWindowSize),
 	}
 	c.clientStreams.Store(streamId, cs)
 	defer func() {
 		c.clientStreams.Delete(streamId)
+		c.outflow.RemoveStream(streamId)
 	}()
 
-	c.writeMu.Lock()
-	if er := WriteDataFrame(c.conn, streamId, FlagDataEndStream, http2tool.EncodeGrpcPayload(reqBytes)); er != nil {
-		c.writeMu.Unlock()
-		return er
+	if er := c.writeDataFrames(ctx, streamId, reqBody); er != nil {
+		return -1, er
 	}
-	c.writeMu.Unlock()
 
 	select {
 	case <-cs.doneCh:
 		if cs.grpcStatus != 0 {
-			return fmt.Errorf("[%d] grpc error: %s", cs.grpcStatus, cs.grpcMessage)
+			return cs.grpcStatus, fmt.Errorf("[%d] grpc error: %s", cs.grpcStatus, cs.grpcMessage)
 		}
 		if er := http2tool.DecodeGrpcFrameWithDecompress(cs.payload, cs.compressionAlgo, res); er != nil {
-			return fmt.Errorf("failed to decode response: %w", er)
+			return cs.grpcStatus, fmt.Errorf("failed to decode response: %w", er)
 		}
-		return nil
+		return cs.grpcStatus, nil
 	case <-ctx.Done():
-		return ctx.Err()
+		return -1, ctx.Err()
 	case <-c.ctx.Done():
-		return c.ctx.Err()
+		return -1, c.ctx.Err()
+	}
+}
+
+// requestCompressor returns the compressor of requests, nil unless set by WithCompression or if the server
+// does not accept it.
+func (c *grpcClient) requestCompressor() http2tool.Compressor {
+	if c.compressor == nil {
+		return nil
+	}
+	if v, ok := c.acceptEncoding.Load().(string); ok && !http2tool.AcceptsEncoding(v, c.compressor.Name()) {
+		return nil
 	}
+	return c.compressor
 }
 
-func grpcHeaders(serverURL *url.URL, fullMethod string) []hpack.HeaderField {
+func grpcHeaders(serverURL *url.URL, fullMethod, encoding string) []hpack.HeaderField {
 	if !strings.HasPrefix(fullMethod, "/") {
 		fullMethod = "/" + fullMethod
 	}
@@ -190,8 +294,8 @@ func grpcHeaders(serverURL *url.URL, fullMethod string) []hpack.HeaderField {
 		{Name: ":path", Value: fullMethod},
 		{Name: "content-type", Value: "application/grpc"},
 		{Name: "te", Value: "trailers"},
-		{Name: "grpc-encoding", Value: "identity"},
-		{Name: "grpc-accept-encoding", Value: "identity"},
+		{Name: "grpc-encoding", Value: encoding},
+		{Name: "grpc-accept-encoding", Value: http2tool.AcceptEncoding()},
 	}
 }
 
@@ -210,24 +314,26 @@ func (c *grpcClient) encodeHpackHeaders(headers []hpack.HeaderField) []byte {
 	return c.encoderBuf.Bytes()
 }
 
-func dialConn(serverURL *url.URL) (conn net.Conn, err error) {
+func dialConn(serverURL *url.URL, dopts *dialOptions) (conn net.Conn, err error) {
 	addr := getHostAddress(serverURL)
+	conn, err = proxy.Dial(context.Background(), dopts.proxy, serverURL.Scheme, addr)
+	if err != nil {
+		log.Printf("dial conn err: %v\n", err)
+		return
+	}
 	if serverURL.Scheme == "https" {
 		tlsConfig := &tls.Config{
+			ServerName:         serverURL.Hostname(),
 			InsecureSkipVerify: true, // NOTE: For testing only. Do not use in production.
 			NextProtos:         []string{"h2"},
 		}
-		conn, err = tls.Dial("tcp", addr, tlsConfig)
-		if err != nil {
-			log.Printf("dial conn err: %v\n", err)
-			return
-		}
-	} else {
-		conn, err = net.Dial("tcp", addr)
-		if err != nil {
+		tlsConn := tls.Client(conn, tlsConfig)
+		if err = tlsConn.Handshake(); err != nil {
 			log.Printf("dial conn err: %v\n", err)
+			conn.Close()
 			return
 		}
+		conn = tlsConn
 	}
 
 	log.Printf("[%T] Send HTTP/2 Client Preface: %s\n", conn, clientPreface)
@@ -0,0 +1,22 @@
+package tcpclient_test
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/pysugar/wheels/grpc/tcpclient"
+	"github.com/pysugar/wheels/internal/clienttest"
+)
+
+func TestGRPCClient(t *testing.T) {
+	clienttest.RunGRPCClientTests(t, func(serverURL, proxyURL *url.URL, compression string) (clienttest.GRPCClient, error) {
+		var opts []tcpclient.DialOption
+		if proxyURL != nil {
+			opts = append(opts, tcpclient.WithProxy(proxyURL))
+		}
+		if compression != "" {
+			opts = append(opts, tcpclient.WithCompression(compression))
+		}
+		return tcpclient.NewGRPCClient(serverURL, opts...)
+	})
+}
@@ -8,6 +8,7 @@ import (
 	"io"
 	"log"
 	"strconv"
+	"sync/atomic"
 )
 
 type (
@@ -116,8 +117,32 @@ func (c *grpcClient) readFrame(ctx context.Context, r io.Reader) error {
 }
 
 func (c *grpcClient) handleDataFrame(fh *frameHeader, payload []byte) error {
+	// flow control counts the whole payload, including padding
+	if err := c.inflow.Take(fh.Length); err != nil {
+		return err
+	}
+	if err := c.writeWindowUpdateFrame(0, c.inflow.Add(fh.Length)); err != nil {
+		return err
+	}
+
+	if fh.Flags.Has(FlagDataPadded) {
+		if len(payload) == 0 || int(payload[0]) >= len(payload) {
+			return fmt.Errorf("invalid padding of data frame")
+		}
+		payload = payload[1 : len(payload)-int(payload[0])]
+	}
+
 	if v, loaded := c.clientStreams.Load(fh.StreamID); loaded {
 		if cs, ok := v.(*clientStream); ok {
+			if err := cs.inflow.Take(fh.Length); err != nil {
+				return err
+			}
+			if !fh.Flags.Has(FlagDataEndStream) {
+				if err := c.writeWindowUpdateFrame(fh.StreamID, cs.inflow.Add(fh.Length)); err != nil {
+					return err
+				}
+			}
+
 			cs.mu.Lock()
 			cs.payload = append(cs.payload, payload...)
 			cs.mu.Unlock()
@@ -154,6 +179,8 @@ func (c *grpcClient) handleHeadersFrame(fh *frameHeader, payload []byte) error {
 					cs.grpcMessage = hf.Value
 				} else if hf.Name == "grpc-encoding" {
 					cs.compressionAlgo = hf.Value
+				} else if hf.Name == "grpc-accept-encoding" {
+					c.acceptEncoding.Store(hf.Value)
 				}
 			}
 			if fh.Flags.Has(FlagHeadersEndStream) {
@@ -182,6 +209,20 @@ func (c *grpcClient) handleSettingsFrame(fh *frameHeader, payload []byte) error
 		return nil
 	}
 
+	// Setting: Identifier (2 bytes), Value (4 bytes)
+	for i := 0; i+6 <= len(payload); i += 6 {
+		id, val := binary.BigEndian.Uint16(payload[i:]), binary.BigEndian.Uint32(payload[i+2:])
+		log.Printf("\tsetting %d: %d\n", id, val)
+		switch id {
+		case settingInitialWindowSize:
+			if err := c.outflow.SetInitialWindowSize(val); err != nil {
+				return err
+			}
+		case settingMaxFrameSize:
+			atomic.StoreUint32(&c.maxFrameSize, val)
+		}
+	}
+
 	c.writeMu.Lock()
 	defer c.writeMu.Unlock()
 
@@ -214,6 +255,13 @@ func (c *grpcClient) handleGoAwayFrame(fh *frameHeader, payload []byte) error {
 	return nil //io.EOF
 }
 
+func (c *grpcClient) handleWindowUpdateFrame(fh *frameHeader, payload []byte) error {
+	if len(payload) != 4 {
+		return fmt.Errorf("window update payload should be 4")
+	}
+	return c.outflow.Update(fh.StreamID, binary.BigEndian.Uint32(payload)&(1<<31-1))
+}
+
 func readFrameHeader(r io.Reader) (*frameHeader, error) {
 	var headerBuf [http2frameHeaderLen]byte
 	n, err := io.ReadFull(r, headerBuf[:http2frameHeaderLen])
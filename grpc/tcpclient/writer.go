@@ -1,11 +1,13 @@
 package tcpclient
 
 import (
+	"context"
 	"encoding/binary"
 	"fmt"
 	"golang.org/x/net/http2/hpack"
 	"io"
 	"log"
+	"sync/atomic"
 )
 
 func (c *grpcClient) writeHeadersFrame(streamID uint32, headers []hpack.HeaderField) error {
@@ -13,6 +15,40 @@ func (c *grpcClient) writeHeadersFrame(streamID uint32, headers []hpack.HeaderFi
 	return WriteHeadersFrame(c.conn, streamID, FlagHeadersEndHeaders, blockFragment)
 }
 
+// writeDataFrames writes payload in DATA frames no larger than the send window and SETTINGS_MAX_FRAME_SIZE allow,
+// the last one carries END_STREAM.
+func (c *grpcClient) writeDataFrames(ctx context.Context, streamID uint32, payload []byte) error {
+	for {
+		size := min(len(payload), int(atomic.LoadUint32(&c.maxFrameSize)))
+		n, err := c.outflow.Take(ctx, streamID, size)
+		if err != nil {
+			return err
+		}
+		chunk := payload[:n]
+		payload = payload[n:]
+
+		var flags Flags
+		if len(payload) == 0 {
+			flags = FlagDataEndStream
+		}
+		c.writeMu.Lock()
+		err = WriteDataFrame(c.conn, streamID, flags, chunk)
+		c.writeMu.Unlock()
+		if err != nil || len(payload) == 0 {
+			return err
+		}
+	}
+}
+
+func (c *grpcClient) writeWindowUpdateFrame(streamID, increment uint32) error {
+	if increment == 0 {
+		return nil
+	}
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	return WriteWindowUpdateFrame(c.conn, streamID, increment)
+}
+
 func WriteDataFrame(w io.Writer, streamID uint32, flags Flags, body []byte) error {
 	length := len(body)
 	var buf [http2frameHeaderLen]byte
@@ -114,3 +150,17 @@ func WritePingFrame(w io.Writer, flags Flags, payload []byte) error {
 
 	return nil
 }
+
+func WriteWindowUpdateFrame(w io.Writer, streamID uint32, increment uint32) error {
+	var buf [http2frameHeaderLen + 4]byte
+	buf[2] = 4                                    // Length (3 bytes)
+	buf[3] = 0x8                                  // Type: WINDOW_UPDATE (0x8)
+	binary.BigEndian.PutUint32(buf[5:], streamID) // Stream ID, 0 for the connection
+	binary.BigEndian.PutUint32(buf[http2frameHeaderLen:], increment&(1<<31-1))
+
+	if _, err := w.Write(buf[:]); err != nil {
+		return err
+	}
+	log.Printf("Write window update success, stream: %d, increment: %d\n", streamID, increment)
+	return nil
+}
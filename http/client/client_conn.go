@@ -11,12 +11,14 @@ import (
 	"log"
 	"net"
 	"net/http"
+	"net/http/httptrace"
 	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
+	http2tool "github.com/pysugar/wheels/binproto/http2"
 	"github.com/pysugar/wheels/concurrent"
 	"golang.org/x/net/http2"
 	"golang.org/x/net/http2/hpack"
@@ -26,33 +28,19 @@ const (
 	ClientPreface = "PRI * HTTP/2.0\r\n\r\nSM\r\n\r\n"
 
 	maxPingRetryTimes = 3
+
+	// receive windows advertised to the server, the stream window is replenished as the response body is read
+	connInitialWindowSize   = 1 << 24
+	streamInitialWindowSize = 1 << 20
 )
 
 var (
 	clientPreface = []byte(ClientPreface)
 
-	insecureTLSConfig = &tls.Config{
-		InsecureSkipVerify: true, // NOTE: For testing only. Do not use in production.
-		NextProtos:         []string{"h2", "http/1.1"},
-	}
-
 	clientConnIdGen uint32
 )
 
 type (
-	clientStream struct {
-		streamId        uint32
-		activeAt        time.Time
-		statusCode      int
-		responseHeaders http.Header
-		payload         bytes.Buffer
-		trailers        http.Header
-		mu              sync.Mutex
-		doneCh          chan struct{}
-		doneOnce        sync.Once
-		sse             bool // Server-Sent Events
-	}
-
 	clientConn struct {
 		id                     uint32
 		dopts                  *dialOptions
@@ -74,20 +62,30 @@ type (
 		settingsAcked          chan struct{}
 		pingRequests           map[uint64]chan uint64
 		nextRequest            uint64 // Next key to use in pingRequests.
+		outflow                *http2tool.OutFlow
+		inflow                 *http2tool.InFlow
+		streamWindowSize       int32  // receive window of new streams
+		maxFrameSize           uint32 // SETTINGS_MAX_FRAME_SIZE of the server
+		lastActive             int64  // UnixNano of the last stream end or pick by the Pool, accessed atomically
+		draining               bool   // GOAWAY received, no new streams
 	}
 )
 
-func (cs *clientStream) done() {
-	cs.doneOnce.Do(func() {
-		close(cs.doneCh)
-	})
-}
-
 func dialContext(ctx context.Context, target string, opts ...DialOption) (cc *clientConn, err error) {
+	if VerboseFromContext(ctx) {
+		opts = append(opts, withVerbose())
+	}
 	dopts := evaluateOptions(opts)
 	conn := dopts.conn
 	if conn == nil {
-		conn, err = dialConn(target, dopts.useTLS)
+		var tlsConfig *tls.Config
+		if dopts.useTLS {
+			tlsConfig = dopts.tlsConfig
+			if tlsConfig == nil {
+				tlsConfig = defaultTLSConfig
+			}
+		}
+		conn, err = dialConn(ctx, target, tlsConfig, dopts)
 		if err != nil {
 			return nil, err
 		}
@@ -115,21 +113,21 @@ func dialContext(ctx context.Context, target string, opts ...DialOption) (cc *cl
 		maxConcurrentStreams: 1000,
 		settingsAcked:        make(chan struct{}),
 		pingRequests:         make(map[uint64]chan uint64),
+		outflow:              http2tool.NewOutFlow(),
+		inflow:               http2tool.NewInFlow(connInitialWindowSize),
+		streamWindowSize:     streamInitialWindowSize,
+		maxFrameSize:         http2tool.DefaultMaxFrameSize,
+		lastActive:           time.Now().UnixNano(),
 	}
 
 	cc.encoder = hpack.NewEncoder(&cc.encoderBuf)
 	cc.decoder = hpack.NewDecoder(4096, nil)
 
 	if dopts.h2cUpgrade {
+		// the settings of an upgraded connection are sent in the HTTP2-Settings header, without a window size
+		cc.streamWindowSize = http2tool.DefaultWindowSize
 		streamId := atomic.AddUint32(&cc.streamIdGen, 2) - 1
-		cs := &clientStream{
-			streamId:        streamId,
-			activeAt:        time.Now(),
-			doneCh:          make(chan struct{}),
-			responseHeaders: make(http.Header),
-			trailers:        make(http.Header),
-		}
-		cc.clientStreams.Store(streamId, cs)
+		cc.clientStreams.Store(streamId, newClientStream(cc, streamId, httptrace.ContextClientTrace(ctx)))
 		logger.Println("[clientConn] use h2c upgrade mode")
 	} else {
 		initSettings := []http2.Setting{{
@@ -137,7 +135,7 @@ func dialContext(ctx context.Context, target string, opts ...DialOption) (cc *cl
 			Val: 4096,
 		}, {
 			ID:  http2.SettingInitialWindowSize,
-			Val: 65535,
+			Val: streamInitialWindowSize,
 		}, {
 			ID:  http2.SettingMaxConcurrentStreams,
 			Val: 100,
@@ -149,12 +147,18 @@ func dialContext(ctx context.Context, target string, opts ...DialOption) (cc *cl
 		}
 		logger.Println("[clientConn] client write settings")
 	}
+	if er := framer.WriteWindowUpdate(0, connInitialWindowSize-http2tool.DefaultWindowSize); er != nil {
+		cc.Close()
+		logger.Printf("[clientConn] write window update failed: %v", er)
+		return nil, er
+	}
 
 	go cc.readLoop(gCtx)
 
 	select {
 	case <-cc.settingsAcked:
 		logger.Println("[clientConn] Settings acknowledged by server")
+		timingFromContext(ctx).recordSettingsAcked()
 		cc.maxConcurrentSemaphore = make(chan struct{}, cc.maxConcurrentStreams)
 		return cc, nil
 	case <-time.After(dopts.timeout):
@@ -167,6 +171,8 @@ func dialContext(ctx context.Context, target string, opts ...DialOption) (cc *cl
 	}
 }
 
+// do sends req on a new stream and returns once the response headers arrive.
+// The response body streams the DATA frames of the stream, and its Trailer is populated when the body reaches EOF.
 func (c *clientConn) do(ctx context.Context, req *http.Request) (*http.Response, error) {
 	c.verbose("[%s] start request", req.URL)
 	defer c.verbose("[%s] end request", req.URL)
@@ -174,7 +180,11 @@ func (c *clientConn) do(ctx context.Context, req *http.Request) (*http.Response,
 	c.mu.Lock()
 	if c.closed {
 		c.mu.Unlock()
-		return nil, fmt.Errorf("clientConn closed")
+		return nil, errClientConnClosed
+	}
+	if c.draining {
+		c.mu.Unlock()
+		return nil, errClientConnDraining
 	}
 	c.mu.Unlock()
 
@@ -184,59 +194,67 @@ func (c *clientConn) do(ctx context.Context, req *http.Request) (*http.Response,
 
 	select {
 	case c.maxConcurrentSemaphore <- struct{}{}:
-		defer func() {
-			<-c.maxConcurrentSemaphore
-		}()
 	case <-ctx.Done():
 		return nil, ctx.Err()
 	}
 
-	isSSE := false
-	acceptHeader := req.Header.Get("Accept")
-	if len(acceptHeader) > 0 && strings.Contains(acceptHeader, "text/event-stream") {
-		isSSE = true
-	}
 	isUpgrade := UpgradeFromContext(ctx)
 	var cs *clientStream
-	var err error
 	if isUpgrade {
 		v, ok := c.clientStreams.Load(uint32(1))
 		if !ok {
+			<-c.maxConcurrentSemaphore
 			return nil, fmt.Errorf("[clientConn] h2c upgrade client stream not found for streamId=1")
 		}
 		cs = v.(*clientStream)
-		defer c.clientStreams.Delete(cs.streamId)
 	} else {
+		var err error
 		cs, err = c.writeHeaders(ctx, req)
 		if err != nil {
+			<-c.maxConcurrentSemaphore
 			return nil, err
 		}
-		defer c.clientStreams.Delete(cs.streamId)
-		if req.Body != nil {
-			if er := c.writeBody(ctx, cs.streamId, req.Body); er != nil {
-				return nil, er
-			}
+	}
+
+	connInfoFromContext(ctx).set("HTTP/2.0", cs.streamId, c.conn)
+	traceWroteHeaders(cs.trace)
+	if isUpgrade || req.Body == nil {
+		traceWroteRequest(cs.trace, nil)
+	}
+
+	go func() {
+		select {
+		case <-cs.doneCh:
+		case <-ctx.Done():
+			cs.reset(http2.ErrCodeCancel, ctx.Err())
 		}
+		<-c.maxConcurrentSemaphore
+		atomic.StoreInt64(&c.lastActive, time.Now().UnixNano())
+		c.closeIfDrained()
+	}()
+
+	if !isUpgrade && req.Body != nil {
+		go func() {
+			err := c.writeBody(ctx, cs, req.Body)
+			traceWroteRequest(cs.trace, err)
+			if err != nil {
+				c.verbose("[stream-%03d] write body failed: %v", cs.streamId, err)
+				cs.reset(http2.ErrCodeCancel, err)
+			}
+		}()
 	}
-	cs.sse = isSSE
 
 	select {
+	case <-cs.headerCh:
+		return cs.response(req), nil
 	case <-cs.doneCh:
-		resp := &http.Response{
-			StatusCode:    cs.statusCode,
-			Status:        fmt.Sprintf("%d %s", cs.statusCode, http.StatusText(cs.statusCode)),
-			Proto:         "HTTP/2.0",
-			ProtoMajor:    2,
-			ProtoMinor:    0,
-			Header:        cs.responseHeaders,
-			Trailer:       cs.trailers,
-			Body:          io.NopCloser(&cs.payload),
-			ContentLength: int64(cs.payload.Len()),
-			Request:       req,
-		}
-		return resp, nil
-	case <-ctx.Done():
-		return nil, ctx.Err()
+		cs.mu.Lock()
+		headered, err := cs.headered, cs.err
+		cs.mu.Unlock()
+		if headered {
+			return cs.response(req), nil
+		}
+		return nil, err
 	}
 }
 
@@ -250,12 +268,13 @@ func (c *clientConn) writeHeaders(ctx context.Context, req *http.Request) (*clie
 		cs  *clientStream
 		err error
 	})
+	trace := httptrace.ContextClientTrace(ctx)
 	c.serializer.TrySchedule(func(ctx context.Context) {
 		if ctx.Err() != nil {
 			return
 		}
 
-		cs, er := c.startNewClientStream(headerFields, req.Body == nil)
+		cs, er := c.startNewClientStream(headerFields, req.Body == nil, trace)
 		clientStreamCh <- struct {
 			cs  *clientStream
 			err error
@@ -276,52 +295,115 @@ func (c *clientConn) writeHeaders(ctx context.Context, req *http.Request) (*clie
 	}
 }
 
-func (c *clientConn) writeBody(ctx context.Context, streamId uint32, body io.ReadCloser) error {
-	bodyBytes, err := io.ReadAll(body)
-	if err != nil {
-		return err
+// writeBody streams body in DATA frames, the last one carries END_STREAM.
+func (c *clientConn) writeBody(ctx context.Context, cs *clientStream, body io.ReadCloser) error {
+	defer body.Close()
+
+	buf := make([]byte, atomic.LoadUint32(&c.maxFrameSize))
+	for {
+		n, err := body.Read(buf)
+		endStream := errors.Is(err, io.EOF)
+		if err != nil && !endStream {
+			return err
+		}
+
+		select {
+		case <-cs.doneCh:
+			return nil // the server has ended the stream, the rest of body is not needed
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+
+		if n > 0 || endStream {
+			if er := c.writeData(ctx, cs.streamId, buf[:n], endStream); er != nil {
+				return er
+			}
+		}
+		if endStream {
+			c.verbose("[clientConn] write body done")
+			return nil
+		}
 	}
+}
 
-	errCh := make(chan error)
-	c.serializer.TrySchedule(func(ccCtx context.Context) {
-		if ccCtx.Err() != nil {
-			return
+// writeData writes data in as many DATA frames as the send window and SETTINGS_MAX_FRAME_SIZE of the server require.
+func (c *clientConn) writeData(ctx context.Context, streamId uint32, data []byte, endStream bool) error {
+	for {
+		size := min(len(data), int(atomic.LoadUint32(&c.maxFrameSize)))
+		n, err := c.outflow.Take(ctx, streamId, size)
+		if err != nil {
+			return err
+		}
+		chunk, last := data[:n], endStream && n == len(data)
+		data = data[n:]
+		if er := c.writeFrame(func() error {
+			return c.framer.WriteData(streamId, last, chunk)
+		}); er != nil {
+			return er
 		}
+		if len(data) == 0 {
+			return nil
+		}
+	}
+}
+
+// writeWindowUpdate grants increment bytes of receive window to the server, streamId 0 means the connection.
+func (c *clientConn) writeWindowUpdate(streamId, increment uint32) error {
+	if increment == 0 {
+		return nil
+	}
+	return c.writeFrame(func() error {
+		return c.framer.WriteWindowUpdate(streamId, increment)
+	})
+}
+
+// writeFrame runs write in the serializer, and waits for its result.
+func (c *clientConn) writeFrame(write func() error) error {
+	errCh := make(chan error, 1)
+	c.serializer.ScheduleOr(func(ctx context.Context) {
 		if ctx.Err() != nil {
-			errCh <- ctx.Err()
+			errCh <- errClientConnClosed
 			return
 		}
-		errCh <- c.framer.WriteData(streamId, true, bodyBytes)
+		errCh <- write()
+	}, func() {
+		errCh <- errClientConnClosed
 	})
-
-	c.verbose("[clientConn] write body done")
 	return <-errCh
 }
 
-func (c *clientConn) startNewClientStream(headerFields []hpack.HeaderField, endStream bool) (*clientStream, error) {
+// abortStreams ends all active streams with err.
+func (c *clientConn) abortStreams(err error) {
+	c.clientStreams.Range(func(key, value any) bool {
+		if cs, ok := value.(*clientStream); ok {
+			cs.finish(err)
+		}
+		return true
+	})
+}
+
+func (c *clientConn) startNewClientStream(headerFields []hpack.HeaderField, endStream bool,
+	trace *httptrace.ClientTrace) (*clientStream, error) {
 	headersPayload := c.encodeHpackHeaders(headerFields)
 
+	// the stream is registered before HEADERS goes out, the response may arrive at once
 	streamId := atomic.AddUint32(&c.streamIdGen, 2) - 1
+	cs := newClientStream(c, streamId, trace)
+	c.outflow.AddStream(streamId)
+	c.clientStreams.Store(streamId, cs)
+
 	err := c.framer.WriteHeaders(http2.HeadersFrameParam{
 		StreamID:      streamId,
 		BlockFragment: headersPayload,
 		EndHeaders:    true,
 		EndStream:     endStream,
 	})
-
 	if err != nil {
+		c.clientStreams.Delete(streamId)
+		c.outflow.RemoveStream(streamId)
 		return nil, err
 	}
-
-	cs := &clientStream{
-		streamId:        streamId,
-		activeAt:        time.Now(),
-		doneCh:          make(chan struct{}),
-		responseHeaders: make(http.Header),
-		trailers:        make(http.Header),
-	}
-	c.clientStreams.Store(streamId, cs)
-
 	return cs, nil
 }
 
@@ -334,6 +416,8 @@ func (c *clientConn) Close() error {
 
 	c.closed = true
 	c.cancel()
+	c.outflow.Close()
+	c.abortStreams(errClientConnClosed)
 	if c.conn != nil {
 		return c.conn.Close()
 	}
@@ -375,7 +459,7 @@ func (c *clientConn) isValid(ctx context.Context) bool {
 
 	select {
 	case ackKey, ok := <-req:
-		log.Printf("[clientConn] ping acked-: %v, target: %s, req: %d, ack: %d, cost: %dμs", ok, c.target,
+		c.verbose("[clientConn] ping acked-: %v, target: %s, req: %d, ack: %d, cost: %dμs", ok, c.target,
 			reqKey, ackKey, time.Since(waitStart).Microseconds())
 		return true
 	case <-ctx.Done():
@@ -388,6 +472,47 @@ func (c *clientConn) isValid(ctx context.Context) bool {
 	}
 }
 
+func (c *clientConn) isClosed() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.closed
+}
+
+func (c *clientConn) isDraining() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.draining
+}
+
+func (c *clientConn) pingsInFlight() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.pingRequests)
+}
+
+func (c *clientConn) activeStreams() int {
+	return len(c.maxConcurrentSemaphore)
+}
+
+func (c *clientConn) maxStreams() int {
+	return cap(c.maxConcurrentSemaphore)
+}
+
+// touch marks the connection as just used, and returns how long it had been idle.
+func (c *clientConn) touch() time.Duration {
+	now := time.Now()
+	var idle time.Duration
+	if c.activeStreams() == 0 {
+		idle = c.idleFor(now)
+	}
+	atomic.StoreInt64(&c.lastActive, now.UnixNano())
+	return idle
+}
+
+func (c *clientConn) idleFor(now time.Time) time.Duration {
+	return now.Sub(time.Unix(0, atomic.LoadInt64(&c.lastActive)))
+}
+
 func (c *clientConn) readLoop(ctx context.Context) {
 	for {
 		c.mu.Lock()
@@ -404,7 +529,8 @@ func (c *clientConn) readLoop(ctx context.Context) {
 			if err := c.readFrame(ctx); err != nil {
 				if errors.Is(err, io.EOF) {
 					log.Printf("Connection closed by remote host")
-					c.cancel()
+					c.abortStreams(io.ErrUnexpectedEOF)
+					c.Close()
 					return
 				}
 				log.Printf("Failed to read frame: %v", err)
@@ -425,7 +551,7 @@ func (c *clientConn) sendRecvPingWithRetries(ctx context.Context, reqKey uint64,
 
 		select {
 		case ackKey, ok := <-req:
-			log.Printf("[clientConn] ping acked+: %v, target: %s, req: %d, ack: %d, cost: %dμs", ok, c.target,
+			c.verbose("[clientConn] ping acked+: %v, target: %s, req: %d, ack: %d, cost: %dμs", ok, c.target,
 				reqKey, ackKey, time.Since(waitStart).Microseconds())
 			return true
 		case <-time.After(pingTimeout):
@@ -520,6 +646,7 @@ func (c *clientConn) readFrame(ctx context.Context) error {
 	case *http2.GoAwayFrame: // 7
 		return c.processGoAwayFrame(f)
 	case *http2.WindowUpdateFrame: //8
+		return c.processWindowUpdateFrame(f)
 	case *http2.ContinuationFrame: //9
 	default:
 		log.Printf("Received unknown frame: %T", f)
@@ -528,6 +655,15 @@ func (c *clientConn) readFrame(ctx context.Context) error {
 }
 
 func (c *clientConn) processDataFrame(f *http2.DataFrame) error {
+	// the connection window is replenished on receipt, DATA of closed streams counts too
+	if err := c.inflow.Take(f.Length); err != nil {
+		c.Close()
+		return err
+	}
+	if err := c.writeWindowUpdate(0, c.inflow.Add(f.Length)); err != nil {
+		return err
+	}
+
 	v, loaded := c.clientStreams.Load(f.StreamID)
 	if !loaded {
 		log.Printf("Stream %d not found", f.StreamID)
@@ -539,17 +675,21 @@ func (c *clientConn) processDataFrame(f *http2.DataFrame) error {
 		return nil
 	}
 
-	cs.mu.Lock()
-	defer cs.mu.Unlock()
-
-	if cs.sse {
-		fmt.Printf("sse: %s", f.Data())
-	} else {
-		cs.payload.Write(f.Data())
+	if err := cs.inflow.Take(f.Length); err != nil {
+		cs.reset(http2.ErrCodeFlowControl, err)
+		return nil
+	}
+	// padding is not delivered to the body, so it is returned to the window at once
+	if padding := f.Length - uint32(len(f.Data())); padding > 0 {
+		if err := c.writeWindowUpdate(f.StreamID, cs.inflow.Add(padding)); err != nil {
+			return err
+		}
+	}
+	if len(f.Data()) > 0 {
+		cs.write(f.Data())
 	}
-
 	if f.StreamEnded() {
-		cs.done()
+		cs.finish(io.EOF)
 	}
 	return nil
 }
@@ -571,18 +711,14 @@ func (c *clientConn) processHeaderFrame(f *http2.HeadersFrame) error {
 		return fmt.Errorf("[stream-%03d] Failed to decode headers: %w", f.StreamID, err)
 	}
 
-	if f.StreamEnded() {
+	cs.mu.Lock()
+	if cs.headered {
 		for _, hf := range headers {
 			c.verbose("\t< Received Trailer (%s: %s)", hf.Name, hf.Value)
 			cs.trailers.Add(hf.Name, hf.Value)
-			if hf.Name == ":status" {
-				if statusCode, er := strconv.Atoi(hf.Value); er == nil {
-					cs.statusCode = statusCode
-				}
-			}
 		}
-		cs.done()
 	} else {
+		traceGotFirstResponseByte(cs.trace)
 		for _, hf := range headers {
 			c.verbose("\t< Received Header (%s: %s)", hf.Name, hf.Value)
 			cs.responseHeaders.Add(hf.Name, hf.Value)
@@ -591,12 +727,17 @@ func (c *clientConn) processHeaderFrame(f *http2.HeadersFrame) error {
 					cs.statusCode = statusCode
 				}
 			}
-			if hf.Name == "content-type" && hf.Value == "text/event-stream" {
-				if !cs.sse {
-					cs.sse = true
-				}
+			// a trailers-only response carries its trailers in the only HEADERS frame
+			if f.StreamEnded() {
+				cs.trailers.Add(hf.Name, hf.Value)
 			}
 		}
+		cs.headersReceivedLocked()
+	}
+	cs.mu.Unlock()
+
+	if f.StreamEnded() {
+		cs.finish(io.EOF)
 	}
 	return nil
 }
@@ -604,9 +745,20 @@ func (c *clientConn) processHeaderFrame(f *http2.HeadersFrame) error {
 func (c *clientConn) processRSTStreamFrame(f *http2.RSTStreamFrame) error {
 	if v, loaded := c.clientStreams.Load(f.StreamID); loaded {
 		if cs, ok := v.(*clientStream); ok {
-			cs.trailers.Add("grpc-status", strconv.Itoa(int(f.ErrCode)))
-			cs.trailers.Add("grpc-message", "stream reset by server")
-			cs.done()
+			cs.finish(http2.StreamError{StreamID: f.StreamID, Code: f.ErrCode})
+		}
+	}
+	return nil
+}
+
+func (c *clientConn) processWindowUpdateFrame(f *http2.WindowUpdateFrame) error {
+	if err := c.outflow.Update(f.StreamID, f.Increment); err != nil {
+		if f.StreamID == 0 {
+			c.Close()
+			return err
+		}
+		if v, loaded := c.clientStreams.Load(f.StreamID); loaded {
+			v.(*clientStream).reset(http2.ErrCodeFlowControl, err)
 		}
 	}
 	return nil
@@ -626,6 +778,15 @@ func (c *clientConn) processSettingsFrame(f *http2.SettingsFrame) error {
 			c.maxConcurrentStreams = settings.Val
 			c.settingsAcked <- struct{}{}
 		}
+		switch settings.ID {
+		case http2.SettingInitialWindowSize:
+			if err := c.outflow.SetInitialWindowSize(settings.Val); err != nil {
+				c.Close()
+				return err
+			}
+		case http2.SettingMaxFrameSize:
+			atomic.StoreUint32(&c.maxFrameSize, settings.Val)
+		}
 		c.verbose("\t%+v: %d\n", settings.ID, settings.Val)
 	}
 
@@ -649,20 +810,38 @@ func (c *clientConn) processPingFrame(f *http2.PingFrame) error {
 	return c.sendPing(true, f.Data)
 }
 
+// processGoAwayFrame drains the connection: the streams up to LastStreamID run to completion, the others were
+// not processed by the server and end with the GOAWAY error, and the connection is closed once no stream is left.
 func (c *clientConn) processGoAwayFrame(f *http2.GoAwayFrame) error {
 	c.verbose("Received GOAWAY frame: LastStreamID=%d, SteamID=%d, ErrorCode=%d, DebugData=%s", f.LastStreamID,
 		f.StreamID, f.ErrCode, f.DebugData())
-	defer c.Close()
+	c.mu.Lock()
+	c.draining = true
+	c.mu.Unlock()
+
+	goAwayErr := http2.GoAwayError{
+		LastStreamID: f.LastStreamID,
+		ErrCode:      f.ErrCode,
+		DebugData:    string(f.DebugData()),
+	}
 	c.clientStreams.Range(func(key, value any) bool {
-		if cs, ok := value.(*clientStream); ok {
-			cs.trailers.Add("grpc-status", strconv.Itoa(int(f.ErrCode)))
-			cs.trailers.Add("grpc-message", string(f.DebugData()))
-			c.clientStreams.Delete(cs.streamId)
-			cs.done()
+		if cs, ok := value.(*clientStream); ok && cs.streamId > f.LastStreamID {
+			cs.finish(goAwayErr)
 		}
 		return true
 	})
-	return nil //io.EOF
+	c.closeIfDrained()
+	return nil
+}
+
+// closeIfDrained closes a draining connection without active streams.
+func (c *clientConn) closeIfDrained() {
+	c.mu.Lock()
+	drained := c.draining && len(c.maxConcurrentSemaphore) == 0
+	c.mu.Unlock()
+	if drained {
+		c.Close()
+	}
 }
 
 func (c *clientConn) encodeHpackHeaders(headers []hpack.HeaderField) []byte {
@@ -700,7 +879,7 @@ func (c *clientConn) createHeaderFields(req *http.Request) ([]hpack.HeaderField,
 
 	for key, values := range req.Header {
 		for _, value := range values {
-			headers = append(headers, hpack.HeaderField{Name: strings.ToLower(key), Value: strings.ToLower(value)})
+			headers = append(headers, hpack.HeaderField{Name: strings.ToLower(key), Value: value})
 		}
 	}
 
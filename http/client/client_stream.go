@@ -0,0 +1,179 @@
+package client
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptrace"
+	"strconv"
+	"sync"
+	"time"
+
+	http2tool "github.com/pysugar/wheels/binproto/http2"
+	"golang.org/x/net/http2"
+)
+
+var (
+	errClientConnClosed   = errors.New("clientConn closed")
+	errClientConnDraining = errors.New("clientConn draining after GOAWAY")
+	errResponseBodyClosed = errors.New("http2: response body closed")
+)
+
+// clientStream is a single HTTP/2 stream of a clientConn. It is also the streaming response body,
+// fed by DATA frames from the read loop as they arrive.
+type clientStream struct {
+	cc              *clientConn
+	streamId        uint32
+	activeAt        time.Time
+	statusCode      int
+	responseHeaders http.Header
+	trailers        http.Header
+	inflow          *http2tool.InFlow // receive window, replenished as the body is read
+	trace           *httptrace.ClientTrace
+
+	mu       sync.Mutex
+	payload  bytes.Buffer // received but not yet read DATA
+	err      error        // io.EOF once the stream ended normally
+	dataCh   chan struct{}
+	headerCh chan struct{} // closed once response headers arrive
+	headered bool
+	doneCh   chan struct{} // closed once the stream ended, was reset, or was canceled
+	doneOnce sync.Once
+}
+
+func newClientStream(cc *clientConn, streamId uint32, trace *httptrace.ClientTrace) *clientStream {
+	return &clientStream{
+		cc:              cc,
+		streamId:        streamId,
+		trace:           trace,
+		activeAt:        time.Now(),
+		responseHeaders: make(http.Header),
+		trailers:        make(http.Header),
+		inflow:          http2tool.NewInFlow(cc.streamWindowSize),
+		dataCh:          make(chan struct{}, 1),
+		headerCh:        make(chan struct{}),
+		doneCh:          make(chan struct{}),
+	}
+}
+
+func (cs *clientStream) done() {
+	cs.doneOnce.Do(func() {
+		cs.cc.clientStreams.Delete(cs.streamId)
+		cs.cc.outflow.RemoveStream(cs.streamId)
+		close(cs.doneCh)
+	})
+}
+
+// headersReceivedLocked marks the response headers as received, it must be called with cs.mu held.
+func (cs *clientStream) headersReceivedLocked() {
+	if !cs.headered {
+		cs.headered = true
+		close(cs.headerCh)
+	}
+}
+
+// write appends DATA received from the server.
+func (cs *clientStream) write(data []byte) {
+	cs.mu.Lock()
+	if cs.err == nil {
+		cs.payload.Write(data)
+	}
+	cs.mu.Unlock()
+
+	select {
+	case cs.dataCh <- struct{}{}:
+	default:
+	}
+}
+
+// finish ends the stream. Reads return err once all received DATA is consumed.
+func (cs *clientStream) finish(err error) {
+	cs.mu.Lock()
+	if cs.err == nil {
+		cs.err = err
+	}
+	cs.mu.Unlock()
+	cs.done()
+}
+
+// reset sends RST_STREAM to the server and ends the stream with err.
+func (cs *clientStream) reset(code http2.ErrCode, err error) {
+	select {
+	case <-cs.doneCh:
+		return
+	default:
+	}
+	cs.finish(err)
+	cs.cc.writeFrame(func() error {
+		return cs.cc.framer.WriteRSTStream(cs.streamId, code)
+	})
+}
+
+// Read implements io.Reader, it blocks until DATA arrives or the stream ends.
+func (cs *clientStream) Read(p []byte) (int, error) {
+	for {
+		cs.mu.Lock()
+		if cs.payload.Len() > 0 {
+			n, _ := cs.payload.Read(p)
+			cs.mu.Unlock()
+			cs.consumed(n)
+			return n, nil
+		}
+		err := cs.err
+		cs.mu.Unlock()
+		if err != nil {
+			return 0, err
+		}
+
+		select {
+		case <-cs.dataCh:
+		case <-cs.doneCh:
+		}
+	}
+}
+
+// consumed grants n bytes read from the body back to the server's send window.
+func (cs *clientStream) consumed(n int) {
+	increment := cs.inflow.Add(uint32(n))
+	if increment == 0 {
+		return
+	}
+	select {
+	case <-cs.doneCh:
+		return // no more DATA is expected
+	default:
+	}
+	cs.cc.writeWindowUpdate(cs.streamId, increment)
+}
+
+// Close implements io.Closer. The stream is reset if the server has not ended it yet.
+func (cs *clientStream) Close() error {
+	cs.reset(http2.ErrCodeCancel, errResponseBodyClosed)
+	cs.mu.Lock()
+	cs.payload.Reset()
+	cs.mu.Unlock()
+	return nil
+}
+
+func (cs *clientStream) response(req *http.Request) *http.Response {
+	contentLength := int64(-1)
+	if v := cs.responseHeaders.Get("content-length"); v != "" {
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+			contentLength = n
+		}
+	}
+	return &http.Response{
+		StatusCode:    cs.statusCode,
+		Status:        fmt.Sprintf("%d %s", cs.statusCode, http.StatusText(cs.statusCode)),
+		Proto:         "HTTP/2.0",
+		ProtoMajor:    2,
+		ProtoMinor:    0,
+		Header:        cs.responseHeaders,
+		Trailer:       cs.trailers,
+		Body:          cs,
+		ContentLength: contentLength,
+		Request:       req,
+		TLS:           tlsState(cs.cc.conn),
+	}
+}
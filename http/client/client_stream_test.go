@@ -0,0 +1,194 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+func newH2CServer(t *testing.T, handler http.Handler) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(h2c.NewHandler(handler, &http2.Server{}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func dialH2CServer(t *testing.T, server *httptest.Server) *clientConn {
+	t.Helper()
+	cc, err := dialContext(context.Background(), server.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { cc.Close() })
+	return cc
+}
+
+func TestClientConnStreamingResponse(t *testing.T) {
+	next := make(chan struct{})
+	server := newH2CServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Trailer", "X-Checksum")
+		w.WriteHeader(http.StatusOK)
+		io.WriteString(w, "first\n")
+		w.(http.Flusher).Flush()
+		<-next
+		io.WriteString(w, "second\n")
+		w.Header().Set("X-Checksum", "42")
+	}))
+	cc := dialH2CServer(t, server)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/stream", nil)
+	res, err := cc.do(ctx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Fatal("unexpected status: ", res.Status)
+	}
+
+	// the first chunk is readable while the handler is still blocked
+	reader := bufio.NewReader(res.Body)
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	if line != "first\n" {
+		t.Error("unexpected first line: ", line)
+	}
+	if v := res.Trailer.Get("X-Checksum"); v != "" {
+		t.Error("unexpected trailer before EOF: ", v)
+	}
+
+	close(next)
+	rest, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(rest) != "second\n" {
+		t.Error("unexpected rest of body: ", string(rest))
+	}
+	if v := res.Trailer.Get("X-Checksum"); v != "42" {
+		t.Error("expected trailer 42, but actually ", v)
+	}
+}
+
+func TestClientConnStreamingRequest(t *testing.T) {
+	server := newH2CServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		scanner := bufio.NewScanner(r.Body)
+		for scanner.Scan() {
+			fmt.Fprintf(w, "echo: %s\n", scanner.Text())
+			w.(http.Flusher).Flush()
+		}
+	}))
+	cc := dialH2CServer(t, server)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	pr, pw := io.Pipe()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, server.URL+"/echo", pr)
+	res, err := cc.do(ctx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	// each line of the request body is echoed before the next one is written
+	reader := bufio.NewReader(res.Body)
+	for i := 0; i < 3; i++ {
+		fmt.Fprintf(pw, "line-%d\n", i)
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expected := fmt.Sprintf("echo: line-%d\n", i); line != expected {
+			t.Error("expected ", expected, " but actually ", line)
+		}
+	}
+	pw.Close()
+	if rest, err := io.ReadAll(reader); err != nil || len(rest) != 0 {
+		t.Error("unexpected rest of body: ", string(rest), err)
+	}
+}
+
+func TestClientConnCloseBody(t *testing.T) {
+	canceled := make(chan struct{})
+	server := newH2CServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+		close(canceled)
+	}))
+	cc := dialH2CServer(t, server)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/wait", nil)
+	res, err := cc.do(ctx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+
+	select {
+	case <-canceled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the stream reset on server side")
+	}
+	if _, err := res.Body.Read(make([]byte, 1)); err == nil || !strings.Contains(err.Error(), "closed") {
+		t.Error("expected closed body error, but actually ", err)
+	}
+}
+
+func TestClientConnFlowControl(t *testing.T) {
+	const size = 8 << 20 // far beyond the initial windows on both sides
+	server := newH2CServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n, err := io.Copy(io.Discard, r.Body)
+		if err != nil || n != size {
+			http.Error(w, fmt.Sprintf("received %d bytes, err: %v", n, err), http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		io.Copy(w, io.LimitReader(zeroReader{}, size))
+	}))
+	cc := dialH2CServer(t, server)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, server.URL+"/upload", io.LimitReader(zeroReader{}, size))
+	res, err := cc.do(ctx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(res.Body)
+		t.Fatal("unexpected status: ", res.Status, string(body))
+	}
+	n, err := io.Copy(io.Discard, res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != size {
+		t.Errorf("expected %d bytes, but actually %d", size, n)
+	}
+}
+
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
@@ -3,9 +3,18 @@ package client
 import (
 	"bytes"
 	"encoding/binary"
+	"errors"
 	"fmt"
+	"io"
+
+	http2tool "github.com/pysugar/wheels/binproto/http2"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
+// DefaultMaxRecvMsgSize limits the size of received gRPC messages, 4MB as in grpc-go.
+const DefaultMaxRecvMsgSize = 4 << 20
+
 func EncodeGrpcPayload(payload []byte) []byte {
 	var buf bytes.Buffer
 	buf.WriteByte(0x00) // 标志位（0 表示未压缩）
@@ -24,6 +33,53 @@ func DecodeGrpcPayload(data []byte) ([]byte, error) {
 		return nil, fmt.Errorf("compressed responses are not supported in this client")
 	}
 	messageLength := binary.BigEndian.Uint32(data[1:5])
+	if uint64(len(data)-5) < uint64(messageLength) {
+		return nil, fmt.Errorf("truncated grpc frame data, expected %d bytes, got %d", messageLength, len(data)-5)
+	}
 	messageData := data[5 : 5+messageLength]
 	return messageData, nil
 }
+
+// ReadGrpcMessage reads the next length-prefixed message of a gRPC body, regardless of how the messages are
+// split into DATA frames. Compressed messages are decompressed by encoding, the grpc-encoding of the response.
+// A message larger than maxSize, before or after decompression, fails with a ResourceExhausted status error
+// and is not read. It returns io.EOF at the end of the body.
+func ReadGrpcMessage(r io.Reader, encoding string, maxSize int) ([]byte, error) {
+	var header [5]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, fmt.Errorf("truncated grpc message header")
+		}
+		return nil, err
+	}
+	length := binary.BigEndian.Uint32(header[1:])
+	if uint64(length) > uint64(maxSize) {
+		return nil, errMessageTooLarge(uint64(length), maxSize)
+	}
+	data := make([]byte, length)
+	if _, err := io.ReadFull(r, data); err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, fmt.Errorf("failed to read grpc message of %d bytes, err: %v", len(data), err)
+	}
+	if header[0] == 0 {
+		return data, nil
+	}
+	dr, err := http2tool.DecompressReader(bytes.NewReader(data), encoding)
+	if err != nil {
+		return nil, err
+	}
+	// the decompressed message is read no further than the limit, whatever its size
+	if data, err = io.ReadAll(io.LimitReader(dr, int64(maxSize)+1)); err != nil {
+		return nil, fmt.Errorf("failed to decompress grpc message, err: %v", err)
+	}
+	if len(data) > maxSize {
+		return nil, status.Errorf(codes.ResourceExhausted, "grpc: received message after decompression larger than max %d", maxSize)
+	}
+	return data, nil
+}
+
+func errMessageTooLarge(size uint64, maxSize int) error {
+	return status.Errorf(codes.ResourceExhausted, "grpc: received message larger than max (%d vs. %d)", size, maxSize)
+}
@@ -0,0 +1,101 @@
+package client
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+)
+
+const acceptEncoding = "gzip, deflate, br"
+
+type (
+	// decodingBody decodes a compressed response body, the decoder is created on the first Read
+	// so that headers of streaming responses are available before any content arrives.
+	decodingBody struct {
+		body      io.ReadCloser
+		encoding  string
+		once      sync.Once
+		decoder   io.Reader
+		decodeErr error
+	}
+)
+
+// WithoutDecompression sends requests without a default Accept-Encoding header, and returns response bodies
+// as received.
+func WithoutDecompression() FetcherOption {
+	return func(o *fetcherOptions) {
+		o.disableDecompression = true
+	}
+}
+
+// requestCompression sets Accept-Encoding on req unless the caller already has, in which case decoding the
+// response is left to the caller too. It reports whether the response is to be decoded.
+func requestCompression(req *http.Request) bool {
+	if req.Method == http.MethodHead || req.Header.Get("Accept-Encoding") != "" || req.Header.Get("Range") != "" {
+		return false
+	}
+	req.Header.Set("Accept-Encoding", acceptEncoding)
+	return true
+}
+
+// decompressResponse replaces the body of res with the decoded content, if it is encoded in a supported way.
+func decompressResponse(res *http.Response) {
+	encoding := strings.ToLower(strings.TrimSpace(res.Header.Get("Content-Encoding")))
+	switch encoding {
+	case "gzip", "x-gzip", "deflate", "br":
+	default:
+		return
+	}
+	if res.Body == nil || res.Body == http.NoBody {
+		return
+	}
+	res.Body = &decodingBody{body: res.Body, encoding: encoding}
+	res.Header.Del("Content-Encoding")
+	res.Header.Del("Content-Length")
+	res.ContentLength = -1
+	res.Uncompressed = true
+}
+
+func (b *decodingBody) Read(p []byte) (int, error) {
+	b.once.Do(func() {
+		b.decoder, b.decodeErr = newDecoder(b.body, b.encoding)
+	})
+	if b.decodeErr != nil {
+		return 0, b.decodeErr
+	}
+	return b.decoder.Read(p)
+}
+
+func (b *decodingBody) Close() error {
+	if c, ok := b.decoder.(io.Closer); ok {
+		c.Close()
+	}
+	return b.body.Close()
+}
+
+func newDecoder(r io.Reader, encoding string) (io.Reader, error) {
+	switch encoding {
+	case "gzip", "x-gzip":
+		return gzip.NewReader(r)
+	case "deflate":
+		// "deflate" is zlib (RFC 1950), although some servers send raw deflate (RFC 1951)
+		br := bufio.NewReader(r)
+		if header, err := br.Peek(2); err == nil && isZlibHeader(header) {
+			return zlib.NewReader(br)
+		}
+		return flate.NewReader(br), nil
+	default:
+		return brotli.NewReader(r), nil
+	}
+}
+
+func isZlibHeader(header []byte) bool {
+	return header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0
+}
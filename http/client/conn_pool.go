@@ -2,81 +2,57 @@ package client
 
 import (
 	"context"
-	"fmt"
 	"log"
 	"sync"
 	"sync/atomic"
-
-	"golang.org/x/sync/singleflight"
+	"time"
 )
 
+// connPool holds the HTTP/2 connections in a Pool, and the idle HTTP/1.1 connections, several per target.
 type connPool struct {
-	rw      sync.RWMutex
-	conns   map[string]*clientConn
-	g       *singleflight.Group
-	verbose uint32
+	h2       *Pool
+	sharedH2 bool // h2 is given by WithPool, and closed by its owner
+	verbose  uint32
+
+	idleMu              sync.Mutex
+	idleConns           map[string][]*persistConn // scheme://host:port -> idle conns, most recently used last
+	idleClosed          bool
+	maxIdleConnsPerHost int
+	idleConnTimeout     time.Duration
 }
 
 func newConnPool() *connPool {
 	return &connPool{
-		conns: make(map[string]*clientConn),
-		g:     new(singleflight.Group),
+		h2:                  NewPool(),
+		idleConns:           make(map[string][]*persistConn),
+		maxIdleConnsPerHost: defaultMaxIdleConnsPerHost,
+		idleConnTimeout:     defaultIdleConnTimeout,
 	}
 }
 
-func (p *connPool) Close() (err error) {
-	p.rw.Lock()
-	defer p.rw.Unlock()
-	for _, conn := range p.conns {
-		err = conn.Close()
+func (cp *connPool) Close() error {
+	cp.closeIdleConns()
+	if cp.sharedH2 {
+		return nil
 	}
-	p.conns = nil
-	return err
+	return cp.h2.Close()
 }
 
+// getConn returns an HTTP/2 connection to target, dialed with opts if the pool has none available.
 func (cp *connPool) getConn(ctx context.Context, target string, opts ...DialOption) (*clientConn, error) {
-	cp.rw.RLock()
-	cc := cp.conns[target]
-	cp.rw.RUnlock()
+	return cp.getConnFunc(ctx, target, func(ctx context.Context) (*clientConn, error) {
+		return dialContext(ctx, target, opts...)
+	})
+}
 
-	verboseFromContext := VerboseFromContext(ctx)
-	if verboseFromContext {
+// getConnFunc returns an HTTP/2 connection of key, dial opens a new one if the pool has none available.
+func (cp *connPool) getConnFunc(ctx context.Context, key string, dial func(context.Context) (*clientConn, error)) (*clientConn, error) {
+	if VerboseFromContext(ctx) {
 		atomic.CompareAndSwapUint32(&cp.verbose, 0, 1)
 	} else {
 		atomic.CompareAndSwapUint32(&cp.verbose, 1, 0)
 	}
-
-	if cc != nil {
-		if cc.isValid(ctx) {
-			cp.printf("[connPool] get conn success from cache, target: %s", target)
-			return cc, nil
-		}
-		fmt.Printf("[connPool] get conn fail from cache, target: %s\n", target)
-		cc.Close()
-	}
-
-	v, err, shared := cp.g.Do(target, func() (interface{}, error) {
-		if verboseFromContext {
-			opts = append(opts, withVerbose())
-		}
-
-		cp.printf("[connPool] connect to target: %s", target)
-		return dialContext(ctx, target, opts...)
-	})
-
-	if err != nil {
-		cp.printf("[connPool] get conn err, target: %s, shared: %v, err: %v", target, shared, err)
-		return nil, err
-	}
-
-	cc = v.(*clientConn)
-	cp.printf("[connPool] get conn-%05d success, target: %s, shared: %v", cc.id, target, shared)
-
-	cp.rw.Lock()
-	defer cp.rw.Unlock()
-	cp.conns[target] = cc
-
-	return cc, nil
+	return cp.h2.getConn(ctx, key, dial)
 }
 
 func (cp *connPool) printf(format string, v ...interface{}) {
@@ -1,12 +1,33 @@
 package client
 
-import "context"
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+)
 
 type (
 	contextKey struct {
 		name string
 	}
 	HttpProtocol uint8
+
+	// ConnInfo describes the connection a request was sent on, the Fetcher fills it in if the context carries it
+	// by WithConnInfo. Following redirects, it describes the last request.
+	ConnInfo struct {
+		Proto      string // HTTP/1.1 or HTTP/2.0
+		StreamID   uint32 // the HTTP/2 stream, 0 for HTTP/1.1
+		LocalAddr  net.Addr
+		RemoteAddr net.Addr
+		TLS        *tls.ConnectionState
+	}
+
+	// RoundTripHook is called by the Fetcher before each request it sends, every redirect of Do and the gRPC
+	// calls included, if the context carries it by WithRoundTripHook. It may wrap the body of req, and returns
+	// the function called once the response headers arrived or the round trip failed, with the connection of
+	// req, which returns the response to go on with, e.g. with its body wrapped.
+	RoundTripHook func(req *http.Request) func(res *http.Response, info *ConnInfo, err error) *http.Response
 )
 
 const (
@@ -24,6 +45,8 @@ var (
 	upgradeCtxKey  = &contextKey{"upgrade"}
 	gorillaCtxKey  = &contextKey{"gorilla"}
 	insecureCtxKey = &contextKey{"insecure"}
+	connInfoCtxKey = &contextKey{"conninfo"}
+	hookCtxKey     = &contextKey{"roundtriphook"}
 )
 
 func (hp HttpProtocol) String() string {
@@ -63,6 +86,14 @@ func WithInsecure(ctx context.Context) context.Context {
 	return context.WithValue(ctx, insecureCtxKey, true)
 }
 
+func WithConnInfo(ctx context.Context, info *ConnInfo) context.Context {
+	return context.WithValue(ctx, connInfoCtxKey, info)
+}
+
+func WithRoundTripHook(ctx context.Context, hook RoundTripHook) context.Context {
+	return context.WithValue(ctx, hookCtxKey, hook)
+}
+
 func ProtocolFromContext(ctx context.Context) HttpProtocol {
 	if protocol, ok := ctx.Value(protocolCtxKey).(HttpProtocol); ok {
 		return protocol
@@ -89,3 +120,35 @@ func InsecureFromContext(ctx context.Context) bool {
 	_, ok := ctx.Value(insecureCtxKey).(bool)
 	return ok
 }
+
+func connInfoFromContext(ctx context.Context) *ConnInfo {
+	info, _ := ctx.Value(connInfoCtxKey).(*ConnInfo)
+	return info
+}
+
+func roundTripHookFromContext(ctx context.Context) RoundTripHook {
+	hook, _ := ctx.Value(hookCtxKey).(RoundTripHook)
+	return hook
+}
+
+// set describes conn in info, if info is not nil.
+func (info *ConnInfo) set(proto string, streamId uint32, conn net.Conn) {
+	if info == nil {
+		return
+	}
+	*info = ConnInfo{
+		Proto:      proto,
+		StreamID:   streamId,
+		LocalAddr:  conn.LocalAddr(),
+		RemoteAddr: conn.RemoteAddr(),
+		TLS:        tlsState(conn),
+	}
+}
+
+func tlsState(conn net.Conn) *tls.ConnectionState {
+	if tlsConn, ok := conn.(*tls.Conn); ok {
+		state := tlsConn.ConnectionState()
+		return &state
+	}
+	return nil
+}
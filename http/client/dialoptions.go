@@ -1,8 +1,13 @@
 package client
 
 import (
+	"crypto/tls"
 	"net"
+	"net/url"
+	"sync"
 	"time"
+
+	"github.com/pysugar/wheels/http/proxy"
 )
 
 // dialOptions configure a Dial call. dialOptions are set by the DialOption values passed to Dial.
@@ -14,6 +19,8 @@ type dialOptions struct {
 	timeout     time.Duration
 	verbose     bool
 	conn        net.Conn
+	tlsConfig   *tls.Config
+	proxy       proxy.Func
 }
 
 type DialOption func(*dialOptions)
@@ -24,6 +31,9 @@ var (
 		verbose:     false,
 		sendPreface: true,
 	}
+
+	// the proxies of the environment are read once, as by net/http
+	envProxyFunc = sync.OnceValue(proxy.FromEnvironment)
 )
 
 func WithTLS() DialOption {
@@ -32,6 +42,28 @@ func WithTLS() DialOption {
 	}
 }
 
+// WithTLSConfig dials with TLS using cfg instead of the default configuration.
+func WithTLSConfig(cfg *tls.Config) DialOption {
+	return func(o *dialOptions) {
+		o.useTLS = true
+		o.tlsConfig = cfg
+	}
+}
+
+// WithProxy dials through proxyURL, an HTTP CONNECT (http, https) or SOCKS5 (socks5, socks5h) proxy.
+// A nil proxyURL dials directly, ignoring the environment.
+func WithProxy(proxyURL *url.URL) DialOption {
+	return WithProxyFunc(proxy.Fixed(proxyURL))
+}
+
+// WithProxyFunc dials through the proxy returned by proxyFunc for each target. Without a proxy option,
+// the proxies are taken from HTTPS_PROXY, HTTP_PROXY, ALL_PROXY and NO_PROXY.
+func WithProxyFunc(proxyFunc proxy.Func) DialOption {
+	return func(o *dialOptions) {
+		o.proxy = proxyFunc
+	}
+}
+
 func DisableSendPreface() DialOption {
 	return func(o *dialOptions) {
 		o.sendPreface = false
@@ -62,6 +94,14 @@ func withVerbose() DialOption {
 	}
 }
 
+// proxyFunc returns the proxies of the dial, which are those of the environment unless set by an option.
+func (o *dialOptions) proxyFunc() proxy.Func {
+	if o.proxy == nil {
+		return envProxyFunc()
+	}
+	return o.proxy
+}
+
 func evaluateOptions(opts []DialOption) *dialOptions {
 	optCopy := &dialOptions{}
 	*optCopy = *defaultDialOptions
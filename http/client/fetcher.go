@@ -1,34 +1,34 @@
 package client
 
 import (
-	"bytes"
 	"context"
 	"crypto/tls"
 	"errors"
 	"fmt"
-	"io"
 	"net"
 	"net/http"
 	"net/url"
-	"strconv"
-	"strings"
+	"sync"
 
-	"google.golang.org/grpc/codes"
-	"google.golang.org/grpc/status"
+	"github.com/pysugar/wheels/http/proxy"
 	"google.golang.org/protobuf/proto"
 )
 
 type (
 	Fetcher interface {
 		Do(context.Context, *http.Request) (*http.Response, error)
-		WS(ctx context.Context, req *http.Request) error
+		DialWebSocket(ctx context.Context, req *http.Request) (WSConn, error)
 		CallGRPC(ctx context.Context, serviceURL *url.URL, req, res proto.Message) error
+		NewGRPCStream(ctx context.Context, serviceURL *url.URL) (GRPCStream, error)
 		Close() error
 	}
 
 	fetcher struct {
 		userAgent string
 		connPool  *connPool
+		fopts     *fetcherOptions
+
+		grpcAcceptEncodings sync.Map // authority -> grpc-accept-encoding of the server
 	}
 )
 
@@ -36,105 +36,100 @@ var (
 	ErrHTTP2Unsupported = errors.New("unsupported protocol http2")
 )
 
-func NewFetcher() Fetcher {
+func NewFetcher(opts ...FetcherOption) Fetcher {
+	fopts := evaluateFetcherOptions(opts)
+	connPool := newConnPool()
+	if fopts.maxIdleConnsPerHost != 0 {
+		connPool.maxIdleConnsPerHost = fopts.maxIdleConnsPerHost
+	}
+	if fopts.idleConnTimeout > 0 {
+		connPool.idleConnTimeout = fopts.idleConnTimeout
+	}
+	if fopts.pool != nil {
+		connPool.h2.Close()
+		connPool.h2, connPool.sharedH2 = fopts.pool, true
+	}
 	return &fetcher{
-		connPool: newConnPool(),
+		connPool: connPool,
+		fopts:    fopts,
 	}
 }
 
+// Do sends req and follows redirects, the cookie jar and decompression apply to every request on the way.
 func (f *fetcher) Do(ctx context.Context, req *http.Request) (*http.Response, error) {
 	logger := newVerboseLogger(ctx)
 	logger.Printf("[http] protocol: %v", ProtocolFromContext(ctx))
 	logger.Printf("[http] upgrade: %v", UpgradeFromContext(ctx))
 	logger.Printf("[http] gorilla: %v", GorillaFromContext(ctx))
-	useTLS := req.URL.Scheme == "https"
-	if useTLS {
-		return f.doTLS(ctx, req)
-	}
-	return f.doHTTP(ctx, req)
-}
-
-func (f *fetcher) WS(ctx context.Context, req *http.Request) error {
-	logger := newVerboseLogger(ctx)
-	logger.Printf("[ws] protocol: %v", ProtocolFromContext(ctx))
-	logger.Printf("[ws] upgrade: %v", UpgradeFromContext(ctx))
-	logger.Printf("[ws] gorilla: %v", GorillaFromContext(ctx))
-	if GorillaFromContext(ctx) {
-		return f.doGorilla(ctx, req)
-	}
-	return f.doWebsocket(ctx, req)
-}
 
-func (f *fetcher) CallGRPC(ctx context.Context, serviceURL *url.URL, req, res proto.Message) error {
-	logger := newVerboseLogger(ctx)
-	logger.Printf("[grpc] protocol: %v", ProtocolFromContext(ctx))
-	logger.Printf("[grpc] upgrade: %v", UpgradeFromContext(ctx))
-	logger.Printf("[grpc] gorilla: %v", GorillaFromContext(ctx))
-
-	ctx = WithProtocol(ctx, HTTP2)
-	reqBytes, err := proto.Marshal(req)
-	if err != nil {
-		return err
-	}
-	reqBodyBytes := EncodeGrpcPayload(reqBytes)
-	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, serviceURL.String(), bytes.NewReader(reqBodyBytes))
-	if err != nil {
-		return err
-	}
-
-	httpReq.Header.Set("content-type", "application/grpc")
-	httpReq.Header.Set("te", "trailers")
-	httpReq.Header.Set("grpc-encoding", "identity")
-	httpReq.Header.Set("grpc-accept-encoding", "identity")
+	fopts := f.options()
+	var via []*http.Request
+	for {
+		// the headers added here are not carried over to redirects, the jar supplies the cookies of each hop
+		out := req.Clone(req.Context())
+		if fopts.jar != nil {
+			for _, cookie := range fopts.jar.Cookies(out.URL) {
+				out.AddCookie(cookie)
+			}
+		}
+		decompress := !fopts.disableDecompression && requestCompression(out)
 
-	if logger.Verbose() {
-		logger.Printf("\t> %s %s HTTP/1.1\r\n", valueOrDefault(httpReq.Method, http.MethodGet), serviceURL.RequestURI())
-		for k, v := range httpReq.Header {
-			logger.Printf("\t> %s: %s", k, strings.Join(v, ","))
+		res, err := f.roundTrip(ctx, out, decompress)
+		if err != nil {
+			return nil, err
+		}
+		if fopts.jar != nil {
+			if cookies := res.Cookies(); len(cookies) > 0 {
+				fopts.jar.SetCookies(out.URL, cookies)
+			}
 		}
-		logger.Printf("\t> \r\n")
-	}
 
-	httpRes, err := f.Do(ctx, httpReq)
-	if err != nil {
-		return err
-	}
-	if logger.Verbose() {
-		logger.Printf("\t< %s %s\r\n", httpRes.Status, httpRes.Proto)
-		for k, v := range httpRes.Header {
-			logger.Printf("\t< %s: %s\r\n", k, strings.Join(v, ","))
+		next, err := fopts.redirectRequest(req, res, via)
+		if err != nil {
+			res.Body.Close()
+			return nil, err
 		}
-		for k, v := range httpRes.Trailer {
-			logger.Printf("\t< %s: %s\r\n", k, strings.Join(v, ","))
+		if next == nil {
+			return res, nil
 		}
+		logger.Printf("[http] %s redirect to %s", res.Status, next.URL)
+		res.Body.Close()
+		via = append(via, req)
+		req = next
 	}
+}
 
-	grpcStatus := 0
-	grpcMessage := ""
-	if v := httpRes.Trailer.Get("grpc-status"); v != "" {
-		grpcStatus, _ = strconv.Atoi(v)
-	}
-	if v := httpRes.Trailer.Get("grpc-message"); v != "" {
-		grpcMessage = v
-	}
-	st := status.New(codes.Code(grpcStatus), grpcMessage)
-	if st.Err() != nil {
-		return st.Err()
+// roundTrip sends req, decompress decodes the content of the response. The RoundTripHook of ctx, if any, sees
+// the response as it is returned.
+func (f *fetcher) roundTrip(ctx context.Context, req *http.Request, decompress bool) (res *http.Response, err error) {
+	if hook := roundTripHookFromContext(ctx); hook != nil {
+		done := hook(req)
+		info, outer := new(ConnInfo), connInfoFromContext(ctx)
+		ctx = WithConnInfo(ctx, info)
+		defer func() {
+			if outer != nil {
+				*outer = *info
+			}
+			res = done(res, info, err)
+		}()
 	}
 
-	resBodyBytes, err := io.ReadAll(httpRes.Body)
-	if err != nil {
-		return err
-	}
-	resBytes, err := DecodeGrpcPayload(resBodyBytes)
-	if err != nil {
-		return err
+	timing := timingFromContext(ctx)
+	timing.begin()
+	traceGetConn(ctx, req.URL.Host)
+
+	if req.URL.Scheme == "https" {
+		res, err = f.doTLS(ctx, req)
+	} else {
+		res, err = f.doHTTP(ctx, req)
 	}
-	err = proto.Unmarshal(resBytes, res)
-	if err != nil {
-		return err
+	if err == nil {
+		timing.wrap(res)
+		if decompress {
+			decompressResponse(res)
+		}
 	}
-	return nil
+	return res, err
 }
 
 func (f *fetcher) doHTTP(ctx context.Context, req *http.Request) (*http.Response, error) {
@@ -153,65 +148,152 @@ func (f *fetcher) doHTTP(ctx context.Context, req *http.Request) (*http.Response
 }
 
 func (f *fetcher) doTLS(ctx context.Context, req *http.Request) (*http.Response, error) {
-	conn, err := dialConn(req.Host, true)
-	if err != nil {
-		return nil, err
+	protocol := ProtocolFromContext(ctx)
+	key := f.tlsConnKey(ctx, req.Host)
+	if protocol != HTTP2 {
+		// an idle HTTP/1.1 connection tells that the server did not negotiate h2
+		if res, ok, err := f.doHTTP1Idle(ctx, req, key); ok {
+			return res, err
+		}
 	}
 
-	tlsConn, ok := conn.(*tls.Conn)
-	if !ok {
-		return nil, fmt.Errorf("expected *tls.Conn, got %T", conn)
+	var conn net.Conn // dialed for HTTP/1.1 if the server does not negotiate h2
+	if protocol != HTTP1 && protocol != HTTP10 && protocol != HTTP11 {
+		cc, err := f.connPool.getConnFunc(ctx, key, func(ctx context.Context) (*clientConn, error) {
+			c, er := f.dial(ctx, req.Host, f.tlsConfig(ctx, req.Host))
+			if er != nil {
+				return nil, er
+			}
+			tlsConn, ok := c.(*tls.Conn)
+			if !ok {
+				c.Close()
+				return nil, fmt.Errorf("expected *tls.Conn, got %T", c)
+			}
+			cc, er := f.dialHTTP2WithTLS(ctx, tlsConn, req)
+			if errors.Is(er, ErrHTTP2Unsupported) {
+				conn = c
+			}
+			return cc, er
+		})
+		if err == nil {
+			return cc.do(ctx, req)
+		}
+		if protocol == HTTP2 || !errors.Is(err, ErrHTTP2Unsupported) {
+			if conn != nil {
+				conn.Close()
+			}
+			return nil, err
+		}
 	}
 
-	protocol := ProtocolFromContext(ctx)
-	if protocol == HTTP2 {
-		return f.doHTTP2WithTLS(ctx, tlsConn, req)
-	} else if protocol == HTTP1 || protocol == HTTP10 || protocol == HTTP11 {
-		return f.doHTTP1WithConn(ctx, req, conn)
-	} else {
-		res, er := f.doHTTP2WithTLS(ctx, tlsConn, req)
-		if !errors.Is(er, ErrHTTP2Unsupported) {
-			return res, er
+	if conn == nil {
+		var err error
+		if conn, err = f.dial(ctx, req.Host, f.tlsConfig(ctx, req.Host)); err != nil {
+			return nil, err
 		}
-		return f.doHTTP1WithConn(ctx, req, conn)
 	}
+	return f.doHTTP1WithConn(ctx, req, newPersistConn(key, conn))
 }
 
-func (f *fetcher) doHTTP2WithTLS(ctx context.Context, tlsConn *tls.Conn, req *http.Request) (*http.Response, error) {
+// dialHTTP2WithTLS starts HTTP/2 on tlsConn if the server negotiated h2, it returns ErrHTTP2Unsupported otherwise.
+func (f *fetcher) dialHTTP2WithTLS(ctx context.Context, tlsConn *tls.Conn, req *http.Request) (*clientConn, error) {
 	state := tlsConn.ConnectionState()
 	logger := newVerboseLogger(ctx)
 	logger.Printf("NegotiatedProtocol: %s\n", state.NegotiatedProtocol)
 
-	if state.NegotiatedProtocol == "h2" {
-		if _, err := tlsConn.Write(clientPreface); err != nil {
-			return nil, err
-		}
-		cc, err := f.connPool.getConn(ctx, req.URL.Host, WithConn(tlsConn), DisableSendPreface())
-		if err == nil {
-			logger.Printf("[%s] Connect using NegotiatedProtocol", req.URL.RequestURI())
-			return cc.do(ctx, req)
-		}
+	if state.NegotiatedProtocol != "h2" {
+		return nil, ErrHTTP2Unsupported
+	}
+	if _, err := tlsConn.Write(clientPreface); err != nil {
+		tlsConn.Close()
+		return nil, err
+	}
+	cc, err := dialContext(ctx, req.URL.Host, WithConn(tlsConn), DisableSendPreface())
+	if err != nil {
 		logger.Printf("[%s] Failed to connect using NegotiatedProtocol: %v", req.URL.RequestURI(), err)
+		tlsConn.Close()
+		return nil, err
 	}
-	return nil, ErrHTTP2Unsupported
+	logger.Printf("[%s] Connect using NegotiatedProtocol", req.URL.RequestURI())
+	return cc, nil
 }
 
 func (f *fetcher) Close() error {
 	return f.connPool.Close()
 }
 
-func dialConn(addr string, useTLS bool) (net.Conn, error) {
-	if useTLS {
+// tlsConfig returns the TLS configuration of requests to host, certificate verification is skipped only if
+// requested by WithInsecureSkipVerify or WithInsecure.
+func (f *fetcher) tlsConfig(ctx context.Context, host string) *tls.Config {
+	insecure := InsecureFromContext(ctx)
+	if insecure {
+		newVerboseLogger(ctx).Printf("[%s] insecure skip verify", host)
+	}
+	return f.options().tlsConfig(host, insecure)
+}
+
+// tlsConnKey identifies the pooled TLS connections to host. Connections dialed without certificate
+// verification, or with other TLS options, are kept apart, so that they never serve requests which expect
+// stricter ones, also in a Pool shared by several Fetchers.
+func (f *fetcher) tlsConnKey(ctx context.Context, host string) string {
+	fopts := f.options()
+	key := connKey("https", host)
+	if fopts.insecure || InsecureFromContext(ctx) {
+		key = connKey("https+insecure", host)
+	}
+	if fopts.tlsKey != "" {
+		key += "#" + fopts.tlsKey
+	}
+	return key
+}
+
+func (f *fetcher) options() *fetcherOptions {
+	if f.fopts == nil {
+		return evaluateFetcherOptions(nil)
+	}
+	return f.fopts
+}
+
+// dial connects to addr with the dial options of the fetcher.
+func (f *fetcher) dial(ctx context.Context, addr string, tlsConfig *tls.Config) (net.Conn, error) {
+	return dialConn(ctx, addr, tlsConfig, evaluateOptions(f.options().dialOptions))
+}
+
+// dialConn connects to addr through the proxy of dopts, using TLS if tlsConfig is not nil.
+func dialConn(ctx context.Context, addr string, tlsConfig *tls.Config, dopts *dialOptions) (net.Conn, error) {
+	scheme := "http"
+	if tlsConfig != nil {
+		scheme = "https"
 		if !hasPort(addr) {
 			addr += ":443"
 		}
-		return tls.Dial("tcp", addr, insecureTLSConfig)
+	} else if !hasPort(addr) {
+		addr += ":80"
 	}
 
-	if !hasPort(addr) {
-		addr += ":80"
+	if dopts.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, dopts.timeout)
+		defer cancel()
+	}
+	conn, err := proxy.Dial(ctx, dopts.proxyFunc(), scheme, addr)
+	if err != nil || tlsConfig == nil {
+		return conn, err
+	}
+
+	if tlsConfig.ServerName == "" {
+		tlsConfig = tlsConfig.Clone()
+		tlsConfig.ServerName = hostname(addr)
+	}
+	tlsConn := tls.Client(conn, tlsConfig)
+	traceTLSHandshakeStart(ctx)
+	err = tlsConn.HandshakeContext(ctx)
+	traceTLSHandshakeDone(ctx, tlsConn.ConnectionState(), err)
+	if err != nil {
+		conn.Close()
+		return nil, err
 	}
-	return net.Dial("tcp", addr)
+	return tlsConn, nil
 }
 
 // hasPort checks if the host includes a port
@@ -0,0 +1,69 @@
+package client
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"time"
+)
+
+// fetcherOptions configure a Fetcher. fetcherOptions are set by the FetcherOption values passed to NewFetcher.
+type fetcherOptions struct {
+	// tls
+	rootCAs      *x509.CertPool
+	certificates []tls.Certificate
+	serverName   string
+	minVersion   uint16
+	pins         [][]byte // SHA-256 of the SubjectPublicKeyInfo
+	nextProtos   []string
+	insecure     bool
+	tlsKey       string // fingerprint of the options above, see tlsFingerprint
+
+	// websocket
+	wsSubprotocols []string
+	wsCompression  bool
+	wsPingInterval time.Duration
+	wsPongTimeout  time.Duration
+
+	// http/2 connections
+	pool *Pool
+
+	// http/1.1 keep-alive
+	maxIdleConnsPerHost int
+	idleConnTimeout     time.Duration
+
+	// redirects, cookies and compression
+	maxRedirects         int
+	checkRedirect        func(req *http.Request, via []*http.Request) error
+	redirectStripHeaders []string
+	jar                  http.CookieJar
+	disableDecompression bool
+
+	// dialing
+	dialOptions []DialOption
+
+	// grpc
+	grpcCompression    string
+	grpcMaxRecvMsgSize int
+}
+
+type FetcherOption func(*fetcherOptions)
+
+// WithDialOptions applies opts to the connections dialed by the Fetcher, such as WithProxy and WithTimeout.
+func WithDialOptions(opts ...DialOption) FetcherOption {
+	return func(o *fetcherOptions) {
+		o.dialOptions = append(o.dialOptions, opts...)
+	}
+}
+
+func evaluateFetcherOptions(opts []FetcherOption) *fetcherOptions {
+	fopts := &fetcherOptions{
+		wsPongTimeout:      defaultPongTimeout,
+		grpcMaxRecvMsgSize: DefaultMaxRecvMsgSize,
+	}
+	for _, o := range opts {
+		o(fopts)
+	}
+	fopts.tlsKey = fopts.tlsFingerprint()
+	return fopts
+}
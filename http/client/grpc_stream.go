@@ -0,0 +1,402 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	http2tool "github.com/pysugar/wheels/binproto/http2"
+	"golang.org/x/net/http2"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+type (
+	// GRPCStream is a client-streaming, server-streaming or bidi-streaming gRPC call.
+	// Send and CloseSend may be called concurrently with Recv, but not with each other.
+	GRPCStream interface {
+		// Send sends msg to the server. It returns io.EOF if the stream has ended,
+		// the status of the call is then returned by Recv.
+		Send(msg proto.Message) error
+		// CloseSend ends the messages from the client.
+		CloseSend() error
+		// Recv receives the next message from the server into msg. It returns io.EOF once the call
+		// finished with status OK, or the status error of the call.
+		Recv(msg proto.Message) error
+		// Trailer returns the trailers of the server, available after Recv returned io.EOF or a status error.
+		Trailer() http.Header
+	}
+
+	grpcStream struct {
+		ctx        context.Context
+		cancel     context.CancelFunc
+		pw         *io.PipeWriter       // nil for unary calls, whose request body is complete
+		compressor http2tool.Compressor // nil sends messages uncompressed
+		maxRecv    int
+
+		resCh chan struct{} // closed once the response headers arrive or the round trip failed
+		res   *http.Response
+		err   error
+
+		recvMu  sync.Mutex
+		recvErr error // io.EOF or the status error, once the call finished
+		trailer http.Header
+	}
+)
+
+// WithGRPCCompression compresses the gRPC messages sent with the compressor name of
+// binproto/http2.RegisterCompressor, gzip is built in. Once a server lists its grpc-accept-encoding without name,
+// typically rejecting a call with Unimplemented, messages to it are sent uncompressed and CallGRPC retries
+// the rejected call. Compressed responses are decompressed regardless of this option.
+func WithGRPCCompression(name string) FetcherOption {
+	return func(o *fetcherOptions) {
+		o.grpcCompression = name
+	}
+}
+
+// WithGRPCMaxRecvMsgSize limits the size of the gRPC messages received, 4MB by default. A larger message,
+// once decompressed, fails the call with ResourceExhausted.
+func WithGRPCMaxRecvMsgSize(n int) FetcherOption {
+	return func(o *fetcherOptions) {
+		o.grpcMaxRecvMsgSize = n
+	}
+}
+
+// NewGRPCStream starts a streaming call of the method at serviceURL, i.e. scheme://host/package.Service/Method.
+// The call is canceled with RST_STREAM once ctx is done, and the deadline of ctx is sent as grpc-timeout.
+// h2c calls use prior knowledge, as an HTTP/1.1 Upgrade request can not stream its body.
+func (f *fetcher) NewGRPCStream(ctx context.Context, serviceURL *url.URL) (GRPCStream, error) {
+	compressor, err := f.grpcCompressor(serviceURL.Host)
+	if err != nil {
+		return nil, err
+	}
+	pr, pw := io.Pipe()
+	s, err := f.newGRPCStream(context.WithValue(ctx, upgradeCtxKey, nil), serviceURL, compressor, pr, -1)
+	if err != nil {
+		return nil, err
+	}
+	s.pw = pw
+	// writing the request body stops once the call is over, a pending body is reset rather than ended
+	context.AfterFunc(s.ctx, func() {
+		pr.CloseWithError(s.ctx.Err())
+	})
+	return s, nil
+}
+
+func (f *fetcher) CallGRPC(ctx context.Context, serviceURL *url.URL, req, res proto.Message) error {
+	reqBytes, err := proto.Marshal(req)
+	if err != nil {
+		return err
+	}
+	compressor, err := f.grpcCompressor(serviceURL.Host)
+	if err != nil {
+		return err
+	}
+	err = f.callGRPC(ctx, serviceURL, compressor, reqBytes, res)
+	if compressor != nil && status.Code(err) == codes.Unimplemented {
+		// the server lists the encodings it accepts when rejecting ours
+		if c, _ := f.grpcCompressor(serviceURL.Host); c == nil {
+			newVerboseLogger(ctx).Printf("[grpc] %s rejected by the server, retry uncompressed", compressor.Name())
+			return f.callGRPC(ctx, serviceURL, nil, reqBytes, res)
+		}
+	}
+	return err
+}
+
+func (f *fetcher) callGRPC(ctx context.Context, serviceURL *url.URL, compressor http2tool.Compressor, reqBytes []byte, res proto.Message) error {
+	reqBody, err := http2tool.EncodeGrpcPayloadWithCompressor(reqBytes, compressor)
+	if err != nil {
+		return err
+	}
+	s, err := f.newGRPCStream(ctx, serviceURL, compressor, io.NopCloser(bytes.NewReader(reqBody)), int64(len(reqBody)))
+	if err != nil {
+		return err
+	}
+	defer s.cancel()
+
+	if err = s.Recv(res); err != nil {
+		if errors.Is(err, io.EOF) {
+			return status.Error(codes.Internal, "grpc: the server sent no response message")
+		}
+		return err
+	}
+	if err = s.Recv(new(emptypb.Empty)); !errors.Is(err, io.EOF) {
+		if err == nil {
+			return status.Error(codes.Internal, "grpc: the server sent more than one response message")
+		}
+		return err
+	}
+	return nil
+}
+
+// newGRPCStream sends the request headers of a call with body, and receives the response in the background.
+func (f *fetcher) newGRPCStream(ctx context.Context, serviceURL *url.URL, compressor http2tool.Compressor,
+	body io.ReadCloser, contentLength int64) (*grpcStream, error) {
+	logger := newVerboseLogger(ctx)
+	logger.Printf("[grpc] protocol: %v", ProtocolFromContext(ctx))
+	logger.Printf("[grpc] upgrade: %v", UpgradeFromContext(ctx))
+
+	ctx, cancel := context.WithCancel(WithProtocol(ctx, HTTP2))
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, serviceURL.String(), body)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	httpReq.ContentLength = contentLength
+
+	httpReq.Header.Set("content-type", "application/grpc")
+	httpReq.Header.Set("te", "trailers")
+	httpReq.Header.Set("grpc-encoding", http2tool.Identity)
+	if compressor != nil {
+		httpReq.Header.Set("grpc-encoding", compressor.Name())
+	}
+	httpReq.Header.Set("grpc-accept-encoding", http2tool.AcceptEncoding())
+	if deadline, ok := ctx.Deadline(); ok {
+		httpReq.Header.Set("grpc-timeout", encodeGrpcTimeout(time.Until(deadline)))
+	}
+
+	if logger.Verbose() {
+		logger.Printf("\t> %s %s HTTP/2.0\r\n", httpReq.Method, serviceURL.RequestURI())
+		for k, v := range httpReq.Header {
+			logger.Printf("\t> %s: %s", k, strings.Join(v, ","))
+		}
+		logger.Printf("\t> \r\n")
+	}
+
+	s := &grpcStream{
+		ctx:        ctx,
+		cancel:     cancel,
+		compressor: compressor,
+		maxRecv:    f.options().grpcMaxRecvMsgSize,
+		resCh:      make(chan struct{}),
+	}
+	go func() {
+		defer close(s.resCh)
+		s.res, s.err = f.roundTrip(ctx, httpReq, false)
+		if s.err != nil {
+			body.Close()
+			return
+		}
+		if v := s.res.Header.Get("grpc-accept-encoding"); v != "" {
+			f.grpcAcceptEncodings.Store(serviceURL.Host, v)
+		}
+		if logger.Verbose() {
+			logger.Printf("\t< %s %s\r\n", s.res.Status, s.res.Proto)
+			for k, v := range s.res.Header {
+				logger.Printf("\t< %s: %s\r\n", k, strings.Join(v, ","))
+			}
+		}
+	}()
+	return s, nil
+}
+
+func (s *grpcStream) Send(msg proto.Message) error {
+	if s.pw == nil {
+		return errors.New("grpc: Send on a unary call")
+	}
+	b, err := proto.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	frame, err := http2tool.EncodeGrpcPayloadWithCompressor(b, s.compressor)
+	if err != nil {
+		return err
+	}
+	if _, err = s.pw.Write(frame); err != nil {
+		return io.EOF
+	}
+	return nil
+}
+
+func (s *grpcStream) CloseSend() error {
+	if s.pw == nil {
+		return nil
+	}
+	return s.pw.Close()
+}
+
+func (s *grpcStream) Recv(msg proto.Message) error {
+	select {
+	case <-s.resCh:
+	case <-s.ctx.Done():
+		return s.finish(nil, streamError(s.ctx.Err()))
+	}
+
+	s.recvMu.Lock()
+	defer s.recvMu.Unlock()
+	if s.recvErr != nil {
+		return s.recvErr
+	}
+	if s.err != nil {
+		return s.finishLocked(nil, streamError(s.err))
+	}
+	if s.res.StatusCode != http.StatusOK && s.res.Trailer.Get("grpc-status") == "" {
+		s.res.Body.Close()
+		return s.finishLocked(s.res.Trailer, status.Errorf(httpStatusCode(s.res.StatusCode),
+			"grpc: unexpected HTTP status %s", s.res.Status))
+	}
+
+	data, err := ReadGrpcMessage(s.res.Body, s.res.Header.Get("grpc-encoding"), s.maxRecv)
+	if errors.Is(err, io.EOF) {
+		s.res.Body.Close()
+		newVerboseLogger(s.ctx).Printf("\t< trailers: %v", s.res.Trailer)
+		if err = grpcStatus(s.res.Trailer).Err(); err == nil {
+			err = io.EOF
+		}
+		return s.finishLocked(s.res.Trailer, err)
+	}
+	if err != nil {
+		s.res.Body.Close()
+		if ctxErr := s.ctx.Err(); ctxErr != nil {
+			err = ctxErr // the reset of a canceled call may surface as another error first
+		}
+		return s.finishLocked(s.res.Trailer, streamError(err))
+	}
+	if err = proto.Unmarshal(data, msg); err != nil {
+		return status.Errorf(codes.Internal, "grpc: failed to unmarshal the response, err: %v", err)
+	}
+	return nil
+}
+
+func (s *grpcStream) Trailer() http.Header {
+	s.recvMu.Lock()
+	defer s.recvMu.Unlock()
+	return s.trailer
+}
+
+func (s *grpcStream) finish(trailer http.Header, err error) error {
+	s.recvMu.Lock()
+	defer s.recvMu.Unlock()
+	if s.recvErr != nil {
+		return s.recvErr
+	}
+	return s.finishLocked(trailer, err)
+}
+
+// finishLocked ends the call with err, it must be called with s.recvMu held.
+func (s *grpcStream) finishLocked(trailer http.Header, err error) error {
+	s.trailer = trailer
+	s.recvErr = err
+	s.cancel()
+	return err
+}
+
+// grpcCompressor returns the compressor of the messages sent to authority, which is nil unless configured
+// by WithGRPCCompression, or if the server does not accept it.
+func (f *fetcher) grpcCompressor(authority string) (http2tool.Compressor, error) {
+	name := f.options().grpcCompression
+	if name == "" || name == http2tool.Identity {
+		return nil, nil
+	}
+	compressor := http2tool.GetCompressor(name)
+	if compressor == nil {
+		return nil, fmt.Errorf("grpc: no compressor registered for %q", name)
+	}
+	if v, ok := f.grpcAcceptEncodings.Load(authority); ok && !http2tool.AcceptsEncoding(v.(string), name) {
+		return nil, nil
+	}
+	return compressor, nil
+}
+
+// grpcStatus returns the status carried by the grpc-status and grpc-message trailers.
+func grpcStatus(trailer http.Header) *status.Status {
+	v := trailer.Get("grpc-status")
+	if v == "" {
+		return status.New(codes.Internal, "grpc: the server sent no grpc-status")
+	}
+	code, err := strconv.Atoi(v)
+	if err != nil {
+		return status.Newf(codes.Internal, "grpc: malformed grpc-status %q", v)
+	}
+	message := trailer.Get("grpc-message")
+	if decoded, er := url.PathUnescape(message); er == nil {
+		message = decoded // grpc-message is percent-encoded
+	}
+	return status.New(codes.Code(code), message)
+}
+
+// streamError converts an error of the HTTP/2 stream into a status error.
+func streamError(err error) error {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return status.FromContextError(err).Err()
+	}
+	var streamErr http2.StreamError
+	if errors.As(err, &streamErr) {
+		code := codes.Internal
+		switch streamErr.Code {
+		case http2.ErrCodeRefusedStream:
+			code = codes.Unavailable
+		case http2.ErrCodeCancel:
+			code = codes.Canceled
+		case http2.ErrCodeEnhanceYourCalm:
+			code = codes.ResourceExhausted
+		case http2.ErrCodeInadequateSecurity:
+			code = codes.PermissionDenied
+		}
+		return status.Errorf(code, "grpc: stream reset by the server: %v", streamErr.Code)
+	}
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	return status.Error(codes.Unavailable, err.Error())
+}
+
+// httpStatusCode maps the HTTP status of a response without grpc-status, as specified by
+// https://github.com/grpc/grpc/blob/master/doc/http-grpc-status-mapping.md
+func httpStatusCode(statusCode int) codes.Code {
+	switch statusCode {
+	case http.StatusBadRequest:
+		return codes.Internal
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound:
+		return codes.Unimplemented
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return codes.Unavailable
+	}
+	return codes.Unknown
+}
+
+// encodeGrpcTimeout encodes d as grpc-timeout, at most 8 digits in the finest unit that fits.
+func encodeGrpcTimeout(d time.Duration) string {
+	const maxTimeoutValue = 99999999
+	if d <= 0 {
+		return "1n"
+	}
+	units := []struct {
+		unit   time.Duration
+		suffix string
+	}{
+		{time.Nanosecond, "n"},
+		{time.Microsecond, "u"},
+		{time.Millisecond, "m"},
+		{time.Second, "S"},
+		{time.Minute, "M"},
+	}
+	for _, u := range units {
+		// round up so that the server never sees a later deadline
+		if v := ceilDiv(d, u.unit); v <= maxTimeoutValue {
+			return fmt.Sprintf("%d%s", v, u.suffix)
+		}
+	}
+	return fmt.Sprintf("%d%s", min(ceilDiv(d, time.Hour), maxTimeoutValue), "H")
+}
+
+func ceilDiv(d, unit time.Duration) time.Duration {
+	if d%unit == 0 {
+		return d / unit
+	}
+	return d/unit + 1
+}
@@ -0,0 +1,521 @@
+package client
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	http2tool "github.com/pysugar/wheels/binproto/http2"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	_ "google.golang.org/grpc/encoding/gzip"
+	testpb "google.golang.org/grpc/interop/grpc_testing"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+type testService struct {
+	testpb.UnimplementedTestServiceServer
+	canceled chan struct{}
+}
+
+func (s *testService) UnaryCall(ctx context.Context, req *testpb.SimpleRequest) (*testpb.SimpleResponse, error) {
+	if st := req.GetResponseStatus(); st != nil {
+		return nil, status.Error(codes.Code(st.Code), st.Message)
+	}
+	if _, ok := ctx.Deadline(); !ok {
+		return nil, status.Error(codes.FailedPrecondition, "no deadline")
+	}
+	return &testpb.SimpleResponse{Payload: req.GetPayload()}, nil
+}
+
+func (s *testService) StreamingOutputCall(req *testpb.StreamingOutputCallRequest, stream testpb.TestService_StreamingOutputCallServer) error {
+	for _, p := range req.GetResponseParameters() {
+		body := bytes.Repeat([]byte{'x'}, int(p.GetSize()))
+		if err := stream.Send(&testpb.StreamingOutputCallResponse{Payload: &testpb.Payload{Body: body}}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *testService) StreamingInputCall(stream testpb.TestService_StreamingInputCallServer) error {
+	var size int32
+	for {
+		req, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return stream.SendAndClose(&testpb.StreamingInputCallResponse{AggregatedPayloadSize: size})
+		}
+		if err != nil {
+			return err
+		}
+		size += int32(len(req.GetPayload().GetBody()))
+	}
+}
+
+func (s *testService) FullDuplexCall(stream testpb.TestService_FullDuplexCallServer) error {
+	for {
+		req, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			if stream.Context().Err() != nil {
+				close(s.canceled)
+			}
+			return err
+		}
+		if err = stream.Send(&testpb.StreamingOutputCallResponse{Payload: req.GetPayload()}); err != nil {
+			return err
+		}
+	}
+}
+
+// grpcTargets serves testService over h2c and h2 over TLS.
+func grpcTargets(t *testing.T) (map[string]string, *testService) {
+	t.Helper()
+	service := &testService{canceled: make(chan struct{})}
+	server := grpc.NewServer()
+	testpb.RegisterTestServiceServer(server, service)
+
+	h2cServer := httptest.NewServer(h2c.NewHandler(server, &http2.Server{}))
+	t.Cleanup(h2cServer.Close)
+	tlsServer := httptest.NewUnstartedServer(server)
+	tlsServer.EnableHTTP2 = true
+	tlsServer.StartTLS()
+	t.Cleanup(tlsServer.Close)
+	return map[string]string{"h2c": h2cServer.URL, "h2": tlsServer.URL}, service
+}
+
+func methodURL(baseURL, method string) *url.URL {
+	u, _ := url.Parse(baseURL + "/grpc.testing.TestService/" + method)
+	return u
+}
+
+func TestFetcherGRPCStreaming(t *testing.T) {
+	targets, _ := grpcTargets(t)
+	for name, baseURL := range targets {
+		t.Run(name, func(t *testing.T) {
+			f := NewFetcher(WithInsecureSkipVerify())
+			defer f.Close()
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			t.Run("server streaming", func(t *testing.T) {
+				stream, err := f.NewGRPCStream(ctx, methodURL(baseURL, "StreamingOutputCall"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				sizes := []int32{1, 0, 70000, 3}
+				req := &testpb.StreamingOutputCallRequest{}
+				for _, size := range sizes {
+					req.ResponseParameters = append(req.ResponseParameters, &testpb.ResponseParameters{Size: size})
+				}
+				if err = stream.Send(req); err != nil {
+					t.Fatal(err)
+				}
+				stream.CloseSend()
+				for _, size := range sizes {
+					res := new(testpb.StreamingOutputCallResponse)
+					if err = stream.Recv(res); err != nil {
+						t.Fatal(err)
+					}
+					if n := len(res.GetPayload().GetBody()); n != int(size) {
+						t.Errorf("expected payload of %d bytes, but actually %d", size, n)
+					}
+				}
+				if err = stream.Recv(new(testpb.StreamingOutputCallResponse)); !errors.Is(err, io.EOF) {
+					t.Error("expected io.EOF, but actually ", err)
+				}
+				if v := stream.Trailer().Get("grpc-status"); v != "0" {
+					t.Errorf("expected grpc-status 0, but actually %q", v)
+				}
+			})
+
+			t.Run("client streaming", func(t *testing.T) {
+				stream, err := f.NewGRPCStream(ctx, methodURL(baseURL, "StreamingInputCall"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				for _, size := range []int{10, 100, 1000} {
+					if err = stream.Send(&testpb.StreamingInputCallRequest{Payload: &testpb.Payload{Body: make([]byte, size)}}); err != nil {
+						t.Fatal(err)
+					}
+				}
+				stream.CloseSend()
+				res := new(testpb.StreamingInputCallResponse)
+				if err = stream.Recv(res); err != nil {
+					t.Fatal(err)
+				}
+				if res.GetAggregatedPayloadSize() != 1110 {
+					t.Error("expected aggregated size 1110, but actually ", res.GetAggregatedPayloadSize())
+				}
+			})
+
+			t.Run("bidi streaming", func(t *testing.T) {
+				stream, err := f.NewGRPCStream(ctx, methodURL(baseURL, "FullDuplexCall"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				// each message is answered before the next one is sent
+				for _, body := range []string{"ping", "pong", "done"} {
+					if err = stream.Send(&testpb.StreamingOutputCallRequest{Payload: &testpb.Payload{Body: []byte(body)}}); err != nil {
+						t.Fatal(err)
+					}
+					res := new(testpb.StreamingOutputCallResponse)
+					if err = stream.Recv(res); err != nil {
+						t.Fatal(err)
+					}
+					if string(res.GetPayload().GetBody()) != body {
+						t.Errorf("expected %s, but actually %s", body, res.GetPayload().GetBody())
+					}
+				}
+				stream.CloseSend()
+				if err = stream.Recv(new(testpb.StreamingOutputCallResponse)); !errors.Is(err, io.EOF) {
+					t.Error("expected io.EOF, but actually ", err)
+				}
+			})
+
+			t.Run("unary", func(t *testing.T) {
+				res := new(testpb.SimpleResponse)
+				req := &testpb.SimpleRequest{Payload: &testpb.Payload{Body: []byte("hello")}}
+				if err := f.CallGRPC(ctx, methodURL(baseURL, "UnaryCall"), req, res); err != nil {
+					t.Fatal(err)
+				}
+				if string(res.GetPayload().GetBody()) != "hello" {
+					t.Error("expected hello, but actually ", res.GetPayload())
+				}
+
+				req = &testpb.SimpleRequest{ResponseStatus: &testpb.EchoStatus{Code: int32(codes.NotFound), Message: "no such thing: 100%"}}
+				err := f.CallGRPC(ctx, methodURL(baseURL, "UnaryCall"), req, res)
+				if st := status.Convert(err); st.Code() != codes.NotFound || st.Message() != "no such thing: 100%" {
+					t.Error("expected NotFound, but actually ", err)
+				}
+
+				err = f.CallGRPC(ctx, methodURL(baseURL, "UnimplementedCall"), new(testpb.Empty), new(testpb.Empty))
+				if status.Code(err) != codes.Unimplemented {
+					t.Error("expected Unimplemented, but actually ", err)
+				}
+			})
+		})
+	}
+}
+
+func TestFetcherGRPCStreamCancel(t *testing.T) {
+	targets, service := grpcTargets(t)
+	f := NewFetcher()
+	defer f.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := f.NewGRPCStream(ctx, methodURL(targets["h2c"], "FullDuplexCall"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = stream.Send(&testpb.StreamingOutputCallRequest{Payload: &testpb.Payload{Body: []byte("ping")}}); err != nil {
+		t.Fatal(err)
+	}
+	if err = stream.Recv(new(testpb.StreamingOutputCallResponse)); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+
+	// the server learns of the cancellation by RST_STREAM
+	select {
+	case <-service.canceled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the server stream canceled")
+	}
+	if err = stream.Recv(new(testpb.StreamingOutputCallResponse)); status.Code(err) != codes.Canceled {
+		t.Error("expected Canceled, but actually ", err)
+	}
+	if err = stream.Send(&testpb.StreamingOutputCallRequest{}); !errors.Is(err, io.EOF) {
+		t.Error("expected io.EOF, but actually ", err)
+	}
+}
+
+func TestFetcherGRPCMessagesPerFrame(t *testing.T) {
+	var timeout string
+	server := httptest.NewServer(h2c.NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		timeout = r.Header.Get("grpc-timeout")
+		io.Copy(io.Discard, r.Body)
+		w.Header().Set("Content-Type", "application/grpc")
+		w.Header().Set("Trailer", "Grpc-Status")
+		var frames []byte
+		for _, body := range []string{"a", "bb", "ccc"} {
+			b, _ := proto.Marshal(&testpb.StreamingOutputCallResponse{Payload: &testpb.Payload{Body: []byte(body)}})
+			frames = append(frames, EncodeGrpcPayload(b)...)
+		}
+		w.Write(frames) // a single DATA frame with three messages
+		w.Header().Set("Grpc-Status", "0")
+	}), &http2.Server{}))
+	defer server.Close()
+
+	f := NewFetcher()
+	defer f.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	stream, err := f.NewGRPCStream(ctx, methodURL(server.URL, "StreamingOutputCall"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream.CloseSend()
+	var bodies []string
+	for {
+		res := new(testpb.StreamingOutputCallResponse)
+		if err = stream.Recv(res); err != nil {
+			break
+		}
+		bodies = append(bodies, string(res.GetPayload().GetBody()))
+	}
+	if !errors.Is(err, io.EOF) || strings.Join(bodies, ",") != "a,bb,ccc" {
+		t.Errorf("expected a,bb,ccc, but actually %v %v", bodies, err)
+	}
+	units := map[string]string{"n": "ns", "u": "us", "m": "ms", "S": "s", "M": "m", "H": "h"}
+	if d, er := time.ParseDuration(timeout[:len(timeout)-1] + units[timeout[len(timeout)-1:]]); er != nil || d <= 0 || d > 10*time.Second {
+		t.Errorf("expected grpc-timeout within 10s, but actually %q", timeout)
+	}
+}
+
+func TestReadGrpcMessage(t *testing.T) {
+	var body []byte
+	for _, m := range []string{"one", "", "three"} {
+		body = append(body, EncodeGrpcPayload([]byte(m))...)
+	}
+	// messages split across reads at every byte
+	r := iotest.OneByteReader(bytes.NewReader(body))
+	for _, want := range []string{"one", "", "three"} {
+		data, err := ReadGrpcMessage(r, "", DefaultMaxRecvMsgSize)
+		if err != nil || string(data) != want {
+			t.Fatalf("expected %q, but actually %q %v", want, data, err)
+		}
+	}
+	if _, err := ReadGrpcMessage(r, "", DefaultMaxRecvMsgSize); !errors.Is(err, io.EOF) {
+		t.Error("expected io.EOF, but actually ", err)
+	}
+	r = bytes.NewReader(body[:len(body)-1])
+	var err error
+	for err == nil {
+		_, err = ReadGrpcMessage(r, "", DefaultMaxRecvMsgSize)
+	}
+	if errors.Is(err, io.EOF) {
+		t.Error("expected truncated message error, but actually ", err)
+	}
+}
+
+func TestReadGrpcMessageMaxSize(t *testing.T) {
+	large := bytes.Repeat([]byte{'x'}, 2048)
+	compressed, err := http2tool.EncodeGrpcPayloadWithCompressor(large, http2tool.GetCompressor("gzip"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name     string
+		body     []byte
+		encoding string
+	}{
+		{name: "plain", body: EncodeGrpcPayload(large)},
+		{name: "decompressed", body: compressed, encoding: "gzip"},
+	}
+	for _, tt := range tests {
+		if _, err := ReadGrpcMessage(bytes.NewReader(tt.body), tt.encoding, 1024); status.Code(err) != codes.ResourceExhausted {
+			t.Errorf("expected ResourceExhausted for the %s message, but actually %v", tt.name, err)
+		}
+		if data, err := ReadGrpcMessage(bytes.NewReader(tt.body), tt.encoding, len(large)); err != nil || len(data) != len(large) {
+			t.Errorf("expected the %s message of %d bytes, but actually %d %v", tt.name, len(large), len(data), err)
+		}
+	}
+}
+
+func TestReadGrpcMessageDecompressionBomb(t *testing.T) {
+	// 256MB of zeros compress to a message of a few hundred KB, far below the limit
+	var compressed bytes.Buffer
+	zw := gzip.NewWriter(&compressed)
+	if _, err := io.CopyN(zw, zeroReader{}, 256<<20); err != nil {
+		t.Fatal(err)
+	}
+	zw.Close()
+	body := EncodeGrpcPayload(compressed.Bytes())
+	body[0] = 1
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	_, err := ReadGrpcMessage(bytes.NewReader(body), "gzip", DefaultMaxRecvMsgSize)
+	runtime.ReadMemStats(&after)
+	if status.Code(err) != codes.ResourceExhausted {
+		t.Error("expected ResourceExhausted, but actually ", err)
+	}
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 4*DefaultMaxRecvMsgSize {
+		t.Errorf("expected decompression stopped at the limit, but actually %d bytes allocated", allocated)
+	}
+}
+
+func TestFetcherGRPCMaxRecvMsgSize(t *testing.T) {
+	targets, _ := grpcTargets(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tests := []struct {
+		name  string
+		opts  []FetcherOption
+		sizes []int32
+	}{
+		{name: "default", sizes: []int32{DefaultMaxRecvMsgSize - 16, DefaultMaxRecvMsgSize + 1}},
+		{name: "option", opts: []FetcherOption{WithGRPCMaxRecvMsgSize(1024)}, sizes: []int32{1000, 1025}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := NewFetcher(tt.opts...)
+			defer f.Close()
+			stream, err := f.NewGRPCStream(ctx, methodURL(targets["h2c"], "StreamingOutputCall"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req := &testpb.StreamingOutputCallRequest{}
+			for _, size := range tt.sizes {
+				req.ResponseParameters = append(req.ResponseParameters, &testpb.ResponseParameters{Size: size})
+			}
+			if err = stream.Send(req); err != nil {
+				t.Fatal(err)
+			}
+			stream.CloseSend()
+			res := new(testpb.StreamingOutputCallResponse)
+			if err = stream.Recv(res); err != nil {
+				t.Fatal(err)
+			}
+			if err = stream.Recv(res); status.Code(err) != codes.ResourceExhausted {
+				t.Error("expected ResourceExhausted, but actually ", err)
+			}
+		})
+	}
+}
+
+func TestEncodeGrpcTimeout(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{0, "1n"},
+		{time.Millisecond, "1000000n"},
+		{10 * time.Second, "10000000u"},
+		{500 * time.Second, "500000m"},
+		{30 * time.Hour, "108000S"},
+		{time.Duration(1<<63 - 1), "2562048H"},
+		{-time.Second, "1n"},
+	}
+	for _, tt := range tests {
+		if got := encodeGrpcTimeout(tt.d); got != tt.want {
+			t.Errorf("%v: expected %s, but actually %s", tt.d, tt.want, got)
+		}
+	}
+}
+
+func TestFetcherGRPCCompression(t *testing.T) {
+	service := &testService{canceled: make(chan struct{})}
+	server := grpc.NewServer()
+	testpb.RegisterTestServiceServer(server, service)
+	var mu sync.Mutex
+	var encodings []string
+	h2cServer := httptest.NewServer(h2c.NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		server.ServeHTTP(w, r)
+		mu.Lock()
+		encodings = append(encodings, r.Header.Get("grpc-encoding")+">"+w.Header().Get("grpc-encoding"))
+		mu.Unlock()
+	}), &http2.Server{}))
+	defer h2cServer.Close()
+
+	f := NewFetcher(WithGRPCCompression("gzip"))
+	defer f.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	body := bytes.Repeat([]byte("compressible "), 1024)
+	for i := 0; i < 2; i++ {
+		res := new(testpb.SimpleResponse)
+		req := &testpb.SimpleRequest{Payload: &testpb.Payload{Body: body}}
+		if err := f.CallGRPC(ctx, methodURL(h2cServer.URL, "UnaryCall"), req, res); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(res.GetPayload().GetBody(), body) {
+			t.Errorf("expected the payload echoed, but actually %d bytes", len(res.GetPayload().GetBody()))
+		}
+	}
+
+	stream, err := f.NewGRPCStream(ctx, methodURL(h2cServer.URL, "FullDuplexCall"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = stream.Send(&testpb.StreamingOutputCallRequest{Payload: &testpb.Payload{Body: body}}); err != nil {
+		t.Fatal(err)
+	}
+	stream.CloseSend()
+	res := new(testpb.StreamingOutputCallResponse)
+	if err = stream.Recv(res); err != nil || !bytes.Equal(res.GetPayload().GetBody(), body) {
+		t.Errorf("expected the payload echoed, but actually %d bytes %v", len(res.GetPayload().GetBody()), err)
+	}
+	if err = stream.Recv(res); !errors.Is(err, io.EOF) {
+		t.Error("expected io.EOF, but actually ", err)
+	}
+
+	// the server answers in the encoding of the request
+	mu.Lock()
+	defer mu.Unlock()
+	if got := strings.Join(encodings, ","); got != "gzip>gzip,gzip>gzip,gzip>gzip" {
+		t.Errorf("expected gzip>gzip for every call, but actually %s", got)
+	}
+
+	if _, err := NewFetcher(WithGRPCCompression("zstd")).NewGRPCStream(ctx, methodURL(h2cServer.URL, "FullDuplexCall")); err == nil {
+		t.Error("expected an error for an unregistered compressor")
+	}
+}
+
+func TestFetcherGRPCCompressionRejected(t *testing.T) {
+	var encodings []string
+	server := httptest.NewServer(h2c.NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		encodings = append(encodings, r.Header.Get("grpc-encoding"))
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/grpc")
+		if r.Header.Get("grpc-encoding") != "identity" {
+			w.Header().Set("Grpc-Accept-Encoding", "identity")
+			w.Header().Set("Grpc-Status", strconv.Itoa(int(codes.Unimplemented)))
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		w.Header().Set("Trailer", "Grpc-Status")
+		w.Write(body)
+		w.Header().Set("Grpc-Status", "0")
+	}), &http2.Server{}))
+	defer server.Close()
+
+	f := NewFetcher(WithGRPCCompression("gzip"))
+	defer f.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	for i := 0; i < 2; i++ {
+		req := &testpb.SimpleRequest{Payload: &testpb.Payload{Body: []byte("hello")}}
+		res := new(testpb.SimpleRequest)
+		if err := f.CallGRPC(ctx, methodURL(server.URL, "UnaryCall"), req, res); err != nil {
+			t.Fatal(err)
+		}
+		if string(res.GetPayload().GetBody()) != "hello" {
+			t.Error("expected hello, but actually ", res.GetPayload())
+		}
+	}
+	// rejected once, then identity is sent right away
+	if got := strings.Join(encodings, ","); got != "gzip,identity,identity" {
+		t.Errorf("expected gzip,identity,identity, but actually %s", got)
+	}
+}
@@ -1,15 +1,12 @@
 package client
 
 import (
-	"bufio"
 	"context"
-	"errors"
 	"fmt"
-	"io"
-	"net"
 	"net/http"
-	"strconv"
+	"net/http/httptrace"
 	"strings"
+	"time"
 )
 
 func (f *fetcher) doHTTP1(ctx context.Context, req *http.Request) (*http.Response, error) {
@@ -18,151 +15,115 @@ func (f *fetcher) doHTTP1(ctx context.Context, req *http.Request) (*http.Respons
 		logger.Printf("[%s] Falling back to HTTP/1.1", req.URL.RequestURI())
 	}
 
-	conn, err := dialConn(req.Host, false)
+	key := connKey("http", req.Host)
+	if res, ok, err := f.doHTTP1Idle(ctx, req, key); ok {
+		return res, err
+	}
+
+	conn, err := f.dial(ctx, req.Host, nil)
 	if err != nil {
 		return nil, err
 	}
+	return f.doHTTP1WithConn(ctx, req, newPersistConn(key, conn))
+}
 
-	req = req.WithContext(ctx)
+// doHTTP1Idle sends req on an idle connection of key. It reports false if there is no idle connection,
+// or if the connection broke and req may be retried on a new one.
+func (f *fetcher) doHTTP1Idle(ctx context.Context, req *http.Request, key string) (*http.Response, bool, error) {
+	pc := f.connPool.getIdleConn(key)
+	if pc == nil {
+		return nil, false, nil
+	}
+	newVerboseLogger(ctx).Printf("[%s] reuse idle HTTP/1.1 connection", key)
+	res, err := f.doHTTP1WithConn(ctx, req, pc)
+	if err == nil || ctx.Err() != nil || !isReplayable(req) {
+		return res, true, err
+	}
+	if req.GetBody != nil {
+		if req.Body, err = req.GetBody(); err != nil {
+			return nil, true, err
+		}
+	}
+	newVerboseLogger(ctx).Printf("[%s] retry on a new connection, err: %v", key, err)
+	return nil, false, nil
+}
 
+func (f *fetcher) doHTTP1WithConn(ctx context.Context, req *http.Request, pc *persistConn) (*http.Response, error) {
+	req = req.WithContext(ctx)
 	if req.Header == nil {
 		req.Header = make(http.Header)
 	}
 	if req.Host == "" {
 		req.Host = req.URL.Host
 	}
-	req.Header.Set("Connection", "close")
-
-	return f.doHTTP1WithConn(ctx, req, conn)
-}
 
-func (f *fetcher) doHTTP1WithConn(ctx context.Context, req *http.Request, conn net.Conn) (*http.Response, error) {
-	writer := bufio.NewWriter(conn)
-	if err := req.Write(writer); err != nil {
+	connInfoFromContext(ctx).set("HTTP/1.1", 0, pc.conn)
+	var idleTime time.Duration
+	if pc.reused {
+		idleTime = time.Since(pc.idleAt)
+	}
+	traceGotConn(ctx, pc.conn, pc.reused, idleTime)
+	trace := httptrace.ContextClientTrace(ctx)
+	stop := context.AfterFunc(ctx, func() { pc.conn.Close() })
+	if err := req.Write(pc.bw); err != nil {
+		stop()
+		pc.conn.Close()
 		return nil, fmt.Errorf("failed to write HTTP/1.1 request: %w", err)
 	}
-	if err := writer.Flush(); err != nil {
+	if err := pc.bw.Flush(); err != nil {
+		stop()
+		pc.conn.Close()
 		return nil, fmt.Errorf("failed to flush HTTP/1.1 request: %w", err)
 	}
+	traceWroteHeaders(trace)
+	traceWroteRequest(trace, nil)
+	if _, err := pc.br.Peek(1); err == nil {
+		traceGotFirstResponseByte(trace)
+	}
 
-	reader := bufio.NewReader(conn)
-	resp, err := http.ReadResponse(reader, req)
+	resp, err := http.ReadResponse(pc.br, req)
 	if err != nil {
+		stop()
+		pc.conn.Close()
 		return nil, fmt.Errorf("failed to read HTTP/1.1 response: %w", err)
 	}
-	return resp, nil
-}
-
-func readHTTPResponse(reader *bufio.Reader, req *http.Request) (*http.Response, error) {
-	statusLine, er := reader.ReadString('\n')
-	if er != nil {
-		return nil, er
-	}
-	statusLine = strings.TrimRight(statusLine, "\r\n")
-	if statusLine == "" {
-		return nil, errors.New("malformed HTTP response: empty status line")
-	}
-
-	parts := strings.SplitN(statusLine, " ", 3)
-	if len(parts) < 2 {
-		return nil, fmt.Errorf("malformed HTTP response status line: %s", statusLine)
-	}
-	proto := parts[0]
-	statusCodeStr := parts[1]
-	statusText := ""
-	if len(parts) > 2 {
-		statusText = parts[2]
-	}
-
-	statusCode, er := strconv.Atoi(statusCodeStr)
-	if er != nil {
-		return nil, fmt.Errorf("invalid status code: %s", statusCodeStr)
-	}
-
-	respHeader := make(http.Header)
-	for {
-		line, err := reader.ReadString('\n')
-		if err != nil {
-			return nil, err
-		}
-		line = strings.TrimRight(line, "\r\n")
-		if line == "" {
-			break
-		}
-
-		colonIndex := strings.Index(line, ":")
-		if colonIndex == -1 {
-			return nil, fmt.Errorf("malformed header line: %s", line)
-		}
-		key := strings.TrimSpace(line[:colonIndex])
-		value := strings.TrimSpace(line[colonIndex+1:])
-		respHeader.Add(key, value)
-	}
+	resp.TLS = tlsState(pc.conn)
 
-	var body io.ReadCloser = http.NoBody
-	if req.Method != "HEAD" {
-		if te := respHeader.Get("Transfer-Encoding"); strings.EqualFold(te, "chunked") {
-			body = &chunkedReader{reader: reader}
-		} else if cl := respHeader.Get("Content-Length"); cl != "" {
-			contentLength, err := strconv.ParseInt(cl, 10, 64)
-			if err != nil {
-				return nil, fmt.Errorf("invalid Content-Length: %s", cl)
-			}
-			body = io.NopCloser(io.LimitReader(reader, contentLength))
-		} else {
-			body = io.NopCloser(reader)
-		}
+	body := &persistBody{
+		body:     resp.Body,
+		pc:       pc,
+		pool:     f.connPool,
+		reusable: !resp.Close && !req.Close,
+		stop:     stop,
 	}
-
-	resp := &http.Response{
-		Status:        fmt.Sprintf("%d %s", statusCode, statusText),
-		StatusCode:    statusCode,
-		Proto:         proto,
-		ProtoMajor:    1,
-		ProtoMinor:    1,
-		Header:        respHeader,
-		Body:          body,
-		ContentLength: -1, // 根据实际情况设置
-		Request:       req,
+	if resp.Body == http.NoBody {
+		body.finish(true)
+	} else {
+		resp.Body = body
 	}
-
 	return resp, nil
 }
 
-type chunkedReader struct {
-	reader *bufio.Reader
-	eof    bool
-}
-
-func (cr *chunkedReader) Read(p []byte) (int, error) {
-	if cr.eof {
-		return 0, io.EOF
+// isReplayable reports whether req can be sent again after a reused connection broke, as net/http does.
+func isReplayable(req *http.Request) bool {
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
 	}
-
-	line, err := cr.reader.ReadString('\n')
-	if err != nil {
-		return 0, err
-	}
-	line = strings.TrimSpace(line)
-	size, err := strconv.ParseInt(line, 16, 64)
-	if err != nil {
-		return 0, fmt.Errorf("invalid chunk size: %s", line)
+	switch req.Method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
 	}
-	if size == 0 {
-		cr.eof = true
-		cr.reader.ReadString('\n')
-		return 0, io.EOF
-	}
-
-	n, err := io.ReadFull(cr.reader, p[:int(size)])
-	if err != nil {
-		return n, err
-	}
-
-	cr.reader.ReadString('\n')
-	return n, nil
+	return req.Header.Get("Idempotency-Key") != "" || req.Header.Get("X-Idempotency-Key") != ""
 }
 
-func (cr *chunkedReader) Close() error {
-	return nil
+// connKey identifies the HTTP/1.1 connections to host, a missing port defaults by scheme.
+func connKey(scheme, host string) string {
+	if !hasPort(host) {
+		if strings.HasPrefix(scheme, "https") {
+			host += ":443"
+		} else {
+			host += ":80"
+		}
+	}
+	return scheme + "://" + host
 }
@@ -0,0 +1,201 @@
+package client
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	defaultMaxIdleConnsPerHost = 8
+	defaultIdleConnTimeout     = 90 * time.Second
+
+	// an unread response body up to maxDrainBytes is discarded on Close to keep the connection reusable
+	maxDrainBytes = 256 << 10
+	drainTimeout  = time.Second
+)
+
+type (
+	// persistConn is an HTTP/1.1 connection which may serve several requests in sequence.
+	persistConn struct {
+		key       string // scheme://host:port
+		conn      net.Conn
+		br        *bufio.Reader
+		bw        *bufio.Writer
+		idleTimer *time.Timer
+		idleAt    time.Time
+		reused    bool
+	}
+
+	// persistBody is the response body of a persistConn, the connection goes back to the idle pool once the body
+	// has been read to EOF or drained on Close.
+	persistBody struct {
+		body     io.ReadCloser
+		pc       *persistConn
+		pool     *connPool
+		reusable bool
+		stop     func() bool // stops closing the connection on ctx done
+
+		mu   sync.Mutex
+		done bool
+	}
+)
+
+// WithMaxIdleConnsPerHost limits the idle HTTP/1.1 connections kept per host, 8 by default.
+// A negative n disables keep-alive.
+func WithMaxIdleConnsPerHost(n int) FetcherOption {
+	return func(o *fetcherOptions) {
+		o.maxIdleConnsPerHost = n
+	}
+}
+
+// WithIdleConnTimeout closes HTTP/1.1 connections idle for longer than timeout, 90 seconds by default.
+func WithIdleConnTimeout(timeout time.Duration) FetcherOption {
+	return func(o *fetcherOptions) {
+		o.idleConnTimeout = timeout
+	}
+}
+
+func newPersistConn(key string, conn net.Conn) *persistConn {
+	return &persistConn{
+		key:  key,
+		conn: conn,
+		br:   bufio.NewReader(conn),
+		bw:   bufio.NewWriter(conn),
+	}
+}
+
+// isHealthy reports whether an idle connection is still usable, i.e. the server has neither closed it
+// nor sent anything unsolicited.
+func (pc *persistConn) isHealthy() bool {
+	if pc.br.Buffered() > 0 {
+		return false
+	}
+	if err := pc.conn.SetReadDeadline(time.Now().Add(time.Millisecond)); err != nil {
+		return false
+	}
+	_, err := pc.br.Peek(1)
+	pc.conn.SetReadDeadline(time.Time{})
+
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// getIdleConn takes the most recently used healthy idle connection of key, if any.
+func (cp *connPool) getIdleConn(key string) *persistConn {
+	for {
+		cp.idleMu.Lock()
+		pcs := cp.idleConns[key]
+		if len(pcs) == 0 {
+			cp.idleMu.Unlock()
+			return nil
+		}
+		pc := pcs[len(pcs)-1]
+		cp.idleConns[key] = pcs[:len(pcs)-1]
+		cp.idleMu.Unlock()
+
+		if !pc.idleTimer.Stop() {
+			continue // expired, closed by the timer
+		}
+		if !pc.isHealthy() {
+			cp.printf("[connPool] discard broken idle conn, target: %s", key)
+			pc.conn.Close()
+			continue
+		}
+		pc.reused = true
+		return pc
+	}
+}
+
+// putIdleConn returns pc to the idle pool, it closes pc if the pool of the host is full.
+func (cp *connPool) putIdleConn(pc *persistConn) {
+	cp.idleMu.Lock()
+	defer cp.idleMu.Unlock()
+
+	if cp.idleClosed || cp.maxIdleConnsPerHost < 0 || len(cp.idleConns[pc.key]) >= cp.maxIdleConnsPerHost {
+		pc.conn.Close()
+		return
+	}
+	pc.idleAt = time.Now()
+	pc.idleTimer = time.AfterFunc(cp.idleConnTimeout, func() {
+		cp.removeIdleConn(pc)
+		pc.conn.Close()
+	})
+	cp.idleConns[pc.key] = append(cp.idleConns[pc.key], pc)
+}
+
+func (cp *connPool) removeIdleConn(pc *persistConn) {
+	cp.idleMu.Lock()
+	defer cp.idleMu.Unlock()
+
+	pcs := cp.idleConns[pc.key]
+	for i, c := range pcs {
+		if c == pc {
+			cp.idleConns[pc.key] = append(pcs[:i], pcs[i+1:]...)
+			break
+		}
+	}
+	if len(cp.idleConns[pc.key]) == 0 {
+		delete(cp.idleConns, pc.key)
+	}
+}
+
+func (cp *connPool) closeIdleConns() {
+	cp.idleMu.Lock()
+	defer cp.idleMu.Unlock()
+
+	cp.idleClosed = true
+	for _, pcs := range cp.idleConns {
+		for _, pc := range pcs {
+			pc.idleTimer.Stop()
+			pc.conn.Close()
+		}
+	}
+	cp.idleConns = nil
+}
+
+func (b *persistBody) Read(p []byte) (int, error) {
+	n, err := b.body.Read(p)
+	if errors.Is(err, io.EOF) {
+		b.finish(true)
+	}
+	return n, err
+}
+
+func (b *persistBody) Close() error {
+	b.mu.Lock()
+	done := b.done
+	b.mu.Unlock()
+	if done {
+		return nil
+	}
+
+	eof := false
+	if b.reusable {
+		b.pc.conn.SetReadDeadline(time.Now().Add(drainTimeout))
+		_, err := io.CopyN(io.Discard, b.body, maxDrainBytes+1)
+		eof = errors.Is(err, io.EOF)
+	}
+	b.finish(eof)
+	return nil
+}
+
+func (b *persistBody) finish(eof bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.done {
+		return
+	}
+	b.done = true
+
+	if eof && b.reusable && b.stop() {
+		b.pc.conn.SetDeadline(time.Time{})
+		b.pool.putIdleConn(b.pc)
+		return
+	}
+	b.stop()
+	b.pc.conn.Close()
+}
@@ -0,0 +1,164 @@
+package client
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newHTTP1Server serves a body of the size in the query "size", and counts the accepted connections.
+func newHTTP1Server(t *testing.T, accepted *int32) *httptest.Server {
+	t.Helper()
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		size, _ := strconv.Atoi(r.URL.Query().Get("size"))
+		w.Header().Set("Content-Length", strconv.Itoa(size))
+		io.WriteString(w, strings.Repeat("x", size))
+	}))
+	server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(accepted, 1)
+		}
+	}
+	server.Start()
+	t.Cleanup(server.Close)
+	return server
+}
+
+func fetchHTTP1(t *testing.T, f Fetcher, url string, read bool) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	ctx = WithProtocol(ctx, HTTP1)
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	res, err := f.Do(ctx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if read {
+		if _, err := io.Copy(io.Discard, res.Body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	res.Body.Close()
+}
+
+func TestHTTP1KeepAlive(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     []FetcherOption
+		size     int
+		read     bool
+		interval time.Duration
+		accepted int32
+	}{
+		{name: "read to EOF", size: 1024, read: true, accepted: 1},
+		{name: "drained on close", size: 1024, accepted: 1},
+		{name: "empty body", size: 0, accepted: 1},
+		{name: "too large to drain", size: maxDrainBytes * 2, accepted: 5},
+		{name: "keep-alive disabled", opts: []FetcherOption{WithMaxIdleConnsPerHost(-1)}, size: 16, read: true, accepted: 5},
+		{name: "idle timeout", opts: []FetcherOption{WithIdleConnTimeout(20 * time.Millisecond)}, size: 16, read: true, interval: 50 * time.Millisecond, accepted: 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var accepted int32
+			server := newHTTP1Server(t, &accepted)
+			f := NewFetcher(tt.opts...)
+			defer f.Close()
+
+			for i := 0; i < 5; i++ {
+				fetchHTTP1(t, f, server.URL+"/?size="+strconv.Itoa(tt.size), tt.read)
+				time.Sleep(tt.interval)
+			}
+			if n := atomic.LoadInt32(&accepted); n != tt.accepted {
+				t.Errorf("expected %d connections, but actually %d", tt.accepted, n)
+			}
+		})
+	}
+}
+
+func TestHTTP1BrokenIdleConn(t *testing.T) {
+	var accepted int32
+	server := newHTTP1Server(t, &accepted)
+	f := NewFetcher()
+	defer f.Close()
+
+	fetchHTTP1(t, f, server.URL+"/?size=16", true)
+	// the idle connection is found closed on reuse, and the request goes through a new one
+	server.CloseClientConnections()
+	fetchHTTP1(t, f, server.URL+"/?size=16", true)
+	if n := atomic.LoadInt32(&accepted); n != 2 {
+		t.Errorf("expected 2 connections, but actually %d", n)
+	}
+}
+
+func TestHTTP1MaxIdleConnsPerHost(t *testing.T) {
+	var accepted int32
+	server := newHTTP1Server(t, &accepted)
+	f := NewFetcher(WithMaxIdleConnsPerHost(2))
+	defer f.Close()
+
+	var wg sync.WaitGroup
+	bodies := make(chan io.ReadCloser, 4)
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx := WithProtocol(context.Background(), HTTP1)
+			req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/?size=16", nil)
+			res, err := f.Do(ctx, req)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			bodies <- res.Body
+		}()
+	}
+	wg.Wait()
+	close(bodies)
+	for body := range bodies {
+		io.Copy(io.Discard, body)
+		body.Close()
+	}
+
+	pool := f.(*fetcher).connPool
+	pool.idleMu.Lock()
+	idle := len(pool.idleConns[connKey("http", server.Listener.Addr().String())])
+	pool.idleMu.Unlock()
+	if idle != 2 {
+		t.Errorf("expected 2 idle connections, but actually %d", idle)
+	}
+}
+
+func TestIsReplayable(t *testing.T) {
+	tests := []struct {
+		method string
+		body   io.Reader
+		header string
+		want   bool
+	}{
+		{method: http.MethodGet, want: true},
+		{method: http.MethodHead, want: true},
+		{method: http.MethodPut, body: strings.NewReader("x")},
+		{method: http.MethodDelete},
+		{method: http.MethodPost, body: strings.NewReader("x")},
+		{method: http.MethodPost, body: strings.NewReader("x"), header: "Idempotency-Key", want: true},
+		{method: http.MethodPut, body: io.NopCloser(strings.NewReader("x")), header: "Idempotency-Key"},
+	}
+	for _, tt := range tests {
+		req, _ := http.NewRequest(tt.method, "http://example.com", tt.body)
+		if tt.header != "" {
+			req.Header.Set(tt.header, "1")
+		}
+		if got := isReplayable(req); got != tt.want {
+			t.Errorf("expected %s %s replayable %v, but actually %v", tt.method, tt.header, tt.want, got)
+		}
+	}
+}
@@ -15,6 +15,27 @@ import (
 )
 
 func (f *fetcher) doHTTP2(ctx context.Context, req *http.Request) (*http.Response, error) {
+	upgraded := false
+	cc, err := f.connPool.getConnFunc(ctx, connKey("http", req.Host), func(ctx context.Context) (*clientConn, error) {
+		cc, up, er := f.dialHTTP2(ctx, req)
+		upgraded = up
+		return cc, er
+	})
+	if err != nil {
+		return nil, err
+	}
+	// only the request sent along the h2c upgrade is answered on stream 1, the others open streams of their own
+	if upgraded {
+		ctx = WithUpgrade(ctx)
+	} else {
+		ctx = context.WithValue(ctx, upgradeCtxKey, nil)
+	}
+	return cc.do(ctx, req)
+}
+
+// dialHTTP2 connects with h2c prior knowledge, or with an h2c upgrade carrying req if that fails or is asked for
+// by WithUpgrade, in which case it reports upgraded.
+func (f *fetcher) dialHTTP2(ctx context.Context, req *http.Request) (*clientConn, bool, error) {
 	logger := newVerboseLogger(ctx)
 	var netOpErr *net.OpError
 
@@ -23,12 +44,12 @@ func (f *fetcher) doHTTP2(ctx context.Context, req *http.Request) (*http.Respons
 		cc, err := f.tryHTTP2Direct(ctx, req)
 		if errors.As(err, &netOpErr) {
 			logger.Printf("[%s] try http2 direct failure: %v", req.URL.RequestURI(), netOpErr)
-			return nil, netOpErr
+			return nil, false, netOpErr
 		}
 
 		if err == nil && cc != nil {
 			logger.Printf("try http2 direct success: %v", req.URL.RequestURI())
-			return cc.do(ctx, req)
+			return cc, false, nil
 		}
 
 		logger.Printf("[%s] try http2 direct failure: %v", req.URL.RequestURI(), err)
@@ -38,21 +59,20 @@ func (f *fetcher) doHTTP2(ctx context.Context, req *http.Request) (*http.Respons
 	cc, err := f.tryHTTP2Upgrade(ctx, req)
 	if errors.As(err, &netOpErr) {
 		logger.Printf("[%s] try http2 upgrade failure: %v >>>", req.URL.RequestURI(), netOpErr)
-		return nil, netOpErr
+		return nil, false, netOpErr
 	}
 
 	if err == nil && cc != nil {
 		logger.Printf("try http2 upgrade success: %v >>>", req.URL.RequestURI())
-		ctx = WithUpgrade(ctx)
-		return cc.do(ctx, req)
+		return cc, true, nil
 	}
 
 	logger.Printf("[%s] try http2 upgrade failure: %v >>>", req.URL.RequestURI(), err)
-	return nil, ErrHTTP2Unsupported
+	return nil, false, ErrHTTP2Unsupported
 }
 
 func (f *fetcher) tryHTTP2Direct(ctx context.Context, req *http.Request) (*clientConn, error) {
-	conn, err := dialConn(req.Host, false)
+	conn, err := f.dial(ctx, req.Host, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -64,11 +84,11 @@ func (f *fetcher) tryHTTP2Direct(ctx context.Context, req *http.Request) (*clien
 	}
 
 	newVerboseLogger(ctx).Printf("[%s] Connect using HTTP/2 Prior Knowledge", req.URL.RequestURI())
-	return f.connPool.getConn(ctx, req.URL.Host, WithConn(conn), DisableSendPreface())
+	return dialContext(ctx, req.URL.Host, WithConn(conn), DisableSendPreface())
 }
 
 func (f *fetcher) tryHTTP2Upgrade(ctx context.Context, req *http.Request) (*clientConn, error) {
-	conn, err := dialConn(req.Host, false)
+	conn, err := f.dial(ctx, req.Host, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -91,7 +111,7 @@ func (f *fetcher) tryHTTP2Upgrade(ctx context.Context, req *http.Request) (*clie
 
 	if upgraded {
 		logger.Printf("[%s] Successfully upgraded to HTTP/2 (h2c)", req.URL.RequestURI())
-		return f.connPool.getConn(ctx, req.URL.Host, WithConn(conn), WithH2CUpgrade())
+		return dialContext(ctx, req.URL.Host, WithConn(conn), WithH2CUpgrade())
 	}
 
 	logger.Printf("[%s] Server does not support HTTP/2 Upgrade", req.URL.RequestURI())
@@ -0,0 +1,304 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	defaultMaxConnsPerAuthority = 4
+	defaultPingAfterIdle        = 15 * time.Second
+)
+
+var errPoolClosed = errors.New("http2 pool closed")
+
+type (
+	// Pool holds HTTP/2 connections, several per authority. A new connection is opened once the streams of the
+	// others reach the SETTINGS_MAX_CONCURRENT_STREAMS of the server, connections idle for longer than the idle
+	// timeout are closed, and a connection which received GOAWAY serves no new streams and is closed once its
+	// remaining streams end. A Pool may be shared by several Fetchers with WithPool.
+	Pool struct {
+		popts *poolOptions
+		g     singleflight.Group
+
+		mu         sync.Mutex
+		conns      map[string][]*clientConn // scheme://host:port[#tls options] -> conns, in the order of dialing
+		evictTimer *time.Timer
+		closed     bool
+	}
+
+	poolOptions struct {
+		maxConnsPerAuthority int
+		idleTimeout          time.Duration
+		pingAfterIdle        time.Duration
+	}
+
+	PoolOption func(*poolOptions)
+
+	// PoolStats is a snapshot of the connections of a Pool.
+	PoolStats struct {
+		Conns         int // open connections, the draining ones included
+		IdleConns     int // open connections without active streams
+		DrainingConns int // connections which received GOAWAY
+		ActiveStreams int
+		PingsInFlight int
+	}
+)
+
+// WithMaxConnsPerAuthority limits the connections opened to an authority, 4 by default. Once all of them are
+// saturated, new streams wait on the least loaded one. A non-positive n means no limit.
+func WithMaxConnsPerAuthority(n int) PoolOption {
+	return func(o *poolOptions) {
+		o.maxConnsPerAuthority = n
+	}
+}
+
+// WithPoolIdleTimeout closes connections without active streams for longer than timeout, 90 seconds by default.
+// A non-positive timeout keeps idle connections open.
+func WithPoolIdleTimeout(timeout time.Duration) PoolOption {
+	return func(o *poolOptions) {
+		o.idleTimeout = timeout
+	}
+}
+
+// WithPingAfterIdle verifies a connection idle for longer than d with a PING before reusing it, 15 seconds
+// by default. A non-positive d disables the check.
+func WithPingAfterIdle(d time.Duration) PoolOption {
+	return func(o *poolOptions) {
+		o.pingAfterIdle = d
+	}
+}
+
+// WithPool makes the Fetcher open its HTTP/2 connections in p, which outlives the Fetcher and is closed by
+// the caller. By default each Fetcher has a Pool of its own. Fetchers share the TLS connections of p only if
+// their TLS options are the same, those with other root CAs, client certificates, pinned keys or without
+// verification get connections of their own.
+func WithPool(p *Pool) FetcherOption {
+	return func(o *fetcherOptions) {
+		o.pool = p
+	}
+}
+
+func NewPool(opts ...PoolOption) *Pool {
+	popts := &poolOptions{
+		maxConnsPerAuthority: defaultMaxConnsPerAuthority,
+		idleTimeout:          defaultIdleConnTimeout,
+		pingAfterIdle:        defaultPingAfterIdle,
+	}
+	for _, o := range opts {
+		o(popts)
+	}
+	return &Pool{
+		popts: popts,
+		conns: make(map[string][]*clientConn),
+	}
+}
+
+// Stats counts the open connections of the pool and their streams.
+func (p *Pool) Stats() PoolStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var stats PoolStats
+	for _, conns := range p.conns {
+		for _, cc := range conns {
+			if cc.isClosed() {
+				continue
+			}
+			stats.Conns++
+			if cc.isDraining() {
+				stats.DrainingConns++
+			}
+			active := cc.activeStreams()
+			if active == 0 {
+				stats.IdleConns++
+			}
+			stats.ActiveStreams += active
+			stats.PingsInFlight += cc.pingsInFlight()
+		}
+	}
+	return stats
+}
+
+// Close closes all connections of the pool, the streams on them end with an error.
+func (p *Pool) Close() (err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.closed = true
+	if p.evictTimer != nil {
+		p.evictTimer.Stop()
+	}
+	for _, conns := range p.conns {
+		for _, cc := range conns {
+			if er := cc.Close(); er != nil {
+				err = er
+			}
+		}
+	}
+	p.conns = nil
+	return err
+}
+
+// getConn returns a connection to key with a free stream if any, dial opens a new one otherwise unless the
+// limit of connections is reached. The dials of concurrent callers are shared, only the caller whose dial
+// ran gets its connection for sure, the others pick again, and dial again if that caller's context ended it.
+func (p *Pool) getConn(ctx context.Context, key string, dial func(context.Context) (*clientConn, error)) (*clientConn, error) {
+	logger := newVerboseLogger(ctx)
+	for {
+		cc, idle, err := p.pick(key)
+		if err != nil {
+			return nil, err
+		}
+		if cc != nil {
+			if p.popts.pingAfterIdle > 0 && idle > p.popts.pingAfterIdle && !cc.isValid(ctx) {
+				logger.Printf("[pool] discard conn-%05d, no ping ack after idle for %v, target: %s", cc.id, idle, key)
+				p.remove(key, cc)
+				cc.Close()
+				continue
+			}
+			logger.Printf("[pool] reuse conn-%05d, active streams: %d, target: %s", cc.id, cc.activeStreams(), key)
+			traceGotConn(ctx, cc.conn, true, idle)
+			return cc, nil
+		}
+
+		dialed := false
+		v, err, _ := p.g.Do(key, func() (interface{}, error) {
+			dialed = true
+			logger.Printf("[pool] connect to target: %s", key)
+			c, er := dial(ctx)
+			if er != nil {
+				return nil, er
+			}
+			if er = p.add(key, c); er != nil {
+				c.Close()
+				return nil, er
+			}
+			return c, nil
+		})
+		if err != nil {
+			if !dialed && ctx.Err() == nil && (errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)) {
+				// the caller whose dial ran gave up, this one did not
+				continue
+			}
+			logger.Printf("[pool] connect err, target: %s, err: %v", key, err)
+			return nil, err
+		}
+		if dialed {
+			cc = v.(*clientConn)
+			logger.Printf("[pool] new conn-%05d, target: %s", cc.id, key)
+			traceGotConn(ctx, cc.conn, false, 0)
+			return cc, nil
+		}
+	}
+}
+
+// pick returns the first usable connection of key with a free stream, or the least loaded one if the limit of
+// connections is reached, along with how long it has been idle. It returns nil if a connection should be dialed.
+func (p *Pool) pick(key string) (*clientConn, time.Duration, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return nil, 0, errPoolClosed
+	}
+
+	var least *clientConn
+	usable := 0
+	for _, cc := range p.conns[key] {
+		if cc.isClosed() || cc.isDraining() {
+			continue
+		}
+		usable++
+		if cc.activeStreams() < cc.maxStreams() {
+			return cc, cc.touch(), nil
+		}
+		if least == nil || cc.activeStreams()*least.maxStreams() < least.activeStreams()*cc.maxStreams() {
+			least = cc
+		}
+	}
+	if least == nil || p.popts.maxConnsPerAuthority <= 0 || usable < p.popts.maxConnsPerAuthority {
+		return nil, 0, nil
+	}
+	return least, least.touch(), nil
+}
+
+func (p *Pool) add(key string, cc *clientConn) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return errPoolClosed
+	}
+
+	cc.touch()
+	// closed connections are dropped here and by evictIdleConns
+	conns := p.conns[key][:0]
+	for _, c := range p.conns[key] {
+		if !c.isClosed() {
+			conns = append(conns, c)
+		}
+	}
+	p.conns[key] = append(conns, cc)
+	if p.evictTimer == nil && p.popts.idleTimeout > 0 {
+		p.evictTimer = time.AfterFunc(p.popts.idleTimeout, p.evictIdleConns)
+	}
+	return nil
+}
+
+func (p *Pool) remove(key string, cc *clientConn) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	conns := p.conns[key]
+	for i, c := range conns {
+		if c == cc {
+			p.conns[key] = append(conns[:i], conns[i+1:]...)
+			break
+		}
+	}
+	if len(p.conns[key]) == 0 {
+		delete(p.conns, key)
+	}
+}
+
+// evictIdleConns closes the connections idle for longer than the idle timeout, and drops the closed ones.
+// It runs again at the earliest time another connection may expire, as long as the pool has connections.
+func (p *Pool) evictIdleConns() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.evictTimer = nil
+	if p.closed {
+		return
+	}
+	now := time.Now()
+	next := p.popts.idleTimeout
+	for key, conns := range p.conns {
+		kept := conns[:0]
+		for _, cc := range conns {
+			if cc.isClosed() {
+				continue
+			}
+			if cc.activeStreams() == 0 {
+				idle := cc.idleFor(now)
+				if idle >= p.popts.idleTimeout {
+					cc.Close()
+					continue
+				}
+				next = min(next, p.popts.idleTimeout-idle)
+			}
+			kept = append(kept, cc)
+		}
+		if len(kept) == 0 {
+			delete(p.conns, key)
+		} else {
+			p.conns[key] = kept
+		}
+	}
+	if len(p.conns) > 0 {
+		p.evictTimer = time.AfterFunc(next, p.evictIdleConns)
+	}
+}
@@ -0,0 +1,211 @@
+package client
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+// newBlockingH2CServer serves h2c with at most maxStreams concurrent streams, the handler answers once
+// release is closed.
+func newBlockingH2CServer(t *testing.T, maxStreams uint32, release <-chan struct{}) *httptest.Server {
+	t.Helper()
+	h2s := &http2.Server{MaxConcurrentStreams: maxStreams}
+	server := httptest.NewUnstartedServer(h2c.NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		<-release
+		io.WriteString(w, "done")
+	}), h2s))
+	// graceful shutdown sends GOAWAY to the h2c connections too
+	if err := http2.ConfigureServer(server.Config, h2s); err != nil {
+		t.Fatal(err)
+	}
+	server.Start()
+	t.Cleanup(server.Close)
+	return server
+}
+
+func fetchH2C(ctx context.Context, f Fetcher, url string) (*http.Response, error) {
+	ctx = WithProtocol(ctx, HTTP2)
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	return f.Do(ctx, req)
+}
+
+// waitStats polls the stats of p until ok accepts them.
+func waitStats(t *testing.T, p *Pool, ok func(PoolStats) bool) PoolStats {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		stats := p.Stats()
+		if ok(stats) {
+			return stats
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("unexpected pool stats %+v", stats)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestPoolMaxConcurrentStreams(t *testing.T) {
+	release := make(chan struct{})
+	server := newBlockingH2CServer(t, 2, release)
+	pool := NewPool(WithMaxConnsPerAuthority(2))
+	defer pool.Close()
+	f := NewFetcher(WithPool(pool))
+	defer f.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res, err := fetchH2C(ctx, f, server.URL)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			defer res.Body.Close()
+			if body, _ := io.ReadAll(res.Body); string(body) != "done" {
+				t.Errorf("expected done, but actually %q", body)
+			}
+		}()
+	}
+
+	// the fifth stream waits for one of the two saturated connections
+	waitStats(t, pool, func(s PoolStats) bool { return s.Conns == 2 && s.ActiveStreams == 4 })
+	close(release)
+	wg.Wait()
+	waitStats(t, pool, func(s PoolStats) bool { return s.Conns == 2 && s.IdleConns == 2 && s.ActiveStreams == 0 })
+}
+
+func TestPoolIdleTimeout(t *testing.T) {
+	release := make(chan struct{})
+	close(release)
+	server := newBlockingH2CServer(t, 100, release)
+	pool := NewPool(WithPoolIdleTimeout(100 * time.Millisecond))
+	defer pool.Close()
+	f := NewFetcher(WithPool(pool))
+	defer f.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	for i := 0; i < 3; i++ {
+		res, err := fetchH2C(ctx, f, server.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.Copy(io.Discard, res.Body)
+		res.Body.Close()
+	}
+	if stats := pool.Stats(); stats.Conns != 1 {
+		t.Errorf("expected requests in sequence to share 1 connection, but actually %+v", stats)
+	}
+	waitStats(t, pool, func(s PoolStats) bool { return s.Conns == 0 })
+}
+
+func TestPoolSharedDialCanceled(t *testing.T) {
+	pool := NewPool()
+	defer pool.Close()
+
+	errRedialed := errors.New("redialed")
+	started := make(chan struct{})
+	var dials int32
+	dial := func(ctx context.Context) (*clientConn, error) {
+		if atomic.AddInt32(&dials, 1) == 1 {
+			close(started)
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+		return nil, errRedialed
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	firstErr := make(chan error, 1)
+	go func() {
+		_, err := pool.getConn(ctx, "http://example.com:80", dial)
+		firstErr <- err
+	}()
+	<-started
+	time.AfterFunc(50*time.Millisecond, cancel) // once the second caller waits on the dial of the first
+	if _, err := pool.getConn(context.Background(), "http://example.com:80", dial); !errors.Is(err, errRedialed) {
+		t.Error("expected the second caller to dial again, but actually ", err)
+	}
+	if err := <-firstErr; !errors.Is(err, context.Canceled) {
+		t.Error("expected the first caller canceled, but actually ", err)
+	}
+}
+
+func TestPoolGoAwayDraining(t *testing.T) {
+	release := make(chan struct{})
+	server := newBlockingH2CServer(t, 100, release)
+	pool := NewPool()
+	defer pool.Close()
+	f := NewFetcher(WithPool(pool))
+	defer f.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	res, err := fetchH2C(ctx, f, server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	go server.Config.Shutdown(ctx)
+	waitStats(t, pool, func(s PoolStats) bool { return s.DrainingConns == 1 && s.ActiveStreams == 1 })
+
+	// the stream in flight completes on the draining connection, which is closed afterward
+	close(release)
+	if body, err := io.ReadAll(res.Body); err != nil || string(body) != "done" {
+		t.Errorf("expected done, but actually %q %v", body, err)
+	}
+	waitStats(t, pool, func(s PoolStats) bool { return s.Conns == 0 })
+}
+
+func TestPoolSharedByTLSOptions(t *testing.T) {
+	server := newTLSServer(t, nil)
+	roots := x509.NewCertPool()
+	roots.AddCert(server.Certificate())
+	pool := NewPool()
+	defer pool.Close()
+
+	trusting := NewFetcher(WithPool(pool), WithRootCAs(roots))
+	defer trusting.Close()
+	if _, err := fetchProto(context.Background(), trusting, server.URL); err != nil {
+		t.Fatal(err)
+	}
+	same := NewFetcher(WithPool(pool), WithRootCAs(roots))
+	defer same.Close()
+	if _, err := fetchProto(context.Background(), same, server.URL); err != nil {
+		t.Fatal(err)
+	}
+	if stats := pool.Stats(); stats.Conns != 1 {
+		t.Errorf("expected Fetchers of the same TLS options to share 1 connection, but actually %+v", stats)
+	}
+
+	pinned := NewFetcher(WithPool(pool), WithRootCAs(roots), WithPinnedKeys(make([]byte, sha256.Size)))
+	defer pinned.Close()
+	if _, err := fetchProto(context.Background(), pinned, server.URL); err == nil {
+		t.Error("expected the pin mismatch, but the connection of another Fetcher was reused")
+	}
+	system := NewFetcher(WithPool(pool))
+	defer system.Close()
+	if _, err := fetchProto(context.Background(), system, server.URL); err == nil {
+		t.Error("expected an unknown authority, but the connection of another Fetcher was reused")
+	}
+}
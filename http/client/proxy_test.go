@@ -0,0 +1,71 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pysugar/wheels/internal/clienttest"
+)
+
+func TestFetcherProxy(t *testing.T) {
+	var tunnels int32
+	proxyURL := clienttest.NewTunnelProxy(t, &tunnels, url.UserPassword("user", "pass"))
+
+	for name, target := range redirectTargets(t) {
+		t.Run(name, func(t *testing.T) {
+			atomic.StoreInt32(&tunnels, 0)
+			f, baseURL, ctx := target(WithDialOptions(WithProxy(proxyURL)))
+			defer f.Close()
+
+			req, _ := http.NewRequest(http.MethodGet, baseURL+"/found", nil)
+			if _, body, err := doFetch(t, ctx, f, req); err != nil || body != "GET  " {
+				t.Fatalf("expected GET, but actually %q %v", body, err)
+			}
+			if n := atomic.LoadInt32(&tunnels); n == 0 {
+				t.Error("expected the request tunneled through the proxy")
+			}
+		})
+	}
+
+	t.Run("unauthorized", func(t *testing.T) {
+		server := httptest.NewServer(newRedirectMux())
+		defer server.Close()
+		noAuth := *proxyURL
+		noAuth.User = nil
+		f := NewFetcher(WithDialOptions(WithProxy(&noAuth)))
+		defer f.Close()
+
+		req, _ := http.NewRequest(http.MethodGet, server.URL+"/echo", nil)
+		_, _, err := doFetch(t, WithProtocol(context.Background(), HTTP1), f, req)
+		if err == nil || !strings.Contains(err.Error(), "407") {
+			t.Error("expected 407 Proxy Authentication Required, but actually ", err)
+		}
+	})
+}
+
+func TestDialWebSocketProxy(t *testing.T) {
+	var tunnels int32
+	proxyURL := clienttest.NewTunnelProxy(t, &tunnels, url.UserPassword("user", "pass"))
+	var pings int32
+	conn := dialWS(t, newWSServer(t, &pings), WithDialOptions(WithProxy(proxyURL)))
+	defer conn.Close(CloseNormalClosure, "")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := conn.WriteMessage(ctx, TextMessage, []byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	if _, data, err := conn.ReadMessage(ctx); err != nil || string(data) != "hello" {
+		t.Errorf("expected hello, but actually %q %v", data, err)
+	}
+	if n := atomic.LoadInt32(&tunnels); n != 1 {
+		t.Errorf("expected 1 tunnel, but actually %d", n)
+	}
+}
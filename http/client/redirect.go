@@ -0,0 +1,160 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const defaultMaxRedirects = 10
+
+var (
+	// ErrUseLastResponse can be returned by a CheckRedirect function to stop following redirects,
+	// Do then returns the redirect response with its body unread.
+	ErrUseLastResponse = http.ErrUseLastResponse
+
+	// headers removed when a redirect leaves the original host
+	defaultRedirectStripHeaders = []string{"Authorization", "Proxy-Authorization", "Www-Authenticate", "Cookie", "Cookie2"}
+)
+
+// WithMaxRedirects limits the redirects followed by Do, 10 by default. A negative n returns 3xx responses as is.
+func WithMaxRedirects(n int) FetcherOption {
+	return func(o *fetcherOptions) {
+		o.maxRedirects = n
+	}
+}
+
+// WithCheckRedirect is called before each redirect with the next request and the requests made so far,
+// oldest first. An error stops following, ErrUseLastResponse returns the redirect response without error.
+func WithCheckRedirect(check func(req *http.Request, via []*http.Request) error) FetcherOption {
+	return func(o *fetcherOptions) {
+		o.checkRedirect = check
+	}
+}
+
+// WithRedirectStripHeaders replaces the headers removed on redirects to another host,
+// which are Authorization, Proxy-Authorization, Www-Authenticate, Cookie and Cookie2 by default.
+// Cookies of the jar are added to each request anew.
+func WithRedirectStripHeaders(headers ...string) FetcherOption {
+	return func(o *fetcherOptions) {
+		o.redirectStripHeaders = headers
+	}
+}
+
+// WithCookieJar stores the cookies of responses in jar, and sends them with the matching requests.
+func WithCookieJar(jar http.CookieJar) FetcherOption {
+	return func(o *fetcherOptions) {
+		o.jar = jar
+	}
+}
+
+// redirectRequest returns the request following res, or nil if res is not a redirect to follow.
+func (o *fetcherOptions) redirectRequest(req *http.Request, res *http.Response, via []*http.Request) (*http.Request, error) {
+	method, keepBody := redirectBehavior(req.Method, res.StatusCode)
+	if method == "" {
+		return nil, nil
+	}
+	loc := res.Header.Get("Location")
+	if loc == "" {
+		return nil, nil // nothing to follow, e.g. 300 Multiple Choices
+	}
+	if o.maxRedirects < 0 {
+		return nil, nil
+	}
+	maxRedirects := o.maxRedirects
+	if maxRedirects == 0 {
+		maxRedirects = defaultMaxRedirects
+	}
+	if len(via) >= maxRedirects {
+		return nil, fmt.Errorf("stopped after %d redirects", maxRedirects)
+	}
+
+	u, err := req.URL.Parse(loc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse Location header %q, err: %v", loc, err)
+	}
+
+	var body io.ReadCloser = http.NoBody
+	var getBody func() (io.ReadCloser, error)
+	if keepBody && req.Body != nil && req.Body != http.NoBody {
+		if req.GetBody == nil {
+			return nil, nil // the body is consumed, the redirect can not be replayed
+		}
+		if body, err = req.GetBody(); err != nil {
+			return nil, err
+		}
+		getBody = req.GetBody
+	}
+
+	next, err := http.NewRequestWithContext(req.Context(), method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	next.GetBody = getBody
+	if keepBody {
+		next.ContentLength = req.ContentLength
+	}
+	next.Header = req.Header.Clone()
+	if !keepBody {
+		next.Header.Del("Content-Type")
+		next.Header.Del("Content-Length")
+	}
+	if !sameHost(req.URL, u) {
+		stripHeaders := o.redirectStripHeaders
+		if stripHeaders == nil {
+			stripHeaders = defaultRedirectStripHeaders
+		}
+		for _, h := range stripHeaders {
+			next.Header.Del(h)
+		}
+	}
+	if ref := referer(req.URL, u); ref != "" {
+		next.Header.Set("Referer", ref)
+	}
+
+	if o.checkRedirect != nil {
+		if err := o.checkRedirect(next, append(via, req)); err != nil {
+			if errors.Is(err, ErrUseLastResponse) {
+				return nil, nil
+			}
+			return nil, err
+		}
+	}
+	return next, nil
+}
+
+// redirectBehavior returns the method of the redirected request, and whether its body is resent.
+// 307 and 308 preserve both, 301, 302 and 303 switch to GET (RFC 9110 Section 15.4).
+func redirectBehavior(method string, statusCode int) (string, bool) {
+	switch statusCode {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther:
+		if method == http.MethodHead {
+			return http.MethodHead, false
+		}
+		return http.MethodGet, false
+	case http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		if method == "" {
+			method = http.MethodGet
+		}
+		return method, true
+	}
+	return "", false
+}
+
+func sameHost(from, to *url.URL) bool {
+	return strings.EqualFold(from.Hostname(), to.Hostname())
+}
+
+// referer returns the Referer header for a redirect, which is omitted from https to http.
+func referer(from, to *url.URL) string {
+	if from.Scheme == "https" && to.Scheme == "http" {
+		return ""
+	}
+	ref := *from
+	ref.User = nil
+	ref.Fragment = ""
+	return ref.String()
+}
@@ -0,0 +1,228 @@
+package client
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andybalholm/brotli"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+const content = "the quick brown fox jumps over the lazy dog"
+
+func newRedirectMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/found", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/echo", http.StatusFound)
+	})
+	mux.HandleFunc("/temporary", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/echo", http.StatusTemporaryRedirect)
+	})
+	mux.HandleFunc("/loop", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/loop", http.StatusFound)
+	})
+	mux.HandleFunc("/echo", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		io.WriteString(w, r.Method+" "+string(body)+" "+r.Header.Get("Authorization"))
+	})
+	mux.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "42", Path: "/"})
+		http.Redirect(w, r, "/home", http.StatusSeeOther)
+	})
+	mux.HandleFunc("/home", func(w http.ResponseWriter, r *http.Request) {
+		cookie, err := r.Cookie("session")
+		if err != nil {
+			http.Error(w, "no session", http.StatusUnauthorized)
+			return
+		}
+		io.WriteString(w, "session "+cookie.Value)
+	})
+	mux.HandleFunc("/encoded/", func(w http.ResponseWriter, r *http.Request) {
+		encoding := strings.TrimPrefix(r.URL.Path, "/encoded/")
+		if !strings.Contains(r.Header.Get("Accept-Encoding"), encoding) {
+			io.WriteString(w, content)
+			return
+		}
+		w.Header().Set("Content-Encoding", encoding)
+		var writer io.WriteCloser
+		switch encoding {
+		case "gzip":
+			writer = gzip.NewWriter(w)
+		case "deflate":
+			writer = zlib.NewWriter(w)
+		case "br":
+			writer = brotli.NewWriter(w)
+		}
+		io.WriteString(writer, content)
+		writer.Close()
+	})
+	return mux
+}
+
+// redirectTargets serves newRedirectMux over each of the paths of Do.
+func redirectTargets(t *testing.T) map[string]func(opts ...FetcherOption) (Fetcher, string, context.Context) {
+	h2cServer := httptest.NewServer(h2c.NewHandler(newRedirectMux(), &http2.Server{}))
+	t.Cleanup(h2cServer.Close)
+	tlsServer := httptest.NewUnstartedServer(newRedirectMux())
+	tlsServer.EnableHTTP2 = true
+	tlsServer.StartTLS()
+	t.Cleanup(tlsServer.Close)
+
+	return map[string]func(opts ...FetcherOption) (Fetcher, string, context.Context){
+		"http1": func(opts ...FetcherOption) (Fetcher, string, context.Context) {
+			return NewFetcher(opts...), h2cServer.URL, WithProtocol(context.Background(), HTTP1)
+		},
+		"h2c": func(opts ...FetcherOption) (Fetcher, string, context.Context) {
+			return NewFetcher(opts...), h2cServer.URL, WithProtocol(context.Background(), HTTP2)
+		},
+		"h2": func(opts ...FetcherOption) (Fetcher, string, context.Context) {
+			return NewFetcher(append(opts, WithInsecureSkipVerify())...), tlsServer.URL, WithProtocol(context.Background(), HTTP2)
+		},
+	}
+}
+
+func doFetch(t *testing.T, ctx context.Context, f Fetcher, req *http.Request) (*http.Response, string, error) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	res, err := f.Do(ctx, req.WithContext(ctx))
+	if err != nil {
+		return nil, "", err
+	}
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	return res, string(body), err
+}
+
+func TestFetcherRedirect(t *testing.T) {
+	for name, target := range redirectTargets(t) {
+		t.Run(name, func(t *testing.T) {
+			f, baseURL, ctx := target()
+			defer f.Close()
+
+			tests := []struct {
+				path string
+				want string
+			}{
+				{path: "/found", want: "GET  secret"},
+				{path: "/temporary", want: "POST payload secret"},
+			}
+			for _, tt := range tests {
+				req, _ := http.NewRequest(http.MethodPost, baseURL+tt.path, strings.NewReader("payload"))
+				req.Header.Set("Authorization", "secret")
+				res, body, err := doFetch(t, ctx, f, req)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if res.StatusCode != http.StatusOK || body != tt.want {
+					t.Errorf("%s: expected %q, but actually %s %q", tt.path, tt.want, res.Status, body)
+				}
+			}
+
+			req, _ := http.NewRequest(http.MethodGet, baseURL+"/loop", nil)
+			if _, _, err := doFetch(t, ctx, f, req); err == nil || !strings.Contains(err.Error(), "redirects") {
+				t.Error("expected too many redirects, but actually ", err)
+			}
+		})
+	}
+}
+
+func TestFetcherRedirectPolicy(t *testing.T) {
+	server := httptest.NewServer(newRedirectMux())
+	defer server.Close()
+	ctx := WithProtocol(context.Background(), HTTP1)
+
+	// 127.0.0.1 and localhost are different hosts to the redirect policy
+	other := strings.Replace(server.URL, "127.0.0.1", "localhost", 1)
+	crossHost := http.NewServeMux()
+	crossHost.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, other+"/echo", http.StatusTemporaryRedirect)
+	})
+	crossServer := httptest.NewServer(crossHost)
+	defer crossServer.Close()
+
+	f := NewFetcher()
+	defer f.Close()
+	req, _ := http.NewRequest(http.MethodGet, crossServer.URL, nil)
+	req.Header.Set("Authorization", "secret")
+	if _, body, err := doFetch(t, ctx, f, req); err != nil || body != "GET  " {
+		t.Errorf("expected Authorization stripped, but actually %q %v", body, err)
+	}
+
+	f = NewFetcher(WithMaxRedirects(-1))
+	defer f.Close()
+	req, _ = http.NewRequest(http.MethodGet, server.URL+"/found", nil)
+	if res, _, err := doFetch(t, ctx, f, req); err != nil || res.StatusCode != http.StatusFound {
+		t.Error("expected the redirect response, but actually ", res, err)
+	}
+
+	var hops []string
+	f = NewFetcher(WithCheckRedirect(func(req *http.Request, via []*http.Request) error {
+		hops = append(hops, req.URL.Path)
+		return ErrUseLastResponse
+	}))
+	defer f.Close()
+	req, _ = http.NewRequest(http.MethodGet, server.URL+"/found", nil)
+	if res, _, err := doFetch(t, ctx, f, req); err != nil || res.StatusCode != http.StatusFound || len(hops) != 1 || hops[0] != "/echo" {
+		t.Error("expected the redirect response, but actually ", res, hops, err)
+	}
+}
+
+func TestFetcherCookieJar(t *testing.T) {
+	for name, target := range redirectTargets(t) {
+		t.Run(name, func(t *testing.T) {
+			jar, _ := cookiejar.New(nil)
+			f, baseURL, ctx := target(WithCookieJar(jar))
+			defer f.Close()
+
+			req, _ := http.NewRequest(http.MethodGet, baseURL+"/login", nil)
+			if _, body, err := doFetch(t, ctx, f, req); err != nil || body != "session 42" {
+				t.Errorf("expected session 42, but actually %q %v", body, err)
+			}
+			req, _ = http.NewRequest(http.MethodGet, baseURL+"/home", nil)
+			if _, body, err := doFetch(t, ctx, f, req); err != nil || body != "session 42" {
+				t.Errorf("expected session 42, but actually %q %v", body, err)
+			}
+		})
+	}
+}
+
+func TestFetcherDecompression(t *testing.T) {
+	for name, target := range redirectTargets(t) {
+		t.Run(name, func(t *testing.T) {
+			f, baseURL, ctx := target()
+			defer f.Close()
+
+			for _, encoding := range []string{"gzip", "deflate", "br"} {
+				req, _ := http.NewRequest(http.MethodGet, baseURL+"/encoded/"+encoding, nil)
+				res, body, err := doFetch(t, ctx, f, req)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if body != content || !res.Uncompressed || res.Header.Get("Content-Encoding") != "" {
+					t.Errorf("%s: expected decoded content, but actually %q", encoding, body)
+				}
+			}
+
+			// an explicit Accept-Encoding leaves decoding to the caller
+			req, _ := http.NewRequest(http.MethodGet, baseURL+"/encoded/gzip", nil)
+			req.Header.Set("Accept-Encoding", "gzip")
+			res, body, err := doFetch(t, ctx, f, req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.Uncompressed || res.Header.Get("Content-Encoding") != "gzip" || body == content {
+				t.Error("expected gzip content as received")
+			}
+		})
+	}
+}
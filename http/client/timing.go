@@ -0,0 +1,216 @@
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+var timingCtxKey = &contextKey{"timing"}
+
+type (
+	// Timing records when the phases of a request end, for HTTP/1.1, h2c, h2 and gRPC alike. Following redirects,
+	// it describes the last request.
+	Timing struct {
+		mu sync.Mutex
+		at timingTimes
+	}
+
+	timingTimes struct {
+		start         time.Time
+		dnsDone       time.Time
+		connectDone   time.Time
+		tlsDone       time.Time
+		settingsAcked time.Time
+		gotConn       time.Time
+		wroteRequest  time.Time
+		firstByte     time.Time
+		bodyDone      time.Time
+		reused        bool
+		proto         string
+	}
+
+	// TimingReport holds the times since the start of a request at which its phases ended, as curl -w reports them.
+	// A phase the request skipped, such as the DNS lookup on a reused connection, ends with the phase before.
+	TimingReport struct {
+		Proto         string        // HTTP/1.1 or HTTP/2.0
+		Reused        bool          // the request was sent on a connection of a previous request
+		NameLookup    time.Duration // DNS lookup
+		Connect       time.Duration // TCP connect, to the proxy if any
+		AppConnect    time.Duration // TLS handshake
+		Settings      time.Duration // HTTP/2 connection preface and SETTINGS acknowledged
+		PreTransfer   time.Duration // connection ready
+		WroteRequest  time.Duration // request sent, the body included
+		StartTransfer time.Duration // first response byte
+		Total         time.Duration // response body complete
+	}
+
+	// timingBody records the end of a response body.
+	timingBody struct {
+		io.ReadCloser
+		t *Timing
+	}
+)
+
+// WithTiming makes the Fetcher record the phases of the requests of ctx in t. The events also go to the
+// httptrace.ClientTrace of ctx, if any.
+func WithTiming(ctx context.Context, t *Timing) context.Context {
+	ctx = context.WithValue(ctx, timingCtxKey, t)
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			t.record(&t.at.dnsDone)
+		},
+		ConnectDone: func(network, addr string, err error) {
+			if err == nil {
+				t.record(&t.at.connectDone)
+			}
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			t.record(&t.at.tlsDone)
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.at.gotConn, t.at.reused = time.Now(), info.Reused
+		},
+		WroteRequest: func(httptrace.WroteRequestInfo) {
+			t.record(&t.at.wroteRequest)
+		},
+		GotFirstResponseByte: func() {
+			t.record(&t.at.firstByte)
+		},
+	})
+}
+
+func timingFromContext(ctx context.Context) *Timing {
+	t, _ := ctx.Value(timingCtxKey).(*Timing)
+	return t
+}
+
+// Report returns the times of the phases of the last request.
+func (t *Timing) Report() TimingReport {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	r := TimingReport{Proto: t.at.proto, Reused: t.at.reused}
+	var last time.Duration
+	since := func(at time.Time) time.Duration {
+		if !at.IsZero() && at.Sub(t.at.start) > last {
+			last = at.Sub(t.at.start)
+		}
+		return last
+	}
+	r.NameLookup = since(t.at.dnsDone)
+	r.Connect = since(t.at.connectDone)
+	r.AppConnect = since(t.at.tlsDone)
+	r.Settings = since(t.at.settingsAcked)
+	r.PreTransfer = since(t.at.gotConn)
+	r.WroteRequest = since(t.at.wroteRequest)
+	r.StartTransfer = since(t.at.firstByte)
+	r.Total = since(t.at.bodyDone)
+	return r
+}
+
+// begin starts over for a new request, t may be nil.
+func (t *Timing) begin() {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.at = timingTimes{start: time.Now()}
+}
+
+func (t *Timing) record(at *time.Time) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if at.IsZero() {
+		*at = time.Now()
+	}
+}
+
+func (t *Timing) recordSettingsAcked() {
+	if t != nil {
+		t.record(&t.at.settingsAcked)
+	}
+}
+
+// wrap records the protocol of res and the end of its body.
+func (t *Timing) wrap(res *http.Response) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	t.at.proto = res.Proto
+	t.mu.Unlock()
+	if res.Body == nil || res.Body == http.NoBody {
+		t.record(&t.at.bodyDone)
+		return
+	}
+	res.Body = &timingBody{ReadCloser: res.Body, t: t}
+}
+
+func (b *timingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if errors.Is(err, io.EOF) {
+		b.t.record(&b.t.at.bodyDone)
+	}
+	return n, err
+}
+
+func (b *timingBody) Close() error {
+	b.t.record(&b.t.at.bodyDone)
+	return b.ReadCloser.Close()
+}
+
+// traceGetConn and the other trace functions call the hooks of the httptrace.ClientTrace of ctx, if any.
+func traceGetConn(ctx context.Context, hostPort string) {
+	if trace := httptrace.ContextClientTrace(ctx); trace != nil && trace.GetConn != nil {
+		trace.GetConn(hostPort)
+	}
+}
+
+func traceGotConn(ctx context.Context, conn net.Conn, reused bool, idleTime time.Duration) {
+	if trace := httptrace.ContextClientTrace(ctx); trace != nil && trace.GotConn != nil {
+		trace.GotConn(httptrace.GotConnInfo{Conn: conn, Reused: reused, WasIdle: idleTime > 0, IdleTime: idleTime})
+	}
+}
+
+func traceTLSHandshakeStart(ctx context.Context) {
+	if trace := httptrace.ContextClientTrace(ctx); trace != nil && trace.TLSHandshakeStart != nil {
+		trace.TLSHandshakeStart()
+	}
+}
+
+func traceTLSHandshakeDone(ctx context.Context, state tls.ConnectionState, err error) {
+	if trace := httptrace.ContextClientTrace(ctx); trace != nil && trace.TLSHandshakeDone != nil {
+		trace.TLSHandshakeDone(state, err)
+	}
+}
+
+func traceWroteHeaders(trace *httptrace.ClientTrace) {
+	if trace != nil && trace.WroteHeaders != nil {
+		trace.WroteHeaders()
+	}
+}
+
+func traceWroteRequest(trace *httptrace.ClientTrace, err error) {
+	if trace != nil && trace.WroteRequest != nil {
+		trace.WroteRequest(httptrace.WroteRequestInfo{Err: err})
+	}
+}
+
+func traceGotFirstResponseByte(trace *httptrace.ClientTrace) {
+	if trace != nil && trace.GotFirstResponseByte != nil {
+		trace.GotFirstResponseByte()
+	}
+}
@@ -0,0 +1,89 @@
+package client
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	testpb "google.golang.org/grpc/interop/grpc_testing"
+)
+
+func TestFetcherTiming(t *testing.T) {
+	h2cServer := newH2CServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.Proto)
+	}))
+	http1Server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.Proto)
+	}))
+	defer http1Server.Close()
+	tlsServer := newTLSServer(t, nil)
+
+	tests := []struct {
+		name     string
+		url      string
+		protocol HttpProtocol
+		proto    string
+		tls      bool
+	}{
+		{name: "http1", url: http1Server.URL, protocol: HTTP1, proto: "HTTP/1.1"},
+		{name: "h2c", url: h2cServer.URL, protocol: HTTP2, proto: "HTTP/2.0"},
+		{name: "h2", url: tlsServer.URL, proto: "HTTP/2.0", tls: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := NewFetcher(WithInsecureSkipVerify())
+			defer f.Close()
+
+			for i, reused := range []bool{false, true} {
+				timing := new(Timing)
+				ctx := WithTiming(WithProtocol(context.Background(), tt.protocol), timing)
+				if _, err := fetchProto(ctx, f, tt.url); err != nil {
+					t.Fatal(err)
+				}
+				r := timing.Report()
+				if r.Proto != tt.proto || r.Reused != reused {
+					t.Errorf("request %d: expected %s reused %v, but actually %s reused %v", i, tt.proto, reused, r.Proto, r.Reused)
+				}
+				phases := []time.Duration{r.NameLookup, r.Connect, r.AppConnect, r.Settings, r.PreTransfer,
+					r.WroteRequest, r.StartTransfer, r.Total}
+				for j := 1; j < len(phases); j++ {
+					if phases[j] < phases[j-1] {
+						t.Errorf("request %d: expected increasing phases, but actually %+v", i, r)
+					}
+				}
+				if !reused && (r.Connect == 0 || tt.tls && r.AppConnect == r.Connect ||
+					tt.proto == "HTTP/2.0" && r.Settings == r.AppConnect) {
+					t.Errorf("expected connect, tls and settings phases, but actually %+v", r)
+				}
+				if reused && r.Connect != 0 {
+					t.Errorf("expected no connect phase on a reused connection, but actually %+v", r)
+				}
+				if r.StartTransfer <= r.PreTransfer || r.Total < r.StartTransfer {
+					t.Errorf("expected the first byte after the connection, but actually %+v", r)
+				}
+			}
+		})
+	}
+}
+
+func TestFetcherGRPCTiming(t *testing.T) {
+	targets, _ := grpcTargets(t)
+	f := NewFetcher(WithInsecureSkipVerify())
+	defer f.Close()
+
+	timing := new(Timing)
+	ctx, cancel := context.WithTimeout(WithTiming(context.Background(), timing), 10*time.Second)
+	defer cancel()
+	res := new(testpb.SimpleResponse)
+	if err := f.CallGRPC(ctx, methodURL(targets["h2"], "UnaryCall"), &testpb.SimpleRequest{ResponseSize: 8}, res); err != nil {
+		t.Fatal(err)
+	}
+	r := timing.Report()
+	if r.Proto != "HTTP/2.0" || r.AppConnect == 0 || r.Settings <= r.AppConnect || r.Total < r.StartTransfer ||
+		r.StartTransfer == r.Settings {
+		t.Errorf("expected the phases of an h2 call, but actually %+v", r)
+	}
+}
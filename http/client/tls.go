@@ -0,0 +1,168 @@
+package client
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+)
+
+const pinPrefix = "sha256//"
+
+var (
+	defaultTLSConfig = &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		NextProtos: []string{"h2", "http/1.1"},
+	}
+)
+
+// WithRootCAs verifies server certificates against pool instead of the system roots.
+func WithRootCAs(pool *x509.CertPool) FetcherOption {
+	return func(o *fetcherOptions) {
+		o.rootCAs = pool
+	}
+}
+
+// WithClientCertificate presents cert to servers that request client authentication (mTLS).
+func WithClientCertificate(cert tls.Certificate) FetcherOption {
+	return func(o *fetcherOptions) {
+		o.certificates = append(o.certificates, cert)
+	}
+}
+
+// WithServerName overrides the SNI and the name verified in the server certificate, which default to the request host.
+func WithServerName(serverName string) FetcherOption {
+	return func(o *fetcherOptions) {
+		o.serverName = serverName
+	}
+}
+
+// WithMinTLSVersion sets the minimum TLS version, tls.VersionTLS12 by default.
+func WithMinTLSVersion(version uint16) FetcherOption {
+	return func(o *fetcherOptions) {
+		o.minVersion = version
+	}
+}
+
+// WithPinnedKeys accepts only servers whose certificate chain contains one of the public keys,
+// given as SHA-256 hashes of the SubjectPublicKeyInfo. See ParsePin for the textual form.
+func WithPinnedKeys(pins ...[]byte) FetcherOption {
+	return func(o *fetcherOptions) {
+		o.pins = append(o.pins, pins...)
+	}
+}
+
+// WithALPN sets the protocols offered in the TLS handshake, "h2" and "http/1.1" by default.
+func WithALPN(protos ...string) FetcherOption {
+	return func(o *fetcherOptions) {
+		o.nextProtos = protos
+	}
+}
+
+// WithInsecureSkipVerify disables the verification of server certificates for all requests,
+// WithInsecure does it for a single request. Pinned keys are still checked.
+func WithInsecureSkipVerify() FetcherOption {
+	return func(o *fetcherOptions) {
+		o.insecure = true
+	}
+}
+
+// ParsePin parses a base64 encoded SHA-256 hash of a SubjectPublicKeyInfo, optionally prefixed by "sha256//"
+// as in curl --pinnedpubkey.
+func ParsePin(s string) ([]byte, error) {
+	pin, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(s, pinPrefix))
+	if err != nil {
+		return nil, fmt.Errorf("invalid pin %s, err: %v", s, err)
+	}
+	if len(pin) != sha256.Size {
+		return nil, fmt.Errorf("invalid pin %s, expected %d bytes but actually %d", s, sha256.Size, len(pin))
+	}
+	return pin, nil
+}
+
+// LoadCertPool reads PEM encoded CA certificates from files.
+func LoadCertPool(files ...string) (*x509.CertPool, error) {
+	pool := x509.NewCertPool()
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("no certificate found in %s", file)
+		}
+	}
+	return pool, nil
+}
+
+// tlsConfig builds the client TLS configuration for host, insecure disables certificate verification.
+func (o *fetcherOptions) tlsConfig(host string, insecure bool) *tls.Config {
+	cfg := defaultTLSConfig.Clone()
+	cfg.ServerName = o.serverName
+	if cfg.ServerName == "" {
+		cfg.ServerName = hostname(host)
+	}
+	cfg.RootCAs = o.rootCAs
+	cfg.Certificates = o.certificates
+	if o.minVersion != 0 {
+		cfg.MinVersion = o.minVersion
+	}
+	if len(o.nextProtos) > 0 {
+		cfg.NextProtos = o.nextProtos
+	}
+	cfg.InsecureSkipVerify = o.insecure || insecure
+	if len(o.pins) > 0 {
+		pins := o.pins
+		cfg.VerifyConnection = func(state tls.ConnectionState) error {
+			return verifyPins(state.PeerCertificates, pins)
+		}
+	}
+	return cfg
+}
+
+// tlsFingerprint identifies the TLS options, so that pooled connections serve only the Fetchers that would
+// have dialed them the same way. Root CAs are told apart by pool, not by content. It is empty for the
+// default options.
+func (o *fetcherOptions) tlsFingerprint() string {
+	if o.rootCAs == nil && len(o.certificates) == 0 && o.serverName == "" && o.minVersion == 0 &&
+		len(o.pins) == 0 && len(o.nextProtos) == 0 {
+		return ""
+	}
+	h := sha256.New()
+	fmt.Fprintf(h, "roots=%p;server=%s;min=%d;alpn=%s;", o.rootCAs, o.serverName, o.minVersion, strings.Join(o.nextProtos, ","))
+	for _, cert := range o.certificates {
+		for _, der := range cert.Certificate {
+			h.Write(der)
+		}
+		h.Write([]byte{';'})
+	}
+	for _, pin := range o.pins {
+		h.Write(pin)
+	}
+	return base64.RawURLEncoding.EncodeToString(h.Sum(nil)[:12])
+}
+
+func verifyPins(certs []*x509.Certificate, pins [][]byte) error {
+	for _, cert := range certs {
+		sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+		for _, pin := range pins {
+			if bytes.Equal(sum[:], pin) {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("none of the server public keys matches the pinned keys")
+}
+
+// hostname strips the port from host, if any.
+func hostname(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		return h
+	}
+	return host
+}
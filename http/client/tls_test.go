@@ -0,0 +1,182 @@
+package client
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newTLSServer(t *testing.T, clientCAs *x509.CertPool) *httptest.Server {
+	t.Helper()
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.Proto)
+	}))
+	server.EnableHTTP2 = true
+	if clientCAs != nil {
+		// TLS 1.2 rejects a missing client certificate within the handshake
+		server.TLS = &tls.Config{
+			ClientAuth: tls.RequireAndVerifyClientCert,
+			ClientCAs:  clientCAs,
+			MaxVersion: tls.VersionTLS12,
+		}
+	}
+	server.StartTLS()
+	t.Cleanup(server.Close)
+	return server
+}
+
+func fetchProto(ctx context.Context, f Fetcher, url string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	res, err := f.Do(ctx, req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	return string(body), err
+}
+
+func TestFetcherTLSVerification(t *testing.T) {
+	server := newTLSServer(t, nil)
+	serverPool := x509.NewCertPool()
+	serverPool.AddCert(server.Certificate())
+	sum := sha256.Sum256(server.Certificate().RawSubjectPublicKeyInfo)
+	pin, err := ParsePin("sha256//" + base64.StdEncoding.EncodeToString(sum[:]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherPin := make([]byte, sha256.Size)
+
+	tests := []struct {
+		name    string
+		opts    []FetcherOption
+		ctx     context.Context
+		wantErr bool
+	}{
+		{name: "unknown authority", ctx: context.Background(), wantErr: true},
+		{name: "root CAs", opts: []FetcherOption{WithRootCAs(serverPool)}, ctx: context.Background()},
+		{name: "insecure request", ctx: WithInsecure(context.Background())},
+		{name: "insecure fetcher", opts: []FetcherOption{WithInsecureSkipVerify()}, ctx: context.Background()},
+		{name: "pinned key", opts: []FetcherOption{WithRootCAs(serverPool), WithPinnedKeys(pin)}, ctx: context.Background()},
+		{name: "pin mismatch", opts: []FetcherOption{WithRootCAs(serverPool), WithPinnedKeys(otherPin)}, ctx: context.Background(), wantErr: true},
+		{name: "pin mismatch insecure", opts: []FetcherOption{WithPinnedKeys(otherPin)}, ctx: WithInsecure(context.Background()), wantErr: true},
+		{name: "server name mismatch", opts: []FetcherOption{WithRootCAs(serverPool), WithServerName("wheels.test")}, ctx: context.Background(), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := NewFetcher(tt.opts...)
+			defer f.Close()
+			proto, err := fetchProto(tt.ctx, f, server.URL)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error: %v, but actually %v", tt.wantErr, err)
+			}
+			if err == nil && proto != "HTTP/2.0" {
+				t.Error("expected HTTP/2.0 via ALPN, but actually ", proto)
+			}
+		})
+	}
+}
+
+func TestFetcherInsecureConnNotReused(t *testing.T) {
+	server := newTLSServer(t, nil)
+	for _, alpn := range []string{"h2", "http/1.1"} {
+		t.Run(alpn, func(t *testing.T) {
+			f := NewFetcher(WithALPN(alpn))
+			defer f.Close()
+			if _, err := fetchProto(WithInsecure(context.Background()), f, server.URL); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := fetchProto(context.Background(), f, server.URL); err == nil {
+				t.Error("expected the verification to fail, but the insecure connection was reused")
+			}
+		})
+	}
+}
+
+func TestFetcherALPN(t *testing.T) {
+	server := newTLSServer(t, nil)
+	f := NewFetcher(WithInsecureSkipVerify(), WithALPN("http/1.1"))
+	defer f.Close()
+
+	proto, err := fetchProto(context.Background(), f, server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if proto != "HTTP/1.1" {
+		t.Error("expected HTTP/1.1, but actually ", proto)
+	}
+}
+
+func TestFetcherClientCertificate(t *testing.T) {
+	clientCert := newClientCertificate(t)
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientCert.Leaf)
+	server := newTLSServer(t, clientCAs)
+
+	tests := []struct {
+		name    string
+		opts    []FetcherOption
+		wantErr bool
+	}{
+		{name: "without certificate", opts: []FetcherOption{WithInsecureSkipVerify()}, wantErr: true},
+		{name: "with certificate", opts: []FetcherOption{WithInsecureSkipVerify(), WithClientCertificate(clientCert)}},
+		{name: "min version", opts: []FetcherOption{WithInsecureSkipVerify(), WithClientCertificate(clientCert), WithMinTLSVersion(tls.VersionTLS13)}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := NewFetcher(tt.opts...)
+			defer f.Close()
+			if _, err := fetchProto(context.Background(), f, server.URL); (err != nil) != tt.wantErr {
+				t.Fatalf("expected error: %v, but actually %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestParsePin(t *testing.T) {
+	if _, err := ParsePin("sha256//not-base64"); err == nil {
+		t.Error("expected error of invalid base64")
+	}
+	if _, err := ParsePin(base64.StdEncoding.EncodeToString([]byte("short"))); err == nil {
+		t.Error("expected error of invalid length")
+	}
+}
+
+func newClientCertificate(t *testing.T) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "wheels client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}
+}
@@ -1,104 +1,280 @@
 package client
 
 import (
-	"bufio"
 	"context"
-	"crypto/tls"
+	"errors"
 	"fmt"
-	"log"
+	"net"
 	"net/http"
-	"os"
 	"strings"
 	"sync"
 	"time"
 
-	"golang.org/x/net/websocket"
+	"github.com/gorilla/websocket"
+	"github.com/pysugar/wheels/http/extensions"
+	"github.com/pysugar/wheels/http/proxy"
 )
 
-func (f *fetcher) doWebsocket(ctx context.Context, req *http.Request) error {
-	origin := req.Header.Get("Origin")
-	if origin == "" {
-		origin = fmt.Sprintf("%s://%s", req.URL.Scheme, req.URL.Host)
+const (
+	TextMessage   = MessageType(websocket.TextMessage)
+	BinaryMessage = MessageType(websocket.BinaryMessage)
+
+	// Close codes defined in RFC 6455, section 11.7.
+	CloseNormalClosure     = websocket.CloseNormalClosure
+	CloseGoingAway         = websocket.CloseGoingAway
+	CloseProtocolError     = websocket.CloseProtocolError
+	CloseUnsupportedData   = websocket.CloseUnsupportedData
+	CloseNoStatusReceived  = websocket.CloseNoStatusReceived
+	CloseAbnormalClosure   = websocket.CloseAbnormalClosure
+	ClosePolicyViolation   = websocket.ClosePolicyViolation
+	CloseMessageTooBig     = websocket.CloseMessageTooBig
+	CloseInternalServerErr = websocket.CloseInternalServerErr
+
+	writeWait          = time.Second
+	closeWait          = 5 * time.Second
+	defaultPongTimeout = 10 * time.Second
+)
+
+type (
+	MessageType int
+
+	// WSConn is a client WebSocket connection. Messages are read and written by at most one goroutine each,
+	// control frames (ping, pong, close) are handled internally.
+	WSConn interface {
+		// ReadMessage blocks until a data message arrives. Once the server has closed the connection it returns
+		// a *WSCloseError with the close code.
+		ReadMessage(ctx context.Context) (MessageType, []byte, error)
+		// WriteMessage sends a data message, the deadline of ctx bounds the write.
+		WriteMessage(ctx context.Context, messageType MessageType, data []byte) error
+		// Ping sends a ping, the matching pong extends the read deadline if keepalive is enabled.
+		Ping(data []byte) error
+		// Subprotocol returns the subprotocol selected by the server, if any.
+		Subprotocol() string
+		// Close sends a close frame with code and reason, waits for the server to close and releases the connection.
+		Close(code int, reason string) error
+	}
+
+	// WSCloseError is returned by ReadMessage once a close frame is received.
+	WSCloseError struct {
+		Code int
+		Text string
 	}
 
+	wsConn struct {
+		conn        *websocket.Conn
+		msgCh       chan wsMessage
+		readDone    chan struct{} // closed once the read loop exits, readErr is set
+		readErr     error
+		closeCh     chan struct{}
+		closeOnce   sync.Once
+		writeMu     sync.Mutex
+		pongTimeout time.Duration
+		logger      VerboseLogger
+	}
+
+	wsMessage struct {
+		messageType MessageType
+		data        []byte
+	}
+)
+
+func (mt MessageType) String() string {
+	switch mt {
+	case TextMessage:
+		return "text"
+	case BinaryMessage:
+		return "binary"
+	default:
+		return fmt.Sprintf("MessageType(%d)", int(mt))
+	}
+}
+
+func (e *WSCloseError) Error() string {
+	return fmt.Sprintf("websocket closed: %d %s", e.Code, e.Text)
+}
+
+// WithWebSocketSubprotocols offers subprotocols in the order of preference, Subprotocol returns the selected one.
+func WithWebSocketSubprotocols(protocols ...string) FetcherOption {
+	return func(o *fetcherOptions) {
+		o.wsSubprotocols = protocols
+	}
+}
+
+// WithWebSocketCompression negotiates permessage-deflate (RFC 7692) and compresses written messages if accepted.
+func WithWebSocketCompression() FetcherOption {
+	return func(o *fetcherOptions) {
+		o.wsCompression = true
+	}
+}
+
+// WithWebSocketKeepalive pings the server every interval, the connection fails if nothing is received
+// within interval plus pongTimeout. An interval of 0 disables keepalive.
+func WithWebSocketKeepalive(interval, pongTimeout time.Duration) FetcherOption {
+	return func(o *fetcherOptions) {
+		o.wsPingInterval = interval
+		o.wsPongTimeout = pongTimeout
+	}
+}
+
+func (f *fetcher) DialWebSocket(ctx context.Context, req *http.Request) (WSConn, error) {
+	logger := newVerboseLogger(ctx)
+	fopts := f.options()
+
+	dopts := evaluateOptions(fopts.dialOptions)
+	scheme := "http"
+	dialer := &websocket.Dialer{
+		NetDialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			// gorilla runs the TLS handshake on the connection
+			return proxy.Dial(ctx, dopts.proxyFunc(), scheme, addr)
+		},
+		HandshakeTimeout:  45 * time.Second,
+		Subprotocols:      fopts.wsSubprotocols,
+		EnableCompression: fopts.wsCompression,
+	}
 	var serverAddr string
-	if strings.EqualFold(req.URL.Scheme, "https") {
+	if strings.EqualFold(req.URL.Scheme, "https") || strings.EqualFold(req.URL.Scheme, "wss") {
+		scheme = "https"
 		serverAddr = fmt.Sprintf("wss://%s%s", req.URL.Host, req.URL.RequestURI())
+		dialer.TLSClientConfig = f.tlsConfig(ctx, req.URL.Host)
+		dialer.TLSClientConfig.NextProtos = nil // the websocket handshake is HTTP/1.1 only
 	} else {
 		serverAddr = fmt.Sprintf("ws://%s%s", req.URL.Host, req.URL.RequestURI())
 	}
 
-	wsCfg, err := websocket.NewConfig(serverAddr, origin)
+	conn, res, err := dialer.DialContext(ctx, serverAddr, req.Header)
 	if err != nil {
-		return err
+		logger.Printf("[%s] websocket dial error: %v", serverAddr, err)
+		return nil, err
 	}
+	if res != nil {
+		res.Body.Close()
+		logger.Printf("Response: %s", extensions.FormatResponse(res))
+	}
+	conn.EnableWriteCompression(fopts.wsCompression)
 
-	if InsecureFromContext(ctx) {
-		wsCfg.TlsConfig = &tls.Config{
-			InsecureSkipVerify: true,
+	wc := &wsConn{
+		conn:        conn,
+		msgCh:       make(chan wsMessage),
+		readDone:    make(chan struct{}),
+		closeCh:     make(chan struct{}),
+		pongTimeout: fopts.wsPongTimeout,
+		logger:      logger,
+	}
+	conn.SetPingHandler(func(message string) error {
+		logger.Printf("Received Ping from server: %s, send pong\n", message)
+		err := conn.WriteControl(websocket.PongMessage, []byte(message), time.Now().Add(writeWait))
+		if errors.Is(err, websocket.ErrCloseSent) {
+			return nil
 		}
-		log.Printf("[%s] insecure skip verify", serverAddr)
+		return err
+	})
+	if fopts.wsPingInterval > 0 {
+		conn.SetPongHandler(func(appData string) error {
+			logger.Printf("Received Pong from server: %s\n", appData)
+			wc.extendReadDeadline(fopts.wsPingInterval)
+			return nil
+		})
+		go wc.pingLoop(fopts.wsPingInterval)
 	}
+	go wc.readLoop(fopts.wsPingInterval)
+	return wc, nil
+}
 
-	conn, err := wsCfg.DialContext(ctx)
-	if err != nil {
+func (c *wsConn) ReadMessage(ctx context.Context) (MessageType, []byte, error) {
+	select {
+	case m := <-c.msgCh:
+		return m.messageType, m.data, nil
+	case <-c.readDone:
+		return 0, nil, c.readErr
+	case <-ctx.Done():
+		return 0, nil, ctx.Err()
+	}
+}
+
+func (c *wsConn) WriteMessage(ctx context.Context, messageType MessageType, data []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	deadline, _ := ctx.Deadline()
+	if err := c.conn.SetWriteDeadline(deadline); err != nil {
 		return err
 	}
-	defer conn.Close()
+	return c.conn.WriteMessage(int(messageType), data)
+}
 
-	gCtx, cancel := context.WithCancel(context.Background())
-	defer cancel()
+func (c *wsConn) Ping(data []byte) error {
+	return c.conn.WriteControl(websocket.PingMessage, data, time.Now().Add(writeWait))
+}
 
-	messageChannel := make(chan string, 10)
-	var wg sync.WaitGroup
-	wg.Add(1)
-	go f.wsReadLoop(gCtx, conn, messageChannel, &wg)
-	reader := bufio.NewReader(os.Stdin)
-	for {
-		fmt.Print("Please input message: ")
-		msg, _ := reader.ReadString('\n')
-		msg = strings.TrimSpace(msg)
+func (c *wsConn) Subprotocol() string {
+	return c.conn.Subprotocol()
+}
 
-		if msg == ":quit" || msg == ":exit" {
-			cancel()
+func (c *wsConn) Close(code int, reason string) (err error) {
+	c.closeOnce.Do(func() {
+		close(c.closeCh)
+		er := c.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(writeWait))
+		if er == nil {
+			// the server echoes the close frame, which ends the read loop
+			select {
+			case <-c.readDone:
+			case <-time.After(closeWait):
+				c.logger.Printf("wait for server close timeout")
+			}
+		} else if !errors.Is(er, websocket.ErrCloseSent) {
+			err = er
 		}
-		if er := websocket.Message.Send(conn, msg); er != nil {
-			log.Printf("send message failure: %v", er)
-			return er
+		if er := c.conn.Close(); err == nil {
+			err = er
 		}
+	})
+	return err
+}
 
-		select {
-		case <-gCtx.Done():
-			wg.Wait()
-			log.Printf("websocket exit success")
-			return nil
-		case m, ok := <-messageChannel:
-			if !ok {
-				return nil
+func (c *wsConn) readLoop(pingInterval time.Duration) {
+	defer close(c.readDone)
+	for {
+		// the deadline runs from the next read, as pongs are not processed while a message is being handed off
+		if pingInterval > 0 {
+			c.extendReadDeadline(pingInterval)
+		}
+		mt, data, err := c.conn.ReadMessage()
+		if err != nil {
+			var closeErr *websocket.CloseError
+			if errors.As(err, &closeErr) {
+				c.logger.Printf("Receive server close: %d (%s)", closeErr.Code, closeErr.Text)
+				c.readErr = &WSCloseError{Code: closeErr.Code, Text: closeErr.Text}
+			} else {
+				c.readErr = err
 			}
-			fmt.Printf("receive message: %s\n", m)
-		case <-time.After(1 * time.Second):
-			log.Println("receive message timeout")
+			return
+		}
+		select {
+		case c.msgCh <- wsMessage{messageType: MessageType(mt), data: data}:
+		case <-c.closeCh:
+			// keep reading until the close frame of the server
 		}
 	}
 }
 
-func (f *fetcher) wsReadLoop(ctx context.Context, ws *websocket.Conn, ch chan<- string, wg *sync.WaitGroup) {
-	defer close(ch)
-	defer wg.Done()
+func (c *wsConn) pingLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
 	for {
 		select {
-		case <-ctx.Done():
-			log.Printf("read loop context done")
-			return
-		default:
-			var msg string
-			err := websocket.Message.Receive(ws, &msg)
-			if err != nil {
-				log.Printf("read message failure: %v", err)
+		case <-ticker.C:
+			if err := c.Ping(nil); err != nil {
+				c.logger.Printf("send ping failure: %v", err)
 				return
 			}
-			ch <- strings.TrimSpace(msg)
+		case <-c.readDone:
+			return
+		case <-c.closeCh:
+			return
 		}
 	}
 }
+
+func (c *wsConn) extendReadDeadline(pingInterval time.Duration) {
+	c.conn.SetReadDeadline(time.Now().Add(pingInterval + c.pongTimeout))
+}
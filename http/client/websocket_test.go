@@ -0,0 +1,168 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// newWSServer echoes messages back, and closes with the code in the message "close <reason>".
+func newWSServer(t *testing.T, pings *int32) *httptest.Server {
+	t.Helper()
+	upgrader := websocket.Upgrader{
+		Subprotocols:      []string{"v2.wheels", "v1.wheels"},
+		EnableCompression: true,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.SetPingHandler(func(appData string) error {
+			atomic.AddInt32(pings, 1)
+			return conn.WriteControl(websocket.PongMessage, []byte(appData), time.Now().Add(time.Second))
+		})
+		for {
+			mt, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if reason, ok := strings.CutPrefix(string(data), "close "); ok {
+				conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.ClosePolicyViolation, reason), time.Now().Add(time.Second))
+				continue
+			}
+			if er := conn.WriteMessage(mt, data); er != nil {
+				return
+			}
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func dialWS(t *testing.T, server *httptest.Server, opts ...FetcherOption) WSConn {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/ws", nil)
+	conn, err := NewFetcher(opts...).DialWebSocket(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return conn
+}
+
+func TestDialWebSocket(t *testing.T) {
+	var pings int32
+	server := newWSServer(t, &pings)
+	conn := dialWS(t, server, WithWebSocketSubprotocols("v1.wheels"), WithWebSocketCompression())
+	if p := conn.Subprotocol(); p != "v1.wheels" {
+		t.Error("expected subprotocol v1.wheels, but actually ", p)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	messages := []struct {
+		mt   MessageType
+		data string
+	}{
+		{TextMessage, "hello"},
+		{BinaryMessage, "\x00\x01\x02"},
+		{TextMessage, strings.Repeat("compressible ", 1024)},
+	}
+	for _, m := range messages {
+		if err := conn.WriteMessage(ctx, m.mt, []byte(m.data)); err != nil {
+			t.Fatal(err)
+		}
+		mt, data, err := conn.ReadMessage(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if mt != m.mt || string(data) != m.data {
+			t.Errorf("expected %s message of %d bytes, but actually %s message of %d bytes", m.mt, len(m.data), mt, len(data))
+		}
+	}
+	if err := conn.Close(CloseNormalClosure, "bye"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestWebSocketServerClose(t *testing.T) {
+	var pings int32
+	server := newWSServer(t, &pings)
+	conn := dialWS(t, server)
+	defer conn.Close(CloseNormalClosure, "")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := conn.WriteMessage(ctx, TextMessage, []byte("close not allowed")); err != nil {
+		t.Fatal(err)
+	}
+	_, _, err := conn.ReadMessage(ctx)
+	var closeErr *WSCloseError
+	if !errors.As(err, &closeErr) {
+		t.Fatal("expected close error, but actually ", err)
+	}
+	if closeErr.Code != ClosePolicyViolation || closeErr.Text != "not allowed" {
+		t.Errorf("unexpected close: %d %s", closeErr.Code, closeErr.Text)
+	}
+}
+
+func TestWebSocketKeepalive(t *testing.T) {
+	var pings int32
+	server := newWSServer(t, &pings)
+	conn := dialWS(t, server, WithWebSocketKeepalive(50*time.Millisecond, time.Second))
+	defer conn.Close(CloseNormalClosure, "")
+
+	// the connection stays readable beyond the ping interval as long as pongs arrive
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	if _, _, err := conn.ReadMessage(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatal("expected deadline exceeded, but actually ", err)
+	}
+	if n := atomic.LoadInt32(&pings); n < 2 {
+		t.Error("expected at least 2 pings, but actually ", n)
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := conn.WriteMessage(ctx, TextMessage, []byte("still alive")); err != nil {
+		t.Fatal(err)
+	}
+	if _, data, err := conn.ReadMessage(ctx); err != nil || string(data) != "still alive" {
+		t.Error("unexpected message: ", string(data), err)
+	}
+}
+
+func TestWebSocketKeepaliveSlowReader(t *testing.T) {
+	var pings int32
+	conn := dialWS(t, newWSServer(t, &pings), WithWebSocketKeepalive(50*time.Millisecond, 100*time.Millisecond))
+	defer conn.Close(CloseNormalClosure, "")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for _, message := range []string{"one", "two"} {
+		if err := conn.WriteMessage(ctx, TextMessage, []byte(message)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// a message waits for the reader far beyond the ping interval plus the pong timeout
+	for _, want := range []string{"one", "two"} {
+		if _, data, err := conn.ReadMessage(ctx); err != nil || string(data) != want {
+			t.Fatalf("expected %s, but actually %q %v", want, data, err)
+		}
+		time.Sleep(400 * time.Millisecond)
+	}
+	if err := conn.WriteMessage(ctx, TextMessage, []byte("three")); err != nil {
+		t.Fatal(err)
+	}
+	if _, data, err := conn.ReadMessage(ctx); err != nil || string(data) != "three" {
+		t.Errorf("expected three, but actually %q %v", data, err)
+	}
+}
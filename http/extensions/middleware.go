@@ -4,6 +4,8 @@ import (
 	"bufio"
 	"bytes"
 	"fmt"
+	"github.com/pysugar/wheels/features/stats"
+	"io"
 	"log"
 	"net/http"
 	"time"
@@ -95,3 +97,44 @@ func FormatResponseWriter(w http.ResponseWriter) string {
 	writer.Flush()
 	return buf.String()
 }
+
+// StatsMiddleware adds the size of request bodies to uplink, and the size of response bodies to downlink.
+// Either counter may be nil.
+func StatsMiddleware(next http.Handler, uplink, downlink stats.Counter) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if uplink != nil && r.Body != nil {
+			r.Body = &countingReadCloser{ReadCloser: r.Body, counter: uplink}
+		}
+		if downlink != nil {
+			w = &countingResponseWriter{ResponseWriter: w, counter: downlink}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+type countingReadCloser struct {
+	io.ReadCloser
+	counter stats.Counter
+}
+
+func (c *countingReadCloser) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	c.counter.Add(int64(n))
+	return n, err
+}
+
+type countingResponseWriter struct {
+	http.ResponseWriter
+	counter stats.Counter
+}
+
+func (c *countingResponseWriter) Write(p []byte) (int, error) {
+	n, err := c.ResponseWriter.Write(p)
+	c.counter.Add(int64(n))
+	return n, err
+}
+
+// Unwrap allows http.ResponseController to reach the underlying ResponseWriter.
+func (c *countingResponseWriter) Unwrap() http.ResponseWriter {
+	return c.ResponseWriter
+}
@@ -0,0 +1,199 @@
+// Package har records the exchanges of a client.Fetcher in HAR 1.2 (HTTP Archive) files, and replays them.
+package har
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+// the HAR 1.2 format, see http://www.softwareishard.com/blog/har-12-spec/.
+// Custom fields start with an underscore.
+type (
+	HAR struct {
+		Log Log `json:"log"`
+	}
+
+	Log struct {
+		Version string  `json:"version"`
+		Creator Creator `json:"creator"`
+		Entries []Entry `json:"entries"`
+	}
+
+	Creator struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+	}
+
+	Entry struct {
+		StartedDateTime time.Time `json:"startedDateTime"`
+		Time            float64   `json:"time"` // total milliseconds
+		Request         Request   `json:"request"`
+		Response        Response  `json:"response"`
+		Cache           struct{}  `json:"cache"`
+		Timings         Timings   `json:"timings"`
+		ServerIPAddress string    `json:"serverIPAddress,omitempty"`
+		Connection      string    `json:"connection,omitempty"` // the local port
+		StreamID        uint32    `json:"_streamId,omitempty"`  // the HTTP/2 stream
+		TLS             *TLS      `json:"_tls,omitempty"`
+		Comment         string    `json:"comment,omitempty"`
+	}
+
+	Request struct {
+		Method      string      `json:"method"`
+		URL         string      `json:"url"`
+		HTTPVersion string      `json:"httpVersion"`
+		Cookies     []Cookie    `json:"cookies"`
+		Headers     []NameValue `json:"headers"`
+		QueryString []NameValue `json:"queryString"`
+		PostData    *PostData   `json:"postData,omitempty"`
+		HeadersSize int64       `json:"headersSize"`
+		BodySize    int64       `json:"bodySize"`
+	}
+
+	Response struct {
+		Status      int         `json:"status"`
+		StatusText  string      `json:"statusText"`
+		HTTPVersion string      `json:"httpVersion"`
+		Cookies     []Cookie    `json:"cookies"`
+		Headers     []NameValue `json:"headers"`
+		Content     Content     `json:"content"`
+		RedirectURL string      `json:"redirectURL"`
+		HeadersSize int64       `json:"headersSize"`
+		BodySize    int64       `json:"bodySize"`
+		Trailers    []NameValue `json:"_trailers,omitempty"`
+	}
+
+	NameValue struct {
+		Name  string `json:"name"`
+		Value string `json:"value"`
+	}
+
+	Cookie struct {
+		Name     string     `json:"name"`
+		Value    string     `json:"value"`
+		Path     string     `json:"path,omitempty"`
+		Domain   string     `json:"domain,omitempty"`
+		Expires  *time.Time `json:"expires,omitempty"`
+		HTTPOnly bool       `json:"httpOnly,omitempty"`
+		Secure   bool       `json:"secure,omitempty"`
+	}
+
+	PostData struct {
+		MimeType string `json:"mimeType"`
+		Text     string `json:"text"`
+		Encoding string `json:"_encoding,omitempty"` // base64 for binary bodies
+	}
+
+	Content struct {
+		Size     int64  `json:"size"`
+		MimeType string `json:"mimeType"`
+		Text     string `json:"text,omitempty"`
+		Encoding string `json:"encoding,omitempty"` // base64 for binary bodies
+	}
+
+	// Timings are in milliseconds, -1 if not applicable.
+	Timings struct {
+		Blocked float64 `json:"blocked"`
+		DNS     float64 `json:"dns"`
+		Connect float64 `json:"connect"`
+		Send    float64 `json:"send"`
+		Wait    float64 `json:"wait"`
+		Receive float64 `json:"receive"`
+		SSL     float64 `json:"ssl"`
+	}
+
+	TLS struct {
+		Version            string   `json:"version"`
+		CipherSuite        string   `json:"cipherSuite"`
+		ServerName         string   `json:"serverName,omitempty"`
+		NegotiatedProtocol string   `json:"negotiatedProtocol,omitempty"`
+		PeerCertificates   []string `json:"peerCertificates,omitempty"` // subjects, the leaf first
+	}
+)
+
+// Load reads a HAR file.
+func Load(name string) (*HAR, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	h := new(HAR)
+	if err = json.Unmarshal(data, h); err != nil {
+		return nil, fmt.Errorf("failed to parse har %s, err: %v", name, err)
+	}
+	return h, nil
+}
+
+// WriteFile writes h to the file name, indented.
+func (h *HAR) WriteFile(name string) error {
+	data, err := json.MarshalIndent(h, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(name, data, 0o644)
+}
+
+func nameValues(header http.Header) []NameValue {
+	nvs := make([]NameValue, 0, len(header))
+	for name, values := range header {
+		for _, value := range values {
+			nvs = append(nvs, NameValue{Name: name, Value: value})
+		}
+	}
+	return nvs
+}
+
+func queryString(u *url.URL) []NameValue {
+	nvs := make([]NameValue, 0)
+	for name, values := range u.Query() {
+		for _, value := range values {
+			nvs = append(nvs, NameValue{Name: name, Value: value})
+		}
+	}
+	return nvs
+}
+
+func cookies(cs []*http.Cookie) []Cookie {
+	hcs := make([]Cookie, 0, len(cs))
+	for _, c := range cs {
+		hc := Cookie{
+			Name:     c.Name,
+			Value:    c.Value,
+			Path:     c.Path,
+			Domain:   c.Domain,
+			HTTPOnly: c.HttpOnly,
+			Secure:   c.Secure,
+		}
+		if !c.Expires.IsZero() {
+			expires := c.Expires
+			hc.Expires = &expires
+		}
+		hcs = append(hcs, hc)
+	}
+	return hcs
+}
+
+func tlsInfo(state *tls.ConnectionState) *TLS {
+	if state == nil {
+		return nil
+	}
+	info := &TLS{
+		Version:            tls.VersionName(state.Version),
+		CipherSuite:        tls.CipherSuiteName(state.CipherSuite),
+		ServerName:         state.ServerName,
+		NegotiatedProtocol: state.NegotiatedProtocol,
+	}
+	for _, cert := range state.PeerCertificates {
+		info.PeerCertificates = append(info.PeerCertificates, cert.Subject.String())
+	}
+	return info
+}
+
+func millis(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
@@ -0,0 +1,196 @@
+package har
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pysugar/wheels/grpc/proto"
+	"github.com/pysugar/wheels/http/client"
+	"github.com/pysugar/wheels/internal/clienttest"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+func newServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(h2c.NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/redirect":
+			http.Redirect(w, r, "/hello?name=redirected", http.StatusFound)
+		case "/echo":
+			body, _ := io.ReadAll(r.Body)
+			w.Header().Set("Content-Type", "application/octet-stream")
+			w.Write(body)
+		default:
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "s1"})
+			w.Header().Set("Content-Type", "text/plain")
+			io.WriteString(w, "hello "+r.URL.Query().Get("name"))
+		}
+	}), &http2.Server{}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	server := newServer(t)
+	f := client.NewFetcher()
+	defer f.Close()
+	recorder := NewRecorder(f)
+
+	binary := []byte{0xff, 0x00, 0xfe, 'x'}
+	requests := []struct {
+		method string
+		url    string
+		body   []byte
+		want   []byte
+	}{
+		{method: http.MethodGet, url: server.URL + "/hello?name=har", want: []byte("hello har")},
+		{method: http.MethodPost, url: server.URL + "/echo", body: binary, want: binary},
+	}
+	ctx, cancel := context.WithTimeout(client.WithProtocol(context.Background(), client.HTTP2), 10*time.Second)
+	defer cancel()
+	for _, r := range requests {
+		req, _ := http.NewRequestWithContext(ctx, r.method, r.url, bytes.NewReader(r.body))
+		res, err := recorder.Do(ctx, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if body, _ := io.ReadAll(res.Body); !bytes.Equal(body, r.want) {
+			t.Errorf("%s: expected %q, but actually %q", r.url, r.want, body)
+		}
+		res.Body.Close()
+	}
+
+	name := filepath.Join(t.TempDir(), "fetch.har")
+	if err := recorder.WriteFile(name); err != nil {
+		t.Fatal(err)
+	}
+	h, err := Load(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(h.Log.Entries) != 2 || h.Log.Version != "1.2" {
+		t.Fatalf("expected 2 entries of har 1.2, but actually %+v", h.Log)
+	}
+	for _, e := range h.Log.Entries {
+		if e.Request.HTTPVersion != "HTTP/2.0" || e.StreamID == 0 || e.Connection == "" || e.ServerIPAddress != "127.0.0.1" {
+			t.Errorf("expected an h2c exchange, but actually %s on stream %d of connection %s to %s",
+				e.Request.HTTPVersion, e.StreamID, e.Connection, e.ServerIPAddress)
+		}
+	}
+	if e := h.Log.Entries[1]; e.Request.PostData == nil || e.Request.PostData.Encoding != "base64" ||
+		e.Response.Content.Encoding != "base64" {
+		t.Errorf("expected base64 bodies, but actually %+v %+v", e.Request.PostData, e.Response.Content)
+	}
+
+	server.Close()
+	replay := &http.Client{Transport: NewReplayer(h)}
+	for _, r := range requests {
+		req, _ := http.NewRequest(r.method, r.url, bytes.NewReader(r.body))
+		res, err := replay.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if body, _ := io.ReadAll(res.Body); !bytes.Equal(body, r.want) || res.ProtoMajor != 2 {
+			t.Errorf("%s: expected %q over HTTP/2, but actually %q over %s", r.url, r.want, body, res.Proto)
+		}
+		res.Body.Close()
+	}
+	res, err := replay.Get(requests[0].url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cookies := res.Cookies(); len(cookies) != 1 || cookies[0].Value != "s1" {
+		t.Errorf("expected the recorded cookie, but actually %v", cookies)
+	}
+	if _, err = replay.Get(server.URL + "/missing"); !errors.Is(err, ErrNotRecorded) {
+		t.Errorf("expected ErrNotRecorded, but actually %v", err)
+	}
+}
+
+func TestRecordError(t *testing.T) {
+	server := newServer(t)
+	server.Close()
+	f := client.NewFetcher()
+	defer f.Close()
+	recorder := NewRecorder(f)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	if _, err := recorder.Do(ctx, req); err == nil {
+		t.Fatal("expected an error")
+	}
+	entries := recorder.HAR().Log.Entries
+	if len(entries) != 1 || entries[0].Response.Status != 0 || !strings.Contains(entries[0].Comment, "refused") {
+		t.Errorf("expected the failed request, but actually %+v", entries)
+	}
+}
+
+func TestRecordRedirects(t *testing.T) {
+	server := newServer(t)
+	f := client.NewFetcher()
+	defer f.Close()
+	recorder := NewRecorder(f)
+
+	ctx, cancel := context.WithTimeout(client.WithProtocol(context.Background(), client.HTTP1), 10*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/redirect", nil)
+	res, err := recorder.Do(ctx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.Copy(io.Discard, res.Body)
+	res.Body.Close()
+
+	entries := recorder.HAR().Log.Entries
+	if len(entries) != 2 {
+		t.Fatalf("expected an entry per hop, but actually %+v", entries)
+	}
+	if e := entries[0]; e.Request.URL != server.URL+"/redirect" || e.Response.Status != http.StatusFound ||
+		e.Response.RedirectURL != "/hello?name=redirected" {
+		t.Errorf("expected the redirect, but actually %s %d %s", e.Request.URL, e.Response.Status, e.Response.RedirectURL)
+	}
+	if e := entries[1]; e.Request.URL != server.URL+"/hello?name=redirected" || e.Response.Status != http.StatusOK ||
+		e.Response.Content.Text != "hello redirected" {
+		t.Errorf("expected the redirected request, but actually %s %d %q", e.Request.URL, e.Response.Status, e.Response.Content.Text)
+	}
+}
+
+func TestRecordGRPC(t *testing.T) {
+	serverURL := clienttest.NewEchoServer(t)
+	f := client.NewFetcher()
+	defer f.Close()
+	recorder := NewRecorder(f)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	methodURL := serverURL.JoinPath("/proto.EchoService/Echo")
+	res := new(proto.EchoResponse)
+	if err := recorder.CallGRPC(ctx, methodURL, &proto.EchoRequest{Message: "hello"}, res); err != nil || res.Message != "hello" {
+		t.Fatalf("expected hello, but actually %q %v", res.Message, err)
+	}
+
+	entries := recorder.HAR().Log.Entries
+	if len(entries) != 1 {
+		t.Fatalf("expected 1 entry, but actually %+v", entries)
+	}
+	e := entries[0]
+	if e.Request.URL != methodURL.String() || e.Request.HTTPVersion != "HTTP/2.0" || e.StreamID == 0 {
+		t.Errorf("expected the call over HTTP/2, but actually %s %s on stream %d", e.Request.URL, e.Request.HTTPVersion, e.StreamID)
+	}
+	if e.Request.PostData == nil || e.Request.BodySize != 12 || e.Response.Content.Size != 12 {
+		t.Errorf("expected the request and response messages, but actually %+v %+v", e.Request.PostData, e.Response.Content)
+	}
+	if len(e.Response.Trailers) != 1 || e.Response.Trailers[0].Value != "0" {
+		t.Errorf("expected grpc-status 0 in the trailers, but actually %+v", e.Response.Trailers)
+	}
+}
@@ -0,0 +1,271 @@
+package har
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/pysugar/wheels/http/client"
+	"google.golang.org/protobuf/proto"
+)
+
+// Recorder is a client.Fetcher which records the requests it sends, every redirect followed by Do and the calls
+// of CallGRPC and NewGRPCStream, an entry each. An exchange is recorded once its response body is read to EOF or
+// closed, or once the request fails. The WebSocket connections of DialWebSocket are not recorded.
+type Recorder struct {
+	client.Fetcher
+
+	mu      sync.Mutex
+	entries []Entry
+}
+
+// recordBody records the entry of a response once the body is read to EOF or closed.
+type recordBody struct {
+	io.ReadCloser
+	r        *Recorder
+	entry    Entry
+	sent     func() []byte // the request body as sent by then, nil without body
+	mimeType string        // of the request body
+	start    time.Time
+	headers  time.Time   // the response headers arrived
+	trailer  http.Header // filled in once the body is read
+	buf      bytes.Buffer
+	once     sync.Once
+}
+
+// sentBody records a request body as the Fetcher reads it, which may be concurrently with the response.
+type sentBody struct {
+	io.ReadCloser
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func NewRecorder(f client.Fetcher) *Recorder {
+	return &Recorder{Fetcher: f}
+}
+
+// Do sends req by the Fetcher, reading the request body into memory to send it again on redirects.
+func (r *Recorder) Do(ctx context.Context, req *http.Request) (*http.Response, error) {
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		body, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		req.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(body)), nil
+		}
+	}
+	return r.Fetcher.Do(r.withHook(ctx), req)
+}
+
+func (r *Recorder) CallGRPC(ctx context.Context, serviceURL *url.URL, req, res proto.Message) error {
+	return r.Fetcher.CallGRPC(r.withHook(ctx), serviceURL, req, res)
+}
+
+// NewGRPCStream starts a streaming call by the Fetcher, whose messages sent are recorded up to the end of the
+// response.
+func (r *Recorder) NewGRPCStream(ctx context.Context, serviceURL *url.URL) (client.GRPCStream, error) {
+	return r.Fetcher.NewGRPCStream(r.withHook(ctx), serviceURL)
+}
+
+func (r *Recorder) withHook(ctx context.Context) context.Context {
+	return client.WithRoundTripHook(ctx, r.roundTrip)
+}
+
+// roundTrip is the client.RoundTripHook which starts the entry of req, and records it once the response is read
+// or the request failed.
+func (r *Recorder) roundTrip(req *http.Request) func(*http.Response, *client.ConnInfo, error) *http.Response {
+	entry := Entry{
+		StartedDateTime: time.Now(),
+		Request: Request{
+			Method:      req.Method,
+			URL:         req.URL.String(),
+			HTTPVersion: "HTTP/1.1",
+			Cookies:     cookies(req.Cookies()),
+			Headers:     nameValues(req.Header),
+			QueryString: queryString(req.URL),
+			HeadersSize: -1,
+			BodySize:    0,
+		},
+		Timings: Timings{Blocked: -1, DNS: -1, Connect: -1, SSL: -1},
+	}
+	sent, mimeType := requestBody(req), req.Header.Get("Content-Type")
+	start := time.Now()
+
+	return func(res *http.Response, info *client.ConnInfo, err error) *http.Response {
+		headers := time.Now()
+		if info.Proto != "" {
+			entry.Request.HTTPVersion = info.Proto
+		}
+		if addr, ok := info.RemoteAddr.(*net.TCPAddr); ok {
+			entry.ServerIPAddress = addr.IP.String()
+		}
+		if addr, ok := info.LocalAddr.(*net.TCPAddr); ok {
+			entry.Connection = strconv.Itoa(addr.Port)
+		}
+		entry.StreamID = info.StreamID
+		entry.TLS = tlsInfo(info.TLS)
+		if err != nil {
+			entry.setPostData(mimeType, sent)
+			entry.Time = millis(headers.Sub(start))
+			entry.Timings.Wait = entry.Time
+			entry.Comment = err.Error()
+			entry.Response = Response{Cookies: []Cookie{}, Headers: []NameValue{}, HeadersSize: -1, BodySize: -1}
+			r.add(entry)
+			return res
+		}
+
+		if entry.TLS == nil {
+			entry.TLS = tlsInfo(res.TLS)
+		}
+		entry.Response = Response{
+			Status:      res.StatusCode,
+			StatusText:  http.StatusText(res.StatusCode),
+			HTTPVersion: res.Proto,
+			Cookies:     cookies(res.Cookies()),
+			Headers:     nameValues(res.Header),
+			RedirectURL: res.Header.Get("Location"),
+			HeadersSize: -1,
+		}
+		res.Body = &recordBody{ReadCloser: res.Body, r: r, entry: entry, sent: sent, mimeType: mimeType,
+			start: start, headers: headers, trailer: res.Trailer}
+		return res
+	}
+}
+
+// requestBody returns the function which returns the body of req as sent so far, or nil without body. A body
+// which can be gotten again is read in full, any other is recorded as it is sent.
+func requestBody(req *http.Request) func() []byte {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil
+	}
+	if req.GetBody != nil {
+		if body, err := req.GetBody(); err == nil {
+			data, _ := io.ReadAll(body)
+			body.Close()
+			return func() []byte { return data }
+		}
+	}
+	sb := &sentBody{ReadCloser: req.Body}
+	req.Body = sb
+	return sb.bytes
+}
+
+// setPostData records the request body of sent, if any.
+func (e *Entry) setPostData(mimeType string, sent func() []byte) {
+	if sent == nil {
+		return
+	}
+	body := sent()
+	text, encoding := bodyText(body)
+	e.Request.PostData = &PostData{MimeType: mimeType, Text: text, Encoding: encoding}
+	e.Request.BodySize = int64(len(body))
+}
+
+// HAR returns the exchanges recorded so far, in the order of their start.
+func (r *Recorder) HAR() *HAR {
+	r.mu.Lock()
+	entries := append([]Entry(nil), r.entries...)
+	r.mu.Unlock()
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].StartedDateTime.Before(entries[j].StartedDateTime)
+	})
+	return &HAR{Log: Log{
+		Version: "1.2",
+		Creator: Creator{Name: "wheels", Version: "1.0"},
+		Entries: entries,
+	}}
+}
+
+// WriteFile writes the exchanges recorded so far to the HAR file name.
+func (r *Recorder) WriteFile(name string) error {
+	return r.HAR().WriteFile(name)
+}
+
+func (r *Recorder) add(entry Entry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries = append(r.entries, entry)
+}
+
+func (b *recordBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.buf.Write(p[:n])
+	if err == io.EOF {
+		b.finish()
+	}
+	return n, err
+}
+
+func (b *recordBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.finish()
+	return err
+}
+
+func (b *sentBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.mu.Lock()
+	b.buf.Write(p[:n])
+	b.mu.Unlock()
+	return n, err
+}
+
+func (b *sentBody) bytes() []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]byte(nil), b.buf.Bytes()...)
+}
+
+func (b *recordBody) finish() {
+	b.once.Do(func() {
+		end := time.Now()
+		b.entry.setPostData(b.mimeType, b.sent)
+		body := b.buf.Bytes()
+		text, encoding := bodyText(body)
+		b.entry.Response.Content = Content{
+			Size:     int64(len(body)),
+			MimeType: b.entry.Response.headerValue("Content-Type"),
+			Text:     text,
+			Encoding: encoding,
+		}
+		b.entry.Response.BodySize = int64(len(body))
+		if trailer := nameValues(b.trailer); len(trailer) > 0 {
+			b.entry.Response.Trailers = trailer
+		}
+		b.entry.Timings.Send = 0
+		b.entry.Timings.Wait = millis(b.headers.Sub(b.start))
+		b.entry.Timings.Receive = millis(end.Sub(b.headers))
+		b.entry.Time = millis(end.Sub(b.start))
+		b.r.add(b.entry)
+	})
+}
+
+func (res *Response) headerValue(name string) string {
+	for _, nv := range res.Headers {
+		if http.CanonicalHeaderKey(nv.Name) == http.CanonicalHeaderKey(name) {
+			return nv.Value
+		}
+	}
+	return ""
+}
+
+// bodyText returns body as HAR text, base64 encoded unless it is valid UTF-8.
+func bodyText(body []byte) (string, string) {
+	if utf8.Valid(body) {
+		return string(body), ""
+	}
+	return base64.StdEncoding.EncodeToString(body), "base64"
+}
@@ -0,0 +1,104 @@
+package har
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+var ErrNotRecorded = errors.New("har: no recorded response")
+
+// Replayer is an http.RoundTripper which serves the responses of a HAR instead of sending requests.
+// A request matches the entries of the same method and URL, which are served in the order of the HAR,
+// the last one again once all have been served. Entries without response, as recorded for failed
+// requests, are skipped.
+type Replayer struct {
+	entries []Entry
+
+	mu     sync.Mutex
+	served map[int]bool
+}
+
+func NewReplayer(h *HAR) *Replayer {
+	return &Replayer{
+		entries: h.Log.Entries,
+		served:  make(map[int]bool),
+	}
+}
+
+func (r *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+	entry, ok := r.match(req.Method, req.URL.String())
+	if !ok {
+		return nil, fmt.Errorf("%w for %s %s", ErrNotRecorded, req.Method, req.URL)
+	}
+	return entry.Response.response(req)
+}
+
+func (r *Replayer) match(method, url string) (*Entry, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	last := -1
+	for i := range r.entries {
+		e := &r.entries[i]
+		if e.Response.Status == 0 || !strings.EqualFold(e.Request.Method, method) || e.Request.URL != url {
+			continue
+		}
+		if !r.served[i] {
+			r.served[i] = true
+			return e, true
+		}
+		last = i
+	}
+	if last < 0 {
+		return nil, false
+	}
+	return &r.entries[last], true
+}
+
+func (res *Response) response(req *http.Request) (*http.Response, error) {
+	body := []byte(res.Content.Text)
+	if res.Content.Encoding == "base64" {
+		var err error
+		if body, err = base64.StdEncoding.DecodeString(res.Content.Text); err != nil {
+			return nil, fmt.Errorf("invalid base64 content of %s, err: %v", req.URL, err)
+		}
+	}
+
+	major, minor, ok := http.ParseHTTPVersion(res.HTTPVersion)
+	if !ok {
+		major, minor = 1, 1
+	}
+	statusText := res.StatusText
+	if statusText == "" {
+		statusText = http.StatusText(res.Status)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", res.Status, statusText),
+		StatusCode:    res.Status,
+		Proto:         res.HTTPVersion,
+		ProtoMajor:    major,
+		ProtoMinor:    minor,
+		Header:        header(res.Headers),
+		Trailer:       header(res.Trailers),
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+func header(nvs []NameValue) http.Header {
+	h := make(http.Header, len(nvs))
+	for _, nv := range nvs {
+		h.Add(nv.Name, nv.Value)
+	}
+	return h
+}
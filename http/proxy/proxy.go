@@ -0,0 +1,188 @@
+// Package proxy dials TCP connections through HTTP CONNECT and SOCKS5 proxies.
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"golang.org/x/net/http/httpproxy"
+	xproxy "golang.org/x/net/proxy"
+)
+
+type (
+	// Func returns the proxy of target, or nil to connect directly.
+	Func func(target *url.URL) (*url.URL, error)
+
+	// bufferedConn is a conn with bytes read past the CONNECT response.
+	bufferedConn struct {
+		net.Conn
+		br *bufio.Reader
+	}
+)
+
+// FromEnvironment returns the proxies set by HTTPS_PROXY, HTTP_PROXY and NO_PROXY, or their lowercase versions.
+// ALL_PROXY applies to the schemes without a proxy of their own. Proxy URLs without a scheme are HTTP proxies.
+// Requests to localhost and loopback addresses are never proxied.
+func FromEnvironment() Func {
+	cfg := httpproxy.FromEnvironment()
+	if all := getEnvAny("ALL_PROXY", "all_proxy"); all != "" {
+		if cfg.HTTPSProxy == "" {
+			cfg.HTTPSProxy = all
+		}
+		if cfg.HTTPProxy == "" {
+			cfg.HTTPProxy = all
+		}
+	}
+	proxyFunc := cfg.ProxyFunc()
+	return func(target *url.URL) (*url.URL, error) {
+		return proxyFunc(target)
+	}
+}
+
+// Fixed returns proxyURL for every target, a nil proxyURL connects directly.
+func Fixed(proxyURL *url.URL) Func {
+	return func(*url.URL) (*url.URL, error) {
+		return proxyURL, nil
+	}
+}
+
+// Dial connects to addr, the host:port of a target with scheme http or https, through the proxy returned by
+// proxyFunc. It connects directly if proxyFunc is nil or returns nil.
+func Dial(ctx context.Context, proxyFunc Func, scheme, addr string) (net.Conn, error) {
+	if proxyFunc != nil {
+		proxyURL, err := proxyFunc(&url.URL{Scheme: scheme, Host: addr})
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve proxy of %s://%s, err: %v", scheme, addr, err)
+		}
+		if proxyURL != nil {
+			return DialVia(ctx, proxyURL, addr)
+		}
+	}
+	var dialer net.Dialer
+	return dialer.DialContext(ctx, "tcp", addr)
+}
+
+// DialVia connects to addr through proxyURL. The schemes http and https tunnel with CONNECT, socks5 and socks5h
+// connect through a SOCKS5 proxy, which resolves host names itself. Credentials in the userinfo of proxyURL
+// authenticate with Basic auth or SOCKS5 username/password auth.
+func DialVia(ctx context.Context, proxyURL *url.URL, addr string) (net.Conn, error) {
+	switch proxyURL.Scheme {
+	case "http", "https":
+		return dialConnect(ctx, proxyURL, addr)
+	case "socks5", "socks5h":
+		return dialSOCKS5(ctx, proxyURL, addr)
+	}
+	return nil, fmt.Errorf("unsupported proxy scheme %q", proxyURL.Scheme)
+}
+
+func dialConnect(ctx context.Context, proxyURL *url.URL, addr string) (net.Conn, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", proxyAddr(proxyURL))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to proxy %s, err: %v", proxyURL.Host, err)
+	}
+	if proxyURL.Scheme == "https" {
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: proxyURL.Hostname()})
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("failed to handshake with proxy %s, err: %v", proxyURL.Host, err)
+		}
+		conn = tlsConn
+	}
+
+	// unblock the CONNECT exchange once ctx is done
+	stop := context.AfterFunc(ctx, func() {
+		conn.SetDeadline(time.Unix(1, 0))
+	})
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: make(http.Header),
+	}
+	if user := proxyURL.User; user != nil {
+		password, _ := user.Password()
+		credentials := base64.StdEncoding.EncodeToString([]byte(user.Username() + ":" + password))
+		req.Header.Set("Proxy-Authorization", "Basic "+credentials)
+	}
+
+	br := bufio.NewReader(conn)
+	res, err := func() (*http.Response, error) {
+		if err := req.Write(conn); err != nil {
+			return nil, err
+		}
+		return http.ReadResponse(br, req)
+	}()
+	if !stop() {
+		conn.Close()
+		return nil, ctx.Err()
+	}
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to CONNECT %s via proxy %s, err: %v", addr, proxyURL.Host, err)
+	}
+	if res.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, fmt.Errorf("proxy %s refused CONNECT %s: %s", proxyURL.Host, addr, res.Status)
+	}
+	if br.Buffered() > 0 {
+		return &bufferedConn{Conn: conn, br: br}, nil
+	}
+	return conn, nil
+}
+
+func dialSOCKS5(ctx context.Context, proxyURL *url.URL, addr string) (net.Conn, error) {
+	var auth *xproxy.Auth
+	if user := proxyURL.User; user != nil {
+		password, _ := user.Password()
+		auth = &xproxy.Auth{User: user.Username(), Password: password}
+	}
+	dialer, err := xproxy.SOCKS5("tcp", proxyAddr(proxyURL), auth, xproxy.Direct)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := dialer.(xproxy.ContextDialer).DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s via proxy %s, err: %v", addr, proxyURL.Host, err)
+	}
+	return conn, nil
+}
+
+// proxyAddr returns the host:port of proxyURL, with the default port of its scheme if omitted.
+func proxyAddr(proxyURL *url.URL) string {
+	if proxyURL.Port() != "" {
+		return proxyURL.Host
+	}
+	port := "1080"
+	switch proxyURL.Scheme {
+	case "http":
+		port = "80"
+	case "https":
+		port = "443"
+	}
+	return net.JoinHostPort(proxyURL.Hostname(), port)
+}
+
+func getEnvAny(names ...string) string {
+	for _, name := range names {
+		if val := os.Getenv(name); val != "" {
+			return val
+		}
+	}
+	return ""
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	if c.br.Buffered() > 0 {
+		return c.br.Read(p)
+	}
+	return c.Conn.Read(p)
+}
@@ -0,0 +1,211 @@
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// newEchoServer echoes the first line of each connection.
+func newEchoServer(t *testing.T) string {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { lis.Close() })
+	go func() {
+		for {
+			conn, err := lis.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				line, _ := bufio.NewReader(conn).ReadString('\n')
+				io.WriteString(conn, line)
+			}()
+		}
+	}()
+	return lis.Addr().String()
+}
+
+// newConnectProxy tunnels CONNECT requests carrying the Basic credentials user:pass.
+func newConnectProxy(t *testing.T) *url.URL {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := parseProxyAuth(r); !ok || user != "user" || pass != "pass" {
+			w.WriteHeader(http.StatusProxyAuthRequired)
+			return
+		}
+		target, err := net.Dial("tcp", r.Host)
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		conn, brw, _ := w.(http.Hijacker).Hijack()
+		brw.Flush()
+		go func() {
+			io.Copy(target, conn)
+			target.Close()
+		}()
+		io.Copy(conn, target)
+		conn.Close()
+	}))
+	t.Cleanup(server.Close)
+	u, _ := url.Parse(server.URL)
+	return u
+}
+
+func parseProxyAuth(r *http.Request) (string, string, bool) {
+	r.Header.Set("Authorization", r.Header.Get("Proxy-Authorization"))
+	return r.BasicAuth()
+}
+
+// newSOCKS5Proxy serves the CONNECT command of SOCKS5 (RFC 1928) with username/password auth (RFC 1929).
+func newSOCKS5Proxy(t *testing.T) *url.URL {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { lis.Close() })
+	go func() {
+		for {
+			conn, err := lis.Accept()
+			if err != nil {
+				return
+			}
+			go serveSOCKS5(conn)
+		}
+	}()
+	return &url.URL{Scheme: "socks5", Host: lis.Addr().String(), User: url.UserPassword("user", "pass")}
+}
+
+func serveSOCKS5(conn net.Conn) {
+	defer conn.Close()
+	br := bufio.NewReader(conn)
+	readN := func(n int) []byte {
+		b := make([]byte, n)
+		io.ReadFull(br, b)
+		return b
+	}
+
+	methods := readN(2)
+	readN(int(methods[1]))
+	conn.Write([]byte{5, 2})
+	auth := readN(2)
+	user := string(readN(int(auth[1])))
+	pass := string(readN(int(readN(1)[0])))
+	if user != "user" || pass != "pass" {
+		conn.Write([]byte{1, 1})
+		return
+	}
+	conn.Write([]byte{1, 0})
+
+	req := readN(4)
+	var host string
+	switch req[3] {
+	case 1:
+		host = net.IP(readN(4)).String()
+	case 3:
+		host = string(readN(int(readN(1)[0])))
+	}
+	port := binary.BigEndian.Uint16(readN(2))
+	target, err := net.Dial("tcp", net.JoinHostPort(host, strconv.Itoa(int(port))))
+	if err != nil {
+		conn.Write([]byte{5, 5, 0, 1, 0, 0, 0, 0, 0, 0})
+		return
+	}
+	defer target.Close()
+	conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+	go io.Copy(target, br)
+	io.Copy(conn, target)
+}
+
+func TestDialVia(t *testing.T) {
+	echoAddr := newEchoServer(t)
+	connectProxy := newConnectProxy(t)
+	socksProxy := newSOCKS5Proxy(t)
+
+	withUser := func(u *url.URL, user *url.Userinfo) *url.URL {
+		c := *u
+		c.User = user
+		return &c
+	}
+	tests := []struct {
+		name    string
+		proxy   *url.URL
+		wantErr bool
+	}{
+		{name: "connect", proxy: withUser(connectProxy, url.UserPassword("user", "pass"))},
+		{name: "connect unauthorized", proxy: connectProxy, wantErr: true},
+		{name: "socks5", proxy: socksProxy},
+		{name: "socks5 unauthorized", proxy: withUser(socksProxy, url.UserPassword("user", "wrong")), wantErr: true},
+		{name: "unsupported", proxy: &url.URL{Scheme: "ftp", Host: "127.0.0.1:21"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			conn, err := DialVia(ctx, tt.proxy, echoAddr)
+			if tt.wantErr {
+				if err == nil {
+					conn.Close()
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+			io.WriteString(conn, "hello\n")
+			if line, err := bufio.NewReader(conn).ReadString('\n'); err != nil || line != "hello\n" {
+				t.Errorf("expected hello, but actually %q %v", line, err)
+			}
+		})
+	}
+}
+
+func TestFromEnvironment(t *testing.T) {
+	t.Setenv("HTTPS_PROXY", "")
+	t.Setenv("https_proxy", "")
+	t.Setenv("HTTP_PROXY", "http://web-proxy:3128")
+	t.Setenv("ALL_PROXY", "socks5://socks-proxy:1080")
+	t.Setenv("NO_PROXY", ".internal")
+
+	proxyFunc := FromEnvironment()
+	tests := []struct {
+		target string
+		want   string
+	}{
+		{target: "http://example.com", want: "http://web-proxy:3128"},
+		{target: "https://example.com", want: "socks5://socks-proxy:1080"},
+		{target: "https://svc.internal", want: ""},
+		{target: "https://localhost:8443", want: ""},
+	}
+	for _, tt := range tests {
+		target, _ := url.Parse(tt.target)
+		proxyURL, err := proxyFunc(target)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := ""
+		if proxyURL != nil {
+			got = proxyURL.String()
+		}
+		if got != tt.want {
+			t.Errorf("%s: expected proxy %q, but actually %q", tt.target, tt.want, got)
+		}
+	}
+}
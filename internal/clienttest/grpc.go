@@ -0,0 +1,116 @@
+package clienttest
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pysugar/wheels/grpc/proto"
+	pb "google.golang.org/protobuf/proto"
+)
+
+type (
+	// GRPCClient is the API shared by the gRPC clients.
+	GRPCClient interface {
+		Call(ctx context.Context, serviceMethod string, req, res pb.Message) error
+		Close()
+	}
+
+	// NewGRPCClient dials serverURL, through the CONNECT proxy at proxyURL unless it is nil, and compresses
+	// requests with the compressor named compression unless it is empty.
+	NewGRPCClient func(serverURL, proxyURL *url.URL, compression string) (GRPCClient, error)
+)
+
+// RunGRPCClientTests runs the tests every gRPC client passes against the clients of newClient.
+func RunGRPCClientTests(t *testing.T, newClient NewGRPCClient) {
+	t.Run("large message", func(t *testing.T) { testLargeMessage(t, newClient) })
+	t.Run("proxy", func(t *testing.T) { testProxy(t, newClient) })
+	t.Run("compression", func(t *testing.T) { testCompression(t, newClient) })
+}
+
+func testLargeMessage(t *testing.T, newClient NewGRPCClient) {
+	client, err := newClient(NewEchoServer(t), nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	message := strings.Repeat("x", 4<<20) // far beyond the initial windows on both sides
+	res := new(proto.EchoResponse)
+	if er := client.Call(ctx, "/proto.EchoService/Echo", &proto.EchoRequest{Message: message}, res); er != nil {
+		t.Fatal(er)
+	}
+	if res.Message != message {
+		t.Errorf("expected echo of %d bytes, but actually %d", len(message), len(res.Message))
+	}
+}
+
+func testProxy(t *testing.T, newClient NewGRPCClient) {
+	var tunnels int32
+	client, err := newClient(NewEchoServer(t), NewTunnelProxy(t, &tunnels, nil), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	res := new(proto.EchoResponse)
+	if er := client.Call(ctx, "/proto.EchoService/Echo", &proto.EchoRequest{Message: "hello"}, res); er != nil {
+		t.Fatal(er)
+	}
+	if res.Message != "hello" || atomic.LoadInt32(&tunnels) != 1 {
+		t.Errorf("expected hello through 1 tunnel, but actually %q through %d", res.Message, tunnels)
+	}
+}
+
+func testCompression(t *testing.T, newClient NewGRPCClient) {
+	if _, err := newClient(NewEchoServer(t), nil, "zstd"); err == nil {
+		t.Error("expected an error for the unregistered compressor")
+	}
+
+	tests := []struct {
+		name       string
+		acceptGzip bool
+		want       []string
+	}{
+		{name: "accepted", acceptGzip: true, want: []string{"gzip", "gzip"}},
+		{name: "rejected", want: []string{"gzip", "identity", "identity"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encodings := make(chan string, 4)
+			client, err := newClient(NewCompressionServer(t, tt.acceptGzip, encodings), nil, "gzip")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer client.Close()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			message := strings.Repeat("hello", 100)
+			for i := 0; i < 2; i++ {
+				res := new(proto.EchoResponse)
+				if er := client.Call(ctx, "/proto.EchoService/Echo", &proto.EchoRequest{Message: message}, res); er != nil {
+					t.Fatal(er)
+				}
+				if res.Message != message {
+					t.Errorf("expected echo of %d bytes, but actually %d", len(message), len(res.Message))
+				}
+			}
+			close(encodings)
+			var got []string
+			for encoding := range encodings {
+				got = append(got, encoding)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("expected grpc-encoding %v, but actually %v", tt.want, got)
+			}
+		})
+	}
+}
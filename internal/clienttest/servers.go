@@ -0,0 +1,133 @@
+// Package clienttest holds the servers the clients of this module are tested against, and the tests shared by
+// the gRPC clients of grpc/http2client and grpc/tcpclient.
+package clienttest
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+
+	http2tool "github.com/pysugar/wheels/binproto/http2"
+	"github.com/pysugar/wheels/grpc/proto"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	pb "google.golang.org/protobuf/proto"
+)
+
+// NewEchoServer serves EchoService/Echo over h2c with prior knowledge.
+func NewEchoServer(t *testing.T) *url.URL {
+	t.Helper()
+	server := httptest.NewServer(h2c.NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		req := new(proto.EchoRequest)
+		if er := http2tool.DecodeGrpcFrame(body, req); er != nil {
+			t.Error(er)
+			return
+		}
+		frame, err := http2tool.EncodeGrpcFrame(&proto.EchoResponse{Message: req.Message})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/grpc")
+		w.WriteHeader(http.StatusOK)
+		w.Write(frame)
+		w.Header().Set(http.TrailerPrefix+"Grpc-Status", "0")
+	}), &http2.Server{}))
+	t.Cleanup(server.Close)
+
+	serverURL, _ := url.Parse(server.URL)
+	return serverURL
+}
+
+// NewCompressionServer echoes in the grpc-encoding of requests, which it records, and rejects the
+// encodings other than identity unless gzip is accepted.
+func NewCompressionServer(t *testing.T, acceptGzip bool, encodings chan<- string) *url.URL {
+	t.Helper()
+	server := httptest.NewServer(h2c.NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		encoding := r.Header.Get("grpc-encoding")
+		encodings <- encoding
+		w.Header().Set("Content-Type", "application/grpc")
+		if encoding != http2tool.Identity && !acceptGzip {
+			w.Header().Set("grpc-accept-encoding", http2tool.Identity)
+			w.Header().Set("grpc-status", "12")
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		req := new(proto.EchoRequest)
+		if er := http2tool.DecodeGrpcFrameWithDecompress(body, encoding, req); er != nil {
+			t.Error(er)
+			return
+		}
+		resBytes, _ := pb.Marshal(&proto.EchoResponse{Message: req.Message})
+		frame, err := http2tool.EncodeGrpcPayloadWithCompressor(resBytes, http2tool.GetCompressor(encoding))
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		w.Header().Set("grpc-encoding", encoding)
+		w.WriteHeader(http.StatusOK)
+		w.Write(frame)
+		w.Header().Set(http.TrailerPrefix+"Grpc-Status", "0")
+	}), &http2.Server{}))
+	t.Cleanup(server.Close)
+
+	serverURL, _ := url.Parse(server.URL)
+	return serverURL
+}
+
+// NewTunnelProxy tunnels CONNECT requests and counts the tunnels. With user, requests must be authorized by
+// its credentials, which the returned URL carries.
+func NewTunnelProxy(t *testing.T, tunnels *int32, user *url.Userinfo) *url.URL {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodConnect {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		if user != nil && !authorized(r, user) {
+			w.WriteHeader(http.StatusProxyAuthRequired)
+			return
+		}
+		target, err := net.Dial("tcp", r.Host)
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		atomic.AddInt32(tunnels, 1)
+		w.WriteHeader(http.StatusOK)
+		conn, brw, _ := w.(http.Hijacker).Hijack()
+		brw.Flush()
+		go func() {
+			io.Copy(target, conn)
+			target.Close()
+		}()
+		io.Copy(conn, target)
+		conn.Close()
+	}))
+	t.Cleanup(server.Close)
+	proxyURL, _ := url.Parse(server.URL)
+	proxyURL.User = user
+	return proxyURL
+}
+
+// authorized reports whether the Proxy-Authorization of r holds the credentials of user.
+func authorized(r *http.Request, user *url.Userinfo) bool {
+	r.Header.Set("Authorization", r.Header.Get("Proxy-Authorization"))
+	name, pass, ok := r.BasicAuth()
+	want, _ := user.Password()
+	return ok && name == user.Username() && pass == want
+}
@@ -0,0 +1,285 @@
+package dns
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pysugar/wheels/buf"
+	"github.com/pysugar/wheels/net"
+	"github.com/pysugar/wheels/retry"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// IPOption is an object for IP query options.
+type IPOption struct {
+	IPv4Enable bool
+	IPv6Enable bool
+}
+
+// clientOptions configure a Client. clientOptions are set by the ClientOption values passed to NewClient.
+type clientOptions struct {
+	timeout      time.Duration
+	retry        retry.Strategy
+	cacheEnabled bool
+	dialer       func(ctx context.Context, dest net.Destination) (net.Conn, error)
+}
+
+type ClientOption func(*clientOptions)
+
+func defaultDial(ctx context.Context, dest net.Destination) (net.Conn, error) {
+	var dialer net.Dialer
+	return dialer.DialContext(ctx, dest.Network.SystemString(), dest.NetAddr())
+}
+
+func defaultClientOptions() *clientOptions {
+	return &clientOptions{
+		timeout:      4 * time.Second,
+		retry:        retry.Timed(2, 100),
+		cacheEnabled: true,
+		dialer:       defaultDial,
+	}
+}
+
+// WithTimeout sets the timeout of a single query attempt.
+func WithTimeout(timeout time.Duration) ClientOption {
+	return func(o *clientOptions) {
+		o.timeout = timeout
+	}
+}
+
+// WithRetry sets the strategy used to retry failed query attempts.
+func WithRetry(strategy retry.Strategy) ClientOption {
+	return func(o *clientOptions) {
+		o.retry = strategy
+	}
+}
+
+// WithDialer sets the function used to connect to the upstream server.
+func WithDialer(dialer func(ctx context.Context, dest net.Destination) (net.Conn, error)) ClientOption {
+	return func(o *clientOptions) {
+		o.dialer = dialer
+	}
+}
+
+// DisableCache turns off the in-memory TTL cache.
+func DisableCache() ClientOption {
+	return func(o *clientOptions) {
+		o.cacheEnabled = false
+	}
+}
+
+type cacheKey struct {
+	name  string
+	qType dnsmessage.Type
+}
+
+type cacheRecord struct {
+	ips    []net.IP
+	expire time.Time
+}
+
+// Client is a stub resolver which queries a single upstream server over UDP or TCP.
+type Client struct {
+	sync.Mutex
+	server  net.Destination
+	options *clientOptions
+	cache   map[cacheKey]*cacheRecord
+	reqID   uint32
+}
+
+// NewClient creates a Client querying server, whose network decides whether UDP or TCP is used.
+func NewClient(server net.Destination, opts ...ClientOption) *Client {
+	options := defaultClientOptions()
+	for _, o := range opts {
+		o(options)
+	}
+	return &Client{
+		server:  server,
+		options: options,
+		cache:   make(map[cacheKey]*cacheRecord),
+	}
+}
+
+func (c *Client) newReqID() uint16 {
+	return uint16(atomic.AddUint32(&c.reqID, 1))
+}
+
+// LookupIP returns the IP addresses of domain, served from the cache while their TTL is not expired.
+func (c *Client) LookupIP(ctx context.Context, domain string, option IPOption) ([]net.IP, error) {
+	if !option.IPv4Enable && !option.IPv6Enable {
+		return nil, fmt.Errorf("neither IPv4 nor IPv6 is enabled for %s", domain)
+	}
+
+	fqdn := domain
+	if !strings.HasSuffix(fqdn, ".") {
+		fqdn += "."
+	}
+
+	var ips []net.IP
+	var errs []error
+	if option.IPv4Enable {
+		ip4s, err := c.lookup(ctx, fqdn, dnsmessage.TypeA)
+		ips = append(ips, ip4s...)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if option.IPv6Enable {
+		ip6s, err := c.lookup(ctx, fqdn, dnsmessage.TypeAAAA)
+		ips = append(ips, ip6s...)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if len(ips) == 0 {
+		if len(errs) > 0 {
+			return nil, fmt.Errorf("failed to lookup %s, err: %v", domain, errs)
+		}
+		return nil, fmt.Errorf("no IP address found for %s", domain)
+	}
+	return ips, nil
+}
+
+func (c *Client) lookup(ctx context.Context, fqdn string, qType dnsmessage.Type) ([]net.IP, error) {
+	key := cacheKey{name: strings.ToLower(fqdn), qType: qType}
+	if c.options.cacheEnabled {
+		c.Lock()
+		record, found := c.cache[key]
+		if found && time.Now().After(record.expire) {
+			delete(c.cache, key)
+			found = false
+		}
+		c.Unlock()
+		if found {
+			return record.ips, nil
+		}
+	}
+
+	name, err := dnsmessage.NewName(fqdn)
+	if err != nil {
+		return nil, fmt.Errorf("invalid domain name: %s, err: %v", fqdn, err)
+	}
+	resp, err := c.Exchange(ctx, &dnsmessage.Message{
+		Header: dnsmessage.Header{
+			RecursionDesired: true,
+		},
+		Questions: []dnsmessage.Question{{
+			Name:  name,
+			Type:  qType,
+			Class: dnsmessage.ClassINET,
+		}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if resp.RCode != dnsmessage.RCodeSuccess {
+		return nil, fmt.Errorf("failed to lookup %s %s, rcode: %s", fqdn, qType, resp.RCode)
+	}
+
+	var ips []net.IP
+	var ttl uint32
+	for _, answer := range resp.Answers {
+		switch body := answer.Body.(type) {
+		case *dnsmessage.AResource:
+			ips = append(ips, net.IP(body.A[:]))
+		case *dnsmessage.AAAAResource:
+			ips = append(ips, net.IP(body.AAAA[:]))
+		default:
+			continue
+		}
+		if ttl == 0 || answer.Header.TTL < ttl {
+			ttl = answer.Header.TTL
+		}
+	}
+
+	if c.options.cacheEnabled && len(ips) > 0 && ttl > 0 {
+		c.Lock()
+		c.cache[key] = &cacheRecord{
+			ips:    ips,
+			expire: time.Now().Add(time.Duration(ttl) * time.Second),
+		}
+		c.Unlock()
+	}
+	return ips, nil
+}
+
+// Exchange sends msg to the upstream server and returns the response, retrying failed attempts.
+// The message ID is overwritten. A truncated UDP response is queried again over TCP.
+func (c *Client) Exchange(ctx context.Context, msg *dnsmessage.Message) (*dnsmessage.Message, error) {
+	var resp *dnsmessage.Message
+	err := c.options.retry.On(func() error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		r, err := c.exchange(ctx, c.server, msg)
+		if err == nil && r.Truncated && c.server.Network == net.Network_UDP {
+			r, err = c.exchange(ctx, net.TCPDestination(c.server.Address, c.server.Port), msg)
+		}
+		if err != nil {
+			return err
+		}
+		resp = r
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query %s, err: %w", c.server, err)
+	}
+	return resp, nil
+}
+
+func (c *Client) exchange(ctx context.Context, server net.Destination, msg *dnsmessage.Message) (*dnsmessage.Message, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.options.timeout)
+	defer cancel()
+
+	conn, err := c.options.dialer(ctx, server)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	query := *msg
+	query.ID = c.newReqID()
+	b, err := PackMessage(&query)
+	if err != nil {
+		return nil, err
+	}
+
+	var writer MessageWriter
+	var reader MessageReader
+	if server.Network == net.Network_TCP {
+		writer = &TCPWriter{Writer: buf.NewWriter(conn)}
+		reader = NewTCPReader(buf.NewReader(conn))
+	} else {
+		writer = &UDPWriter{Writer: buf.NewWriter(conn)}
+		reader = &UDPReader{Reader: buf.NewPacketReader(conn)}
+	}
+	if err := writer.WriteMessage(b); err != nil {
+		return nil, err
+	}
+
+	for {
+		b, err := reader.ReadMessage()
+		if err != nil {
+			return nil, err
+		}
+		var resp dnsmessage.Message
+		err = resp.Unpack(b.Bytes())
+		b.Release()
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse DNS response, err: %v", err)
+		}
+		// responses to earlier, timed out queries may still arrive on UDP
+		if resp.ID != query.ID {
+			continue
+		}
+		return &resp, nil
+	}
+}
@@ -0,0 +1,229 @@
+package dns_test
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pysugar/wheels/net"
+	. "github.com/pysugar/wheels/protocol/dns"
+	"github.com/pysugar/wheels/retry"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// fakeServer is an in-process DNS server answering A and AAAA queries from records over UDP and TCP.
+type fakeServer struct {
+	sync.Mutex
+	records   map[string][]net.IP
+	ttl       uint32
+	truncate  bool  // truncate every UDP response
+	drop      int32 // number of UDP queries to drop
+	queries   int32
+	udpConn   net.PacketConn
+	tcpListen net.Listener
+}
+
+func startFakeServer(t *testing.T, server *fakeServer) {
+	t.Helper()
+	udpConn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IP{127, 0, 0, 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tcpListener, err := net.ListenTCP("tcp", &net.TCPAddr{IP: net.IP{127, 0, 0, 1}, Port: udpConn.LocalAddr().(*net.UDPAddr).Port})
+	if err != nil {
+		udpConn.Close()
+		t.Fatal(err)
+	}
+	server.udpConn = udpConn
+	server.tcpListen = tcpListener
+	t.Cleanup(func() {
+		udpConn.Close()
+		tcpListener.Close()
+	})
+
+	go func() {
+		b := make([]byte, 512)
+		for {
+			n, addr, err := udpConn.ReadFrom(b)
+			if err != nil {
+				return
+			}
+			atomic.AddInt32(&server.queries, 1)
+			if atomic.AddInt32(&server.drop, -1) >= 0 {
+				continue
+			}
+			if resp := server.respond(b[:n], server.truncate); resp != nil {
+				udpConn.WriteTo(resp, addr)
+			}
+		}
+	}()
+	go func() {
+		for {
+			conn, err := tcpListener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				var size uint16
+				if err := binary.Read(conn, binary.BigEndian, &size); err != nil {
+					return
+				}
+				b := make([]byte, size)
+				if _, err := io.ReadFull(conn, b); err != nil {
+					return
+				}
+				atomic.AddInt32(&server.queries, 1)
+				if resp := server.respond(b, false); resp != nil {
+					binary.Write(conn, binary.BigEndian, uint16(len(resp)))
+					conn.Write(resp)
+				}
+			}()
+		}
+	}()
+}
+
+func (s *fakeServer) udpDestination() net.Destination {
+	return net.DestinationFromAddr(s.udpConn.LocalAddr())
+}
+
+func (s *fakeServer) tcpDestination() net.Destination {
+	return net.DestinationFromAddr(s.tcpListen.Addr())
+}
+
+func (s *fakeServer) respond(query []byte, truncate bool) []byte {
+	var msg dnsmessage.Message
+	if err := msg.Unpack(query); err != nil || len(msg.Questions) != 1 {
+		return nil
+	}
+	question := msg.Questions[0]
+	msg.Response = true
+	msg.Truncated = truncate
+
+	s.Lock()
+	ips, found := s.records[strings.ToLower(question.Name.String())]
+	s.Unlock()
+	if !found {
+		msg.RCode = dnsmessage.RCodeNameError
+	}
+	if !truncate {
+		for _, ip := range ips {
+			header := dnsmessage.ResourceHeader{Name: question.Name, Type: question.Type, Class: dnsmessage.ClassINET, TTL: s.ttl}
+			if ip4 := ip.To4(); ip4 != nil && question.Type == dnsmessage.TypeA {
+				r := &dnsmessage.AResource{}
+				copy(r.A[:], ip4)
+				msg.Answers = append(msg.Answers, dnsmessage.Resource{Header: header, Body: r})
+			} else if ip.To4() == nil && question.Type == dnsmessage.TypeAAAA {
+				r := &dnsmessage.AAAAResource{}
+				copy(r.AAAA[:], ip)
+				msg.Answers = append(msg.Answers, dnsmessage.Resource{Header: header, Body: r})
+			}
+		}
+	}
+	resp, err := msg.Pack()
+	if err != nil {
+		return nil
+	}
+	return resp
+}
+
+var testRecords = map[string][]net.IP{
+	"example.com.": {{93, 184, 216, 34}, net.ParseIP("2606:2800:220:1:248:1893:25c8:1946")},
+}
+
+func TestClientLookupIP(t *testing.T) {
+	server := &fakeServer{records: testRecords, ttl: 60}
+	startFakeServer(t, server)
+
+	for _, dest := range []net.Destination{server.udpDestination(), server.tcpDestination()} {
+		t.Run(dest.Network.SystemString(), func(t *testing.T) {
+			client := NewClient(dest, DisableCache())
+
+			ips, err := client.LookupIP(context.Background(), "example.com", IPOption{IPv4Enable: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if r := cmp.Diff(ips, []net.IP{{93, 184, 216, 34}}); r != "" {
+				t.Error(r)
+			}
+
+			ips, err = client.LookupIP(context.Background(), "example.com", IPOption{IPv4Enable: true, IPv6Enable: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(ips) != 2 || !ips[1].Equal(net.ParseIP("2606:2800:220:1:248:1893:25c8:1946")) {
+				t.Error("unexpected ips: ", ips)
+			}
+
+			if _, err := client.LookupIP(context.Background(), "unknown.example.com", IPOption{IPv4Enable: true}); err == nil {
+				t.Error("expected error, but actually nil")
+			}
+		})
+	}
+}
+
+func TestClientCache(t *testing.T) {
+	server := &fakeServer{records: testRecords, ttl: 1}
+	startFakeServer(t, server)
+	client := NewClient(server.udpDestination())
+
+	for i := 0; i < 3; i++ {
+		if _, err := client.LookupIP(context.Background(), "Example.COM", IPOption{IPv4Enable: true}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if queries := atomic.LoadInt32(&server.queries); queries != 1 {
+		t.Error("expected 1 query, but actually ", queries)
+	}
+
+	time.Sleep(time.Millisecond * 1100)
+	if _, err := client.LookupIP(context.Background(), "example.com", IPOption{IPv4Enable: true}); err != nil {
+		t.Fatal(err)
+	}
+	if queries := atomic.LoadInt32(&server.queries); queries != 2 {
+		t.Error("expected 2 queries after expiration, but actually ", queries)
+	}
+}
+
+func TestClientRetry(t *testing.T) {
+	server := &fakeServer{records: testRecords, ttl: 60, drop: 2}
+	startFakeServer(t, server)
+
+	client := NewClient(server.udpDestination(), WithTimeout(time.Millisecond*200), WithRetry(retry.Timed(3, 10)))
+	ips, err := client.LookupIP(context.Background(), "example.com", IPOption{IPv4Enable: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := cmp.Diff(ips, []net.IP{{93, 184, 216, 34}}); r != "" {
+		t.Error(r)
+	}
+	if queries := atomic.LoadInt32(&server.queries); queries != 3 {
+		t.Error("expected 3 queries, but actually ", queries)
+	}
+
+	atomic.StoreInt32(&server.drop, 100)
+	client = NewClient(server.udpDestination(), DisableCache(), WithTimeout(time.Millisecond*100), WithRetry(retry.Timed(2, 10)))
+	if _, err := client.LookupIP(context.Background(), "example.com", IPOption{IPv4Enable: true}); err == nil {
+		t.Error("expected timeout error, but actually nil")
+	}
+}
+
+func TestClientTruncatedFallbackToTCP(t *testing.T) {
+	server := &fakeServer{records: testRecords, ttl: 60, truncate: true}
+	startFakeServer(t, server)
+
+	client := NewClient(server.udpDestination())
+	ips, err := client.LookupIP(context.Background(), "example.com", IPOption{IPv4Enable: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := cmp.Diff(ips, []net.IP{{93, 184, 216, 34}}); r != "" {
+		t.Error(r)
+	}
+}
@@ -0,0 +1,101 @@
+package dns_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pysugar/wheels/buf"
+	. "github.com/pysugar/wheels/protocol/dns"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+func newQuery(t *testing.T, id uint16, domain string) *dnsmessage.Message {
+	t.Helper()
+	return &dnsmessage.Message{
+		Header: dnsmessage.Header{ID: id, RecursionDesired: true},
+		Questions: []dnsmessage.Question{{
+			Name:  dnsmessage.MustNewName(domain),
+			Type:  dnsmessage.TypeA,
+			Class: dnsmessage.ClassINET,
+		}},
+	}
+}
+
+func TestPackMessage(t *testing.T) {
+	msg := newQuery(t, 1, "example.com.")
+	b, err := PackMessage(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Release()
+
+	expected, err := msg.Pack()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := cmp.Diff(b.Bytes(), expected); r != "" {
+		t.Error(r)
+	}
+}
+
+func TestTCPReaderWriter(t *testing.T) {
+	var stream bytes.Buffer
+	writer := &TCPWriter{Writer: buf.NewWriter(&stream)}
+	for i, domain := range []string{"example.com.", "example.org."} {
+		b, err := PackMessage(newQuery(t, uint16(i+1), domain))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := writer.WriteMessage(b); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	reader := NewTCPReader(buf.NewReader(&stream))
+	for i, domain := range []string{"example.com.", "example.org."} {
+		b, err := reader.ReadMessage()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var msg dnsmessage.Message
+		if err := msg.Unpack(b.Bytes()); err != nil {
+			t.Fatal(err)
+		}
+		b.Release()
+		if msg.ID != uint16(i+1) || msg.Questions[0].Name.String() != domain {
+			t.Error("unexpected message: ", msg.GoString())
+		}
+	}
+	if _, err := reader.ReadMessage(); err == nil {
+		t.Error("expected EOF, but actually nil")
+	}
+}
+
+func TestUDPReader(t *testing.T) {
+	var mb buf.MultiBuffer
+	for _, domain := range []string{"example.com.", "example.org."} {
+		b, err := PackMessage(newQuery(t, 1, domain))
+		if err != nil {
+			t.Fatal(err)
+		}
+		mb = append(mb, b)
+	}
+
+	reader := &UDPReader{Reader: &buf.MultiBufferContainer{MultiBuffer: mb}}
+	defer reader.Close()
+	for _, domain := range []string{"example.com.", "example.org."} {
+		b, err := reader.ReadMessage()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var msg dnsmessage.Message
+		if err := msg.Unpack(b.Bytes()); err != nil {
+			t.Fatal(err)
+		}
+		b.Release()
+		if r := cmp.Diff(msg.Questions[0].Name.String(), domain); r != "" {
+			t.Error(r)
+		}
+	}
+}
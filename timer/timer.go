@@ -2,6 +2,7 @@ package timer
 
 import (
 	"context"
+	"github.com/pysugar/wheels/features/policy"
 	"github.com/pysugar/wheels/task"
 	"sync"
 	"time"
@@ -16,6 +17,7 @@ type ActivityTimer struct {
 	updated   chan struct{}
 	checkTask *task.Periodic
 	onTimeout func()
+	timeouts  policy.Timeout
 }
 
 func (t *ActivityTimer) Update() {
@@ -74,11 +76,34 @@ func (t *ActivityTimer) SetTimeout(timeout time.Duration) {
 	}
 }
 
+// HandshakeDone switches the timer to the ConnectionIdle timeout of the session policy.
+func (t *ActivityTimer) HandshakeDone() {
+	t.SetTimeout(t.timeouts.ConnectionIdle)
+}
+
+// UplinkDone switches the timer to the DownlinkOnly timeout of the session policy.
+func (t *ActivityTimer) UplinkDone() {
+	t.SetTimeout(t.timeouts.DownlinkOnly)
+}
+
+// DownlinkDone switches the timer to the UplinkOnly timeout of the session policy.
+func (t *ActivityTimer) DownlinkDone() {
+	t.SetTimeout(t.timeouts.UplinkOnly)
+}
+
+// CancelAfterInactivity calls cancel if there is no activity within timeout.
+// The timeouts of the session policy in ctx are used by HandshakeDone, UplinkDone and DownlinkDone.
 func CancelAfterInactivity(ctx context.Context, cancel context.CancelFunc, timeout time.Duration) *ActivityTimer {
 	timer := &ActivityTimer{
 		updated:   make(chan struct{}, 1),
 		onTimeout: cancel,
+		timeouts:  policy.SessionPolicyFromContext(ctx).Timeouts,
 	}
 	timer.SetTimeout(timeout)
 	return timer
 }
+
+// CancelAfterHandshakeTimeout is CancelAfterInactivity starting with the Handshake timeout of the session policy in ctx.
+func CancelAfterHandshakeTimeout(ctx context.Context, cancel context.CancelFunc) *ActivityTimer {
+	return CancelAfterInactivity(ctx, cancel, policy.SessionPolicyFromContext(ctx).Timeouts.Handshake)
+}
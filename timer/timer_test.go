@@ -2,6 +2,7 @@ package timer_test
 
 import (
 	"context"
+	"github.com/pysugar/wheels/features/policy"
 	. "github.com/pysugar/wheels/timer"
 	"runtime"
 	"testing"
@@ -57,3 +58,26 @@ func TestActivityTimerZeroTimeout(t *testing.T) {
 	}
 	runtime.KeepAlive(timer)
 }
+
+func TestActivityTimerSessionPolicy(t *testing.T) {
+	session := policy.SessionDefault()
+	session.Timeouts.Handshake = time.Second * 2
+	session.Timeouts.ConnectionIdle = time.Second * 10
+	session.Timeouts.DownlinkOnly = time.Second * 1
+	ctx, cancel := context.WithCancel(policy.ContextWithSessionPolicy(context.Background(), session))
+
+	timer := CancelAfterHandshakeTimeout(ctx, cancel)
+	time.Sleep(time.Second * 1)
+	timer.HandshakeDone()
+	time.Sleep(time.Second * 3)
+	if ctx.Err() != nil {
+		t.Error("expected nil, but got ", ctx.Err().Error())
+	}
+
+	timer.UplinkDone()
+	time.Sleep(time.Second * 3)
+	if ctx.Err() == nil {
+		t.Error("expected some error, but got nil")
+	}
+	runtime.KeepAlive(timer)
+}
@@ -0,0 +1,162 @@
+package internet
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/rand"
+
+	"github.com/pysugar/wheels/errors"
+	"github.com/pysugar/wheels/net"
+	"github.com/pysugar/wheels/transport/internet/stat"
+)
+
+// DNSResolver resolves domain destinations when SocketConfig.DomainStrategy is not AS_IS.
+// network is one of "ip", "ip4" or "ip6", as in net.Resolver.
+type DNSResolver interface {
+	LookupIP(ctx context.Context, network, host string) ([]net.IP, error)
+}
+
+// DialFunc is the interface for dialing a stream transport connection.
+type DialFunc func(ctx context.Context, dest net.Destination, streamSettings *MemoryStreamConfig) (stat.Connection, error)
+
+// DialerProxyFunc dials a destination through the outbound referred to by SocketConfig.DialerProxy.
+type DialerProxyFunc func(ctx context.Context, dest net.Destination) (net.Conn, error)
+
+var (
+	dnsResolver          DNSResolver = &net.Resolver{}
+	dialerProxyCache                 = make(map[string]DialerProxyFunc)
+	transportDialerCache             = make(map[string]DialFunc)
+)
+
+// RegisterTransportDialer registers a Dialer with given name.
+func RegisterTransportDialer(protocol string, dialer DialFunc) error {
+	if _, found := transportDialerCache[protocol]; found {
+		return fmt.Errorf("dialer already registered: %s.", protocol)
+	}
+	transportDialerCache[protocol] = dialer
+	return nil
+}
+
+// Dial dials a stream transport connection to the given destination, with the transport chosen by streamSettings.
+func Dial(ctx context.Context, dest net.Destination, streamSettings *MemoryStreamConfig) (stat.Connection, error) {
+	if dest.Network != net.Network_TCP && dest.Network != net.Network_UNIX {
+		return nil, fmt.Errorf("unknown network for stream transport: %s", dest.Network)
+	}
+
+	if streamSettings == nil {
+		s, err := ToMemoryStreamConfig(nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create default stream settings, err: %v", err)
+		}
+		streamSettings = s
+	}
+
+	protocol := streamSettings.ProtocolName
+	dialer := transportDialerCache[protocol]
+	if dialer == nil {
+		return nil, fmt.Errorf("%s dialer not registered.", protocol)
+	}
+	return dialer(ctx, dest, streamSettings)
+}
+
+// UseAlternativeDNSResolver replaces the resolver used for domain strategies, nil restores the system resolver.
+// Caller must ensure there is no race condition.
+func UseAlternativeDNSResolver(resolver DNSResolver) {
+	if resolver == nil {
+		resolver = &net.Resolver{}
+	}
+	dnsResolver = resolver
+}
+
+// RegisterDialerProxy registers the dialer used for connections whose SocketConfig.DialerProxy equals tag.
+func RegisterDialerProxy(tag string, dialer DialerProxyFunc) error {
+	if dialer == nil {
+		return errors.New("nil dialer proxy")
+	}
+	if _, found := dialerProxyCache[tag]; found {
+		return fmt.Errorf("dialer proxy already registered: %s", tag)
+	}
+	dialerProxyCache[tag] = dialer
+	return nil
+}
+
+func ipNetwork(ip4, ip6 bool) string {
+	switch {
+	case ip4 && ip6:
+		return "ip"
+	case ip4:
+		return "ip4"
+	case ip6:
+		return "ip6"
+	default:
+		return ""
+	}
+}
+
+func lookupIP(ctx context.Context, domain string, strategy DomainStrategy, localAddr net.Address) ([]net.IP, error) {
+	var ips []net.IP
+	var err error
+	network := ipNetwork(
+		(localAddr == nil || localAddr.Family().IsIPv4()) && strategy.preferIP4(),
+		(localAddr == nil || localAddr.Family().IsIPv6()) && strategy.preferIP6(),
+	)
+	if network != "" {
+		ips, err = dnsResolver.LookupIP(ctx, network, domain)
+	}
+
+	// resolve fallback
+	if (len(ips) == 0 || err != nil) && strategy.hasFallback() && localAddr == nil {
+		ips, err = dnsResolver.LookupIP(ctx, ipNetwork(strategy.fallbackIP4(), strategy.fallbackIP6()), domain)
+	}
+	if err == nil && len(ips) == 0 {
+		err = fmt.Errorf("no IP address found for %s with domain strategy %s", domain, strategy)
+	}
+	return ips, err
+}
+
+func canLookupIP(dst net.Destination, sockopt *SocketConfig) bool {
+	if dst.Address.Family().IsIP() || dst.Network == net.Network_UNIX {
+		return false
+	}
+	return sockopt.DomainStrategy.hasStrategy()
+}
+
+// DialSystem calls system dialer to create a network connection.
+//
+// xray:api:beta
+func DialSystem(ctx context.Context, dest net.Destination, sockopt *SocketConfig) (net.Conn, error) {
+	if sockopt == nil {
+		return effectiveSystemDialer.Dial(ctx, nil, dest, sockopt)
+	}
+
+	var src net.Address
+	if len(sockopt.BindAddress) > 0 && sockopt.BindPort == 0 {
+		src = net.IPAddress(sockopt.BindAddress)
+	}
+
+	if canLookupIP(dest, sockopt) {
+		ips, err := lookupIP(ctx, dest.Address.String(), sockopt.DomainStrategy, src)
+		if err == nil {
+			if config := HappyEyeballsFromContext(ctx); config != nil && dest.Network == net.Network_TCP && len(ips) > 1 && len(sockopt.DialerProxy) == 0 {
+				return happyEyeballsDial(ctx, src, dest, ips, sockopt, config)
+			}
+			dest.Address = net.IPAddress(ips[rand.Intn(len(ips))])
+		} else {
+			if sockopt.DomainStrategy.forceIP() {
+				return nil, fmt.Errorf("failed to resolve ip for %s, err: %v", dest, err)
+			}
+			log.Printf("failed to resolve ip for %s, dial as is, err: %v", dest, err)
+		}
+	}
+
+	if len(sockopt.DialerProxy) > 0 {
+		dialer := dialerProxyCache[sockopt.DialerProxy]
+		if dialer == nil {
+			return nil, fmt.Errorf("dialer proxy not registered: %s", sockopt.DialerProxy)
+		}
+		return dialer(ctx, dest)
+	}
+
+	return effectiveSystemDialer.Dial(ctx, src, dest, sockopt)
+}
@@ -0,0 +1,254 @@
+package internet_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"syscall"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pysugar/wheels/net"
+	"github.com/pysugar/wheels/testing/servers/tcp"
+	. "github.com/pysugar/wheels/transport/internet"
+)
+
+type fakeResolver struct {
+	sync.Mutex
+	records  map[string][]net.IP
+	networks []string
+}
+
+func (r *fakeResolver) LookupIP(ctx context.Context, network, host string) ([]net.IP, error) {
+	r.Lock()
+	defer r.Unlock()
+	r.networks = append(r.networks, network)
+	var ips []net.IP
+	for _, ip := range r.records[host] {
+		if (network == "ip4" && ip.To4() == nil) || (network == "ip6" && ip.To4() != nil) {
+			continue
+		}
+		ips = append(ips, ip)
+	}
+	if len(ips) == 0 {
+		return nil, fmt.Errorf("no such host: %s", host)
+	}
+	return ips, nil
+}
+
+func useFakeResolver(t *testing.T, records map[string][]net.IP) *fakeResolver {
+	r := &fakeResolver{records: records}
+	UseAlternativeDNSResolver(r)
+	t.Cleanup(func() { UseAlternativeDNSResolver(nil) })
+	return r
+}
+
+func startEchoServer(t *testing.T) net.Destination {
+	server := &tcp.Server{
+		MsgProcessor: func(msg []byte) []byte { return msg },
+	}
+	dest, err := server.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { server.Close() })
+	return dest
+}
+
+func assertEcho(t *testing.T, conn net.Conn) {
+	t.Helper()
+	payload := []byte("hello wheels")
+	if _, err := conn.Write(payload); err != nil {
+		t.Fatal(err)
+	}
+	response := make([]byte, len(payload))
+	if _, err := io.ReadFull(conn, response); err != nil {
+		t.Fatal(err)
+	}
+	if r := cmp.Diff(response, payload); r != "" {
+		t.Error(r)
+	}
+}
+
+func TestDialSystem(t *testing.T) {
+	dest := startEchoServer(t)
+
+	conn, err := DialSystem(context.Background(), dest, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	assertEcho(t, conn)
+}
+
+func TestDialSystemDomainStrategy(t *testing.T) {
+	dest := startEchoServer(t)
+
+	testCases := []struct {
+		Strategy DomainStrategy
+		Records  []net.IP
+		Networks []string
+		Fail     bool
+	}{
+		{Strategy: DomainStrategy_USE_IP, Records: []net.IP{{127, 0, 0, 1}}, Networks: []string{"ip"}},
+		{Strategy: DomainStrategy_USE_IP4, Records: []net.IP{{127, 0, 0, 1}}, Networks: []string{"ip4"}},
+		{Strategy: DomainStrategy_USE_IP46, Records: []net.IP{{127, 0, 0, 1}}, Networks: []string{"ip4"}},
+		{Strategy: DomainStrategy_USE_IP64, Records: []net.IP{{127, 0, 0, 1}}, Networks: []string{"ip6", "ip4"}},
+		{Strategy: DomainStrategy_FORCE_IP64, Records: []net.IP{{127, 0, 0, 1}}, Networks: []string{"ip6", "ip4"}},
+		{Strategy: DomainStrategy_FORCE_IP6, Records: []net.IP{{127, 0, 0, 1}}, Networks: []string{"ip6"}, Fail: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Strategy.String(), func(t *testing.T) {
+			resolver := useFakeResolver(t, map[string][]net.IP{"echo.test": tc.Records})
+			conn, err := DialSystem(context.Background(), net.TCPDestination(net.DomainAddress("echo.test"), dest.Port), &SocketConfig{
+				DomainStrategy: tc.Strategy,
+			})
+			if r := cmp.Diff(resolver.networks, tc.Networks); r != "" {
+				t.Error(r)
+			}
+			if tc.Fail {
+				if err == nil {
+					conn.Close()
+					t.Fatal("expected error, but actually nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+			assertEcho(t, conn)
+		})
+	}
+}
+
+func TestDialSystemUseIPFallbackAsIs(t *testing.T) {
+	dest := startEchoServer(t)
+	useFakeResolver(t, nil)
+
+	// resolving fails, so the domain is dialed as is through the system resolver
+	conn, err := DialSystem(context.Background(), net.TCPDestination(net.DomainAddress("localhost"), dest.Port), &SocketConfig{
+		DomainStrategy: DomainStrategy_USE_IP4,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	assertEcho(t, conn)
+}
+
+func TestDialSystemHappyEyeballs(t *testing.T) {
+	dest := startEchoServer(t)
+	useFakeResolver(t, map[string][]net.IP{
+		"echo.test": {net.ParseIP("::1"), {127, 0, 0, 1}},
+	})
+
+	ctx := ContextWithHappyEyeballs(context.Background(), &HappyEyeballsConfig{PrioritizeIPv6: true})
+	// nothing listens on [::1], the IPv4 attempt must win
+	conn, err := DialSystem(ctx, net.TCPDestination(net.DomainAddress("echo.test"), dest.Port), &SocketConfig{
+		DomainStrategy: DomainStrategy_USE_IP,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if ip := conn.RemoteAddr().(*net.TCPAddr).IP; !ip.Equal(net.IP{127, 0, 0, 1}) {
+		t.Error("unexpected remote address: ", ip)
+	}
+	assertEcho(t, conn)
+}
+
+func TestDialSystemBindAddress(t *testing.T) {
+	dest := startEchoServer(t)
+
+	conn, err := DialSystem(context.Background(), dest, &SocketConfig{
+		BindAddress: []byte{127, 0, 0, 1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if ip := conn.LocalAddr().(*net.TCPAddr).IP; !ip.Equal(net.IP{127, 0, 0, 1}) {
+		t.Error("unexpected local address: ", ip)
+	}
+	assertEcho(t, conn)
+}
+
+func TestDialSystemDialerProxy(t *testing.T) {
+	dest := startEchoServer(t)
+
+	var proxied net.Destination
+	tag := fmt.Sprintf("proxy-%d", dest.Port)
+	if err := RegisterDialerProxy(tag, func(ctx context.Context, d net.Destination) (net.Conn, error) {
+		proxied = d
+		return DialSystem(ctx, dest, nil)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := RegisterDialerProxy(tag, nil); err == nil {
+		t.Error("expected error, but actually nil")
+	}
+
+	target := net.TCPDestination(net.DomainAddress("example.com"), 443)
+	conn, err := DialSystem(context.Background(), target, &SocketConfig{DialerProxy: tag})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if r := cmp.Diff(proxied.String(), target.String()); r != "" {
+		t.Error(r)
+	}
+	assertEcho(t, conn)
+
+	if _, err := DialSystem(context.Background(), target, &SocketConfig{DialerProxy: "not-registered"}); err == nil {
+		t.Error("expected error, but actually nil")
+	}
+}
+
+func TestDialSystemUDP(t *testing.T) {
+	server, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IP{127, 0, 0, 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	go func() {
+		b := make([]byte, 1024)
+		for {
+			n, addr, err := server.ReadFrom(b)
+			if err != nil {
+				return
+			}
+			server.WriteTo(b[:n], addr)
+		}
+	}()
+
+	conn, err := DialSystem(context.Background(), net.DestinationFromAddr(server.LocalAddr()), &SocketConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	assertEcho(t, conn)
+}
+
+func TestRegisterDialerController(t *testing.T) {
+	dest := startEchoServer(t)
+
+	var called bool
+	if err := RegisterDialerController(func(network, address string, c syscall.RawConn) error {
+		called = true
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	conn, err := DialSystem(context.Background(), dest, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+	if !called {
+		t.Error("dialer controller is not called")
+	}
+}
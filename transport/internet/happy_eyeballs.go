@@ -0,0 +1,151 @@
+package internet
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pysugar/wheels/errors"
+	"github.com/pysugar/wheels/net"
+)
+
+// HappyEyeballsConfig enables racing connection attempts (RFC 8305) across the addresses
+// resolved by DialSystem for TCP destinations.
+type HappyEyeballsConfig struct {
+	// Whether IPv6 addresses are tried first.
+	PrioritizeIPv6 bool
+	// Number of addresses of the first family tried before switching to the other one. 0 means 1.
+	Interleave int
+	// Delay before the next attempt is started while the previous ones are still pending. 0 means 250ms.
+	TryDelay time.Duration
+	// Maximum number of attempts in flight. 0 means unlimited.
+	MaxConcurrentTry int
+}
+
+type happyEyeballsKey int32
+
+const (
+	happyEyeballsConfigKey happyEyeballsKey = 0
+)
+
+func ContextWithHappyEyeballs(ctx context.Context, config *HappyEyeballsConfig) context.Context {
+	return context.WithValue(ctx, happyEyeballsConfigKey, config)
+}
+
+func HappyEyeballsFromContext(ctx context.Context) *HappyEyeballsConfig {
+	if config, ok := ctx.Value(happyEyeballsConfigKey).(*HappyEyeballsConfig); ok {
+		return config
+	}
+	return nil
+}
+
+// sortIPsForHappyEyeballs orders addresses so that families alternate, starting with
+// config.Interleave addresses of the preferred family.
+func sortIPsForHappyEyeballs(ips []net.IP, config *HappyEyeballsConfig) []net.IP {
+	var ip4s, ip6s []net.IP
+	for _, ip := range ips {
+		if ip.To4() != nil {
+			ip4s = append(ip4s, ip)
+		} else {
+			ip6s = append(ip6s, ip)
+		}
+	}
+
+	first, second := ip4s, ip6s
+	if config.PrioritizeIPv6 {
+		first, second = ip6s, ip4s
+	}
+	interleave := config.Interleave
+	if interleave <= 0 {
+		interleave = 1
+	}
+
+	sorted := make([]net.IP, 0, len(ips))
+	for len(first) > 0 || len(second) > 0 {
+		n := interleave
+		if n > len(first) {
+			n = len(first)
+		}
+		sorted = append(sorted, first[:n]...)
+		first = first[n:]
+		if len(second) > 0 {
+			sorted = append(sorted, second[0])
+			second = second[1:]
+		}
+		if len(first) == 0 {
+			sorted = append(sorted, second...)
+			second = nil
+		}
+	}
+	return sorted
+}
+
+func happyEyeballsDial(ctx context.Context, src net.Address, dest net.Destination, ips []net.IP, sockopt *SocketConfig, config *HappyEyeballsConfig) (net.Conn, error) {
+	ips = sortIPsForHappyEyeballs(ips, config)
+	tryDelay := config.TryDelay
+	if tryDelay <= 0 {
+		tryDelay = 250 * time.Millisecond
+	}
+	maxConcurrent := config.MaxConcurrentTry
+	if maxConcurrent <= 0 {
+		maxConcurrent = len(ips)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		conn net.Conn
+		err  error
+	}
+	results := make(chan result, len(ips))
+	next, inFlight := 0, 0
+	startNext := func() <-chan time.Time {
+		d := dest
+		d.Address = net.IPAddress(ips[next])
+		next++
+		inFlight++
+		go func() {
+			conn, err := effectiveSystemDialer.Dial(ctx, src, d, sockopt)
+			results <- result{conn: conn, err: err}
+		}()
+		return time.After(tryDelay)
+	}
+	// attempts still in flight may succeed after the winner, close them once they finish
+	drain := func(n int) {
+		go func() {
+			for i := 0; i < n; i++ {
+				if r := <-results; r.conn != nil {
+					r.conn.Close()
+				}
+			}
+		}()
+	}
+
+	var errs []error
+	timeout := startNext()
+	for {
+		select {
+		case r := <-results:
+			inFlight--
+			if r.err == nil {
+				drain(inFlight)
+				return r.conn, nil
+			}
+			errs = append(errs, r.err)
+			if next < len(ips) {
+				timeout = startNext()
+			} else if inFlight == 0 {
+				return nil, errors.Multi(fmt.Errorf("failed to dial %s with happy eyeballs", dest), errs)
+			}
+		case <-timeout:
+			timeout = nil
+			if next < len(ips) && inFlight < maxConcurrent {
+				timeout = startNext()
+			}
+		case <-ctx.Done():
+			drain(inFlight)
+			return nil, ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package internet
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/pysugar/wheels/net"
+)
+
+// IsValidHTTPHost reports whether the Host of an incoming request matches the configured host, ignoring the port.
+func IsValidHTTPHost(request string, config string) bool {
+	r := strings.ToLower(request)
+	c := strings.ToLower(config)
+	if strings.Contains(r, ":") {
+		h, _, _ := net.SplitHostPort(r)
+		return h == c
+	}
+	return r == c
+}
+
+// HasHTTPHeaders reports whether header carries every configured header with the same value.
+func HasHTTPHeaders(header http.Header, expected map[string]string) bool {
+	for key, value := range expected {
+		if header.Get(key) != value {
+			return false
+		}
+	}
+	return true
+}
+
+// ParseXForwardedFor parses X-Forwarded-For header in http headers, and return the IP list in it.
+func ParseXForwardedFor(header http.Header) []net.Address {
+	xff := header.Get("X-Forwarded-For")
+	if xff == "" {
+		return nil
+	}
+	list := strings.Split(xff, ",")
+	addrs := make([]net.Address, 0, len(list))
+	for _, proxy := range list {
+		addrs = append(addrs, net.ParseAddress(strings.TrimSpace(proxy)))
+	}
+	return addrs
+}
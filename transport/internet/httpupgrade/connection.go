@@ -0,0 +1,27 @@
+package httpupgrade
+
+import (
+	"bufio"
+	"net"
+)
+
+// connection is an upgraded connection, with the bytes buffered while reading the HTTP request.
+type connection struct {
+	net.Conn
+	reader     *bufio.Reader
+	remoteAddr net.Addr
+}
+
+func (c *connection) Read(b []byte) (int, error) {
+	if c.reader != nil {
+		if c.reader.Buffered() > 0 {
+			return c.reader.Read(b)
+		}
+		c.reader = nil
+	}
+	return c.Conn.Read(b)
+}
+
+func (c *connection) RemoteAddr() net.Addr {
+	return c.remoteAddr
+}
@@ -0,0 +1,165 @@
+package httpupgrade
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/pysugar/wheels/net"
+	"github.com/pysugar/wheels/transport/internet"
+	"github.com/pysugar/wheels/transport/internet/stat"
+)
+
+// Dial dials an HTTP/1.1 upgraded connection to the given destination.
+func Dial(ctx context.Context, dest net.Destination, streamSettings *internet.MemoryStreamConfig) (stat.Connection, error) {
+	config, ok := streamSettings.ProtocolSettings.(*Config)
+	if !ok || config == nil {
+		config = &Config{}
+	}
+
+	conn, err := internet.DialSystem(ctx, dest, streamSettings.SocketSettings)
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial to %s, err: %v", dest, err)
+	}
+
+	req, err := buildRequest(dest, config)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	c := &clientConn{
+		Conn:      conn,
+		req:       req,
+		ed:        int(config.Ed),
+		reader:    bufio.NewReader(conn),
+		requested: make(chan struct{}),
+	}
+
+	// without early data, the upgrade is completed before the connection is handed out
+	if config.Ed == 0 {
+		if err := c.upgrade(); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return stat.Connection(c), nil
+}
+
+func buildRequest(dest net.Destination, config *Config) (*http.Request, error) {
+	host := config.Host
+	if host == "" {
+		if dest.Network == net.Network_UNIX {
+			host = "localhost"
+		} else {
+			host = dest.NetAddr()
+		}
+	}
+
+	header := config.GetRequestHeader()
+	header.Del("Host")
+	header.Set("Connection", "Upgrade")
+	header.Set("Upgrade", "websocket")
+
+	uri, err := url.Parse("http://" + host + config.GetNormalizedPath())
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse upgrade url, err: %v", err)
+	}
+	return &http.Request{
+		Method:     http.MethodGet,
+		URL:        uri,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     header,
+		Host:       host,
+	}, nil
+}
+
+// clientConn sends the upgrade request lazily, so that the first write fitting in the
+// early data limit goes out in the same packet as the request.
+type clientConn struct {
+	net.Conn
+	req    *http.Request
+	ed     int
+	reader *bufio.Reader
+
+	requestOnce  sync.Once
+	requested    chan struct{}
+	requestErr   error
+	responseOnce sync.Once
+	responseErr  error
+}
+
+func (c *clientConn) writeRequest(earlyData []byte) bool {
+	sent := false
+	c.requestOnce.Do(func() {
+		defer close(c.requested)
+		var b bytes.Buffer
+		if err := c.req.Write(&b); err != nil {
+			c.requestErr = err
+			return
+		}
+		if len(earlyData) > 0 && len(earlyData) <= c.ed {
+			b.Write(earlyData)
+			sent = true
+		}
+		if _, err := c.Conn.Write(b.Bytes()); err != nil {
+			c.requestErr = err
+			sent = false
+		}
+	})
+	return sent
+}
+
+func (c *clientConn) readResponse() error {
+	c.responseOnce.Do(func() {
+		<-c.requested
+		if c.requestErr != nil {
+			c.responseErr = c.requestErr
+			return
+		}
+		resp, err := http.ReadResponse(c.reader, c.req)
+		if err != nil {
+			c.responseErr = fmt.Errorf("failed to read upgrade response, err: %v", err)
+			return
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusSwitchingProtocols || !strings.EqualFold(resp.Header.Get("Upgrade"), "websocket") {
+			c.responseErr = fmt.Errorf("unrecognized upgrade response: %s", resp.Status)
+		}
+	})
+	return c.responseErr
+}
+
+func (c *clientConn) upgrade() error {
+	c.writeRequest(nil)
+	return c.readResponse()
+}
+
+func (c *clientConn) Write(b []byte) (int, error) {
+	if c.writeRequest(b) {
+		return len(b), nil
+	}
+	if c.requestErr != nil {
+		return 0, c.requestErr
+	}
+	return c.Conn.Write(b)
+}
+
+func (c *clientConn) Read(b []byte) (int, error) {
+	if err := c.upgrade(); err != nil {
+		return 0, err
+	}
+	return c.reader.Read(b)
+}
+
+func init() {
+	if err := internet.RegisterTransportDialer(protocolName, Dial); err != nil {
+		panic(err)
+	}
+}
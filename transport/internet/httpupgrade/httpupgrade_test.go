@@ -0,0 +1,154 @@
+package httpupgrade_test
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pysugar/wheels/net"
+	"github.com/pysugar/wheels/transport/internet"
+	. "github.com/pysugar/wheels/transport/internet/httpupgrade"
+	"github.com/pysugar/wheels/transport/internet/stat"
+)
+
+func listenEcho(t *testing.T, config *Config) net.Destination {
+	t.Helper()
+	listener, err := internet.ListenTCP(context.Background(), net.LocalHostIP, 0, &internet.MemoryStreamConfig{
+		ProtocolName:     "httpupgrade",
+		ProtocolSettings: config,
+	}, func(conn stat.Connection) {
+		go func() {
+			defer conn.Close()
+			io.Copy(conn, conn)
+		}()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	return net.DestinationFromAddr(listener.Addr())
+}
+
+func dial(dest net.Destination, config *Config) (stat.Connection, error) {
+	return internet.Dial(context.Background(), dest, &internet.MemoryStreamConfig{
+		ProtocolName:     "httpupgrade",
+		ProtocolSettings: config,
+	})
+}
+
+func assertEcho(t *testing.T, conn net.Conn, payload string) {
+	t.Helper()
+	if _, err := conn.Write([]byte(payload)); err != nil {
+		t.Fatal(err)
+	}
+	b := make([]byte, len(payload))
+	if _, err := io.ReadFull(conn, b); err != nil {
+		t.Fatal(err)
+	}
+	if r := cmp.Diff(string(b), payload); r != "" {
+		t.Error(r)
+	}
+}
+
+func TestDialAndListen(t *testing.T) {
+	config := &Config{
+		Host:   "example.com",
+		Path:   "up",
+		Header: map[string]string{"X-Token": "wheels"},
+	}
+	dest := listenEcho(t, config)
+
+	conn, err := dial(dest, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	for i := 0; i < 10; i++ {
+		assertEcho(t, conn, "Test connection")
+	}
+}
+
+func TestDialRejected(t *testing.T) {
+	dest := listenEcho(t, &Config{
+		Host:   "example.com",
+		Path:   "/up",
+		Header: map[string]string{"X-Token": "wheels"},
+	})
+
+	testCases := map[string]*Config{
+		"path":   {Host: "example.com", Path: "/other", Header: map[string]string{"X-Token": "wheels"}},
+		"host":   {Host: "example.org", Path: "/up", Header: map[string]string{"X-Token": "wheels"}},
+		"header": {Host: "example.com", Path: "/up", Header: map[string]string{"X-Token": "other"}},
+	}
+	for name, config := range testCases {
+		t.Run(name, func(t *testing.T) {
+			conn, err := dial(dest, config)
+			if err == nil {
+				conn.Close()
+				t.Fatal("expected error, but actually nil")
+			}
+		})
+	}
+}
+
+func TestDialWithEarlyData(t *testing.T) {
+	dest := listenEcho(t, &Config{Path: "/up"})
+
+	conn, err := dial(dest, &Config{Path: "/up", Ed: 2048})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	assertEcho(t, conn, "early data")
+	assertEcho(t, conn, "late data")
+}
+
+func TestListenBehindProxy(t *testing.T) {
+	received := make(chan stat.Connection, 1)
+	listener, err := internet.ListenTCP(context.Background(), net.LocalHostIP, 0, &internet.MemoryStreamConfig{
+		ProtocolName:     "httpupgrade",
+		ProtocolSettings: &Config{Path: "/up"},
+	}, func(conn stat.Connection) {
+		received <- conn
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	request := "GET /up HTTP/1.1\r\nHost: example.com\r\nConnection: keep-alive, Upgrade\r\nUpgrade: websocket\r\nX-Forwarded-For: 10.1.1.1, 10.2.2.2\r\n\r\nhello"
+	if _, err := io.WriteString(conn, request); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatal("unexpected status: ", resp.Status)
+	}
+
+	upgraded := <-received
+	defer upgraded.Close()
+	if !strings.HasPrefix(upgraded.RemoteAddr().String(), "10.1.1.1:") {
+		t.Error("unexpected remote address: ", upgraded.RemoteAddr())
+	}
+	b := make([]byte, 5)
+	if _, err := io.ReadFull(upgraded, b); err != nil {
+		t.Fatal(err)
+	}
+	if r := cmp.Diff(string(b), "hello"); r != "" {
+		t.Error(r)
+	}
+}
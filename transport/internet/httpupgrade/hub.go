@@ -0,0 +1,173 @@
+package httpupgrade
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pysugar/wheels/net"
+	"github.com/pysugar/wheels/transport/internet"
+	"github.com/pysugar/wheels/transport/internet/stat"
+	"google.golang.org/protobuf/proto"
+)
+
+const upgradeResponse = "HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: websocket\r\n\r\n"
+
+// Listener is an internet.Listener that accepts HTTP/1.1 upgraded connections.
+type Listener struct {
+	listener            net.Listener
+	config              *Config
+	acceptProxyProtocol bool
+	addConn             internet.ConnHandler
+}
+
+// ListenHTTPUpgrade creates a new Listener based on configurations. A domain address is taken as the path of a unix domain socket.
+func ListenHTTPUpgrade(ctx context.Context, address net.Address, port net.Port, streamSettings *internet.MemoryStreamConfig, addConn internet.ConnHandler) (internet.Listener, error) {
+	l := &Listener{
+		addConn: addConn,
+	}
+	config, ok := streamSettings.ProtocolSettings.(*Config)
+	if !ok || config == nil {
+		config = &Config{}
+	}
+	l.config = config
+
+	sockopt := streamSettings.SocketSettings
+	if config.AcceptProxyProtocol {
+		if sockopt == nil {
+			sockopt = &internet.SocketConfig{}
+		} else {
+			sockopt = proto.Clone(sockopt).(*internet.SocketConfig)
+		}
+		sockopt.AcceptProxyProtocol = true
+	}
+	l.acceptProxyProtocol = sockopt.GetAcceptProxyProtocol()
+
+	var listener net.Listener
+	var err error
+	if address.Family().IsDomain() {
+		listener, err = internet.ListenSystem(ctx, &net.UnixAddr{
+			Name: address.Domain(),
+			Net:  "unix",
+		}, sockopt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to listen unix domain socket(for HttpUpgrade) on %s, err: %v", address, err)
+		}
+		log.Printf("listening unix domain socket(for HttpUpgrade) on %s", address)
+	} else {
+		listener, err = internet.ListenSystem(ctx, &net.TCPAddr{
+			IP:   address.IP(),
+			Port: int(port),
+		}, sockopt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to listen TCP(for HttpUpgrade) on %s:%d, err: %v", address, port, err)
+		}
+		log.Printf("listening TCP(for HttpUpgrade) on %s:%d", address, port)
+	}
+
+	l.listener = listener
+	go l.keepAccepting()
+	return l, nil
+}
+
+func (l *Listener) keepAccepting() {
+	for {
+		conn, err := l.listener.Accept()
+		if err != nil {
+			errStr := err.Error()
+			if strings.Contains(errStr, "closed") {
+				break
+			}
+			log.Printf("failed to accepted raw connections, err: %v", err)
+			if strings.Contains(errStr, "too many") {
+				time.Sleep(time.Millisecond * 500)
+			}
+			continue
+		}
+
+		go func() {
+			upgraded, err := l.upgrade(conn)
+			if err != nil {
+				log.Printf("failed to serve HttpUpgrade from %s, err: %v", conn.RemoteAddr(), err)
+				conn.Close()
+				return
+			}
+			l.addConn(stat.Connection(upgraded))
+		}()
+	}
+}
+
+func (l *Listener) upgrade(conn net.Conn) (net.Conn, error) {
+	if err := conn.SetReadDeadline(time.Now().Add(time.Second * 4)); err != nil {
+		return nil, err
+	}
+	reader := bufio.NewReader(conn)
+	req, err := http.ReadRequest(reader)
+	if err != nil {
+		return nil, err
+	}
+	if err := conn.SetReadDeadline(time.Time{}); err != nil {
+		return nil, err
+	}
+
+	if len(l.config.Host) > 0 && !internet.IsValidHTTPHost(req.Host, l.config.Host) {
+		writeNotFound(conn)
+		return nil, fmt.Errorf("bad host: %s", req.Host)
+	}
+	if req.URL.Path != l.config.GetNormalizedPath() {
+		writeNotFound(conn)
+		return nil, fmt.Errorf("bad path: %s", req.URL.Path)
+	}
+	if !internet.HasHTTPHeaders(req.Header, l.config.Header) {
+		writeNotFound(conn)
+		return nil, fmt.Errorf("missing required headers")
+	}
+	if !strings.EqualFold(req.Header.Get("Upgrade"), "websocket") || !strings.Contains(strings.ToLower(req.Header.Get("Connection")), "upgrade") {
+		writeNotFound(conn)
+		return nil, fmt.Errorf("unrecognized upgrade request: %s %s", req.Header.Get("Connection"), req.Header.Get("Upgrade"))
+	}
+
+	if _, err := conn.Write([]byte(upgradeResponse)); err != nil {
+		return nil, err
+	}
+
+	remoteAddr := conn.RemoteAddr()
+	if !l.acceptProxyProtocol { // proxy protocol already provides the real address
+		if forwardedAddrs := internet.ParseXForwardedFor(req.Header); len(forwardedAddrs) > 0 && forwardedAddrs[0].Family().IsIP() {
+			remoteAddr = &net.TCPAddr{
+				IP:   forwardedAddrs[0].IP(),
+				Port: 0,
+			}
+		}
+	}
+
+	return &connection{
+		Conn:       conn,
+		reader:     reader,
+		remoteAddr: remoteAddr,
+	}, nil
+}
+
+func writeNotFound(conn net.Conn) {
+	conn.Write([]byte("HTTP/1.1 404 Not Found\r\nContent-Length: 0\r\nConnection: close\r\n\r\n"))
+}
+
+// Addr implements net.Listener.Addr().
+func (l *Listener) Addr() net.Addr {
+	return l.listener.Addr()
+}
+
+// Close implements net.Listener.Close().
+func (l *Listener) Close() error {
+	return l.listener.Close()
+}
+
+func init() {
+	if err := internet.RegisterTransportListener(protocolName, ListenHTTPUpgrade); err != nil {
+		panic(err)
+	}
+}
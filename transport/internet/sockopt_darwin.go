@@ -34,7 +34,8 @@ const (
 )
 
 // OriginalDst uses ioctl to read original destination from /dev/pf
-func OriginalDst(la, ra net.Addr) (net.IP, int, error) {
+func OriginalDst(conn net.Conn) (net.IP, int, error) {
+	la, ra := conn.LocalAddr(), conn.RemoteAddr()
 	f, err := os.Open("/dev/pf")
 	if err != nil {
 		return net.IP{}, -1, fmt.Errorf("failed to open device /dev/pf, err: %v", err)
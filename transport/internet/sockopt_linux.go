@@ -0,0 +1,257 @@
+package internet
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+	"unsafe"
+
+	"github.com/pysugar/wheels/net"
+	"golang.org/x/sys/unix"
+)
+
+// IP6T_SO_ORIGINAL_DST is the ip6tables counterpart of SO_ORIGINAL_DST, missing from x/sys/unix.
+const IP6T_SO_ORIGINAL_DST = 80 // nolint: revive,stylecheck
+
+// OriginalDst reads the original destination of a connection redirected by iptables/nftables,
+// using SO_ORIGINAL_DST for IPv4 and IP6T_SO_ORIGINAL_DST for IPv6 sockets. The error wraps unix.ENOENT if
+// conntrack has no entry of the connection.
+func OriginalDst(conn net.Conn) (net.IP, int, error) {
+	sc, ok := conn.(syscall.Conn)
+	if !ok {
+		return net.IP{}, -1, fmt.Errorf("failed to get raw connection of %T", conn)
+	}
+	rawConn, err := sc.SyscallConn()
+	if err != nil {
+		return net.IP{}, -1, fmt.Errorf("failed to get raw connection, err: %v", err)
+	}
+
+	var ip net.IP
+	var port int
+	var sockErr error
+	err = rawConn.Control(func(fd uintptr) {
+		var addr unix.RawSockaddrInet6
+		size := uint32(unsafe.Sizeof(addr))
+		level, opt := unix.SOL_IP, unix.SO_ORIGINAL_DST
+		if sa, err := unix.Getsockname(int(fd)); err == nil {
+			if _, ok := sa.(*unix.SockaddrInet6); ok {
+				level, opt = unix.SOL_IPV6, IP6T_SO_ORIGINAL_DST
+			}
+		}
+		_, _, errno := unix.Syscall6(unix.SYS_GETSOCKOPT, fd, uintptr(level), uintptr(opt),
+			uintptr(unsafe.Pointer(&addr)), uintptr(unsafe.Pointer(&size)), 0)
+		if errno != 0 {
+			sockErr = os.NewSyscallError("getsockopt", errno)
+			return
+		}
+
+		switch addr.Family {
+		case unix.AF_INET:
+			addr4 := (*unix.RawSockaddrInet4)(unsafe.Pointer(&addr))
+			ip = make(net.IP, net.IPv4len)
+			copy(ip, addr4.Addr[:])
+			port = int(net.PortFromBytes((*[2]byte)(unsafe.Pointer(&addr4.Port))[:]))
+		case unix.AF_INET6:
+			ip = make(net.IP, net.IPv6len)
+			copy(ip, addr.Addr[:])
+			port = int(net.PortFromBytes((*[2]byte)(unsafe.Pointer(&addr.Port))[:]))
+		default:
+			sockErr = fmt.Errorf("unexpected address family: %d", addr.Family)
+		}
+	})
+	if err != nil {
+		return net.IP{}, -1, fmt.Errorf("failed to control raw connection, err: %v", err)
+	}
+	if sockErr != nil {
+		return net.IP{}, -1, fmt.Errorf("failed to read original destination, err: %w", sockErr)
+	}
+	return ip, port, nil
+}
+
+func applyOutboundSocketOptions(network string, address string, fd uintptr, config *SocketConfig) error {
+	if config.Mark != 0 {
+		if err := unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_MARK, int(config.Mark)); err != nil {
+			return fmt.Errorf("failed to set SO_MARK, err: %v", err)
+		}
+	}
+
+	if config.Interface != "" {
+		if err := unix.BindToDevice(int(fd), config.Interface); err != nil {
+			return fmt.Errorf("failed to set Interface, err: %v", err)
+		}
+	}
+
+	if isTCPSocket(network) {
+		tfo := config.ParseTFOValue()
+		if tfo > 0 {
+			tfo = 1
+		}
+		if tfo >= 0 {
+			if err := unix.SetsockoptInt(int(fd), unix.SOL_TCP, unix.TCP_FASTOPEN_CONNECT, tfo); err != nil {
+				return fmt.Errorf("failed to set TCP_FASTOPEN_CONNECT=%d, err: %v", tfo, err)
+			}
+		}
+
+		if err := applyTCPSocketOptions(fd, config); err != nil {
+			return err
+		}
+
+		if config.TcpNoDelay {
+			if err := unix.SetsockoptInt(int(fd), unix.IPPROTO_TCP, unix.TCP_NODELAY, 1); err != nil {
+				return fmt.Errorf("failed to set TCP_NODELAY, err: %v", err)
+			}
+		}
+	}
+
+	if config.Tproxy.IsEnabled() {
+		if err := unix.SetsockoptInt(int(fd), unix.SOL_IP, unix.IP_TRANSPARENT, 1); err != nil {
+			return fmt.Errorf("failed to set IP_TRANSPARENT, err: %v", err)
+		}
+	}
+
+	return nil
+}
+
+func applyInboundSocketOptions(network string, fd uintptr, config *SocketConfig) error {
+	if config.Mark != 0 {
+		if err := unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_MARK, int(config.Mark)); err != nil {
+			return fmt.Errorf("failed to set SO_MARK, err: %v", err)
+		}
+	}
+
+	if config.Interface != "" {
+		if err := unix.BindToDevice(int(fd), config.Interface); err != nil {
+			return fmt.Errorf("failed to set Interface, err: %v", err)
+		}
+	}
+
+	if isTCPSocket(network) {
+		tfo := config.ParseTFOValue()
+		if tfo >= 0 {
+			if err := unix.SetsockoptInt(int(fd), unix.SOL_TCP, unix.TCP_FASTOPEN, tfo); err != nil {
+				return fmt.Errorf("failed to set TCP_FASTOPEN=%d, err: %v", tfo, err)
+			}
+		}
+
+		if err := applyTCPSocketOptions(fd, config); err != nil {
+			return err
+		}
+	}
+
+	if config.Tproxy.IsEnabled() {
+		ip6Err := unix.SetsockoptInt(int(fd), unix.SOL_IPV6, unix.IPV6_TRANSPARENT, 1)
+		ip4Err := unix.SetsockoptInt(int(fd), unix.SOL_IP, unix.IP_TRANSPARENT, 1)
+		if ip6Err != nil && ip4Err != nil {
+			return fmt.Errorf("failed to set IP_TRANSPARENT, err: %v", ip4Err)
+		}
+	}
+
+	if config.ReceiveOriginalDestAddress && isUDPSocket(network) {
+		ip6Err := unix.SetsockoptInt(int(fd), unix.SOL_IPV6, unix.IPV6_RECVORIGDSTADDR, 1)
+		ip4Err := unix.SetsockoptInt(int(fd), unix.SOL_IP, unix.IP_RECVORIGDSTADDR, 1)
+		if ip6Err != nil && ip4Err != nil {
+			return fmt.Errorf("failed to set IP_RECVORIGDSTADDR, err: %v", ip4Err)
+		}
+	}
+
+	if config.V6Only {
+		if err := unix.SetsockoptInt(int(fd), unix.SOL_IPV6, unix.IPV6_V6ONLY, 1); err != nil {
+			return fmt.Errorf("failed to set IPV6_V6ONLY, err: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// applyTCPSocketOptions applies the options shared by inbound and outbound TCP sockets.
+func applyTCPSocketOptions(fd uintptr, config *SocketConfig) error {
+	if config.TcpKeepAliveInterval > 0 || config.TcpKeepAliveIdle > 0 {
+		if config.TcpKeepAliveInterval > 0 {
+			if err := unix.SetsockoptInt(int(fd), unix.IPPROTO_TCP, unix.TCP_KEEPINTVL, int(config.TcpKeepAliveInterval)); err != nil {
+				return fmt.Errorf("failed to set TCP_KEEPINTVL, err: %v", err)
+			}
+		}
+		if config.TcpKeepAliveIdle > 0 {
+			if err := unix.SetsockoptInt(int(fd), unix.IPPROTO_TCP, unix.TCP_KEEPIDLE, int(config.TcpKeepAliveIdle)); err != nil {
+				return fmt.Errorf("failed to set TCP_KEEPIDLE, err: %v", err)
+			}
+		}
+		if err := unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_KEEPALIVE, 1); err != nil {
+			return fmt.Errorf("failed to set SO_KEEPALIVE, err: %v", err)
+		}
+	} else if config.TcpKeepAliveInterval < 0 || config.TcpKeepAliveIdle < 0 {
+		if err := unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_KEEPALIVE, 0); err != nil {
+			return fmt.Errorf("failed to unset SO_KEEPALIVE, err: %v", err)
+		}
+	}
+
+	if config.TcpCongestion != "" {
+		if err := unix.SetsockoptString(int(fd), unix.SOL_TCP, unix.TCP_CONGESTION, config.TcpCongestion); err != nil {
+			return fmt.Errorf("failed to set TCP_CONGESTION, err: %v", err)
+		}
+	}
+
+	if config.TcpWindowClamp > 0 {
+		if err := unix.SetsockoptInt(int(fd), unix.IPPROTO_TCP, unix.TCP_WINDOW_CLAMP, int(config.TcpWindowClamp)); err != nil {
+			return fmt.Errorf("failed to set TCP_WINDOW_CLAMP, err: %v", err)
+		}
+	}
+
+	if config.TcpUserTimeout > 0 {
+		if err := unix.SetsockoptInt(int(fd), unix.IPPROTO_TCP, unix.TCP_USER_TIMEOUT, int(config.TcpUserTimeout)); err != nil {
+			return fmt.Errorf("failed to set TCP_USER_TIMEOUT, err: %v", err)
+		}
+	}
+
+	if config.TcpMaxSeg > 0 {
+		if err := unix.SetsockoptInt(int(fd), unix.IPPROTO_TCP, unix.TCP_MAXSEG, int(config.TcpMaxSeg)); err != nil {
+			return fmt.Errorf("failed to set TCP_MAXSEG, err: %v", err)
+		}
+	}
+
+	return nil
+}
+
+func bindAddr(fd uintptr, address []byte, port uint32) error {
+	if err := setReuseAddr(fd); err != nil {
+		return err
+	}
+	if err := setReusePort(fd); err != nil {
+		return err
+	}
+
+	var sockaddr unix.Sockaddr
+
+	switch len(address) {
+	case net.IPv4len:
+		a4 := &unix.SockaddrInet4{
+			Port: int(port),
+		}
+		copy(a4.Addr[:], address)
+		sockaddr = a4
+	case net.IPv6len:
+		a6 := &unix.SockaddrInet6{
+			Port: int(port),
+		}
+		copy(a6.Addr[:], address)
+		sockaddr = a6
+	default:
+		return fmt.Errorf("unexpected length of ip")
+	}
+
+	return unix.Bind(int(fd), sockaddr)
+}
+
+func setReuseAddr(fd uintptr) error {
+	if err := unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_REUSEADDR, 1); err != nil {
+		return fmt.Errorf("failed to set SO_REUSEADDR, err: %v", err)
+	}
+	return nil
+}
+
+func setReusePort(fd uintptr) error {
+	if err := unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_REUSEPORT, 1); err != nil {
+		return fmt.Errorf("failed to set SO_REUSEPORT, err: %v", err)
+	}
+	return nil
+}
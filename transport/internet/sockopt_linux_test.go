@@ -0,0 +1,186 @@
+package internet_test
+
+import (
+	"context"
+	"errors"
+	network "net"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/pysugar/wheels/net"
+	. "github.com/pysugar/wheels/transport/internet"
+	"golang.org/x/sys/unix"
+)
+
+func getsockoptInt(t *testing.T, conn syscall.Conn, level, opt int) int {
+	t.Helper()
+	rawConn, err := conn.SyscallConn()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var value int
+	var sockErr error
+	if err := rawConn.Control(func(fd uintptr) {
+		value, sockErr = unix.GetsockoptInt(int(fd), level, opt)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if sockErr != nil {
+		t.Fatal(sockErr)
+	}
+	return value
+}
+
+func getsockoptString(t *testing.T, conn syscall.Conn, level, opt int) string {
+	t.Helper()
+	rawConn, err := conn.SyscallConn()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var value string
+	var sockErr error
+	if err := rawConn.Control(func(fd uintptr) {
+		value, sockErr = unix.GetsockoptString(int(fd), level, opt)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if sockErr != nil {
+		t.Fatal(sockErr)
+	}
+	return value
+}
+
+func TestListenSystemTCPSocketOptions(t *testing.T) {
+	sockopt := &SocketConfig{
+		Tfo:                  256,
+		TcpKeepAliveInterval: 15,
+		TcpKeepAliveIdle:     30,
+		TcpCongestion:        "reno",
+		TcpWindowClamp:       8192,
+		TcpUserTimeout:       5000,
+		TcpMaxSeg:            1200,
+	}
+	listener, err := ListenSystem(context.Background(), &net.TCPAddr{IP: net.IP{127, 0, 0, 1}}, sockopt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	conn := listener.(*net.TCPListener)
+
+	intOptions := []struct {
+		Name   string
+		Level  int
+		Opt    int
+		Expect int
+	}{
+		{"TCP_FASTOPEN", unix.SOL_TCP, unix.TCP_FASTOPEN, 256},
+		{"TCP_KEEPINTVL", unix.IPPROTO_TCP, unix.TCP_KEEPINTVL, 15},
+		{"TCP_KEEPIDLE", unix.IPPROTO_TCP, unix.TCP_KEEPIDLE, 30},
+		{"SO_KEEPALIVE", unix.SOL_SOCKET, unix.SO_KEEPALIVE, 1},
+		{"TCP_USER_TIMEOUT", unix.IPPROTO_TCP, unix.TCP_USER_TIMEOUT, 5000},
+		{"TCP_MAXSEG", unix.IPPROTO_TCP, unix.TCP_MAXSEG, 1200},
+		{"SO_REUSEPORT", unix.SOL_SOCKET, unix.SO_REUSEPORT, 1},
+	}
+	for _, tc := range intOptions {
+		if v := getsockoptInt(t, conn, tc.Level, tc.Opt); v != tc.Expect {
+			t.Errorf("%s: expected %d, got %d", tc.Name, tc.Expect, v)
+		}
+	}
+
+	// the kernel reports at least half of the minimum receive buffer as window clamp
+	if v := getsockoptInt(t, conn, unix.IPPROTO_TCP, unix.TCP_WINDOW_CLAMP); v < 4096 || v > 8192 {
+		t.Errorf("TCP_WINDOW_CLAMP: expected about 8192, got %d", v)
+	}
+	if v := getsockoptString(t, conn, unix.SOL_TCP, unix.TCP_CONGESTION); v != "reno" {
+		t.Errorf("TCP_CONGESTION: expected reno, got %s", v)
+	}
+}
+
+func TestListenSystemPrivilegedSocketOptions(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("CAP_NET_ADMIN is required for SO_MARK and IP_TRANSPARENT")
+	}
+
+	sockopt := &SocketConfig{
+		Mark:      255,
+		Tproxy:    SocketConfig_TProxy,
+		Interface: "lo",
+	}
+	listener, err := ListenSystem(context.Background(), &net.TCPAddr{IP: net.IP{127, 0, 0, 1}}, sockopt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	conn := listener.(*net.TCPListener)
+
+	if v := getsockoptInt(t, conn, unix.SOL_SOCKET, unix.SO_MARK); v != 255 {
+		t.Errorf("SO_MARK: expected 255, got %d", v)
+	}
+	if v := getsockoptInt(t, conn, unix.SOL_IP, unix.IP_TRANSPARENT); v != 1 {
+		t.Errorf("IP_TRANSPARENT: expected 1, got %d", v)
+	}
+	if v := getsockoptString(t, conn, unix.SOL_SOCKET, unix.SO_BINDTODEVICE); v != "lo" {
+		t.Errorf("SO_BINDTODEVICE: expected lo, got %s", v)
+	}
+}
+
+func TestListenSystemPacketSocketOptions(t *testing.T) {
+	sockopt := &SocketConfig{
+		ReceiveOriginalDestAddress: true,
+		V6Only:                     true,
+	}
+	conn, err := ListenSystemPacket(context.Background(), &net.UDPAddr{IP: net.ParseIP("::1")}, sockopt)
+	if err != nil {
+		t.Skipf("IPv6 loopback is not available: %v", err)
+	}
+	defer conn.Close()
+	udpConn := conn.(*net.UDPConn)
+
+	if v := getsockoptInt(t, udpConn, unix.SOL_IPV6, unix.IPV6_V6ONLY); v != 1 {
+		t.Errorf("IPV6_V6ONLY: expected 1, got %d", v)
+	}
+	if v := getsockoptInt(t, udpConn, unix.SOL_IPV6, unix.IPV6_RECVORIGDSTADDR); v != 1 {
+		t.Errorf("IPV6_RECVORIGDSTADDR: expected 1, got %d", v)
+	}
+}
+
+func TestOriginalDst(t *testing.T) {
+	pipe, _ := network.Pipe()
+	defer pipe.Close()
+	if _, _, err := OriginalDst(pipe); err == nil {
+		t.Error("expected an error for a connection without socket")
+	}
+
+	listener, err := ListenSystem(context.Background(), &net.TCPAddr{IP: net.IP{127, 0, 0, 1}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	go func() {
+		conn, err := net.Dial("tcp", listener.Addr().String())
+		if err == nil {
+			conn.Close()
+		}
+	}()
+
+	conn, err := listener.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// without a redirect rule the conntrack entry reports the listener address itself
+	ip, port, err := OriginalDst(conn)
+	if errors.Is(err, unix.ENOENT) {
+		t.Skipf("no conntrack entry of the connection, nf_conntrack is not loaded: %v", err)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().(*net.TCPAddr)
+	if !ip.Equal(addr.IP) || port != addr.Port {
+		t.Errorf("expected %s, got %s:%d", addr, ip, port)
+	}
+}
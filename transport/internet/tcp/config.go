@@ -0,0 +1,20 @@
+package tcp
+
+import (
+	"github.com/pysugar/wheels/transport/internet"
+)
+
+const (
+	protocolName     = "tcp"
+	unixProtocolName = "domainsocket"
+)
+
+func init() {
+	for _, name := range []string{protocolName, unixProtocolName} {
+		if err := internet.RegisterProtocolConfigCreator(name, func() interface{} {
+			return new(Config)
+		}); err != nil {
+			panic(err)
+		}
+	}
+}
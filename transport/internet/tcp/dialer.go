@@ -0,0 +1,28 @@
+package tcp
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pysugar/wheels/net"
+	"github.com/pysugar/wheels/transport/internet"
+	"github.com/pysugar/wheels/transport/internet/stat"
+)
+
+// Dial dials a new TCP connection, or a unix domain socket one for unix destinations, to the given destination.
+func Dial(ctx context.Context, dest net.Destination, streamSettings *internet.MemoryStreamConfig) (stat.Connection, error) {
+	conn, err := internet.DialSystem(ctx, dest, streamSettings.SocketSettings)
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial to %s, err: %v", dest, err)
+	}
+	return stat.Connection(conn), nil
+}
+
+func init() {
+	if err := internet.RegisterTransportDialer(protocolName, Dial); err != nil {
+		panic(err)
+	}
+	if err := internet.RegisterTransportDialer(unixProtocolName, Dial); err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,117 @@
+package tcp
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/pysugar/wheels/net"
+	"github.com/pysugar/wheels/transport/internet"
+	"github.com/pysugar/wheels/transport/internet/stat"
+	"google.golang.org/protobuf/proto"
+)
+
+// Listener is an internet.Listener that accepts raw TCP or unix domain socket connections.
+type Listener struct {
+	listener net.Listener
+	config   *Config
+	addConn  internet.ConnHandler
+}
+
+// ListenTCP creates a new Listener based on configurations. A domain address is taken as the path of a unix domain socket.
+func ListenTCP(ctx context.Context, address net.Address, port net.Port, streamSettings *internet.MemoryStreamConfig, handler internet.ConnHandler) (internet.Listener, error) {
+	if address.Family().IsDomain() {
+		return ListenUnix(ctx, address, port, streamSettings, handler)
+	}
+
+	l, sockopt := newListener(streamSettings, handler)
+	listener, err := internet.ListenSystem(ctx, &net.TCPAddr{
+		IP:   address.IP(),
+		Port: int(port),
+	}, sockopt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen TCP on %s:%d, err: %v", address, port, err)
+	}
+	log.Printf("listening TCP on %s:%d", address, port)
+
+	l.listener = listener
+	go l.keepAccepting()
+	return l, nil
+}
+
+// ListenUnix creates a new Listener on the unix domain socket named by address, the port is ignored.
+func ListenUnix(ctx context.Context, address net.Address, _ net.Port, streamSettings *internet.MemoryStreamConfig, handler internet.ConnHandler) (internet.Listener, error) {
+	l, sockopt := newListener(streamSettings, handler)
+	listener, err := internet.ListenSystem(ctx, &net.UnixAddr{
+		Name: address.Domain(),
+		Net:  "unix",
+	}, sockopt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen Unix Domain Socket on %s, err: %v", address, err)
+	}
+	log.Printf("listening Unix Domain Socket on %s", address)
+
+	l.listener = listener
+	go l.keepAccepting()
+	return l, nil
+}
+
+func newListener(streamSettings *internet.MemoryStreamConfig, handler internet.ConnHandler) (*Listener, *internet.SocketConfig) {
+	l := &Listener{
+		addConn: handler,
+	}
+	if config, ok := streamSettings.ProtocolSettings.(*Config); ok {
+		l.config = config
+	}
+
+	sockopt := streamSettings.SocketSettings
+	if l.config.GetAcceptProxyProtocol() {
+		if sockopt == nil {
+			sockopt = &internet.SocketConfig{}
+		} else {
+			sockopt = proto.Clone(sockopt).(*internet.SocketConfig)
+		}
+		sockopt.AcceptProxyProtocol = true
+	}
+	return l, sockopt
+}
+
+func (v *Listener) keepAccepting() {
+	for {
+		conn, err := v.listener.Accept()
+		if err != nil {
+			errStr := err.Error()
+			if strings.Contains(errStr, "closed") {
+				break
+			}
+			log.Printf("failed to accepted raw connections, err: %v", err)
+			if strings.Contains(errStr, "too many") {
+				time.Sleep(time.Millisecond * 500)
+			}
+			continue
+		}
+
+		v.addConn(stat.Connection(conn))
+	}
+}
+
+// Addr implements internet.Listener.Addr.
+func (v *Listener) Addr() net.Addr {
+	return v.listener.Addr()
+}
+
+// Close implements internet.Listener.Close.
+func (v *Listener) Close() error {
+	return v.listener.Close()
+}
+
+func init() {
+	if err := internet.RegisterTransportListener(protocolName, ListenTCP); err != nil {
+		panic(err)
+	}
+	if err := internet.RegisterTransportListener(unixProtocolName, ListenUnix); err != nil {
+		panic(err)
+	}
+}
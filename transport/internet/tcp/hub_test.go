@@ -0,0 +1,146 @@
+package tcp_test
+
+import (
+	"context"
+	"io"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pires/go-proxyproto"
+	"github.com/pysugar/wheels/net"
+	"github.com/pysugar/wheels/transport/internet"
+	"github.com/pysugar/wheels/transport/internet/stat"
+	. "github.com/pysugar/wheels/transport/internet/tcp"
+)
+
+func acceptOne(t *testing.T, conns <-chan stat.Connection) stat.Connection {
+	t.Helper()
+	select {
+	case conn := <-conns:
+		return conn
+	case <-time.After(time.Second * 5):
+		t.Fatal("timeout waiting for connection")
+		return nil
+	}
+}
+
+func TestListenTCP(t *testing.T) {
+	conns := make(chan stat.Connection, 1)
+	listener, err := internet.ListenTCP(context.Background(), net.LocalHostIP, 0, nil, func(conn stat.Connection) {
+		conns <- conn
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	client, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	conn := acceptOne(t, conns)
+	defer conn.Close()
+	if r := cmp.Diff(conn.RemoteAddr().String(), client.LocalAddr().String()); r != "" {
+		t.Error(r)
+	}
+
+	if _, err := client.Write([]byte("ping")); err != nil {
+		t.Fatal(err)
+	}
+	b := make([]byte, 4)
+	if _, err := io.ReadFull(conn, b); err != nil {
+		t.Fatal(err)
+	}
+	if r := cmp.Diff(string(b), "ping"); r != "" {
+		t.Error(r)
+	}
+}
+
+func TestListenTCPAcceptProxyProtocol(t *testing.T) {
+	conns := make(chan stat.Connection, 1)
+	settings := &internet.MemoryStreamConfig{
+		ProtocolName:     "tcp",
+		ProtocolSettings: &Config{AcceptProxyProtocol: true},
+	}
+	listener, err := internet.ListenTCP(context.Background(), net.LocalHostIP, 0, settings, func(conn stat.Connection) {
+		conns <- conn
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	if settings.SocketSettings != nil {
+		t.Error("stream settings should not be modified")
+	}
+
+	client, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	header := &proxyproto.Header{
+		Version:           2,
+		Command:           proxyproto.PROXY,
+		TransportProtocol: proxyproto.TCPv4,
+		SourceAddr:        &net.TCPAddr{IP: net.ParseIP("10.1.1.1"), Port: 1000},
+		DestinationAddr:   &net.TCPAddr{IP: net.ParseIP("20.2.2.2"), Port: 2000},
+	}
+	if _, err := header.WriteTo(client); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Write([]byte("ping")); err != nil {
+		t.Fatal(err)
+	}
+
+	conn := acceptOne(t, conns)
+	defer conn.Close()
+	b := make([]byte, 4)
+	if _, err := io.ReadFull(conn, b); err != nil {
+		t.Fatal(err)
+	}
+	if r := cmp.Diff(string(b), "ping"); r != "" {
+		t.Error(r)
+	}
+	if r := cmp.Diff(conn.RemoteAddr().String(), "10.1.1.1:1000"); r != "" {
+		t.Error(r)
+	}
+}
+
+func TestListenUnix(t *testing.T) {
+	conns := make(chan stat.Connection, 1)
+	path := filepath.Join(t.TempDir(), "test.sock")
+	settings := &internet.MemoryStreamConfig{
+		ProtocolName:     "domainsocket",
+		ProtocolSettings: &Config{},
+	}
+	listener, err := internet.ListenUnix(context.Background(), net.DomainAddress(path), settings, func(conn stat.Connection) {
+		conns <- conn
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	client, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	if _, err := client.Write([]byte("ping")); err != nil {
+		t.Fatal(err)
+	}
+
+	conn := acceptOne(t, conns)
+	defer conn.Close()
+	b := make([]byte, 4)
+	if _, err := io.ReadFull(conn, b); err != nil {
+		t.Fatal(err)
+	}
+	if r := cmp.Diff(string(b), "ping"); r != "" {
+		t.Error(r)
+	}
+}
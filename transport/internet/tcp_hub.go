@@ -44,7 +44,7 @@ func ListenUnix(ctx context.Context, address net.Address, settings *MemoryStream
 	}
 	listener, err := listenFunc(ctx, address, net.Port(0), settings, handler)
 	if err != nil {
-		return nil, fmt.Errorf("failed to listen on unix address: %s", address)
+		return nil, fmt.Errorf("failed to listen on unix address: %s, err: %v", address, err)
 	}
 	return listener, nil
 }
@@ -73,7 +73,7 @@ func ListenTCP(ctx context.Context, address net.Address, port net.Port, settings
 	}
 	listener, err := listenFunc(ctx, address, port, settings, handler)
 	if err != nil {
-		return nil, fmt.Errorf("failed to listen on address: %s:%d", address, port)
+		return nil, fmt.Errorf("failed to listen on address: %s:%d, err: %v", address, port, err)
 	}
 	return listener, nil
 }
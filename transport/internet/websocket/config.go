@@ -0,0 +1,39 @@
+package websocket
+
+import (
+	"net/http"
+
+	"github.com/pysugar/wheels/transport/internet"
+)
+
+const protocolName = "websocket"
+
+func (c *Config) GetNormalizedPath() string {
+	path := c.GetPath()
+	if path == "" {
+		return "/"
+	}
+	if path[0] != '/' {
+		return "/" + path
+	}
+	return path
+}
+
+func (c *Config) GetRequestHeader() http.Header {
+	header := http.Header{}
+	for k, v := range c.GetHeader() {
+		header.Add(k, v)
+	}
+	if host := c.GetHost(); host != "" {
+		header.Set("Host", host)
+	}
+	return header
+}
+
+func init() {
+	if err := internet.RegisterProtocolConfigCreator(protocolName, func() interface{} {
+		return new(Config)
+	}); err != nil {
+		panic(err)
+	}
+}
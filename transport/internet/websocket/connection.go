@@ -0,0 +1,132 @@
+package websocket
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+var _ net.Conn = (*connection)(nil)
+
+// connection is a wrapper for net.Conn over WebSocket connection.
+type connection struct {
+	conn       *websocket.Conn
+	reader     io.Reader
+	remoteAddr net.Addr
+	done       chan struct{}
+	closeOnce  sync.Once
+}
+
+func newConnection(conn *websocket.Conn, remoteAddr net.Addr, extraReader io.Reader, heartbeatPeriod uint32) *connection {
+	c := &connection{
+		conn:       conn,
+		remoteAddr: remoteAddr,
+		reader:     extraReader,
+		done:       make(chan struct{}),
+	}
+	if heartbeatPeriod > 0 {
+		go c.keepAlive(time.Duration(heartbeatPeriod) * time.Second)
+	}
+	return c
+}
+
+func (c *connection) keepAlive(period time.Duration) {
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+			if err := c.conn.WriteControl(websocket.PingMessage, []byte{}, time.Now().Add(period)); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// Read implements net.Conn.Read()
+func (c *connection) Read(b []byte) (int, error) {
+	for {
+		reader, err := c.getReader()
+		if err != nil {
+			return 0, err
+		}
+
+		nBytes, err := reader.Read(b)
+		if err == io.EOF {
+			c.reader = nil
+			if nBytes == 0 {
+				continue
+			}
+			err = nil
+		}
+		return nBytes, err
+	}
+}
+
+func (c *connection) getReader() (io.Reader, error) {
+	if c.reader != nil {
+		return c.reader, nil
+	}
+
+	_, reader, err := c.conn.NextReader()
+	if err != nil {
+		return nil, err
+	}
+	c.reader = reader
+	return reader, nil
+}
+
+// Write implements io.Writer.
+func (c *connection) Write(b []byte) (int, error) {
+	if err := c.conn.WriteMessage(websocket.BinaryMessage, b); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+func (c *connection) Close() error {
+	var err error
+	c.closeOnce.Do(func() {
+		close(c.done)
+		var errs []error
+		if err := c.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second*5)); err != nil {
+			errs = append(errs, err)
+		}
+		if err := c.conn.Close(); err != nil {
+			errs = append(errs, err)
+		}
+		if len(errs) > 0 {
+			err = fmt.Errorf("failed to close connection, err: %v", errs)
+		}
+	})
+	return err
+}
+
+func (c *connection) LocalAddr() net.Addr {
+	return c.conn.LocalAddr()
+}
+
+func (c *connection) RemoteAddr() net.Addr {
+	return c.remoteAddr
+}
+
+func (c *connection) SetDeadline(t time.Time) error {
+	if err := c.SetReadDeadline(t); err != nil {
+		return err
+	}
+	return c.SetWriteDeadline(t)
+}
+
+func (c *connection) SetReadDeadline(t time.Time) error {
+	return c.conn.SetReadDeadline(t)
+}
+
+func (c *connection) SetWriteDeadline(t time.Time) error {
+	return c.conn.SetWriteDeadline(t)
+}
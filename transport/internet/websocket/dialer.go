@@ -0,0 +1,184 @@
+package websocket
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/pysugar/wheels/net"
+	"github.com/pysugar/wheels/transport/internet"
+	"github.com/pysugar/wheels/transport/internet/stat"
+)
+
+// Dial dials a WebSocket connection to the given destination.
+func Dial(ctx context.Context, dest net.Destination, streamSettings *internet.MemoryStreamConfig) (stat.Connection, error) {
+	config, ok := streamSettings.ProtocolSettings.(*Config)
+	if !ok || config == nil {
+		config = &Config{}
+	}
+
+	if config.Ed > 0 {
+		ctx, cancel := context.WithCancel(ctx)
+		return &delayDialConn{
+			ctx:            ctx,
+			cancel:         cancel,
+			dest:           dest,
+			config:         config,
+			streamSettings: streamSettings,
+			dialed:         make(chan struct{}),
+		}, nil
+	}
+
+	conn, err := dialWebSocket(ctx, dest, config, streamSettings, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial WebSocket, err: %v", err)
+	}
+	return stat.Connection(conn), nil
+}
+
+func dialWebSocket(ctx context.Context, dest net.Destination, config *Config, streamSettings *internet.MemoryStreamConfig, ed []byte) (net.Conn, error) {
+	dialer := &websocket.Dialer{
+		NetDial: func(network, addr string) (net.Conn, error) {
+			return internet.DialSystem(ctx, dest, streamSettings.SocketSettings)
+		},
+		ReadBufferSize:   4 * 1024,
+		WriteBufferSize:  4 * 1024,
+		HandshakeTimeout: time.Second * 8,
+	}
+
+	host := dest.NetAddr()
+	if dest.Network == net.Network_UNIX {
+		host = "localhost"
+	} else if dest.Port == 80 {
+		host = dest.Address.String()
+	}
+	uri := "ws://" + host + config.GetNormalizedPath()
+
+	header := config.GetRequestHeader()
+	if ed != nil {
+		// RawURLEncoding is supported by both V2Ray/V2Fly and Xray.
+		header.Set("Sec-WebSocket-Protocol", base64.RawURLEncoding.EncodeToString(ed))
+	}
+
+	conn, resp, err := dialer.DialContext(ctx, uri, header)
+	if err != nil {
+		var reason string
+		if resp != nil {
+			reason = resp.Status
+		}
+		return nil, fmt.Errorf("failed to dial to (%s): %s, err: %v", uri, reason, err)
+	}
+
+	return newConnection(conn, conn.RemoteAddr(), nil, config.HeartbeatPeriod), nil
+}
+
+// delayDialConn postpones the WebSocket handshake until the first Write, so the written
+// bytes can be sent as early data along with the handshake.
+type delayDialConn struct {
+	ctx            context.Context
+	cancel         context.CancelFunc
+	dest           net.Destination
+	config         *Config
+	streamSettings *internet.MemoryStreamConfig
+
+	dialOnce sync.Once
+	dialed   chan struct{}
+	conn     net.Conn
+	err      error
+}
+
+func (d *delayDialConn) Write(b []byte) (int, error) {
+	select {
+	case <-d.dialed:
+	default:
+		ed := b
+		if len(ed) > int(d.config.Ed) {
+			ed = nil
+		}
+		sentAsEarlyData := false
+		d.dialOnce.Do(func() {
+			d.conn, d.err = dialWebSocket(d.ctx, d.dest, d.config, d.streamSettings, ed)
+			sentAsEarlyData = d.err == nil && ed != nil
+			close(d.dialed)
+		})
+		if sentAsEarlyData {
+			return len(b), nil
+		}
+	}
+
+	if d.err != nil {
+		return 0, d.err
+	}
+	return d.conn.Write(b)
+}
+
+func (d *delayDialConn) Read(b []byte) (int, error) {
+	select {
+	case <-d.dialed:
+	case <-d.ctx.Done():
+		return 0, io.ErrUnexpectedEOF
+	}
+
+	if d.err != nil {
+		return 0, d.err
+	}
+	return d.conn.Read(b)
+}
+
+func (d *delayDialConn) Close() error {
+	d.cancel()
+	d.dialOnce.Do(func() {
+		d.err = io.ErrClosedPipe
+		close(d.dialed)
+	})
+	if d.conn == nil {
+		return nil
+	}
+	return d.conn.Close()
+}
+
+func (d *delayDialConn) LocalAddr() net.Addr {
+	if d.conn == nil {
+		return &net.TCPAddr{}
+	}
+	return d.conn.LocalAddr()
+}
+
+func (d *delayDialConn) RemoteAddr() net.Addr {
+	if d.conn == nil {
+		return d.dest.RawNetAddr()
+	}
+	return d.conn.RemoteAddr()
+}
+
+// SetDeadline has no effect until the connection is dialed, so is SetReadDeadline and SetWriteDeadline.
+func (d *delayDialConn) SetDeadline(t time.Time) error {
+	if d.conn == nil {
+		return nil
+	}
+	return d.conn.SetDeadline(t)
+}
+
+func (d *delayDialConn) SetReadDeadline(t time.Time) error {
+	if d.conn == nil {
+		return nil
+	}
+	return d.conn.SetReadDeadline(t)
+}
+
+func (d *delayDialConn) SetWriteDeadline(t time.Time) error {
+	if d.conn == nil {
+		return nil
+	}
+	return d.conn.SetWriteDeadline(t)
+}
+
+func init() {
+	if err := internet.RegisterTransportDialer(protocolName, Dial); err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,169 @@
+package websocket
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/pysugar/wheels/net"
+	"github.com/pysugar/wheels/transport/internet"
+	"github.com/pysugar/wheels/transport/internet/stat"
+	"google.golang.org/protobuf/proto"
+)
+
+type requestHandler struct {
+	config *Config
+	ln     *Listener
+}
+
+// early data is encoded with RawURLEncoding, but some clients use StdEncoding
+var replacer = strings.NewReplacer("+", "-", "/", "_", "=", "")
+
+var upgrader = &websocket.Upgrader{
+	ReadBufferSize:   0,
+	WriteBufferSize:  0,
+	HandshakeTimeout: time.Second * 4,
+	CheckOrigin: func(r *http.Request) bool {
+		return true
+	},
+}
+
+func (h *requestHandler) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	if len(h.config.Host) > 0 && !internet.IsValidHTTPHost(request.Host, h.config.Host) {
+		log.Printf("failed to validate host, request: %s, config: %s", request.Host, h.config.Host)
+		writer.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if request.URL.Path != h.config.GetNormalizedPath() {
+		log.Printf("failed to validate path, request: %s, config: %s", request.URL.Path, h.config.GetNormalizedPath())
+		writer.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if !internet.HasHTTPHeaders(request.Header, h.config.Header) {
+		log.Printf("failed to validate headers of request from %s", request.RemoteAddr)
+		writer.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	var extraReader io.Reader
+	responseHeader := http.Header{}
+	if str := request.Header.Get("Sec-WebSocket-Protocol"); str != "" {
+		if ed, err := base64.RawURLEncoding.DecodeString(replacer.Replace(str)); err == nil && len(ed) > 0 {
+			extraReader = bytes.NewReader(ed)
+			responseHeader.Set("Sec-WebSocket-Protocol", str)
+		}
+	}
+
+	conn, err := upgrader.Upgrade(writer, request, responseHeader)
+	if err != nil {
+		log.Printf("failed to convert to WebSocket connection, err: %v", err)
+		return
+	}
+
+	remoteAddr := conn.RemoteAddr()
+	if !h.ln.acceptProxyProtocol { // proxy protocol already provides the real address
+		if forwardedAddrs := internet.ParseXForwardedFor(request.Header); len(forwardedAddrs) > 0 && forwardedAddrs[0].Family().IsIP() {
+			remoteAddr = &net.TCPAddr{
+				IP:   forwardedAddrs[0].IP(),
+				Port: 0,
+			}
+		}
+	}
+
+	h.ln.addConn(stat.Connection(newConnection(conn, remoteAddr, extraReader, h.config.HeartbeatPeriod)))
+}
+
+// Listener is an internet.Listener that accepts WebSocket connections.
+type Listener struct {
+	server              http.Server
+	listener            net.Listener
+	config              *Config
+	acceptProxyProtocol bool
+	addConn             internet.ConnHandler
+}
+
+// ListenWS creates a new Listener based on configurations. A domain address is taken as the path of a unix domain socket.
+func ListenWS(ctx context.Context, address net.Address, port net.Port, streamSettings *internet.MemoryStreamConfig, addConn internet.ConnHandler) (internet.Listener, error) {
+	l := &Listener{
+		addConn: addConn,
+	}
+	config, ok := streamSettings.ProtocolSettings.(*Config)
+	if !ok || config == nil {
+		config = &Config{}
+	}
+	l.config = config
+
+	sockopt := streamSettings.SocketSettings
+	if config.AcceptProxyProtocol {
+		if sockopt == nil {
+			sockopt = &internet.SocketConfig{}
+		} else {
+			sockopt = proto.Clone(sockopt).(*internet.SocketConfig)
+		}
+		sockopt.AcceptProxyProtocol = true
+	}
+	l.acceptProxyProtocol = sockopt.GetAcceptProxyProtocol()
+
+	var listener net.Listener
+	var err error
+	if address.Family().IsDomain() {
+		listener, err = internet.ListenSystem(ctx, &net.UnixAddr{
+			Name: address.Domain(),
+			Net:  "unix",
+		}, sockopt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to listen unix domain socket(for WS) on %s, err: %v", address, err)
+		}
+		log.Printf("listening unix domain socket(for WS) on %s", address)
+	} else {
+		listener, err = internet.ListenSystem(ctx, &net.TCPAddr{
+			IP:   address.IP(),
+			Port: int(port),
+		}, sockopt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to listen TCP(for WS) on %s:%d, err: %v", address, port, err)
+		}
+		log.Printf("listening TCP(for WS) on %s:%d", address, port)
+	}
+
+	l.listener = listener
+	l.server = http.Server{
+		Handler: &requestHandler{
+			config: config,
+			ln:     l,
+		},
+		ReadHeaderTimeout: time.Second * 4,
+		MaxHeaderBytes:    8192,
+	}
+
+	go func() {
+		if err := l.server.Serve(l.listener); err != nil && err != http.ErrServerClosed {
+			log.Printf("failed to serve http for WebSocket, err: %v", err)
+		}
+	}()
+
+	return l, nil
+}
+
+// Addr implements net.Listener.Addr().
+func (ln *Listener) Addr() net.Addr {
+	return ln.listener.Addr()
+}
+
+// Close implements net.Listener.Close().
+func (ln *Listener) Close() error {
+	return ln.server.Close()
+}
+
+func init() {
+	if err := internet.RegisterTransportListener(protocolName, ListenWS); err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,142 @@
+package websocket_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/gorilla/websocket"
+	"github.com/pysugar/wheels/net"
+	"github.com/pysugar/wheels/transport/internet"
+	"github.com/pysugar/wheels/transport/internet/stat"
+	. "github.com/pysugar/wheels/transport/internet/websocket"
+)
+
+func listenEcho(t *testing.T, config *Config) net.Destination {
+	t.Helper()
+	listener, err := internet.ListenTCP(context.Background(), net.LocalHostIP, 0, &internet.MemoryStreamConfig{
+		ProtocolName:     "websocket",
+		ProtocolSettings: config,
+	}, func(conn stat.Connection) {
+		go func() {
+			defer conn.Close()
+			io.Copy(conn, conn)
+		}()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	return net.DestinationFromAddr(listener.Addr())
+}
+
+func dial(dest net.Destination, config *Config) (stat.Connection, error) {
+	return internet.Dial(context.Background(), dest, &internet.MemoryStreamConfig{
+		ProtocolName:     "websocket",
+		ProtocolSettings: config,
+	})
+}
+
+func assertEcho(t *testing.T, conn net.Conn, payload string) {
+	t.Helper()
+	if _, err := conn.Write([]byte(payload)); err != nil {
+		t.Fatal(err)
+	}
+	b := make([]byte, len(payload))
+	if _, err := io.ReadFull(conn, b); err != nil {
+		t.Fatal(err)
+	}
+	if r := cmp.Diff(string(b), payload); r != "" {
+		t.Error(r)
+	}
+}
+
+func TestDialAndListen(t *testing.T) {
+	config := &Config{
+		Host:   "example.com",
+		Path:   "ws",
+		Header: map[string]string{"X-Token": "wheels"},
+	}
+	dest := listenEcho(t, config)
+
+	conn, err := dial(dest, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	for i := 0; i < 10; i++ {
+		assertEcho(t, conn, "Test connection")
+	}
+}
+
+func TestDialRejected(t *testing.T) {
+	dest := listenEcho(t, &Config{
+		Host:   "example.com",
+		Path:   "/ws",
+		Header: map[string]string{"X-Token": "wheels"},
+	})
+
+	testCases := map[string]*Config{
+		"path":   {Host: "example.com", Path: "/other", Header: map[string]string{"X-Token": "wheels"}},
+		"host":   {Host: "example.org", Path: "/ws", Header: map[string]string{"X-Token": "wheels"}},
+		"header": {Host: "example.com", Path: "/ws", Header: map[string]string{"X-Token": "other"}},
+	}
+	for name, config := range testCases {
+		t.Run(name, func(t *testing.T) {
+			conn, err := dial(dest, config)
+			if err == nil {
+				conn.Close()
+				t.Fatal("expected error, but actually nil")
+			}
+		})
+	}
+}
+
+func TestDialWithEarlyData(t *testing.T) {
+	dest := listenEcho(t, &Config{Path: "/ws"})
+
+	conn, err := dial(dest, &Config{Path: "/ws", Ed: 2048})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	assertEcho(t, conn, "early data")
+	assertEcho(t, conn, "late data")
+}
+
+func TestHeartbeat(t *testing.T) {
+	dest := listenEcho(t, &Config{Path: "/ws", HeartbeatPeriod: 1})
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws://"+dest.NetAddr()+"/ws", http.Header{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	pinged := make(chan struct{}, 1)
+	conn.SetPingHandler(func(string) error {
+		select {
+		case pinged <- struct{}{}:
+		default:
+		}
+		return nil
+	})
+	go func() {
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	select {
+	case <-pinged:
+	case <-time.After(time.Second * 3):
+		t.Fatal("no ping received")
+	}
+}